@@ -0,0 +1,71 @@
+"use client"
+
+import { Suspense, useEffect, useState } from "react"
+import { useRouter, useSearchParams } from "next/navigation"
+import { useAuthStore } from "@/lib/auth"
+import { TwoFactorForm } from "@/components/auth/two-factor-form"
+import { toast } from "sonner"
+
+const ERROR_MESSAGES: Record<string, string> = {
+  access_denied: "Login cancelado",
+  invalid_state: "Login expirado, tente novamente",
+  suspended: "Conta suspensa",
+  login_failed: "Erro ao fazer login",
+}
+
+interface Challenge {
+  token: string
+  step: "verify" | "setup"
+}
+
+// The API redirects here after a provider login, with the session cookies set, ?error=
+// or a #challenge_token= when the account needs a second factor
+function OAuthCallback() {
+  const router = useRouter()
+  const searchParams = useSearchParams()
+  const { loadSession } = useAuthStore()
+  const [challenge, setChallenge] = useState<Challenge | null>(null)
+
+  useEffect(() => {
+    const error = searchParams.get("error")
+    if (error) {
+      toast.error(ERROR_MESSAGES[error] ?? ERROR_MESSAGES.login_failed)
+      router.replace("/")
+      return
+    }
+
+    const fragment = new URLSearchParams(window.location.hash.slice(1))
+    const challengeToken = fragment.get("challenge_token")
+    if (challengeToken) {
+      setChallenge({ token: challengeToken, step: fragment.get("two_factor") === "setup" ? "setup" : "verify" })
+      return
+    }
+
+    loadSession()
+      .then(() => {
+        toast.success("Login realizado com sucesso!")
+        router.replace("/dashboard")
+      })
+      .catch((err) => {
+        console.error("Auth error:", err)
+        toast.error(ERROR_MESSAGES.login_failed)
+        router.replace("/")
+      })
+  }, [searchParams, loadSession, router])
+
+  if (challenge) {
+    return <TwoFactorForm challengeToken={challenge.token} step={challenge.step} onDone={() => router.replace("/dashboard")} />
+  }
+
+  return <p className="text-muted-foreground">Carregando...</p>
+}
+
+export default function OAuthCallbackPage() {
+  return (
+    <div className="min-h-screen flex items-center justify-center">
+      <Suspense fallback={null}>
+        <OAuthCallback />
+      </Suspense>
+    </div>
+  )
+}
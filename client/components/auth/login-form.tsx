@@ -2,13 +2,16 @@
 
 import type React from "react"
 
-import { useState } from "react"
+import { useEffect, useState } from "react"
 import { useRouter } from "next/navigation"
 import { Button } from "@/components/ui/button"
 import { Input } from "@/components/ui/input"
 import { Label } from "@/components/ui/label"
 import { Card, CardContent, CardDescription, CardHeader, CardTitle } from "@/components/ui/card"
 import { useAuthStore } from "@/lib/auth"
+import { apiClient } from "@/lib/api"
+import type { AuthResponse } from "@/lib/types"
+import { TwoFactorForm } from "@/components/auth/two-factor-form"
 import { toast } from "sonner"
 
 interface LoginFormProps {
@@ -20,9 +23,18 @@ export function LoginForm({ onToggleMode, isRegister }: LoginFormProps) {
   const [name, setname] = useState("")
   const [password, setPassword] = useState("")
   const [isLoading, setIsLoading] = useState(false)
+  const [providers, setProviders] = useState<string[]>([])
+  const [challenge, setChallenge] = useState<AuthResponse | null>(null)
   const { login, register } = useAuthStore()
   const router = useRouter()
 
+  useEffect(() => {
+    apiClient
+      .getOAuthProviders()
+      .then((resp) => setProviders(resp.providers))
+      .catch((error) => console.error("OAuth providers error:", error))
+  }, [])
+
   const handleSubmit = async (e: React.FormEvent) => {
     e.preventDefault()
     if (!name || !password) {
@@ -36,7 +48,11 @@ export function LoginForm({ onToggleMode, isRegister }: LoginFormProps) {
         await register(name, password)
         toast.success("Conta criada com sucesso!")
       } else {
-        await login(name, password)
+        const pending = await login(name, password)
+        if (pending) {
+          setChallenge(pending)
+          return
+        }
         toast.success("Login realizado com sucesso!")
       }
       router.push("/dashboard")
@@ -48,6 +64,16 @@ export function LoginForm({ onToggleMode, isRegister }: LoginFormProps) {
     }
   }
 
+  if (challenge?.challenge_token) {
+    return (
+      <TwoFactorForm
+        challengeToken={challenge.challenge_token}
+        step={challenge.two_factor ?? "verify"}
+        onDone={() => router.push("/dashboard")}
+      />
+    )
+  }
+
   return (
     <Card className="w-full max-w-md">
       <CardHeader className="text-center">
@@ -84,6 +110,15 @@ export function LoginForm({ onToggleMode, isRegister }: LoginFormProps) {
             {isLoading ? "Carregando..." : isRegister ? "Criar Conta" : "Entrar"}
           </Button>
         </form>
+        {providers.length > 0 && (
+          <div className="mt-4 space-y-2">
+            {providers.map((provider) => (
+              <Button key={provider} variant="outline" className="w-full capitalize" disabled={isLoading} asChild>
+                <a href={apiClient.oauthLoginUrl(provider)}>Entrar com {provider}</a>
+              </Button>
+            ))}
+          </div>
+        )}
         <div className="mt-4 text-center">
           <Button variant="link" onClick={onToggleMode} disabled={isLoading} className="text-sm">
             {isRegister ? "Já tem uma conta? Faça login" : "Não tem conta? Registre-se"}
@@ -0,0 +1,126 @@
+"use client"
+
+import type React from "react"
+
+import { useEffect, useState } from "react"
+import { Button } from "@/components/ui/button"
+import { Input } from "@/components/ui/input"
+import { Label } from "@/components/ui/label"
+import { Card, CardContent, CardDescription, CardHeader, CardTitle } from "@/components/ui/card"
+import { useAuthStore } from "@/lib/auth"
+import { apiClient } from "@/lib/api"
+import type { TwoFactorSetup } from "@/lib/types"
+import { toast } from "sonner"
+
+interface TwoFactorFormProps {
+  challengeToken: string
+  step: "verify" | "setup"
+  onDone: () => void
+}
+
+// Second step of a login, after the password or the provider
+export function TwoFactorForm({ challengeToken, step, onDone }: TwoFactorFormProps) {
+  const [code, setCode] = useState("")
+  const [setup, setSetup] = useState<TwoFactorSetup | null>(null)
+  const [recoveryCodes, setRecoveryCodes] = useState<string[]>([])
+  const [isLoading, setIsLoading] = useState(false)
+  const { loadSession } = useAuthStore()
+
+  useEffect(() => {
+    if (step !== "setup") return
+    apiClient
+      .setupTwoFactor(challengeToken)
+      .then(setSetup)
+      .catch((error) => {
+        console.error("2FA setup error:", error)
+        toast.error("Login expirado, tente novamente")
+      })
+  }, [challengeToken, step])
+
+  const handleSubmit = async (e: React.FormEvent) => {
+    e.preventDefault()
+    if (!code) {
+      toast.error("Por favor, informe o código")
+      return
+    }
+
+    setIsLoading(true)
+    try {
+      const response = await apiClient.verifyTwoFactor(challengeToken, code)
+      await loadSession()
+      toast.success("Login realizado com sucesso!")
+      if (response.recovery_codes?.length) {
+        setRecoveryCodes(response.recovery_codes)
+      } else {
+        onDone()
+      }
+    } catch (error) {
+      toast.error("Código inválido")
+      console.error("2FA error:", error)
+    } finally {
+      setIsLoading(false)
+    }
+  }
+
+  if (recoveryCodes.length > 0) {
+    return (
+      <Card className="w-full max-w-md">
+        <CardHeader className="text-center">
+          <CardTitle className="text-2xl font-bold">Códigos de recuperação</CardTitle>
+          <CardDescription>Guarde estes códigos, cada um serve uma vez caso perca o autenticador</CardDescription>
+        </CardHeader>
+        <CardContent className="space-y-4">
+          <ul className="grid grid-cols-2 gap-2 font-mono text-sm">
+            {recoveryCodes.map((recoveryCode) => (
+              <li key={recoveryCode}>{recoveryCode}</li>
+            ))}
+          </ul>
+          <Button className="w-full" onClick={onDone}>
+            Continuar
+          </Button>
+        </CardContent>
+      </Card>
+    )
+  }
+
+  return (
+    <Card className="w-full max-w-md">
+      <CardHeader className="text-center">
+        <CardTitle className="text-2xl font-bold">Verificação em duas etapas</CardTitle>
+        <CardDescription>
+          {step === "setup"
+            ? "Sua conta exige verificação em duas etapas, adicione a chave ao seu autenticador"
+            : "Digite o código do seu autenticador ou um código de recuperação"}
+        </CardDescription>
+      </CardHeader>
+      <CardContent>
+        {setup && (
+          <div className="mb-4 space-y-2 text-sm">
+            <p>
+              Chave: <span className="font-mono break-all">{setup.secret}</span>
+            </p>
+            <p className="text-muted-foreground break-all">{setup.provisioning_uri}</p>
+          </div>
+        )}
+        <form onSubmit={handleSubmit} className="space-y-4">
+          <div className="space-y-2">
+            <Label htmlFor="code">Código</Label>
+            <Input
+              id="code"
+              type="text"
+              inputMode="numeric"
+              autoComplete="one-time-code"
+              value={code}
+              onChange={(e) => setCode(e.target.value)}
+              placeholder="000000"
+              disabled={isLoading}
+            />
+          </div>
+          <Button type="submit" className="w-full" disabled={isLoading}>
+            {isLoading ? "Carregando..." : "Verificar"}
+          </Button>
+        </form>
+      </CardContent>
+    </Card>
+  )
+}
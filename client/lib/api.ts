@@ -1,23 +1,34 @@
-import type { Championship, Match, AuthResponse, BroadcastResponse } from "./types"
+import type { Championship, Match, AuthResponse, BroadcastResponse, Profile, TwoFactorSetup } from "./types"
 import { toast } from "sonner"
 
 const API_BASE_URL = process.env.SERVER_URL || "http://localhost:4000/api/v1"
 
+const SAFE_METHODS = ["GET", "HEAD", "OPTIONS"]
+
+// The session token lives in an HttpOnly cookie set by the API, state-changing
+// requests echo the csrf_token cookie in the X-CSRF-Token header
+function getCsrfToken() {
+  const match = document.cookie.match(/(?:^|;\s*)csrf_token=([^;]*)/)
+  return match ? decodeURIComponent(match[1]) : null
+}
+
 class ApiClient {
-  private getAuthHeaders() {
-    const token = localStorage.getItem("auth_token")
-    return token ? { Authorization: `Bearer ${token}` } : {}
+  private getCsrfHeaders(method = "GET") {
+    if (SAFE_METHODS.includes(method.toUpperCase())) return {}
+    const csrfToken = getCsrfToken()
+    return csrfToken ? { "X-CSRF-Token": csrfToken } : {}
   }
 
   async request<T>(endpoint: string, options: RequestInit = {}): Promise<T> {
     const url = `${API_BASE_URL}${endpoint}`
     const config: RequestInit = {
+      ...options,
+      credentials: "include",
       headers: {
         "Content-Type": "application/json",
-        ...(this.getAuthHeaders() as Record<string, string>),
+        ...(this.getCsrfHeaders(options.method) as Record<string, string>),
         ...(options.headers as Record<string, string>),
       } as Record<string, string>,
-      ...options,
     }
 
     console.log("API Request:", url, config)
@@ -33,7 +44,7 @@ class ApiClient {
 
         if (endpoint.endsWith('/fans') && response.status === 429) {
           const resp = await response.json()
-          toast.warning(resp.message)
+          toast.warning(resp.detail ?? resp.message)
         }
 
       throw error
@@ -51,12 +62,39 @@ class ApiClient {
   }
 
   async login(name: string, password: string) {
-    return this.request<AuthResponse>("/auth/login", {
+    return this.request<AuthResponse>("/auth/login?mode=cookie", {
       method: "POST",
       body: JSON.stringify({ name, password }),
     })
   }
 
+  async setupTwoFactor(challengeToken: string) {
+    return this.request<TwoFactorSetup>("/auth/2fa/setup", {
+      method: "POST",
+      body: JSON.stringify({ challenge_token: challengeToken }),
+    })
+  }
+
+  async verifyTwoFactor(challengeToken: string, code: string) {
+    return this.request<AuthResponse>("/auth/2fa/verify", {
+      method: "POST",
+      body: JSON.stringify({ challenge_token: challengeToken, code }),
+    })
+  }
+
+  async getOAuthProviders() {
+    return this.request<{ providers: string[] }>("/auth/oauth/providers")
+  }
+
+  // Opened by the browser, the API redirects to the provider and back to /auth/callback
+  oauthLoginUrl(provider: string) {
+    return `${API_BASE_URL}/auth/oauth/${encodeURIComponent(provider)}?mode=cookie`
+  }
+
+  async getMe() {
+    return this.request<Profile>("/me")
+  }
+
   async logout() {
     return this.request<{ message: string }>("/auth/logout", {
       method: "POST",
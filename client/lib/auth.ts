@@ -1,15 +1,16 @@
 import { create } from "zustand"
 import { persist } from "zustand/middleware"
-import type { User } from "./types"
+import type { AuthResponse, User } from "./types"
 import { apiClient } from "./api"
 
 interface AuthState {
   user: User | null
-  token: string | null
   isAuthenticated: boolean
   isInitialized: boolean
-  login: (name: string, password:string) => Promise<void>
+  // resolves with the challenge when a second factor is needed
+  login: (name: string, password:string) => Promise<AuthResponse | null>
   register: (name: string, password: string) => Promise<void>
+  loadSession: () => Promise<void>
   logout: () => Promise<void>
   setUser: (user: User) => void
   initializeAuth: () => void
@@ -19,13 +20,12 @@ export const useAuthStore = create<AuthState>()(
   persist(
     (set, get) => ({
       user: null,
-      token: null,
       isAuthenticated: false,
       isInitialized: false,
 
       initializeAuth: () => {
-        const { token, user } = get()
-        if (token && user) {
+        const { user } = get()
+        if (user) {
           set({ isAuthenticated: true })
         }
         set({ isInitialized: true })
@@ -33,8 +33,11 @@ export const useAuthStore = create<AuthState>()(
 
       login: async (name: string, password: string) => {
         try {
+          // the API keeps the session in an HttpOnly cookie
           const response = await apiClient.login(name, password)
-          localStorage.setItem("auth_token", response.token)
+          if (response.challenge_token) {
+            return response
+          }
 
           // Mock user data - in real app, you'd get this from token or separate endpoint
           // TODO: use real user data
@@ -46,14 +49,23 @@ export const useAuthStore = create<AuthState>()(
 
           set({
             user,
-            token: response.token,
             isAuthenticated: true,
           })
+          return null
         } catch (error) {
           throw error
         }
       },
 
+      // After a provider login the session cookie is already set, only the user is missing
+      loadSession: async () => {
+        const profile = await apiClient.getMe()
+        set({
+          user: { id: profile.id, name: profile.name, isAdmin: profile.role === "admin" },
+          isAuthenticated: true,
+        })
+      },
+
       register: async (name: string, password: string) => {
         try {
           await apiClient.register(name, password)
@@ -71,10 +83,8 @@ export const useAuthStore = create<AuthState>()(
           // Continue with logout even if API call fails
           console.error("Logout API error:", error)
         } finally {
-          localStorage.removeItem("auth_token")
           set({
             user: null,
-            token: null,
             isAuthenticated: false,
           })
         }
@@ -88,7 +98,6 @@ export const useAuthStore = create<AuthState>()(
       name: "auth-storage",
       partialize: (state) => ({
         user: state.user,
-        token: state.token,
       }),
     },
   ),
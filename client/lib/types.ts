@@ -4,6 +4,14 @@ export interface User {
   isAdmin?: boolean
 }
 
+export interface Profile {
+  id: number
+  name: string
+  role: string
+  display_name?: string
+  email?: string
+}
+
 export interface Championship {
   id: number
   name: string
@@ -60,7 +68,19 @@ export interface Match {
 }
 
 export interface AuthResponse {
-  token: string
+  // bearer mode only, cookie sessions get csrf_token instead
+  token?: string
+  csrf_token?: string
+  // set instead of a session when a second factor is needed
+  challenge_token?: string
+  two_factor?: "verify" | "setup"
+  // shown once, when 2FA was set up during the login
+  recovery_codes?: string[]
+}
+
+export interface TwoFactorSetup {
+  secret: string
+  provisioning_uri: string
 }
 
 export interface BroadcastResponse {
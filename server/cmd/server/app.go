@@ -0,0 +1,152 @@
+package main
+
+import (
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	data "github.com/tsntt/footballapi/data/postgres"
+	"github.com/tsntt/footballapi/internal/config"
+	"github.com/tsntt/footballapi/internal/controller"
+	"github.com/tsntt/footballapi/internal/model"
+	"github.com/tsntt/footballapi/pkg/broadcast"
+	consumer "github.com/tsntt/footballapi/pkg/external_api_consumer"
+	"github.com/tsntt/footballapi/pkg/metrics"
+	"github.com/tsntt/footballapi/pkg/passwordpolicy"
+	"github.com/tsntt/footballapi/pkg/services/email"
+	"github.com/tsntt/footballapi/pkg/services/sms"
+	"github.com/tsntt/footballapi/pkg/utils"
+)
+
+// app holds the repositories, services and controllers shared by the HTTP server and the
+// command line, so both go through the same rules
+type app struct {
+	db      *sqlx.DB
+	metrics *metrics.Metrics
+
+	userRepo       model.IUserRepository
+	identityRepo   model.IUserIdentityRepository
+	oauthStateRepo model.IOAuthStateRepository
+	fanRepo        model.IFanRepository
+	broadcastRepo  model.IBroadcastRepository
+	passwordPolicy *passwordpolicy.Policy
+	jwtService     *utils.JWTService
+	// nil with HS256, the keys are loaded by serve, the command line never signs a token
+	keyRing          *utils.JWTKeyRing
+	footballAPI      *consumer.FootballAPIClient
+	emailService     *email.MailgunService
+	smsService       *sms.TwilioService
+	broadcastService *broadcast.BroadcastService
+
+	userController          *controller.UserController
+	championshipController  *controller.ChampionshipController
+	fanController           *controller.FanController
+	roleController          *controller.RoleController
+	deliveryController      *controller.DeliveryController
+	adminUserController     *controller.AdminUserController
+	apiKeyController        *controller.APIKeyController
+	twoFactorController     *controller.TwoFactorController
+	passwordResetController *controller.PasswordResetController
+	adminController         *controller.AdminController
+}
+
+func newApp(cfg *config.Config, db *sqlx.DB) (*app, error) {
+	a := &app{db: db}
+
+	a.metrics = metrics.New()
+	a.metrics.RegisterDB(db.DB, cfg.Database.Name)
+
+	// init repositories
+	a.userRepo = data.NewUserRepository(db)
+	roleRepo := data.NewRoleRepository(db)
+	auditRepo := data.NewAuditRepository(db)
+	passwordResetRepo := data.NewPasswordResetRepository(db)
+	loginAttemptRepo := data.NewLoginAttemptRepository(db)
+	a.fanRepo = data.NewFanRepository(db)
+	a.broadcastRepo = data.NewBroadcastRepository(db)
+	deliveryRepo := data.NewDeliveryRepository(db)
+	verificationRepo := data.NewChannelVerificationRepository(db)
+	a.identityRepo = data.NewUserIdentityRepository(db)
+	a.oauthStateRepo = data.NewOAuthStateRepository(db)
+	apiKeyRepo := data.NewAPIKeyRepository(db)
+	twoFactorRepo := data.NewTwoFactorRepository(db)
+	mfaChallengeRepo := data.NewMFAChallengeRepository(db)
+
+	// init services
+	var breachedPasswords passwordpolicy.IBreachedChecker
+	if cfg.Password.BreachedDatasetDir != "" {
+		breachedPasswords = passwordpolicy.NewHashPrefixDataset(cfg.Password.BreachedDatasetDir)
+	}
+	a.passwordPolicy = passwordpolicy.New(passwordpolicy.Options{
+		MinLength:     cfg.Password.MinLength,
+		RequireUpper:  cfg.Password.RequireUpper,
+		RequireLower:  cfg.Password.RequireLower,
+		RequireDigit:  cfg.Password.RequireDigit,
+		RequireSymbol: cfg.Password.RequireSymbol,
+		Breached:      breachedPasswords,
+	})
+
+	if cfg.JWT.Algorithm == utils.AlgHS256 {
+		a.jwtService = utils.NewJWTService(cfg.JWT.Secret, cfg.JWT.ExpiresHours)
+	} else {
+		keyRing, err := utils.NewJWTKeyRing(
+			data.NewSigningKeyRepository(db),
+			cfg.JWT.Algorithm,
+			time.Duration(cfg.JWT.RotationHours)*time.Hour,
+			time.Duration(cfg.JWT.ExpiresHours)*time.Hour,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		a.keyRing = keyRing
+		a.jwtService = utils.NewKeyRingJWTService(keyRing, cfg.JWT.ExpiresHours)
+	}
+	a.jwtService.SetIssuer(cfg.JWT.Issuer, cfg.JWT.Audience)
+	// tokens signed with JWT_SECRET before the upgrade to iss, aud and rotated keys, never with a published secret
+	if !config.InsecureSecret(cfg.JWT.Secret) {
+		a.jwtService.AcceptLegacyTokens(cfg.JWT.Secret)
+	}
+	unsubscribeTokens := utils.NewUnsubscribeTokenService(cfg.Unsubscribe.Secret, cfg.Unsubscribe.ExpiresHours, cfg.Server.PublicURL)
+	a.footballAPI = consumer.NewFootballAPIClient(cfg.FootballAPI.URL, cfg.FootballAPI.Token)
+	a.emailService = email.NewMailgunService(cfg.EmailAPI.Domain, cfg.EmailAPI.APIKey, cfg.EmailAPI.From, cfg.EmailAPI.WebhookSigningKey)
+	a.smsService = sms.NewTwilioService(cfg.SMSAPI.AccountSID, cfg.SMSAPI.AuthToken, cfg.SMSAPI.From, cfg.SMSAPI.StatusCallbackURL, cfg.SMSAPI.InboundURL)
+	a.broadcastService = broadcast.NewBroadcastService()
+
+	a.footballAPI.SetMetrics(a.metrics)
+	a.broadcastService.SetMetrics(a.metrics)
+
+	a.emailService.SetUnsubscribeLinker(unsubscribeTokens)
+
+	a.broadcastService.RegisterNotifier(broadcast.Email, a.emailService)
+	a.broadcastService.RegisterNotifier(broadcast.SMS, a.smsService)
+
+	// init controllers
+	a.userController = controller.NewUserController(a.userRepo, roleRepo, loginAttemptRepo, a.passwordPolicy, a.jwtService)
+	a.championshipController = controller.NewChampionshipController(a.footballAPI)
+	a.fanController = controller.NewFanController(a.fanRepo, verificationRepo, a.broadcastService, unsubscribeTokens)
+	a.roleController = controller.NewRoleController(roleRepo, a.userRepo, auditRepo)
+	a.deliveryController = controller.NewDeliveryController(deliveryRepo, a.broadcastRepo, a.fanRepo, a.smsService, a.emailService, data.NewWebhookTokenRepository(db))
+	a.adminUserController = controller.NewAdminUserController(a.userRepo, a.fanRepo, deliveryRepo, auditRepo, loginAttemptRepo)
+	a.apiKeyController = controller.NewAPIKeyController(apiKeyRepo, a.userRepo, roleRepo)
+	a.twoFactorController = controller.NewTwoFactorController(
+		twoFactorRepo,
+		mfaChallengeRepo,
+		a.userRepo,
+		roleRepo,
+		a.userController,
+		utils.NewSecretBox(cfg.TwoFactor.EncryptionKey),
+		cfg.TwoFactor.Issuer,
+	)
+	a.userController.SetTwoFactor(a.twoFactorController)
+	a.passwordResetController = controller.NewPasswordResetController(a.userRepo, passwordResetRepo, verificationRepo, a.passwordPolicy, a.broadcastService, cfg.Server.AppURL)
+	a.broadcastService.SetDeliveryRecorder(a.deliveryController)
+
+	a.adminController = controller.NewAdminController(
+		a.footballAPI,
+		a.fanRepo,
+		a.broadcastRepo,
+		a.broadcastService,
+	)
+
+	return a, nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"slices"
+	"text/tabwriter"
+)
+
+const broadcastUsage = "usage: server broadcast <send|status> --match <id>"
+
+// runBroadcast implements `server broadcast`, send goes through the same checks as the admin
+// endpoint, a match is only broadcast once
+func runBroadcast(ctx context.Context, a *app, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(broadcastUsage)
+	}
+
+	flags := flag.NewFlagSet("broadcast "+args[0], flag.ContinueOnError)
+	matchID := flags.Int("match", 0, "football-data.org match id")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+	if *matchID <= 0 {
+		return errors.New(broadcastUsage)
+	}
+
+	switch args[0] {
+	case "send":
+		resp, err := a.adminController.BroadcastMatch(ctx, *matchID)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(out, resp.Message)
+
+		// exiting now would drop the notifications still being sent
+		done := make(chan struct{})
+		go func() {
+			a.adminController.Wait()
+			close(done)
+		}()
+
+		select {
+		case <-done:
+			fmt.Fprintf(out, "done, see `server broadcast status --match %d`\n", *matchID)
+			return nil
+		case <-ctx.Done():
+			return fmt.Errorf("interrupted before every fan was notified, see `server broadcast status --match %d`", *matchID)
+		}
+	case "status":
+		report, err := a.deliveryController.GetMatchDeliveries(ctx, *matchID)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(out, "broadcast %d of match %d, %d deliveries\n\n", report.BroadcastID, report.MatchID, len(report.Deliveries))
+
+		statuses := make([]string, 0, len(report.Summary))
+		for status := range report.Summary {
+			statuses = append(statuses, status)
+		}
+		slices.Sort(statuses)
+
+		w := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+		fmt.Fprintln(w, "STATUS\tCOUNT")
+		for _, status := range statuses {
+			fmt.Fprintf(w, "%s\t%d\n", status, report.Summary[status])
+		}
+		return w.Flush()
+	default:
+		return errors.New(broadcastUsage)
+	}
+}
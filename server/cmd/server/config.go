@@ -0,0 +1,36 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/tsntt/footballapi/internal/config"
+)
+
+const configUsage = "usage: server config print [--redacted]"
+
+// runConfig implements `server config print`, the output can be used as a --config file.
+// Problems are reported after the print so an invalid configuration can still be inspected
+func runConfig(cfg *config.Config, args []string, out, errOut io.Writer) error {
+	if len(args) == 0 || args[0] != "print" {
+		return errors.New(configUsage)
+	}
+
+	flags := flag.NewFlagSet("config print", flag.ContinueOnError)
+	redacted := flags.Bool("redacted", false, "replace the secrets that are set")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	if err := cfg.Print(out, *redacted); err != nil {
+		return err
+	}
+
+	for _, warning := range cfg.Warnings {
+		fmt.Fprintln(errOut, "warning:", warning)
+	}
+
+	return cfg.Validate()
+}
@@ -2,31 +2,137 @@ package main
 
 import (
 	"context"
-	"log"
+	"errors"
+	"flag"
+	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
 	"time"
+	// profile timezones are validated with time.LoadLocation, the runtime image has no zoneinfo
+	_ "time/tzdata"
 
+	"github.com/jmoiron/sqlx"
 	"github.com/labstack/echo/v4"
+	"github.com/tsntt/footballapi/data/migrations"
 	data "github.com/tsntt/footballapi/data/postgres"
 	"github.com/tsntt/footballapi/internal/api/handler"
 	"github.com/tsntt/footballapi/internal/api/middleware"
+	"github.com/tsntt/footballapi/internal/api/openapi"
 	"github.com/tsntt/footballapi/internal/config"
 	"github.com/tsntt/footballapi/internal/controller"
-	"github.com/tsntt/footballapi/pkg/broadcast"
-	consumer "github.com/tsntt/footballapi/pkg/external_api_consumer"
-	"github.com/tsntt/footballapi/pkg/services/email"
-	"github.com/tsntt/footballapi/pkg/services/sms"
-	"github.com/tsntt/footballapi/pkg/utils"
+	"github.com/tsntt/footballapi/internal/model"
+	"github.com/tsntt/footballapi/pkg/health"
+	"github.com/tsntt/footballapi/pkg/logging"
+	"github.com/tsntt/footballapi/pkg/ratelimit"
+	"github.com/tsntt/footballapi/pkg/services/oauth"
+	"github.com/tsntt/footballapi/pkg/tracing"
 
 	echomiddleware "github.com/labstack/echo/v4/middleware"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/labstack/echo/otelecho"
 )
 
+const usage = `usage: server [--config <file>] [--set <key>=<value>]... [command]
+
+commands:
+  serve                                        start the API (default)
+  migrate <up|down|status|redo>                apply or roll back the embedded migrations
+  user create --name <name> [--admin]          create a user, the password is read from stdin
+  user set-role --name <name> --role <role>    replace the role of a user
+  broadcast send --match <id>                  notify the fans of both teams and wait for the sends
+  broadcast status --match <id>                delivery summary of the broadcast of a match
+  seed                                         create the first admin from SEED_ADMIN_NAME and SEED_ADMIN_PASSWORD
+  config print [--redacted]                    show the effective configuration
+
+settings come from the defaults, then the --config file (or CONFIG_FILE), the environment and --set
+`
+
+// overrides collects the repeated --set flags
+type overrides []string
+
+func (o *overrides) String() string { return strings.Join(*o, " ") }
+
+func (o *overrides) Set(value string) error {
+	*o = append(*o, value)
+	return nil
+}
+
 func main() {
+	flags := flag.NewFlagSet("server", flag.ContinueOnError)
+	flags.Usage = func() { fmt.Fprint(flags.Output(), usage) }
+	configFile := flags.String("config", "", "YAML or TOML config file")
+	var sets overrides
+	flags.Var(&sets, "set", "key=value, applied over the file and the environment")
+	if err := flags.Parse(os.Args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		os.Exit(2)
+	}
+
+	command, args := "serve", flags.Args()
+	if len(args) > 0 {
+		command, args = args[0], args[1:]
+	}
+
+	switch command {
+	case "serve", "migrate", "user", "broadcast", "seed", "config":
+	case "help":
+		fmt.Print(usage)
+		return
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
 	// load config
-	cfg := config.Load()
+	cfg, err := config.Load(config.Options{File: *configFile, Overrides: sets})
+	if err != nil {
+		fatal("Invalid configuration", err)
+	}
+
+	if command == "config" {
+		if err := runConfig(cfg, args, os.Stdout, os.Stderr); err != nil {
+			fatal("Invalid configuration", err)
+		}
+		return
+	}
+
+	if err := cfg.Validate(); err != nil {
+		fatal("Invalid configuration", err)
+	}
+
+	// the other commands write their results to stdout
+	logOutput := os.Stdout
+	if command != "serve" {
+		logOutput = os.Stderr
+	}
+
+	logger, err := logging.New(logOutput, cfg.Log.Format, cfg.Log.Level)
+	if err != nil {
+		slog.Error("Invalid log configuration", slog.String("err", err.Error()))
+		os.Exit(1)
+	}
+	slog.SetDefault(logger)
+
+	for _, warning := range cfg.Warnings {
+		slog.Warn("Check the configuration", slog.String("warning", warning))
+	}
+
+	// cancelled on interrupt or SIGTERM (sent by Docker and Kubernetes), stops the background jobs and the server
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// installed before anything opens a connection or a client, so they all pick up the provider
+	shutdownTracing, err := tracing.Setup(ctx, cfg.Tracing.Exporter, cfg.Tracing.ServiceName)
+	if err != nil {
+		fatal("Invalid tracing configuration", err)
+	}
 
 	// Connect to database
 	db, err := data.NewDB(
@@ -38,83 +144,295 @@ func main() {
 		cfg.Database.Port,
 	)
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		fatal("Failed to connect to database", err)
 	}
 	defer db.Close()
 
-	// init repositories
-	userRepo := data.NewUserRepository(db)
-	fanRepo := data.NewFanRepository(db)
-	broadcastRepo := data.NewBroadcastRepository(db)
-
-	// init services
-	jwtService := utils.NewJWTService(cfg.JWT.Secret, cfg.JWT.ExpiresHours)
-	footballAPI := consumer.NewFootballAPIClient(cfg.FootballAPI.URL, cfg.FootballAPI.Token)
-	emailService := email.NewMailgunService(cfg.Server.Host, cfg.EmailAPI.APIKey, cfg.EmailAPI.From)
-	smsService := sms.NewTwilioService(cfg.SMSAPI.AccountSID, cfg.SMSAPI.APIKey, cfg.SMSAPI.From, "")
-	broadcastService := broadcast.NewBroadcastService()
-
-	broadcastService.RegisterNotifier(broadcast.Email, emailService)
-	broadcastService.RegisterNotifier(broadcast.SMS, smsService)
-
-	// init controllers
-	userController := controller.NewUserController(userRepo, jwtService)
-	championshipController := controller.NewChampionshipController(footballAPI)
-	fanController := controller.NewFanController(fanRepo)
-	adminController := controller.NewAdminController(
-		footballAPI,
-		fanRepo,
-		broadcastRepo,
-		broadcastService,
-	)
+	migrationProvider, err := data.NewMigrationProvider(db)
+	if err != nil {
+		fatal("Failed to load migrations", err)
+	}
+
+	if command == "migrate" {
+		if err := runMigrate(ctx, migrationProvider, args, os.Stdout); err != nil {
+			fatal("Migration failed", err)
+		}
+		return
+	}
+
+	if cfg.Database.AutoMigrate {
+		results, err := migrationProvider.Up(ctx)
+		if err != nil {
+			fatal("Failed to apply migrations", err)
+		}
+		for _, result := range results {
+			slog.Info("Applied migration", slog.String("migration", filepath.Base(result.Source.Path)), slog.Duration("duration", result.Duration))
+		}
+	}
+
+	a, err := newApp(cfg, db)
+	if err != nil {
+		fatal("Invalid configuration", err)
+	}
+
+	switch command {
+	case "serve":
+		err = serve(ctx, cfg, a)
+	case "user":
+		err = runUser(ctx, a, args, os.Stdin, os.Stdout)
+	case "broadcast":
+		err = runBroadcast(ctx, a, args, os.Stdout)
+	case "seed":
+		err = runSeed(ctx, a, cfg.Seed, os.Stdout)
+	}
+	if err != nil {
+		fatal("Command failed", err)
+	}
+
+	// the batcher still holds the last spans
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		slog.Error("Failed to flush traces", slog.String("err", err.Error()))
+	}
+}
+
+// serve runs the API until the first interrupt or SIGTERM
+func serve(ctx context.Context, cfg *config.Config, a *app) error {
+	if a.keyRing != nil {
+		if err := a.keyRing.Load(ctx); err != nil {
+			return fmt.Errorf("failed to load JWT signing keys: %w", err)
+		}
+		// picks up keys rotated by other instances and rotates when due
+		go a.keyRing.Run(ctx, time.Minute)
+	}
+
+	oauthController := controller.NewOAuthController(oauthProviders(ctx, cfg), a.oauthStateRepo, a.identityRepo, a.userRepo, a.userController)
+
+	sessionCookies := middleware.NewSessionCookies(middleware.SessionCookieConfig{
+		Secure:   cfg.Session.CookieSecure,
+		SameSite: middleware.ParseSameSite(cfg.Session.CookieSameSite),
+		Domain:   cfg.Session.CookieDomain,
+		MaxAge:   time.Duration(cfg.JWT.ExpiresHours) * time.Hour,
+	})
+
+	// readiness, the instance can serve without the upstream API or a notifier but not without its database
+	healthChecker := health.NewChecker(2 * time.Second)
+	healthChecker.Register(health.Component{Name: "database", Check: databaseCheck(a.db)})
+	// every call costs a request of the upstream quota
+	healthChecker.Register(health.Component{Name: "football_api", Check: a.footballAPI.Ping, Optional: true, CacheFor: time.Minute})
+	healthChecker.Register(health.Component{Name: "email", Check: a.emailService.CheckConfig, Optional: true})
+	healthChecker.Register(health.Component{Name: "sms", Check: a.smsService.CheckConfig, Optional: true})
+
+	// the memory store counts per instance, replicas behind a load balancer share Redis instead
+	var rateLimitStore ratelimit.Store
+	if cfg.RateLimit.Enabled {
+		switch cfg.RateLimit.Store {
+		case "redis":
+			redisStore, err := ratelimit.NewRedisStore(cfg.RateLimit.RedisURL)
+			if err != nil {
+				return fmt.Errorf("failed to set up rate limiting: %w", err)
+			}
+			defer redisStore.Close()
+			// requests are let through while Redis is down
+			healthChecker.Register(health.Component{Name: "rate_limit", Check: redisStore.Ping, Optional: true})
+			rateLimitStore = redisStore
+		default:
+			rateLimitStore = ratelimit.NewMemoryStore()
+		}
+	}
 
 	// init handlers
 	handlers := handler.NewHandlers(
-		userController,
-		championshipController,
-		fanController,
-		adminController,
+		a.userController,
+		a.championshipController,
+		a.fanController,
+		a.adminController,
+		a.deliveryController,
+		a.roleController,
+		a.adminUserController,
+		a.passwordResetController,
+		oauthController,
+		a.apiKeyController,
+		a.twoFactorController,
+		a.jwtService,
+		healthChecker,
+		sessionCookies,
+		cfg.Server.AppURL,
+		cfg.Server.AllowedOrigins,
 	)
 
 	// init middlewares
-	authMiddleware := middleware.NewAuthMiddleware(jwtService)
+	authMiddleware := middleware.NewAuthMiddleware(a.jwtService, a.userController, a.apiKeyController, a.roleController)
+	rateLimiter := middleware.NewRateLimiter(rateLimitStore, middleware.RateLimits{
+		Auth:      ratelimit.Limit{Requests: cfg.RateLimit.AuthRequests, Window: cfg.RateLimit.AuthWindow},
+		User:      ratelimit.Limit{Requests: cfg.RateLimit.UserRequests, Window: cfg.RateLimit.UserWindow},
+		IP:        ratelimit.Limit{Requests: cfg.RateLimit.IPRequests, Window: cfg.RateLimit.IPWindow},
+		Broadcast: ratelimit.Limit{Requests: cfg.RateLimit.BroadcastRequests, Window: cfg.RateLimit.BroadcastWindow},
+	})
+	requestValidator, err := openapi.NewValidator(openapi.Operations)
+	if err != nil {
+		return fmt.Errorf("failed to build the request validator: %w", err)
+	}
 
 	// Configure Echo
 	e := echo.New()
+	// everything is logged through slog, see middleware.RequestLogger
+	e.HideBanner = true
+	e.HidePort = true
+	// X-Forwarded-For is only honoured when set by a proxy on a private network, a client
+	// must not be able to pick its own IP (login throttling is per IP)
+	e.IPExtractor = echo.ExtractIPFromXFFHeader()
+	// every error is answered as application/problem+json
+	e.HTTPErrorHandler = handler.HTTPErrorHandler
 
 	// Middlewares globais
-	e.Use(echomiddleware.Logger())
-	e.Use(echomiddleware.Recover())
-	e.Use(echomiddleware.CORS())
-
-	// Configure rotas
-	handler.SetupRoutes(e, handlers, authMiddleware)
-
-	// Health check
-	e.GET("/health", func(c echo.Context) error {
-		return c.JSON(200, map[string]string{"status": "ok"})
-	})
+	e.Use(otelecho.Middleware(cfg.Tracing.ServiceName))
+	e.Use(a.metrics.Middleware())
+	e.Use(middleware.RequestLogger())
+	e.Use(echomiddleware.RecoverWithConfig(echomiddleware.RecoverConfig{
+		LogErrorFunc: func(c echo.Context, err error, stack []byte) error {
+			middleware.Logger(c).Error("Recovered from panic", slog.String("err", err.Error()), slog.String("stack", string(stack)))
+			return err
+		},
+	}))
+	// Credentials (the session cookie) are only accepted from the listed origins
+	e.Use(echomiddleware.CORSWithConfig(echomiddleware.CORSConfig{
+		AllowOrigins: cfg.Server.AllowedOrigins,
+		AllowHeaders: []string{
+			echo.HeaderOrigin, echo.HeaderContentType, echo.HeaderAccept, echo.HeaderAuthorization, middleware.CSRFHeaderName,
+		},
+		// so the client can back off before it is refused
+		ExposeHeaders: []string{
+			middleware.RateLimitLimitHeader, middleware.RateLimitRemainingHeader, middleware.RateLimitResetHeader,
+			middleware.RateLimitPolicyHeader, echo.HeaderRetryAfter,
+		},
+		AllowCredentials: true,
+	}))
+	// Configure rotas, bodies the OpenAPI spec does not allow are refused before the handler runs
+	handler.SetupRoutes(e, handlers, authMiddleware, rateLimiter, requestValidator.Middleware())
 
 	// graceful shutdown
-	port := ":" + cfg.Server.Port
-
-	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
-	defer stop()
+	addr := net.JoinHostPort(cfg.Server.Host, cfg.Server.Port)
 
-	slog.Info("Starting server on port", slog.String("port", cfg.Server.Port))
+	slog.Info("Starting server", slog.String("addr", addr))
 
 	go func() {
-		if err := e.Start(port); err != nil && err != http.ErrServerClosed {
-			e.Logger.Fatal("shutting down the server")
+		if err := e.Start(addr); err != nil && err != http.ErrServerClosed {
+			fatal("Server stopped", err)
 		}
 	}()
 
+	// scraped on a separate listener so it is never exposed with the API
+	var metricsServer *http.Server
+	if cfg.Metrics.Addr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", a.metrics.Handler(cfg.Metrics.Token))
+		metricsServer = &http.Server{Addr: cfg.Metrics.Addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+		slog.Info("Serving metrics", slog.String("addr", cfg.Metrics.Addr))
+		go func() {
+			if err := metricsServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				slog.Error("Metrics server stopped", slog.String("err", err.Error()))
+			}
+		}()
+	}
+
 	<-ctx.Done()
+	// a second signal stops right away
+	signal.Reset(os.Interrupt, syscall.SIGTERM)
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	// /readyz fails from now on, the load balancer gets the time to notice before the listener closes
+	healthChecker.SetDraining()
+	slog.Info("Draining before shutdown", slog.Duration("delay", cfg.Server.DrainDelay))
+	time.Sleep(cfg.Server.DrainDelay)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	if err := e.Shutdown(ctx); err != nil {
-		e.Logger.Fatal(err)
+	if metricsServer != nil {
+		metricsServer.Shutdown(shutdownCtx)
+	}
+
+	if err := e.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("failed to shut down the server: %w", err)
+	}
+
+	// a broadcast started just before the interrupt still reaches every fan, as long as it ends
+	// within the shutdown timeout (Kubernetes kills the process after its grace period anyway)
+	done := make(chan struct{})
+	go func() {
+		a.adminController.Wait()
+		a.passwordResetController.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-shutdownCtx.Done():
+		return errors.New("shut down before every fan and password reset was notified, see `server broadcast status`")
+	}
+}
+
+// databaseCheck also fails while migrations this build needs are not applied
+func databaseCheck(db *sqlx.DB) health.Check {
+	return func(ctx context.Context) error {
+		if err := db.PingContext(ctx); err != nil {
+			return fmt.Errorf("failed to reach database: %w", err)
+		}
+
+		expected, err := migrations.LatestVersion()
+		if err != nil {
+			return err
+		}
+
+		version, err := data.SchemaVersion(ctx, db)
+		if err != nil {
+			return err
+		}
+
+		if version < expected {
+			return fmt.Errorf("schema at version %d, expected %d", version, expected)
+		}
+
+		return nil
 	}
 }
+
+func fatal(msg string, err error) {
+	slog.Error(msg, slog.String("err", err.Error()))
+	os.Exit(1)
+}
+
+// oauthProviders returns the configured login providers, one that can not be reached is left
+// out so the rest of the API still starts
+func oauthProviders(ctx context.Context, cfg *config.Config) []model.IOAuthProvider {
+	callbackURL := func(name string) string {
+		return strings.TrimRight(cfg.Server.PublicURL, "/") + "/api/v1/auth/oauth/" + name + "/callback"
+	}
+
+	var providers []model.IOAuthProvider
+	if cfg.OAuth.GitHubClientID != "" {
+		providers = append(providers, oauth.NewGitHubProvider(cfg.OAuth.GitHubClientID, cfg.OAuth.GitHubClientSecret, callbackURL("github")))
+	}
+
+	oidcProviders := []struct{ name, issuer, clientID, clientSecret string }{
+		{"google", "https://accounts.google.com", cfg.OAuth.GoogleClientID, cfg.OAuth.GoogleClientSecret},
+		{cfg.OAuth.OIDCName, cfg.OAuth.OIDCIssuerURL, cfg.OAuth.OIDCClientID, cfg.OAuth.OIDCClientSecret},
+	}
+	for _, p := range oidcProviders {
+		if p.clientID == "" || p.issuer == "" {
+			continue
+		}
+
+		provider, err := oauth.NewOIDCProvider(ctx, p.name, p.issuer, p.clientID, p.clientSecret, callbackURL(p.name))
+		if err != nil {
+			slog.Error("Login provider disabled", slog.String("provider", p.name), slog.String("err", err.Error()))
+			continue
+		}
+		providers = append(providers, provider)
+	}
+
+	return providers
+}
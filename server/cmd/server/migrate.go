@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pressly/goose/v3"
+)
+
+const migrateUsage = "usage: server migrate <up|down|status|redo>"
+
+// runMigrate implements `server migrate`, the embedded migrations without the goose CLI
+func runMigrate(ctx context.Context, provider *goose.Provider, args []string, out io.Writer) error {
+	if len(args) != 1 {
+		return errors.New(migrateUsage)
+	}
+
+	switch args[0] {
+	case "up":
+		results, err := provider.Up(ctx)
+		printResults(out, results...)
+		if err != nil {
+			return err
+		}
+		if len(results) == 0 {
+			fmt.Fprintln(out, "no migrations to apply")
+		}
+		return nil
+	case "down":
+		result, err := provider.Down(ctx)
+		if err != nil {
+			return err
+		}
+		printResults(out, result)
+		return nil
+	case "redo":
+		// rolls back the newest migration and applies it again, for iterating on a migration
+		down, err := provider.Down(ctx)
+		if err != nil {
+			return err
+		}
+		printResults(out, down)
+
+		up, err := provider.UpByOne(ctx)
+		if err != nil {
+			return err
+		}
+		printResults(out, up)
+		return nil
+	case "status":
+		statuses, err := provider.Status(ctx)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+		fmt.Fprintln(w, "APPLIED AT\tMIGRATION")
+		for _, status := range statuses {
+			appliedAt := "pending"
+			if status.State == goose.StateApplied {
+				appliedAt = status.AppliedAt.Format(time.DateTime)
+			}
+			fmt.Fprintf(w, "%s\t%s\n", appliedAt, filepath.Base(status.Source.Path))
+		}
+		return w.Flush()
+	default:
+		return errors.New(migrateUsage)
+	}
+}
+
+func printResults(out io.Writer, results ...*goose.MigrationResult) {
+	for _, result := range results {
+		if result != nil {
+			fmt.Fprintln(out, result)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/tsntt/footballapi/internal/config"
+	"github.com/tsntt/footballapi/internal/dto"
+	"github.com/tsntt/footballapi/internal/model"
+)
+
+// runSeed implements `server seed`, it creates the first admin and does nothing once any user
+// has the admin role, so it can run on every deploy
+func runSeed(ctx context.Context, a *app, cfg config.SeedConfig, out io.Writer) error {
+	_, admins, err := a.userRepo.List(ctx, model.UserFilter{Role: "admin", Limit: 1})
+	if err != nil {
+		return err
+	}
+
+	if admins > 0 {
+		fmt.Fprintln(out, "an admin already exists, nothing to seed")
+		return nil
+	}
+
+	if cfg.AdminPassword == "" {
+		return errors.New("SEED_ADMIN_PASSWORD is not set")
+	}
+
+	user, err := a.userController.CreateUser(ctx, &dto.UserRequest{Name: cfg.AdminName, Password: cfg.AdminPassword}, "admin")
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "created admin %s (id %d), two-factor enrolment is asked at the first login\n", user.Name, user.ID)
+	return nil
+}
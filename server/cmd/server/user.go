@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/tsntt/footballapi/internal/dto"
+	"github.com/tsntt/footballapi/internal/model"
+	"golang.org/x/term"
+)
+
+const userUsage = "usage: server user <create --name <name> [--admin] | set-role --name <name> --role <role>>"
+
+// runUser implements `server user`, the password of a new user is read from in so it never
+// shows up in the shell history or the process list
+func runUser(ctx context.Context, a *app, args []string, in io.Reader, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(userUsage)
+	}
+
+	switch args[0] {
+	case "create":
+		flags := flag.NewFlagSet("user create", flag.ContinueOnError)
+		name := flags.String("name", "", "name the user logs in with")
+		admin := flags.Bool("admin", false, "give the user the admin role")
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+		if *name == "" {
+			return errors.New(userUsage)
+		}
+
+		password, err := readPassword(in, out)
+		if err != nil {
+			return err
+		}
+
+		role := "default"
+		if *admin {
+			role = "admin"
+		}
+
+		user, err := a.userController.CreateUser(ctx, &dto.UserRequest{Name: *name, Password: password}, role)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(out, "created user %s (id %d) with role %s\n", user.Name, user.ID, user.Role)
+		return nil
+	case "set-role":
+		flags := flag.NewFlagSet("user set-role", flag.ContinueOnError)
+		name := flags.String("name", "", "name of the user")
+		role := flags.String("role", "", "role to assign")
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+		if *name == "" || *role == "" {
+			return errors.New(userUsage)
+		}
+
+		user, err := a.userRepo.GetByName(ctx, *name)
+		if err != nil {
+			return fmt.Errorf("failed to get user: %w", err)
+		}
+
+		if _, err := a.roleController.AssignRole(ctx, model.AuditActorCLI, user.ID, &dto.AssignRoleRequest{Role: *role}); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(out, "role of %s set to %s, their sessions are revoked\n", user.Name, *role)
+		return nil
+	default:
+		return errors.New(userUsage)
+	}
+}
+
+// readPassword prompts without echo on a terminal, otherwise reads the first line, e.g.
+// `printenv ADMIN_PASSWORD | server user create --name admin --admin`
+func readPassword(in io.Reader, out io.Writer) (string, error) {
+	if f, ok := in.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		fmt.Fprint(out, "Password: ")
+		password, err := term.ReadPassword(int(f.Fd()))
+		fmt.Fprintln(out)
+		if err != nil {
+			return "", fmt.Errorf("failed to read password: %w", err)
+		}
+		return string(password), nil
+	}
+
+	scanner := bufio.NewScanner(in)
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return "", fmt.Errorf("failed to read password: %w", err)
+		}
+		return "", errors.New("no password given on stdin")
+	}
+
+	return strings.TrimRight(scanner.Text(), "\r"), nil
+}
@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS deliveries (
+    id SERIAL PRIMARY KEY,
+    broadcast_id INTEGER NOT NULL REFERENCES broadcasted_messages(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL,
+    notification_type VARCHAR(20) NOT NULL,
+    address TEXT NOT NULL DEFAULT '',
+    provider_message_id VARCHAR(64),
+    status VARCHAR(20) NOT NULL DEFAULT 'queued',
+    error_message TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX idx_deliveries_provider_message_id ON deliveries(provider_message_id) WHERE provider_message_id IS NOT NULL;
+CREATE INDEX idx_deliveries_broadcast_id ON deliveries(broadcast_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE deliveries;
+-- +goose StatementEnd
@@ -0,0 +1,37 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE fans ADD COLUMN notification_type VARCHAR(20) NOT NULL DEFAULT 'email';
+ALTER TABLE fans ADD COLUMN address TEXT NOT NULL DEFAULT '';
+ALTER TABLE fans ADD COLUMN active BOOLEAN NOT NULL DEFAULT TRUE;
+
+ALTER TABLE fans DROP CONSTRAINT IF EXISTS fans_user_id_team_id_key;
+ALTER TABLE fans ADD CONSTRAINT fans_user_id_team_id_notification_type_key UNIQUE (user_id, team_id, notification_type);
+
+CREATE INDEX idx_fans_address ON fans(notification_type, address);
+
+CREATE TABLE IF NOT EXISTS delivery_events (
+    id SERIAL PRIMARY KEY,
+    delivery_id INTEGER REFERENCES deliveries(id) ON DELETE CASCADE,
+    provider_event_id VARCHAR(64) NOT NULL UNIQUE,
+    event VARCHAR(20) NOT NULL,
+    recipient TEXT NOT NULL DEFAULT '',
+    reason TEXT NOT NULL DEFAULT '',
+    occurred_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_delivery_events_delivery_id ON delivery_events(delivery_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE delivery_events;
+
+DROP INDEX IF EXISTS idx_fans_address;
+ALTER TABLE fans DROP CONSTRAINT IF EXISTS fans_user_id_team_id_notification_type_key;
+ALTER TABLE fans ADD CONSTRAINT fans_user_id_team_id_key UNIQUE (user_id, team_id);
+
+ALTER TABLE fans DROP COLUMN active;
+ALTER TABLE fans DROP COLUMN address;
+ALTER TABLE fans DROP COLUMN notification_type;
+-- +goose StatementEnd
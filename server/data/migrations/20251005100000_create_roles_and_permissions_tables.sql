@@ -0,0 +1,53 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS roles (
+    name VARCHAR(20) PRIMARY KEY,
+    description TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS permissions (
+    name VARCHAR(50) PRIMARY KEY,
+    description TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+    role VARCHAR(20) NOT NULL REFERENCES roles(name) ON DELETE CASCADE ON UPDATE CASCADE,
+    permission VARCHAR(50) NOT NULL REFERENCES permissions(name) ON DELETE CASCADE ON UPDATE CASCADE,
+    PRIMARY KEY (role, permission)
+);
+
+INSERT INTO roles (name, description) VALUES
+('admin', 'Full access'),
+('operator', 'Sends and follows match broadcasts'),
+('default', 'Fan')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO permissions (name, description) VALUES
+('broadcast:send', 'Start match broadcasts'),
+('broadcast:read', 'List matches and follow broadcast deliveries'),
+('users:manage', 'Manage users and their roles'),
+('templates:edit', 'Edit notification templates')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role, permission) VALUES
+('admin', 'broadcast:send'),
+('admin', 'broadcast:read'),
+('admin', 'users:manage'),
+('admin', 'templates:edit'),
+('operator', 'broadcast:send'),
+('operator', 'broadcast:read')
+ON CONFLICT DO NOTHING;
+
+UPDATE users SET role = 'default' WHERE role IS NULL OR role NOT IN (SELECT name FROM roles);
+ALTER TABLE users ALTER COLUMN role SET NOT NULL;
+ALTER TABLE users ADD CONSTRAINT users_role_fkey FOREIGN KEY (role) REFERENCES roles(name) ON UPDATE CASCADE;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_role_fkey;
+ALTER TABLE users ALTER COLUMN role DROP NOT NULL;
+DROP TABLE role_permissions;
+DROP TABLE permissions;
+DROP TABLE roles;
+-- +goose StatementEnd
@@ -0,0 +1,36 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE users ADD COLUMN suspended_at TIMESTAMP;
+-- Tokens issued at or before this instant are rejected
+ALTER TABLE users ADD COLUMN tokens_valid_after TIMESTAMP;
+
+CREATE INDEX idx_users_name_lower ON users(LOWER(name));
+
+-- Deliveries hold the user's address, they go with the user
+DELETE FROM deliveries WHERE user_id NOT IN (SELECT id FROM users);
+ALTER TABLE deliveries ADD CONSTRAINT deliveries_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+CREATE INDEX idx_deliveries_user_id ON deliveries(user_id);
+
+-- actor and target are not foreign keys, entries must outlive deleted users
+CREATE TABLE IF NOT EXISTS audit_logs (
+    id SERIAL PRIMARY KEY,
+    actor_id INTEGER NOT NULL,
+    action VARCHAR(50) NOT NULL,
+    target_type VARCHAR(20) NOT NULL,
+    target_id INTEGER NOT NULL,
+    details TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_audit_logs_target ON audit_logs(target_type, target_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE audit_logs;
+DROP INDEX IF EXISTS idx_deliveries_user_id;
+ALTER TABLE deliveries DROP CONSTRAINT IF EXISTS deliveries_user_id_fkey;
+DROP INDEX IF EXISTS idx_users_name_lower;
+ALTER TABLE users DROP COLUMN tokens_valid_after;
+ALTER TABLE users DROP COLUMN suspended_at;
+-- +goose StatementEnd
@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+-- The users migration inserts an admin whose password (admin123) is public, that migration
+-- is left as shipped. The admin goes while its password was never changed, with its API keys and subscriptions;
+-- create the first admin again with `server seed` or `server user create --admin`
+DELETE FROM users
+WHERE name = 'admin' AND password = '$2a$10$hx3lXJ6pHpbIPgMis4RYeuZv7T9KMXPHI75h9IwBGR1gi61vEtyxu';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- the default admin is not restored
+SELECT 1;
+-- +goose StatementEnd
@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+-- tokens of the signed Mailgun webhooks already handled, a replayed request is refused.
+-- Kept as long as a timestamp is accepted, older ones are deleted as new ones come in
+CREATE TABLE IF NOT EXISTS webhook_tokens (
+    token VARCHAR(100) PRIMARY KEY,
+    received_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_webhook_tokens_received_at ON webhook_tokens(received_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE webhook_tokens;
+-- +goose StatementEnd
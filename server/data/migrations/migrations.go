@@ -0,0 +1,33 @@
+// Package migrations embeds the goose migrations so the binary can apply the schema it needs
+package migrations
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+)
+
+//go:embed *.sql
+var FS embed.FS
+
+// LatestVersion is the version of the newest migration, goose takes it from the file name prefix
+func LatestVersion() (int64, error) {
+	files, err := fs.Glob(FS, "*.sql")
+	if err != nil {
+		return 0, fmt.Errorf("failed to list migrations: %w", err)
+	}
+
+	var latest int64
+	for _, file := range files {
+		prefix, _, _ := strings.Cut(file, "_")
+		version, err := strconv.ParseInt(prefix, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid migration name %s", file)
+		}
+		latest = max(latest, version)
+	}
+
+	return latest, nil
+}
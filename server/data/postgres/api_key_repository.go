@@ -0,0 +1,123 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/tsntt/footballapi/internal/model"
+)
+
+type APIKeyRepository struct {
+	db *sqlx.DB
+}
+
+func NewAPIKeyRepository(db *sqlx.DB) *APIKeyRepository {
+	return &APIKeyRepository{db: db}
+}
+
+// apiKeyRow reads the scopes column, model.APIKey keeps a plain slice
+type apiKeyRow struct {
+	model.APIKey
+	Scopes pq.StringArray `db:"scopes"`
+}
+
+func (row apiKeyRow) toModel() model.APIKey {
+	key := row.APIKey
+	key.Scopes = []string(row.Scopes)
+	return key
+}
+
+const apiKeyColumns = `id, user_id, name, prefix, key_hash, scopes, expires_at, last_used_at, last_used_ip, revoked_at, created_at`
+
+func (r *APIKeyRepository) Create(ctx context.Context, key *model.APIKey) error {
+	query := `
+		INSERT INTO api_keys (user_id, name, prefix, key_hash, scopes, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at`
+
+	err := r.db.QueryRowContext(ctx, query, key.UserID, key.Name, key.Prefix, key.KeyHash, pq.Array(key.Scopes), key.ExpiresAt).
+		Scan(&key.ID, &key.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create api key: %w", err)
+	}
+
+	return nil
+}
+
+func (r *APIKeyRepository) GetByPrefix(ctx context.Context, prefix string) (*model.APIKey, error) {
+	var row apiKeyRow
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE prefix = $1`
+
+	err := r.db.GetContext(ctx, &row, query, prefix)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("api key %w", model.ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get api key: %w", err)
+	}
+
+	key := row.toModel()
+	return &key, nil
+}
+
+func (r *APIKeyRepository) ListByUser(ctx context.Context, userID int) ([]model.APIKey, error) {
+	var rows []apiKeyRow
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE user_id = $1 ORDER BY created_at DESC`
+
+	if err := r.db.SelectContext(ctx, &rows, query, userID); err != nil {
+		return nil, fmt.Errorf("failed to list api keys: %w", err)
+	}
+
+	keys := make([]model.APIKey, 0, len(rows))
+	for _, row := range rows {
+		keys = append(keys, row.toModel())
+	}
+
+	return keys, nil
+}
+
+func (r *APIKeyRepository) CountActive(ctx context.Context, userID int) (int, error) {
+	var count int
+	query := `
+		SELECT COUNT(*) FROM api_keys
+		WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > CURRENT_TIMESTAMP`
+
+	if err := r.db.GetContext(ctx, &count, query, userID); err != nil {
+		return 0, fmt.Errorf("failed to count api keys: %w", err)
+	}
+
+	return count, nil
+}
+
+func (r *APIKeyRepository) Revoke(ctx context.Context, userID, id int) (bool, error) {
+	query := `UPDATE api_keys SET revoked_at = CURRENT_TIMESTAMP WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL`
+
+	result, err := r.db.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to revoke api key: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	return rowsAffected == 1, nil
+}
+
+func (r *APIKeyRepository) TouchLastUsed(ctx context.Context, id int, ip string) error {
+	query := `
+		UPDATE api_keys SET last_used_at = CURRENT_TIMESTAMP, last_used_ip = $2
+		WHERE id = $1
+		  AND (last_used_at IS NULL OR last_used_at < CURRENT_TIMESTAMP - INTERVAL '1 minute' OR last_used_ip IS DISTINCT FROM $2)`
+
+	if _, err := r.db.ExecContext(ctx, query, id, ip); err != nil {
+		return fmt.Errorf("failed to update api key last use: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,46 @@
+package data
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/tsntt/footballapi/internal/model"
+)
+
+type AuditRepository struct {
+	db *sqlx.DB
+}
+
+func NewAuditRepository(db *sqlx.DB) *AuditRepository {
+	return &AuditRepository{db: db}
+}
+
+func (r *AuditRepository) Create(ctx context.Context, entry *model.AuditEntry) error {
+	query := `
+		INSERT INTO audit_logs (actor_id, action, target_type, target_id, details)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at`
+
+	err := r.db.QueryRowContext(ctx, query, entry.ActorID, entry.Action, entry.TargetType, entry.TargetID, entry.Details).
+		Scan(&entry.ID, &entry.CreatedAt)
+
+	if err != nil {
+		return fmt.Errorf("failed to create audit entry: %w", err)
+	}
+
+	return nil
+}
+
+func (r *AuditRepository) GetByTarget(ctx context.Context, targetType string, targetID int) ([]model.AuditEntry, error) {
+	entries := []model.AuditEntry{}
+	query := `
+		SELECT id, actor_id, action, target_type, target_id, details, created_at
+		FROM audit_logs WHERE target_type = $1 AND target_id = $2 ORDER BY id DESC`
+
+	if err := r.db.SelectContext(ctx, &entries, query, targetType, targetID); err != nil {
+		return nil, fmt.Errorf("failed to get audit entries: %w", err)
+	}
+
+	return entries, nil
+}
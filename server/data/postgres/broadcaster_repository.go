@@ -41,7 +41,7 @@ func (r *BroadcastRepository) GetByMatchID(ctx context.Context, matchID int) (*m
 	err := r.db.GetContext(ctx, broadcast, query, matchID)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, fmt.Errorf("broadcast message not found")
+			return nil, fmt.Errorf("broadcast message %w", model.ErrNotFound)
 		}
 		return nil, fmt.Errorf("failed to get broadcast message: %w", err)
 	}
@@ -0,0 +1,123 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/tsntt/footballapi/internal/model"
+)
+
+type DeliveryRepository struct {
+	db *sqlx.DB
+}
+
+func NewDeliveryRepository(db *sqlx.DB) *DeliveryRepository {
+	return &DeliveryRepository{db: db}
+}
+
+func (r *DeliveryRepository) Create(ctx context.Context, delivery *model.Delivery) error {
+	query := `
+		INSERT INTO deliveries (broadcast_id, user_id, notification_type, address, provider_message_id, status, error_message)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7)
+		RETURNING id, created_at, updated_at`
+
+	err := r.db.QueryRowContext(ctx, query,
+		delivery.BroadcastID,
+		delivery.UserID,
+		delivery.NotificationType,
+		delivery.Address,
+		delivery.ProviderMessageID,
+		delivery.Status,
+		delivery.ErrorMessage,
+	).Scan(&delivery.ID, &delivery.CreatedAt, &delivery.UpdatedAt)
+
+	if err != nil {
+		return fmt.Errorf("failed to create delivery: %w", err)
+	}
+
+	return nil
+}
+
+func (r *DeliveryRepository) GetByProviderMessageID(ctx context.Context, providerMessageID string) (*model.Delivery, error) {
+	delivery := &model.Delivery{}
+	query := `
+		SELECT id, broadcast_id, user_id, notification_type, address, COALESCE(provider_message_id, '') AS provider_message_id,
+			status, error_message, created_at, updated_at
+		FROM deliveries WHERE provider_message_id = $1`
+
+	err := r.db.GetContext(ctx, delivery, query, providerMessageID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("delivery %w", model.ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get delivery: %w", err)
+	}
+
+	return delivery, nil
+}
+
+func (r *DeliveryRepository) GetByBroadcastID(ctx context.Context, broadcastID int) ([]model.Delivery, error) {
+	deliveries := []model.Delivery{}
+	query := `
+		SELECT id, broadcast_id, user_id, notification_type, address, COALESCE(provider_message_id, '') AS provider_message_id,
+			status, error_message, created_at, updated_at
+		FROM deliveries WHERE broadcast_id = $1 ORDER BY id`
+
+	err := r.db.SelectContext(ctx, &deliveries, query, broadcastID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get deliveries by broadcast: %w", err)
+	}
+
+	return deliveries, nil
+}
+
+func (r *DeliveryRepository) GetByUserID(ctx context.Context, userID, limit int) ([]model.Delivery, error) {
+	deliveries := []model.Delivery{}
+	query := `
+		SELECT id, broadcast_id, user_id, notification_type, address, COALESCE(provider_message_id, '') AS provider_message_id,
+			status, error_message, created_at, updated_at
+		FROM deliveries WHERE user_id = $1 ORDER BY id DESC LIMIT $2`
+
+	err := r.db.SelectContext(ctx, &deliveries, query, userID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get deliveries by user: %w", err)
+	}
+
+	return deliveries, nil
+}
+
+func (r *DeliveryRepository) UpdateStatus(ctx context.Context, delivery *model.Delivery) error {
+	query := `UPDATE deliveries SET status = $1, error_message = $2, updated_at = CURRENT_TIMESTAMP WHERE id = $3`
+
+	_, err := r.db.ExecContext(ctx, query, delivery.Status, delivery.ErrorMessage, delivery.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update delivery status: %w", err)
+	}
+
+	return nil
+}
+
+// AddEvent ignores events we already stored, providers retry webhooks until they get a 2xx
+func (r *DeliveryRepository) AddEvent(ctx context.Context, event *model.DeliveryEvent) error {
+	query := `
+		INSERT INTO delivery_events (delivery_id, provider_event_id, event, recipient, reason, occurred_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (provider_event_id) DO NOTHING`
+
+	_, err := r.db.ExecContext(ctx, query,
+		event.DeliveryID,
+		event.ProviderEventID,
+		event.Event,
+		event.Recipient,
+		event.Reason,
+		event.OccurredAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to add delivery event: %w", err)
+	}
+
+	return nil
+}
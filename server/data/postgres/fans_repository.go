@@ -8,6 +8,13 @@ import (
 	"github.com/tsntt/footballapi/internal/model"
 )
 
+// A channel is verified once its owner confirmed the address with a one-time code
+const verifiedColumn = `EXISTS (
+	SELECT 1 FROM channel_verifications v
+	WHERE v.user_id = f.user_id AND v.notification_type = f.notification_type
+		AND v.address = f.address AND v.verified_at IS NOT NULL
+) AS verified`
+
 type FanRepository struct {
 	db *sqlx.DB
 }
@@ -17,9 +24,15 @@ func NewFanRepository(db *sqlx.DB) *FanRepository {
 }
 
 func (r *FanRepository) Create(ctx context.Context, fan *model.Fan) error {
-	query := `INSERT INTO fans (user_id, team_id) VALUES ($1, $2) RETURNING id`
-	err := r.db.QueryRowContext(ctx, query, fan.UserID, fan.TeamID).Scan(&fan.ID)
+	query := `
+		INSERT INTO fans (user_id, team_id, notification_type, address, active)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id`
+	err := r.db.QueryRowContext(ctx, query, fan.UserID, fan.TeamID, fan.NotificationType, fan.Address, fan.Active).Scan(&fan.ID)
 	if err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("subscription to team %d %w", fan.TeamID, model.ErrConflict)
+		}
 		return fmt.Errorf("failed to create fan: %w", err)
 	}
 	return nil
@@ -27,7 +40,7 @@ func (r *FanRepository) Create(ctx context.Context, fan *model.Fan) error {
 
 func (r *FanRepository) GetAll(ctx context.Context) ([]model.Fan, error) {
 	fans := []model.Fan{}
-	query := `SELECT id, user_id, team_id FROM fans`
+	query := `SELECT id, user_id, team_id, notification_type, address, active FROM fans`
 
 	err := r.db.SelectContext(ctx, &fans, query)
 	if err != nil {
@@ -39,7 +52,9 @@ func (r *FanRepository) GetAll(ctx context.Context) ([]model.Fan, error) {
 
 func (r *FanRepository) GetByTeamID(ctx context.Context, teamID int) ([]model.Fan, error) {
 	fans := []model.Fan{}
-	query := `SELECT id, user_id, team_id FROM fans WHERE team_id = $1`
+	query := `
+		SELECT f.id, f.user_id, f.team_id, f.notification_type, f.address, f.active, ` + verifiedColumn + `
+		FROM fans f WHERE f.team_id = $1 AND f.active = TRUE`
 
 	err := r.db.SelectContext(ctx, &fans, query, teamID)
 	if err != nil {
@@ -51,7 +66,9 @@ func (r *FanRepository) GetByTeamID(ctx context.Context, teamID int) ([]model.Fa
 
 func (r *FanRepository) GetByUserID(ctx context.Context, userID int) ([]model.Fan, error) {
 	fans := []model.Fan{}
-	query := `SELECT id, user_id, team_id FROM fans WHERE user_id = $1`
+	query := `
+		SELECT f.id, f.user_id, f.team_id, f.notification_type, f.address, f.active, ` + verifiedColumn + `
+		FROM fans f WHERE f.user_id = $1`
 
 	err := r.db.SelectContext(ctx, &fans, query, userID)
 	if err != nil {
@@ -75,8 +92,74 @@ func (r *FanRepository) DeleteByUserIDAndTeam(ctx context.Context, userID int, t
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("subscription not found")
+		return fmt.Errorf("subscription %w", model.ErrNotFound)
 	}
 
 	return nil
 }
+
+func (r *FanRepository) DisableByAddress(ctx context.Context, notificationType, address string) (int64, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE fans SET active = FALSE WHERE notification_type = $1 AND address = $2 AND active = TRUE`
+
+	result, err := tx.ExecContext(ctx, query, notificationType, address)
+	if err != nil {
+		return 0, fmt.Errorf("failed to disable fan subscriptions: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	// the code of the last verification expires with it, only a new one sent to the address turns it back on
+	revoke := `
+		UPDATE channel_verifications SET verified_at = NULL, expires_at = CURRENT_TIMESTAMP
+		WHERE notification_type = $1 AND address = $2`
+	if _, err := tx.ExecContext(ctx, revoke, notificationType, address); err != nil {
+		return 0, fmt.Errorf("failed to revoke channel verifications: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit disabled subscriptions: %w", err)
+	}
+
+	return rowsAffected, nil
+}
+
+func (r *FanRepository) DisableByUserAndAddress(ctx context.Context, userID int, notificationType, address string) (int64, error) {
+	query := `UPDATE fans SET active = FALSE WHERE user_id = $1 AND notification_type = $2 AND address = $3 AND active = TRUE`
+
+	result, err := r.db.ExecContext(ctx, query, userID, notificationType, address)
+	if err != nil {
+		return 0, fmt.Errorf("failed to disable fan subscriptions: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	return rowsAffected, nil
+}
+
+func (r *FanRepository) ActivateByUserAndAddress(ctx context.Context, userID int, notificationType, address string) (int64, error) {
+	query := `UPDATE fans SET active = TRUE WHERE user_id = $1 AND notification_type = $2 AND address = $3`
+
+	result, err := r.db.ExecContext(ctx, query, userID, notificationType, address)
+	if err != nil {
+		return 0, fmt.Errorf("failed to activate fan subscriptions: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	return rowsAffected, nil
+}
@@ -0,0 +1,127 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/tsntt/footballapi/internal/model"
+)
+
+type UserIdentityRepository struct {
+	db *sqlx.DB
+}
+
+func NewUserIdentityRepository(db *sqlx.DB) *UserIdentityRepository {
+	return &UserIdentityRepository{db: db}
+}
+
+func (r *UserIdentityRepository) GetByProviderSubject(ctx context.Context, provider, subject string) (*model.UserIdentity, error) {
+	identity := &model.UserIdentity{}
+	query := `
+		SELECT id, user_id, provider, subject, email, created_at, last_login_at
+		FROM user_identities WHERE provider = $1 AND subject = $2`
+
+	err := r.db.GetContext(ctx, identity, query, provider, subject)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("identity %w", model.ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get identity: %w", err)
+	}
+
+	return identity, nil
+}
+
+func (r *UserIdentityRepository) CreateWithUser(ctx context.Context, user *model.User, identity *model.UserIdentity) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	userQuery := `
+		INSERT INTO users (name, password, role, display_name, email)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, language, timezone, created_at, updated_at`
+
+	err = tx.QueryRowContext(ctx, userQuery, user.Name, user.Password, user.Role, user.DisplayName, user.Email).
+		Scan(&user.ID, &user.Language, &user.Timezone, &user.CreatedAt, &user.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create user: %w", err)
+	}
+
+	identityQuery := `
+		INSERT INTO user_identities (user_id, provider, subject, email, last_login_at)
+		VALUES ($1, $2, $3, $4, CURRENT_TIMESTAMP)
+		RETURNING id, created_at`
+
+	identity.UserID = user.ID
+	err = tx.QueryRowContext(ctx, identityQuery, identity.UserID, identity.Provider, identity.Subject, identity.Email).
+		Scan(&identity.ID, &identity.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create identity: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit identity: %w", err)
+	}
+
+	return nil
+}
+
+func (r *UserIdentityRepository) TouchLogin(ctx context.Context, id int) error {
+	query := `UPDATE user_identities SET last_login_at = CURRENT_TIMESTAMP WHERE id = $1`
+
+	if _, err := r.db.ExecContext(ctx, query, id); err != nil {
+		return fmt.Errorf("failed to update identity: %w", err)
+	}
+
+	return nil
+}
+
+type OAuthStateRepository struct {
+	db *sqlx.DB
+}
+
+func NewOAuthStateRepository(db *sqlx.DB) *OAuthStateRepository {
+	return &OAuthStateRepository{db: db}
+}
+
+func (r *OAuthStateRepository) Create(ctx context.Context, state *model.OAuthState) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM oauth_states WHERE expires_at < CURRENT_TIMESTAMP`); err != nil {
+		return fmt.Errorf("failed to delete expired oauth states: %w", err)
+	}
+
+	query := `
+		INSERT INTO oauth_states (state_hash, provider, code_verifier, nonce, cookie_session, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at`
+
+	err := r.db.QueryRowContext(ctx, query, state.StateHash, state.Provider, state.CodeVerifier, state.Nonce, state.CookieSession, state.ExpiresAt).
+		Scan(&state.ID, &state.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create oauth state: %w", err)
+	}
+
+	return nil
+}
+
+func (r *OAuthStateRepository) Consume(ctx context.Context, stateHash string) (*model.OAuthState, error) {
+	state := &model.OAuthState{}
+	query := `
+		DELETE FROM oauth_states WHERE state_hash = $1
+		RETURNING id, state_hash, provider, code_verifier, nonce, cookie_session, expires_at, created_at`
+
+	err := r.db.GetContext(ctx, state, query, stateHash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("oauth state %w", model.ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to consume oauth state: %w", err)
+	}
+
+	return state, nil
+}
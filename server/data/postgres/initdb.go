@@ -1,22 +1,53 @@
 package data
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 
+	"github.com/XSAM/otelsql"
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
 )
 
 func NewDB(host, user, password, dbname, sslmode string, port int) (*sqlx.DB, error) {
 	dsn := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
 		host, port, user, password, dbname, sslmode)
 
-	db, err := sqlx.Connect("postgres", dsn)
+	// every query of the repositories becomes a span under the request that ran it
+	sqlDB, err := otelsql.Open("postgres", dsn,
+		otelsql.WithAttributes(semconv.DBSystemNamePostgreSQL),
+		otelsql.WithSpanOptions(otelsql.SpanOptions{OmitConnResetSession: true, OmitRows: true}),
+	)
 	if err != nil {
+		slog.Error("Failed to open database", slog.String("err", err.Error()))
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	db := sqlx.NewDb(sqlDB, "postgres")
+	if err := db.Ping(); err != nil {
+		db.Close()
 		slog.Error("Failed to connect to database", slog.String("err", err.Error()))
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
 
 	return db, nil
 }
+
+// SchemaVersion is the newest migration goose applied (and did not roll back since)
+func SchemaVersion(ctx context.Context, db *sqlx.DB) (int64, error) {
+	var version int64
+	query := `
+		SELECT COALESCE(MAX(v.version_id), 0) FROM goose_db_version v
+		WHERE v.is_applied AND NOT EXISTS (
+			SELECT 1 FROM goose_db_version l
+			WHERE l.version_id = v.version_id AND l.id > v.id AND NOT l.is_applied
+		)`
+
+	if err := db.GetContext(ctx, &version, query); err != nil {
+		return 0, fmt.Errorf("failed to get schema version: %w", err)
+	}
+
+	return version, nil
+}
@@ -0,0 +1,109 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/tsntt/footballapi/internal/model"
+)
+
+type LoginAttemptRepository struct {
+	db *sqlx.DB
+}
+
+func NewLoginAttemptRepository(db *sqlx.DB) *LoginAttemptRepository {
+	return &LoginAttemptRepository{db: db}
+}
+
+func (r *LoginAttemptRepository) Record(ctx context.Context, attempt *model.LoginAttempt) error {
+	query := `
+		INSERT INTO login_attempts (user_id, name, ip, user_agent, success, failure_reason)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at`
+
+	err := r.db.QueryRowContext(ctx, query,
+		attempt.UserID,
+		attempt.Name,
+		attempt.IP,
+		attempt.UserAgent,
+		attempt.Success,
+		attempt.FailureReason,
+	).Scan(&attempt.ID, &attempt.CreatedAt)
+
+	if err != nil {
+		return fmt.Errorf("failed to record login attempt: %w", err)
+	}
+
+	return nil
+}
+
+func (r *LoginAttemptRepository) GetThrottle(ctx context.Context, key string) (*model.LoginThrottle, error) {
+	throttle := &model.LoginThrottle{}
+	query := `SELECT key, failures, last_failure_at, locked_until FROM login_throttles WHERE key = $1`
+
+	err := r.db.GetContext(ctx, throttle, query, key)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("login throttle %w", model.ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get login throttle: %w", err)
+	}
+
+	return throttle, nil
+}
+
+func (r *LoginAttemptRepository) AddFailure(ctx context.Context, key string, now, windowStart time.Time) (*model.LoginThrottle, error) {
+	throttle := &model.LoginThrottle{}
+	query := `
+		INSERT INTO login_throttles (key, failures, last_failure_at)
+		VALUES ($1, 1, $2)
+		ON CONFLICT (key) DO UPDATE SET
+			failures = CASE WHEN login_throttles.last_failure_at < $3 THEN 1 ELSE login_throttles.failures + 1 END,
+			locked_until = CASE WHEN login_throttles.last_failure_at < $3 THEN NULL ELSE login_throttles.locked_until END,
+			last_failure_at = EXCLUDED.last_failure_at
+		RETURNING key, failures, last_failure_at, locked_until`
+
+	if err := r.db.GetContext(ctx, throttle, query, key, now, windowStart); err != nil {
+		return nil, fmt.Errorf("failed to count login failure: %w", err)
+	}
+
+	return throttle, nil
+}
+
+func (r *LoginAttemptRepository) LockThrottle(ctx context.Context, key string, until time.Time) error {
+	query := `UPDATE login_throttles SET locked_until = $1 WHERE key = $2`
+
+	if _, err := r.db.ExecContext(ctx, query, until, key); err != nil {
+		return fmt.Errorf("failed to lock login throttle: %w", err)
+	}
+
+	return nil
+}
+
+func (r *LoginAttemptRepository) DeleteThrottle(ctx context.Context, key string) error {
+	query := `DELETE FROM login_throttles WHERE key = $1`
+
+	if _, err := r.db.ExecContext(ctx, query, key); err != nil {
+		return fmt.Errorf("failed to delete login throttle: %w", err)
+	}
+
+	return nil
+}
+
+func (r *LoginAttemptRepository) ListThrottles(ctx context.Context, since time.Time) ([]model.LoginThrottle, error) {
+	throttles := []model.LoginThrottle{}
+	query := `
+		SELECT key, failures, last_failure_at, locked_until FROM login_throttles
+		WHERE last_failure_at >= $1 OR locked_until > CURRENT_TIMESTAMP
+		ORDER BY last_failure_at DESC`
+
+	if err := r.db.SelectContext(ctx, &throttles, query, since); err != nil {
+		return nil, fmt.Errorf("failed to list login throttles: %w", err)
+	}
+
+	return throttles, nil
+}
@@ -0,0 +1,27 @@
+package data
+
+import (
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pressly/goose/v3"
+	"github.com/pressly/goose/v3/lock"
+	"github.com/tsntt/footballapi/data/migrations"
+)
+
+// NewMigrationProvider runs the migrations embedded in the binary. Up and down hold a Postgres
+// advisory lock for the whole run, so replicas booting together apply each migration once.
+// The goose_db_version table is the one the goose CLI uses
+func NewMigrationProvider(db *sqlx.DB) (*goose.Provider, error) {
+	locker, err := lock.NewPostgresSessionLocker()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create migration lock: %w", err)
+	}
+
+	provider, err := goose.NewProvider(goose.DialectPostgres, db.DB, migrations.FS, goose.WithSessionLocker(locker))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load migrations: %w", err)
+	}
+
+	return provider, nil
+}
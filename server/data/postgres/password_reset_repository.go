@@ -0,0 +1,104 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/tsntt/footballapi/internal/model"
+)
+
+type PasswordResetRepository struct {
+	db *sqlx.DB
+}
+
+func NewPasswordResetRepository(db *sqlx.DB) *PasswordResetRepository {
+	return &PasswordResetRepository{db: db}
+}
+
+func (r *PasswordResetRepository) Create(ctx context.Context, reset *model.PasswordReset) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	invalidate := `UPDATE password_resets SET used_at = CURRENT_TIMESTAMP WHERE user_id = $1 AND used_at IS NULL`
+	if _, err := tx.ExecContext(ctx, invalidate, reset.UserID); err != nil {
+		return fmt.Errorf("failed to invalidate password resets: %w", err)
+	}
+
+	query := `
+		INSERT INTO password_resets (user_id, selector, verifier_hash, expires_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at`
+
+	err = tx.QueryRowContext(ctx, query, reset.UserID, reset.Selector, reset.VerifierHash, reset.ExpiresAt).
+		Scan(&reset.ID, &reset.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create password reset: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit password reset: %w", err)
+	}
+
+	return nil
+}
+
+func (r *PasswordResetRepository) GetBySelector(ctx context.Context, selector string) (*model.PasswordReset, error) {
+	reset := &model.PasswordReset{}
+	query := `
+		SELECT id, user_id, selector, verifier_hash, expires_at, attempts, used_at, created_at
+		FROM password_resets WHERE selector = $1`
+
+	err := r.db.GetContext(ctx, reset, query, selector)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("password reset %w", model.ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get password reset: %w", err)
+	}
+
+	return reset, nil
+}
+
+func (r *PasswordResetRepository) CountSince(ctx context.Context, userID int, since time.Time) (int, error) {
+	var count int
+	query := `SELECT COUNT(*) FROM password_resets WHERE user_id = $1 AND created_at >= $2`
+
+	if err := r.db.GetContext(ctx, &count, query, userID, since); err != nil {
+		return 0, fmt.Errorf("failed to count password resets: %w", err)
+	}
+
+	return count, nil
+}
+
+func (r *PasswordResetRepository) IncrementAttempts(ctx context.Context, id int) error {
+	query := `UPDATE password_resets SET attempts = attempts + 1 WHERE id = $1`
+
+	if _, err := r.db.ExecContext(ctx, query, id); err != nil {
+		return fmt.Errorf("failed to increment password reset attempts: %w", err)
+	}
+
+	return nil
+}
+
+func (r *PasswordResetRepository) MarkUsed(ctx context.Context, id int) (bool, error) {
+	query := `UPDATE password_resets SET used_at = CURRENT_TIMESTAMP WHERE id = $1 AND used_at IS NULL`
+
+	result, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to mark password reset as used: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	return rowsAffected == 1, nil
+}
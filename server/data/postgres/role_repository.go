@@ -0,0 +1,70 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/tsntt/footballapi/internal/model"
+)
+
+type RoleRepository struct {
+	db *sqlx.DB
+}
+
+func NewRoleRepository(db *sqlx.DB) *RoleRepository {
+	return &RoleRepository{db: db}
+}
+
+func (r *RoleRepository) GetAll(ctx context.Context) ([]model.Role, error) {
+	roles := []model.Role{}
+	query := `SELECT name, description FROM roles ORDER BY name`
+
+	if err := r.db.SelectContext(ctx, &roles, query); err != nil {
+		return nil, fmt.Errorf("failed to get roles: %w", err)
+	}
+
+	for i := range roles {
+		permissions, err := r.GetPermissions(ctx, roles[i].Name)
+		if err != nil {
+			return nil, err
+		}
+		roles[i].Permissions = permissions
+	}
+
+	return roles, nil
+}
+
+func (r *RoleRepository) GetByName(ctx context.Context, name string) (*model.Role, error) {
+	role := &model.Role{}
+	query := `SELECT name, description FROM roles WHERE name = $1`
+
+	err := r.db.GetContext(ctx, role, query, name)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("role %w", model.ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get role: %w", err)
+	}
+
+	permissions, err := r.GetPermissions(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	role.Permissions = permissions
+
+	return role, nil
+}
+
+func (r *RoleRepository) GetPermissions(ctx context.Context, role string) ([]string, error) {
+	permissions := []string{}
+	query := `SELECT permission FROM role_permissions WHERE role = $1 ORDER BY permission`
+
+	if err := r.db.SelectContext(ctx, &permissions, query, role); err != nil {
+		return nil, fmt.Errorf("failed to get role permissions: %w", err)
+	}
+
+	return permissions, nil
+}
@@ -0,0 +1,78 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/tsntt/footballapi/internal/model"
+)
+
+type SigningKeyRepository struct {
+	db *sqlx.DB
+}
+
+func NewSigningKeyRepository(db *sqlx.DB) *SigningKeyRepository {
+	return &SigningKeyRepository{db: db}
+}
+
+func (r *SigningKeyRepository) Rotate(ctx context.Context, key *model.SigningKey) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	retire := `UPDATE signing_keys SET retired_at = CURRENT_TIMESTAMP WHERE retired_at IS NULL`
+	if _, err := tx.ExecContext(ctx, retire); err != nil {
+		return fmt.Errorf("failed to retire signing keys: %w", err)
+	}
+
+	query := `
+		INSERT INTO signing_keys (kid, algorithm, private_key)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at`
+
+	err = tx.QueryRowContext(ctx, query, key.KID, key.Algorithm, key.PrivateKey).Scan(&key.ID, &key.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create signing key: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit signing key: %w", err)
+	}
+
+	return nil
+}
+
+func (r *SigningKeyRepository) ListValid(ctx context.Context, since time.Time) ([]model.SigningKey, error) {
+	keys := []model.SigningKey{}
+	query := `
+		SELECT id, kid, algorithm, private_key, created_at, retired_at
+		FROM signing_keys
+		WHERE retired_at IS NULL OR retired_at > $1
+		ORDER BY created_at DESC, id DESC`
+
+	if err := r.db.SelectContext(ctx, &keys, query, since); err != nil {
+		return nil, fmt.Errorf("failed to list signing keys: %w", err)
+	}
+
+	return keys, nil
+}
+
+func (r *SigningKeyRepository) DeleteRetiredBefore(ctx context.Context, before time.Time) (int64, error) {
+	query := `DELETE FROM signing_keys WHERE retired_at < $1`
+
+	result, err := r.db.ExecContext(ctx, query, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete signing keys: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	return rowsAffected, nil
+}
@@ -0,0 +1,271 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/tsntt/footballapi/internal/model"
+)
+
+type TwoFactorRepository struct {
+	db *sqlx.DB
+}
+
+func NewTwoFactorRepository(db *sqlx.DB) *TwoFactorRepository {
+	return &TwoFactorRepository{db: db}
+}
+
+func (r *TwoFactorRepository) Get(ctx context.Context, userID int) (*model.TwoFactor, error) {
+	twoFactor := &model.TwoFactor{}
+	query := `SELECT user_id, secret, enabled_at, last_used_step, created_at FROM user_two_factor WHERE user_id = $1`
+
+	err := r.db.GetContext(ctx, twoFactor, query, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("two factor %w", model.ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get two factor: %w", err)
+	}
+
+	return twoFactor, nil
+}
+
+func (r *TwoFactorRepository) SavePending(ctx context.Context, userID int, secret string) error {
+	query := `
+		INSERT INTO user_two_factor (user_id, secret)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE
+		SET secret = EXCLUDED.secret, last_used_step = 0, created_at = CURRENT_TIMESTAMP
+		WHERE user_two_factor.enabled_at IS NULL`
+
+	result, err := r.db.ExecContext(ctx, query, userID, secret)
+	if err != nil {
+		return fmt.Errorf("failed to save two factor: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("two factor already enabled")
+	}
+
+	return nil
+}
+
+func (r *TwoFactorRepository) Enable(ctx context.Context, userID int, step int64, recoveryCodeHashes []string) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE user_two_factor SET enabled_at = CURRENT_TIMESTAMP, last_used_step = $2
+		WHERE user_id = $1 AND enabled_at IS NULL`
+
+	result, err := tx.ExecContext(ctx, query, userID, step)
+	if err != nil {
+		return fmt.Errorf("failed to enable two factor: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("two factor not pending")
+	}
+
+	if err := replaceRecoveryCodes(ctx, tx, userID, recoveryCodeHashes); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit two factor: %w", err)
+	}
+
+	return nil
+}
+
+func (r *TwoFactorRepository) Delete(ctx context.Context, userID int) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM user_two_factor WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("failed to delete two factor: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit two factor: %w", err)
+	}
+
+	return nil
+}
+
+func (r *TwoFactorRepository) UseStep(ctx context.Context, userID int, step int64) (bool, error) {
+	query := `UPDATE user_two_factor SET last_used_step = $2 WHERE user_id = $1 AND last_used_step < $2`
+
+	result, err := r.db.ExecContext(ctx, query, userID, step)
+	if err != nil {
+		return false, fmt.Errorf("failed to use two factor step: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	return rowsAffected == 1, nil
+}
+
+func (r *TwoFactorRepository) ReplaceRecoveryCodes(ctx context.Context, userID int, codeHashes []string) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := replaceRecoveryCodes(ctx, tx, userID, codeHashes); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit recovery codes: %w", err)
+	}
+
+	return nil
+}
+
+func replaceRecoveryCodes(ctx context.Context, tx *sqlx.Tx, userID int, codeHashes []string) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+
+	for _, codeHash := range codeHashes {
+		query := `INSERT INTO recovery_codes (user_id, code_hash) VALUES ($1, $2)`
+		if _, err := tx.ExecContext(ctx, query, userID, codeHash); err != nil {
+			return fmt.Errorf("failed to create recovery code: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (r *TwoFactorRepository) UseRecoveryCode(ctx context.Context, userID int, codeHash string) (bool, error) {
+	query := `
+		UPDATE recovery_codes SET used_at = CURRENT_TIMESTAMP
+		WHERE id = (
+			SELECT id FROM recovery_codes
+			WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+			LIMIT 1
+		) AND used_at IS NULL`
+
+	result, err := r.db.ExecContext(ctx, query, userID, codeHash)
+	if err != nil {
+		return false, fmt.Errorf("failed to use recovery code: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	return rowsAffected == 1, nil
+}
+
+func (r *TwoFactorRepository) CountRecoveryCodes(ctx context.Context, userID int) (int, error) {
+	var count int
+	query := `SELECT COUNT(*) FROM recovery_codes WHERE user_id = $1 AND used_at IS NULL`
+
+	if err := r.db.GetContext(ctx, &count, query, userID); err != nil {
+		return 0, fmt.Errorf("failed to count recovery codes: %w", err)
+	}
+
+	return count, nil
+}
+
+type MFAChallengeRepository struct {
+	db *sqlx.DB
+}
+
+func NewMFAChallengeRepository(db *sqlx.DB) *MFAChallengeRepository {
+	return &MFAChallengeRepository{db: db}
+}
+
+func (r *MFAChallengeRepository) Create(ctx context.Context, challenge *model.MFAChallenge) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM mfa_challenges WHERE expires_at < CURRENT_TIMESTAMP`); err != nil {
+		return fmt.Errorf("failed to delete expired mfa challenges: %w", err)
+	}
+
+	query := `
+		INSERT INTO mfa_challenges (user_id, token_hash, cookie_session, expires_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at`
+
+	err := r.db.QueryRowContext(ctx, query, challenge.UserID, challenge.TokenHash, challenge.CookieSession, challenge.ExpiresAt).
+		Scan(&challenge.ID, &challenge.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create mfa challenge: %w", err)
+	}
+
+	return nil
+}
+
+func (r *MFAChallengeRepository) GetByTokenHash(ctx context.Context, tokenHash string) (*model.MFAChallenge, error) {
+	challenge := &model.MFAChallenge{}
+	query := `
+		SELECT id, user_id, token_hash, cookie_session, attempts, expires_at, created_at
+		FROM mfa_challenges WHERE token_hash = $1`
+
+	err := r.db.GetContext(ctx, challenge, query, tokenHash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("mfa challenge %w", model.ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get mfa challenge: %w", err)
+	}
+
+	return challenge, nil
+}
+
+func (r *MFAChallengeRepository) IncrementAttempts(ctx context.Context, id, maxAttempts int) (bool, error) {
+	query := `UPDATE mfa_challenges SET attempts = attempts + 1 WHERE id = $1 AND attempts < $2`
+
+	result, err := r.db.ExecContext(ctx, query, id, maxAttempts)
+	if err != nil {
+		return false, fmt.Errorf("failed to increment mfa challenge attempts: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	return rowsAffected == 1, nil
+}
+
+func (r *MFAChallengeRepository) Delete(ctx context.Context, id int) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM mfa_challenges WHERE id = $1`, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete mfa challenge: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	return rowsAffected == 1, nil
+}
@@ -5,6 +5,8 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 	"github.com/tsntt/footballapi/internal/model"
@@ -28,6 +30,9 @@ func (r *UserRepository) Create(ctx context.Context, user *model.User) error {
 		Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt)
 
 	if err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("user %s %w", user.Name, model.ErrConflict)
+		}
 		return fmt.Errorf("failed to create user: %w", err)
 	}
 
@@ -36,12 +41,14 @@ func (r *UserRepository) Create(ctx context.Context, user *model.User) error {
 
 func (r *UserRepository) GetByName(ctx context.Context, name string) (*model.User, error) {
 	user := &model.User{}
-	query := `SELECT id, name, password, role, created_at, updated_at FROM users WHERE name = $1`
+	query := `
+		SELECT id, name, password, role, display_name, email, phone, language, timezone, suspended_at, tokens_valid_after, created_at, updated_at
+		FROM users WHERE name = $1`
 
 	err := r.db.GetContext(ctx, user, query, name)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, fmt.Errorf("user not found")
+			return nil, fmt.Errorf("user %w", model.ErrNotFound)
 		}
 		return nil, fmt.Errorf("failed to get user by name: %w", err)
 	}
@@ -51,15 +58,173 @@ func (r *UserRepository) GetByName(ctx context.Context, name string) (*model.Use
 
 func (r *UserRepository) GetByID(ctx context.Context, id int) (*model.User, error) {
 	user := &model.User{}
-	query := `SELECT id, name, password, role, created_at, updated_at FROM users WHERE id = $1`
+	query := `
+		SELECT id, name, password, role, display_name, email, phone, language, timezone, suspended_at, tokens_valid_after, created_at, updated_at
+		FROM users WHERE id = $1`
 
 	err := r.db.GetContext(ctx, user, query, id)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, fmt.Errorf("user not found")
+			return nil, fmt.Errorf("user %w", model.ErrNotFound)
 		}
 		return nil, fmt.Errorf("failed to get user by id: %w", err)
 	}
 
 	return user, nil
 }
+
+// UpdateRole also revokes the tokens of the user, they carry the old role
+func (r *UserRepository) UpdateRole(ctx context.Context, id int, role string, revokedAt time.Time) error {
+	query := `
+		UPDATE users SET role = $1, tokens_valid_after = $2, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $3`
+
+	result, err := r.db.ExecContext(ctx, query, role, revokedAt, id)
+	if err != nil {
+		return fmt.Errorf("failed to update user role: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("user %w", model.ErrNotFound)
+	}
+
+	return nil
+}
+
+func (r *UserRepository) UpdateProfile(ctx context.Context, user *model.User) error {
+	query := `
+		UPDATE users SET display_name = $1, email = $2, phone = $3, language = $4, timezone = $5, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $6
+		RETURNING updated_at`
+
+	err := r.db.QueryRowContext(ctx, query, user.DisplayName, user.Email, user.Phone, user.Language, user.Timezone, user.ID).
+		Scan(&user.UpdatedAt)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("user %w", model.ErrNotFound)
+		}
+		return fmt.Errorf("failed to update user profile: %w", err)
+	}
+
+	return nil
+}
+
+func (r *UserRepository) UpdatePassword(ctx context.Context, id int, password string, revokedAt time.Time) error {
+	query := `
+		UPDATE users SET password = $1, tokens_valid_after = $2, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $3`
+
+	result, err := r.db.ExecContext(ctx, query, password, revokedAt, id)
+	if err != nil {
+		return fmt.Errorf("failed to update user password: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("user %w", model.ErrNotFound)
+	}
+
+	return nil
+}
+
+func (r *UserRepository) List(ctx context.Context, filter model.UserFilter) ([]model.User, int, error) {
+	users := []model.User{}
+	search := "%" + strings.ToLower(filter.Search) + "%"
+
+	var total int
+	countQuery := `SELECT COUNT(*) FROM users WHERE LOWER(name) LIKE $1 AND ($2 = '' OR role = $2)`
+	if err := r.db.GetContext(ctx, &total, countQuery, search, filter.Role); err != nil {
+		return nil, 0, fmt.Errorf("failed to count users: %w", err)
+	}
+
+	query := `
+		SELECT id, name, role, display_name, email, phone, language, timezone, suspended_at, created_at, updated_at
+		FROM users WHERE LOWER(name) LIKE $1 AND ($2 = '' OR role = $2)
+		ORDER BY id LIMIT $3 OFFSET $4`
+
+	if err := r.db.SelectContext(ctx, &users, query, search, filter.Role, filter.Limit, filter.Offset); err != nil {
+		return nil, 0, fmt.Errorf("failed to list users: %w", err)
+	}
+
+	return users, total, nil
+}
+
+func (r *UserRepository) Suspend(ctx context.Context, id int, suspendedAt time.Time) error {
+	query := `
+		UPDATE users SET suspended_at = $2, tokens_valid_after = $2, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1`
+
+	return r.execByID(ctx, query, id, "suspend user", suspendedAt)
+}
+
+func (r *UserRepository) Reactivate(ctx context.Context, id int) error {
+	query := `UPDATE users SET suspended_at = NULL, updated_at = CURRENT_TIMESTAMP WHERE id = $1`
+
+	return r.execByID(ctx, query, id, "reactivate user")
+}
+
+func (r *UserRepository) Delete(ctx context.Context, id int) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Email events without a delivery are only linked to the user by address
+	eventsQuery := `
+		DELETE FROM delivery_events
+		WHERE delivery_id IS NULL AND recipient IN (SELECT address FROM fans WHERE user_id = $1 AND notification_type = 'email')`
+	if _, err := tx.ExecContext(ctx, eventsQuery, id); err != nil {
+		return fmt.Errorf("failed to delete user email events: %w", err)
+	}
+
+	// fans, channel_verifications and deliveries (with their events) cascade
+	result, err := tx.ExecContext(ctx, `DELETE FROM users WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("user %w", model.ErrNotFound)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit user deletion: %w", err)
+	}
+
+	return nil
+}
+
+// execByID runs query with id as $1 and args from $2 on
+func (r *UserRepository) execByID(ctx context.Context, query string, id int, action string, args ...interface{}) error {
+	result, err := r.db.ExecContext(ctx, query, append([]interface{}{id}, args...)...)
+	if err != nil {
+		return fmt.Errorf("failed to %s: %w", action, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("user %w", model.ErrNotFound)
+	}
+
+	return nil
+}
@@ -0,0 +1,98 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/tsntt/footballapi/internal/model"
+)
+
+type ChannelVerificationRepository struct {
+	db *sqlx.DB
+}
+
+func NewChannelVerificationRepository(db *sqlx.DB) *ChannelVerificationRepository {
+	return &ChannelVerificationRepository{db: db}
+}
+
+func (r *ChannelVerificationRepository) Get(ctx context.Context, userID int, notificationType, address string) (*model.ChannelVerification, error) {
+	verification := &model.ChannelVerification{}
+	query := `
+		SELECT id, user_id, notification_type, address, code_hash, expires_at, attempts, send_count,
+			window_started_at, last_sent_at, verified_at, created_at
+		FROM channel_verifications
+		WHERE user_id = $1 AND notification_type = $2 AND address = $3`
+
+	err := r.db.GetContext(ctx, verification, query, userID, notificationType, address)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("verification %w", model.ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get verification: %w", err)
+	}
+
+	return verification, nil
+}
+
+// Save creates the verification or replaces the pending code of an existing one
+func (r *ChannelVerificationRepository) Save(ctx context.Context, verification *model.ChannelVerification) error {
+	query := `
+		INSERT INTO channel_verifications (user_id, notification_type, address, code_hash, expires_at, attempts, send_count, window_started_at, last_sent_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (user_id, notification_type, address) DO UPDATE SET
+			code_hash = EXCLUDED.code_hash,
+			expires_at = EXCLUDED.expires_at,
+			attempts = EXCLUDED.attempts,
+			send_count = EXCLUDED.send_count,
+			window_started_at = EXCLUDED.window_started_at,
+			last_sent_at = EXCLUDED.last_sent_at
+		RETURNING id, created_at`
+
+	err := r.db.QueryRowContext(ctx, query,
+		verification.UserID,
+		verification.NotificationType,
+		verification.Address,
+		verification.CodeHash,
+		verification.ExpiresAt,
+		verification.Attempts,
+		verification.SendCount,
+		verification.WindowStartedAt,
+		verification.LastSentAt,
+	).Scan(&verification.ID, &verification.CreatedAt)
+
+	if err != nil {
+		return fmt.Errorf("failed to save verification: %w", err)
+	}
+
+	return nil
+}
+
+func (r *ChannelVerificationRepository) IncrementAttempts(ctx context.Context, id, maxAttempts int) (bool, error) {
+	query := `UPDATE channel_verifications SET attempts = attempts + 1 WHERE id = $1 AND attempts < $2`
+
+	result, err := r.db.ExecContext(ctx, query, id, maxAttempts)
+	if err != nil {
+		return false, fmt.Errorf("failed to increment verification attempts: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	return rowsAffected == 1, nil
+}
+
+func (r *ChannelVerificationRepository) MarkVerified(ctx context.Context, id int) error {
+	query := `UPDATE channel_verifications SET verified_at = CURRENT_TIMESTAMP, code_hash = '' WHERE id = $1`
+
+	_, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("failed to mark verification as verified: %w", err)
+	}
+
+	return nil
+}
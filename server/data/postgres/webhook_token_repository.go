@@ -0,0 +1,43 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type WebhookTokenRepository struct {
+	db *sqlx.DB
+}
+
+func NewWebhookTokenRepository(db *sqlx.DB) *WebhookTokenRepository {
+	return &WebhookTokenRepository{db: db}
+}
+
+func (r *WebhookTokenRepository) Claim(ctx context.Context, token string, forgetBefore time.Time) (bool, error) {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM webhook_tokens WHERE received_at < $1`, forgetBefore); err != nil {
+		return false, fmt.Errorf("failed to delete old webhook tokens: %w", err)
+	}
+
+	result, err := r.db.ExecContext(ctx, `INSERT INTO webhook_tokens (token) VALUES ($1) ON CONFLICT (token) DO NOTHING`, token)
+	if err != nil {
+		return false, fmt.Errorf("failed to record webhook token: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to record webhook token: %w", err)
+	}
+
+	return rows == 1, nil
+}
+
+func (r *WebhookTokenRepository) Release(ctx context.Context, token string) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM webhook_tokens WHERE token = $1`, token); err != nil {
+		return fmt.Errorf("failed to release webhook token: %w", err)
+	}
+
+	return nil
+}
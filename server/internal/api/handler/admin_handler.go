@@ -3,43 +3,49 @@ package handler
 import (
 	"log/slog"
 	"net/http"
+	"slices"
 	"strconv"
 
 	"github.com/gorilla/websocket"
 	"github.com/labstack/echo/v4"
+	"github.com/tsntt/footballapi/internal/api/middleware"
 	"github.com/tsntt/footballapi/internal/controller"
 )
 
-var (
-	upgrader = websocket.Upgrader{
-		CheckOrigin: func(r *http.Request) bool {
-			return true
-		},
-	}
-)
-
 type AdminHandler struct {
 	controller *controller.AdminController
+	upgrader   websocket.Upgrader
 }
 
-func NewAdminHandler(controller *controller.AdminController) *AdminHandler {
-	return &AdminHandler{controller: controller}
+// NewAdminHandler only upgrades browser connections from allowedOrigins, the ones CORS allows,
+// another site must not open the WebSocket with the session cookie of an admin
+func NewAdminHandler(controller *controller.AdminController, allowedOrigins []string) *AdminHandler {
+	return &AdminHandler{
+		controller: controller,
+		upgrader: websocket.Upgrader{
+			CheckOrigin: func(r *http.Request) bool {
+				// clients other than browsers send no Origin
+				origin := r.Header.Get("Origin")
+				return origin == "" || slices.Contains(allowedOrigins, origin) || slices.Contains(allowedOrigins, "*")
+			},
+		},
+	}
 }
 
 func (h *AdminHandler) GetMatches(c echo.Context) error {
 	matches, err := h.controller.GetMatches(c.Request().Context())
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		return err
 	}
 
 	return c.JSON(http.StatusOK, matches)
 }
 
 func (h *AdminHandler) WsHandler(c echo.Context) error {
-	ws, err := upgrader.Upgrade(c.Response(), c.Request(), nil)
+	ws, err := h.upgrader.Upgrade(c.Response(), c.Request(), nil)
 	if err != nil {
-		slog.Error("Failed to upgrade to websocket", slog.String("err", err.Error()))
-		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		middleware.Logger(c).Error("Failed to upgrade to websocket", slog.String("err", err.Error()))
+		return err
 	}
 	defer ws.Close()
 
@@ -49,13 +55,13 @@ func (h *AdminHandler) WsHandler(c echo.Context) error {
 	for {
 		_, msg, err := ws.ReadMessage()
 		if err != nil {
-			slog.Info("read:", slog.String("err", err.Error()))
+			middleware.Logger(c).Info("read:", slog.String("err", err.Error()))
 			break
 		}
-		slog.Info("recv:", slog.String("msg", string(msg)))
+		middleware.Logger(c).Info("recv:", slog.String("msg", string(msg)))
 
 		if _, _, err := ws.NextReader(); err != nil {
-			slog.Info("Admin connection lost!")
+			middleware.Logger(c).Info("Admin connection lost!")
 			break
 		}
 	}
@@ -68,14 +74,14 @@ func (h *AdminHandler) BroadcastMatch(c echo.Context) error {
 
 	matchID, err := strconv.Atoi(matchIDstr)
 	if err != nil {
-		slog.Error("Invalid match ID", slog.String("err", err.Error()))
+		middleware.Logger(c).Error("Invalid match ID", slog.String("err", err.Error()))
 		return echo.NewHTTPError(http.StatusBadRequest, "Invalid match ID")
 	}
 
 	response, err := h.controller.BroadcastMatch(c.Request().Context(), matchID)
 	if err != nil {
-		slog.Error("Failed to broadcast match", slog.String("err", err.Error()))
-		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		middleware.Logger(c).Error("Failed to broadcast match", slog.String("err", err.Error()))
+		return err
 	}
 
 	return c.JSON(http.StatusOK, response)
@@ -0,0 +1,79 @@
+package handler_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/labstack/echo/v4"
+	"github.com/tsntt/footballapi/internal/api/handler"
+	"github.com/tsntt/footballapi/internal/api/middleware"
+	"github.com/tsntt/footballapi/internal/api/openapi"
+	"github.com/tsntt/footballapi/internal/controller"
+	"github.com/tsntt/footballapi/pkg/broadcast"
+)
+
+func TestAdminHandler_WsHandler_Origin(t *testing.T) {
+	adminController := controller.NewAdminController(nil, nil, nil, broadcast.NewBroadcastService())
+	adminHandler := handler.NewAdminHandler(adminController, []string{"https://app.example.com"})
+
+	e := echo.New()
+	e.GET("/ws", adminHandler.WsHandler)
+	server := httptest.NewServer(e)
+	defer server.Close()
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+
+	tests := []struct {
+		name       string
+		origin     string
+		wantStatus int
+	}{
+		{"allowed origin", "https://app.example.com", http.StatusSwitchingProtocols},
+		{"no origin", "", http.StatusSwitchingProtocols},
+		{"other site", "https://evil.example.com", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			if tt.origin != "" {
+				header.Set("Origin", tt.origin)
+			}
+
+			conn, resp, err := websocket.DefaultDialer.Dial(url, header)
+			if conn != nil {
+				conn.Close()
+			}
+
+			if resp == nil {
+				t.Fatalf("expected a response, got %v", err)
+			}
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("expected status %d, got %d", tt.wantStatus, resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestAdminHandler_WsHandler_NeedsCredential(t *testing.T) {
+	validator, err := openapi.NewValidator(openapi.Operations)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := echo.New()
+	handlers := &handler.Handlers{Admin: handler.NewAdminHandler(nil, nil)}
+	handler.SetupRoutes(e, handlers, middleware.NewAuthMiddleware(nil, nil, nil, nil), middleware.NewRateLimiter(nil, middleware.RateLimits{}), validator.Middleware())
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/ws", nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected status %d, got %d", http.StatusUnauthorized, rec.Code)
+	}
+}
@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/tsntt/footballapi/internal/api/middleware"
+	"github.com/tsntt/footballapi/internal/controller"
+	"github.com/tsntt/footballapi/internal/dto"
+)
+
+type AdminUserHandler struct {
+	controller *controller.AdminUserController
+}
+
+func NewAdminUserHandler(controller *controller.AdminUserController) *AdminUserHandler {
+	return &AdminUserHandler{controller: controller}
+}
+
+func (h *AdminUserHandler) ListUsers(c echo.Context) error {
+	var req dto.UserListRequest
+	if err := c.Bind(&req); err != nil {
+		middleware.Logger(c).Error("Invalid query parameters", slog.String("err", err.Error()))
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid query parameters")
+	}
+
+	response, err := h.controller.ListUsers(c.Request().Context(), &req)
+	if err != nil {
+		middleware.Logger(c).Error("Failed to list users", slog.String("err", err.Error()))
+		return err
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+func (h *AdminUserHandler) GetUser(c echo.Context) error {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		middleware.Logger(c).Error("Invalid user ID", slog.String("err", err.Error()))
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid user ID")
+	}
+
+	response, err := h.controller.GetUser(c.Request().Context(), userID)
+	if err != nil {
+		middleware.Logger(c).Error("Failed to get user", slog.String("err", err.Error()))
+		return err
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+func (h *AdminUserHandler) SuspendUser(c echo.Context) error {
+	return h.userAction(c, "suspend", h.controller.SuspendUser)
+}
+
+func (h *AdminUserHandler) ReactivateUser(c echo.Context) error {
+	return h.userAction(c, "reactivate", h.controller.ReactivateUser)
+}
+
+func (h *AdminUserHandler) DeleteUser(c echo.Context) error {
+	return h.userAction(c, "delete", h.controller.DeleteUser)
+}
+
+func (h *AdminUserHandler) ListLockouts(c echo.Context) error {
+	lockouts, err := h.controller.ListLockouts(c.Request().Context())
+	if err != nil {
+		middleware.Logger(c).Error("Failed to list lockouts", slog.String("err", err.Error()))
+		return err
+	}
+
+	return c.JSON(http.StatusOK, lockouts)
+}
+
+func (h *AdminUserHandler) ClearLockout(c echo.Context) error {
+	user, err := middleware.GetUserFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	response, err := h.controller.ClearLockout(c.Request().Context(), user.UserID, c.Param("kind"), c.Param("value"))
+	if err != nil {
+		middleware.Logger(c).Error("Failed to clear lockout", slog.String("err", err.Error()))
+		return err
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// userAction runs an admin change on the user in the :id path parameter
+func (h *AdminUserHandler) userAction(
+	c echo.Context,
+	name string,
+	action func(ctx context.Context, actorID, userID int) (*dto.APIResponse, error),
+) error {
+	user, err := middleware.GetUserFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		middleware.Logger(c).Error("Invalid user ID", slog.String("err", err.Error()))
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid user ID")
+	}
+
+	response, err := action(c.Request().Context(), user.UserID, userID)
+	if err != nil {
+		middleware.Logger(c).Error("Failed to "+name+" user", slog.String("err", err.Error()))
+		return err
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
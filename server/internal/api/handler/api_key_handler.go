@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/tsntt/footballapi/internal/api/middleware"
+	"github.com/tsntt/footballapi/internal/controller"
+	"github.com/tsntt/footballapi/internal/dto"
+)
+
+type APIKeyHandler struct {
+	controller *controller.APIKeyController
+}
+
+func NewAPIKeyHandler(controller *controller.APIKeyController) *APIKeyHandler {
+	return &APIKeyHandler{controller: controller}
+}
+
+func (h *APIKeyHandler) CreateKey(c echo.Context) error {
+	user, err := middleware.GetUserFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	var req dto.CreateAPIKeyRequest
+	if err := c.Bind(&req); err != nil {
+		middleware.Logger(c).Error("Invalid request body", slog.String("err", err.Error()))
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	response, err := h.controller.Create(c.Request().Context(), user.UserID, &req)
+	if err != nil {
+		middleware.Logger(c).Error("Failed to create API key", slog.Int("user_id", user.UserID), slog.String("err", err.Error()))
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, response)
+}
+
+func (h *APIKeyHandler) ListKeys(c echo.Context) error {
+	user, err := middleware.GetUserFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	keys, err := h.controller.List(c.Request().Context(), user.UserID)
+	if err != nil {
+		middleware.Logger(c).Error("Failed to list API keys", slog.Int("user_id", user.UserID), slog.String("err", err.Error()))
+		return err
+	}
+
+	return c.JSON(http.StatusOK, keys)
+}
+
+func (h *APIKeyHandler) RevokeKey(c echo.Context) error {
+	user, err := middleware.GetUserFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		middleware.Logger(c).Error("Invalid API key ID", slog.String("err", err.Error()))
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid API key ID")
+	}
+
+	response, err := h.controller.Revoke(c.Request().Context(), user.UserID, id)
+	if err != nil {
+		middleware.Logger(c).Error("Failed to revoke API key", slog.Int("user_id", user.UserID), slog.String("err", err.Error()))
+		return err
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 
 	"github.com/labstack/echo/v4"
+	"github.com/tsntt/footballapi/internal/api/middleware"
 	"github.com/tsntt/footballapi/internal/controller"
 )
 
@@ -19,8 +20,8 @@ func NewChampionshipHandler(controller *controller.ChampionshipController) *Cham
 func (h *ChampionshipHandler) GetChampionships(c echo.Context) error {
 	championships, err := h.controller.GetChampionships(c.Request().Context())
 	if err != nil {
-		slog.Error("Failed to get championships", slog.String("err", err.Error()))
-		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		middleware.Logger(c).Error("Failed to get championships", slog.String("err", err.Error()))
+		return err
 	}
 
 	return c.JSON(http.StatusOK, championships)
@@ -33,8 +34,8 @@ func (h *ChampionshipHandler) GetMatches(c echo.Context) error {
 
 	matches, err := h.controller.GetMatches(c.Request().Context(), championshipID, team, stage)
 	if err != nil {
-		slog.Error("Failed to get matches", slog.String("err", err.Error()))
-		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		middleware.Logger(c).Error("Failed to get matches", slog.String("err", err.Error()))
+		return err
 	}
 
 	return c.JSON(http.StatusOK, matches)
@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/tsntt/footballapi/internal/api/middleware"
+	"github.com/tsntt/footballapi/internal/controller"
+	"github.com/tsntt/footballapi/internal/dto"
+)
+
+type DeliveryHandler struct {
+	controller *controller.DeliveryController
+}
+
+func NewDeliveryHandler(controller *controller.DeliveryController) *DeliveryHandler {
+	return &DeliveryHandler{controller: controller}
+}
+
+// TwilioStatus receives Twilio StatusCallback requests (form encoded)
+func (h *DeliveryHandler) TwilioStatus(c echo.Context) error {
+	form, err := c.FormParams()
+	if err != nil {
+		middleware.Logger(c).Error("Invalid twilio callback body", slog.String("err", err.Error()))
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	params := make(map[string]string, len(form))
+	for key := range form {
+		params[key] = form.Get(key)
+	}
+
+	signature := c.Request().Header.Get("X-Twilio-Signature")
+	if err := h.controller.HandleSMSStatus(c.Request().Context(), params, signature); err != nil {
+		middleware.Logger(c).Error("Failed to handle twilio status callback", slog.String("err", err.Error()))
+		return err
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// MailgunEvents receives Mailgun webhooks (JSON) for delivered, opened, clicked, failed and complained
+func (h *DeliveryHandler) MailgunEvents(c echo.Context) error {
+	var req dto.MailgunWebhookRequest
+	if err := c.Bind(&req); err != nil {
+		middleware.Logger(c).Error("Invalid mailgun webhook body", slog.String("err", err.Error()))
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	if err := h.controller.HandleEmailEvent(c.Request().Context(), &req); err != nil {
+		middleware.Logger(c).Error("Failed to handle mailgun event", slog.String("err", err.Error()))
+		if errors.Is(err, controller.ErrInvalidSignature) {
+			// Mailgun stops retrying on 406
+			return echo.NewHTTPError(http.StatusNotAcceptable, err.Error())
+		}
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+// TwilioInbound receives messages sent to our number, used to honour STOP replies
+func (h *DeliveryHandler) TwilioInbound(c echo.Context) error {
+	form, err := c.FormParams()
+	if err != nil {
+		middleware.Logger(c).Error("Invalid twilio inbound body", slog.String("err", err.Error()))
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	params := make(map[string]string, len(form))
+	for key := range form {
+		params[key] = form.Get(key)
+	}
+
+	signature := c.Request().Header.Get("X-Twilio-Signature")
+	if err := h.controller.HandleSMSInbound(c.Request().Context(), params, signature); err != nil {
+		middleware.Logger(c).Error("Failed to handle twilio inbound message", slog.String("err", err.Error()))
+		return err
+	}
+
+	// Empty TwiML, Twilio already sends the carrier opt-out confirmation
+	return c.Blob(http.StatusOK, echo.MIMEApplicationXML, []byte("<Response></Response>"))
+}
+
+func (h *DeliveryHandler) GetMatchDeliveries(c echo.Context) error {
+	matchID, err := strconv.Atoi(c.Param("match_id"))
+	if err != nil {
+		middleware.Logger(c).Error("Invalid match ID", slog.String("err", err.Error()))
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid match ID")
+	}
+
+	report, err := h.controller.GetMatchDeliveries(c.Request().Context(), matchID)
+	if err != nil {
+		middleware.Logger(c).Error("Failed to get deliveries", slog.String("err", err.Error()))
+		return err
+	}
+
+	return c.JSON(http.StatusOK, report)
+}
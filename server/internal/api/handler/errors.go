@@ -0,0 +1,220 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+	"github.com/tsntt/footballapi/internal/api/middleware"
+	"github.com/tsntt/footballapi/internal/api/openapi"
+	"github.com/tsntt/footballapi/internal/controller"
+	"github.com/tsntt/footballapi/internal/dto"
+	"github.com/tsntt/footballapi/pkg/passwordpolicy"
+)
+
+const mimeProblemJSON = "application/problem+json"
+
+// kindStatuses answers each kind of controller error, in the order they are checked
+var kindStatuses = []struct {
+	kind   error
+	status int
+}{
+	{controller.ErrValidation, http.StatusBadRequest},
+	{controller.ErrUnauthorized, http.StatusUnauthorized},
+	{controller.ErrForbidden, http.StatusForbidden},
+	{controller.ErrNotFound, http.StatusNotFound},
+	{controller.ErrConflict, http.StatusConflict},
+	{controller.ErrTooManyRequests, http.StatusTooManyRequests},
+	{controller.ErrUnavailable, http.StatusServiceUnavailable},
+}
+
+// statusCodes is the code of an error that has none of its own
+var statusCodes = map[int]string{
+	http.StatusBadRequest:            "bad_request",
+	http.StatusUnauthorized:          "unauthorized",
+	http.StatusForbidden:             "forbidden",
+	http.StatusNotFound:              "not_found",
+	http.StatusMethodNotAllowed:      "method_not_allowed",
+	http.StatusNotAcceptable:         "not_acceptable",
+	http.StatusConflict:              "conflict",
+	http.StatusRequestEntityTooLarge: "request_too_large",
+	http.StatusUnsupportedMediaType:  "unsupported_media_type",
+	http.StatusTooManyRequests:       "too_many_requests",
+	http.StatusInternalServerError:   "internal_error",
+	http.StatusServiceUnavailable:    "unavailable",
+}
+
+// HTTPErrorHandler answers every error returned by a handler or a middleware with a
+// problem+json body (RFC 9457). Only what the client may see goes in it, an error the API
+// does not know is logged and answered with a bare 500
+func HTTPErrorHandler(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+
+	problem := newProblem(c, err)
+
+	if problem.Status >= http.StatusInternalServerError {
+		middleware.Logger(c).Error("Request failed", slog.String("err", err.Error()))
+	}
+
+	var throttled *controller.RetryAfterError
+	if errors.As(err, &throttled) {
+		c.Response().Header().Set("Retry-After", strconv.Itoa(throttled.Seconds()))
+	}
+
+	var respErr error
+	if c.Request().Method == http.MethodHead {
+		respErr = c.NoContent(problem.Status)
+	} else {
+		c.Response().Header().Set(echo.HeaderContentType, mimeProblemJSON)
+		respErr = c.JSON(problem.Status, problem)
+	}
+	if respErr != nil {
+		middleware.Logger(c).Error("Failed to send the error", slog.String("err", respErr.Error()))
+	}
+}
+
+func newProblem(c echo.Context, err error) *dto.Problem {
+	problem := &dto.Problem{
+		Status:    http.StatusInternalServerError,
+		Detail:    "Something went wrong, try again later",
+		Instance:  c.Request().URL.Path,
+		RequestID: c.Response().Header().Get(middleware.RequestIDHeader),
+	}
+
+	var (
+		apiErr       *controller.Error
+		policyErr    *passwordpolicy.Error
+		schemaErr    *openapi.ValidationError
+		validateErrs validator.ValidationErrors
+		httpErr      *echo.HTTPError
+	)
+
+	switch {
+	case errors.As(err, &policyErr):
+		problem.Status = http.StatusBadRequest
+		problem.Code = "password_policy"
+		problem.Detail = policyErr.Error()
+		problem.Violations = policyErr.Violations
+	case errors.As(err, &schemaErr):
+		problem.Status = http.StatusBadRequest
+		problem.Code = controller.ErrInvalidRequest.Code
+		problem.Detail = "The request body does not match the schema"
+		problem.Errors = schemaErr.Fields
+	case errors.As(err, &apiErr):
+		problem.Status = kindStatus(apiErr.Kind)
+		problem.Code = apiErr.Code
+		problem.Detail = apiErr.Message
+		if errors.As(err, &validateErrs) {
+			problem.Errors = fieldErrors(validateErrs)
+		}
+	case errors.As(err, &httpErr):
+		problem.Status = httpErr.Code
+		if message, ok := httpErr.Message.(string); ok {
+			problem.Detail = message
+		} else if httpErr.Code < http.StatusInternalServerError {
+			problem.Detail = fmt.Sprint(httpErr.Message)
+		}
+	default:
+		// a kind without a controller error, e.g. a missing row the controller passed on
+		for _, k := range kindStatuses {
+			if errors.Is(err, k.kind) {
+				problem.Status = k.status
+				problem.Detail = k.kind.Error()
+				break
+			}
+		}
+	}
+
+	if problem.Code == "" {
+		problem.Code = statusCodes[problem.Status]
+		if problem.Code == "" {
+			problem.Code = strings.ToLower(strings.ReplaceAll(http.StatusText(problem.Status), " ", "_"))
+		}
+	}
+	problem.Type = "about:blank"
+	problem.Title = http.StatusText(problem.Status)
+
+	return problem
+}
+
+func kindStatus(kind error) int {
+	for _, k := range kindStatuses {
+		if errors.Is(kind, k.kind) {
+			return k.status
+		}
+	}
+	return http.StatusInternalServerError
+}
+
+// fieldErrors points at each field by its JSON name, the validators of the controllers
+// name fields after their json tag
+func fieldErrors(errs validator.ValidationErrors) []dto.FieldError {
+	fields := make([]dto.FieldError, 0, len(errs))
+	for _, fe := range errs {
+		fields = append(fields, dto.FieldError{
+			Field:   fieldPointer(fe.Namespace()),
+			Rule:    fe.Tag(),
+			Message: ruleMessage(fe),
+		})
+	}
+
+	return fields
+}
+
+// fieldPointer turns a namespace such as ProfileRequest.channels[0].address into /channels/0/address
+func fieldPointer(namespace string) string {
+	// the first segment is the struct that was validated
+	_, path, _ := strings.Cut(namespace, ".")
+
+	var b strings.Builder
+	for _, segment := range strings.Split(path, ".") {
+		name, index, indexed := strings.Cut(segment, "[")
+		b.WriteString("/" + name)
+		if indexed {
+			b.WriteString("/" + strings.Trim(strings.ReplaceAll(index, "][", "/"), "]"))
+		}
+	}
+
+	return b.String()
+}
+
+func ruleMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "min", "max", "len":
+		bound := map[string]string{"min": "at least", "max": "at most", "len": "exactly"}[fe.Tag()]
+		switch fe.Kind() {
+		case reflect.String:
+			return fmt.Sprintf("must be %s %s characters long", bound, fe.Param())
+		case reflect.Slice, reflect.Array, reflect.Map:
+			return fmt.Sprintf("must have %s %s items", bound, fe.Param())
+		default:
+			return fmt.Sprintf("must be %s %s", bound, fe.Param())
+		}
+	case "email":
+		return "must be an email address"
+	case "e164":
+		return "must be a phone number in E.164 format, e.g. +5511999999999"
+	case "oneof":
+		return "must be one of " + strings.Join(strings.Fields(fe.Param()), ", ")
+	case "numeric":
+		return "must be a number"
+	case "nefield":
+		return "must differ from " + fe.Param()
+	case "bcp47_language_tag":
+		return "must be a language tag, e.g. pt-BR"
+	case "timezone":
+		return "must be an IANA time zone, e.g. America/Sao_Paulo"
+	default:
+		return "breaks the " + fe.Tag() + " rule"
+	}
+}
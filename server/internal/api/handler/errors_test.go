@@ -0,0 +1,145 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/tsntt/footballapi/internal/api/handler"
+	"github.com/tsntt/footballapi/internal/api/middleware"
+	"github.com/tsntt/footballapi/internal/controller"
+	"github.com/tsntt/footballapi/internal/dto"
+	"github.com/tsntt/footballapi/internal/model"
+	"github.com/tsntt/footballapi/pkg/passwordpolicy"
+)
+
+func TestHTTPErrorHandler(t *testing.T) {
+	// the role is validated before any repository is used
+	_, invalidErr := controller.NewRoleController(nil, nil, nil).AssignRole(context.Background(), 1, 2, &dto.AssignRoleRequest{})
+
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   string
+		wantDetail string
+		check      func(t *testing.T, problem dto.Problem, rec *httptest.ResponseRecorder)
+	}{
+		{
+			name: "validation", err: invalidErr,
+			wantStatus: http.StatusBadRequest, wantCode: "validation_failed", wantDetail: "validation error",
+			check: func(t *testing.T, problem dto.Problem, _ *httptest.ResponseRecorder) {
+				want := []dto.FieldError{{Field: "/role", Rule: "required", Message: "is required"}}
+				if len(problem.Errors) != 1 || problem.Errors[0] != want[0] {
+					t.Errorf("expected %v, got %v", want, problem.Errors)
+				}
+			},
+		},
+		{
+			name:       "not found",
+			err:        controller.ErrUserNotFound.Wrap(fmt.Errorf("user %w", model.ErrNotFound)),
+			wantStatus: http.StatusNotFound, wantCode: "user_not_found", wantDetail: "user not found",
+		},
+		{
+			name:       "conflict",
+			err:        controller.ErrNameTaken.Wrap(errors.New("pq: duplicate key value violates unique constraint")),
+			wantStatus: http.StatusConflict, wantCode: "name_taken", wantDetail: "name is already taken",
+		},
+		{
+			name:       "unavailable",
+			err:        controller.ErrFootballAPIDown.Wrap(errors.New("dial tcp: i/o timeout")),
+			wantStatus: http.StatusServiceUnavailable, wantCode: "football_api_unavailable",
+			wantDetail: "football data provider is unavailable, try again later",
+		},
+		{
+			name:       "bare kind",
+			err:        controller.ErrTooManyRequests,
+			wantStatus: http.StatusTooManyRequests, wantCode: "too_many_requests", wantDetail: controller.ErrTooManyRequests.Error(),
+		},
+		{
+			name:       "retry after",
+			err:        &controller.RetryAfterError{RetryAfter: 1500 * time.Millisecond},
+			wantStatus: http.StatusTooManyRequests, wantCode: "too_many_requests",
+			check: func(t *testing.T, _ dto.Problem, rec *httptest.ResponseRecorder) {
+				if got := rec.Header().Get("Retry-After"); got != "2" {
+					t.Errorf("expected Retry-After 2, got %q", got)
+				}
+			},
+		},
+		{
+			name: "password policy",
+			err: &passwordpolicy.Error{Violations: []passwordpolicy.Violation{
+				{Rule: "min_length", Message: "must be at least 12 characters"},
+			}},
+			wantStatus: http.StatusBadRequest, wantCode: "password_policy",
+			check: func(t *testing.T, problem dto.Problem, _ *httptest.ResponseRecorder) {
+				if len(problem.Violations) != 1 || problem.Violations[0].Rule != "min_length" {
+					t.Errorf("expected the violations, got %v", problem.Violations)
+				}
+			},
+		},
+		{
+			name:       "echo error",
+			err:        echo.NewHTTPError(http.StatusBadRequest, "Invalid user ID"),
+			wantStatus: http.StatusBadRequest, wantCode: "bad_request", wantDetail: "Invalid user ID",
+		},
+		{
+			name:       "internal",
+			err:        fmt.Errorf("failed to get fans: %w", errors.New("pq: connection refused")),
+			wantStatus: http.StatusInternalServerError, wantCode: "internal_error",
+			check: func(t *testing.T, _ dto.Problem, rec *httptest.ResponseRecorder) {
+				if strings.Contains(rec.Body.String(), "pq:") {
+					t.Errorf("expected the cause to stay out of the response, got %s", rec.Body.String())
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := echo.New()
+			e.HTTPErrorHandler = handler.HTTPErrorHandler
+			e.Use(middleware.RequestLogger())
+			e.GET("/users/:id", func(c echo.Context) error { return tt.err })
+
+			req := httptest.NewRequest(http.MethodGet, "/users/7", nil)
+			req.Header.Set(middleware.RequestIDHeader, "edge-1234")
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+			if contentType := rec.Header().Get(echo.HeaderContentType); contentType != "application/problem+json" {
+				t.Errorf("expected application/problem+json, got %q", contentType)
+			}
+
+			var problem dto.Problem
+			if err := json.Unmarshal(rec.Body.Bytes(), &problem); err != nil {
+				t.Fatal(err)
+			}
+			if problem.Code != tt.wantCode {
+				t.Errorf("expected code %q, got %q", tt.wantCode, problem.Code)
+			}
+			if tt.wantDetail != "" && problem.Detail != tt.wantDetail {
+				t.Errorf("expected detail %q, got %q", tt.wantDetail, problem.Detail)
+			}
+			if problem.Status != tt.wantStatus || problem.Title != http.StatusText(tt.wantStatus) || problem.Type != "about:blank" {
+				t.Errorf("expected status, title and type to match, got %+v", problem)
+			}
+			if problem.RequestID != "edge-1234" || problem.Instance != "/users/7" {
+				t.Errorf("expected the request ID and the path, got %+v", problem)
+			}
+			if tt.check != nil {
+				tt.check(t, problem, rec)
+			}
+		})
+	}
+}
@@ -26,7 +26,7 @@ func (h *FanHandler) Subscribe(c echo.Context) error {
 
 	var req dto.FanRequest
 	if err := c.Bind(&req); err != nil {
-		slog.Error("Invalid request body", slog.String("err", err.Error()))
+		middleware.Logger(c).Error("Invalid request body", slog.String("err", err.Error()))
 		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
 	}
 
@@ -34,8 +34,8 @@ func (h *FanHandler) Subscribe(c echo.Context) error {
 
 	response, err := h.controller.Subscribe(c.Request().Context(), &req)
 	if err != nil {
-		slog.Error("Failed to subscribe to team", slog.String("err", err.Error()))
-		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		middleware.Logger(c).Error("Failed to subscribe to team", slog.String("err", err.Error()))
+		return err
 	}
 
 	return c.JSON(http.StatusOK, response)
@@ -49,14 +49,14 @@ func (h *FanHandler) Unsubscribe(c echo.Context) error {
 
 	var req dto.UnsubscribeRequest
 	if err := c.Bind(&req); err != nil {
-		slog.Error("Invalid request body", slog.String("err", err.Error()))
+		middleware.Logger(c).Error("Invalid request body", slog.String("err", err.Error()))
 		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
 	}
 
 	response, err := h.controller.Unsubscribe(c.Request().Context(), user.UserID, &req)
 	if err != nil {
-		slog.Error("Failed to unsubscribe from team", slog.String("err", err.Error()))
-		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		middleware.Logger(c).Error("Failed to unsubscribe from team", slog.String("err", err.Error()))
+		return err
 	}
 
 	return c.JSON(http.StatusOK, response)
@@ -70,9 +70,85 @@ func (h *FanHandler) GetSubscriptions(c echo.Context) error {
 
 	subscriptions, err := h.controller.GetSubscriptions(c.Request().Context(), user.UserID)
 	if err != nil {
-		slog.Error("Failed to get user subscriptions", slog.String("err", err.Error()))
-		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		middleware.Logger(c).Error("Failed to get user subscriptions", slog.String("err", err.Error()))
+		return err
 	}
 
 	return c.JSON(http.StatusOK, subscriptions)
 }
+
+func (h *FanHandler) ResendVerification(c echo.Context) error {
+	user, err := middleware.GetUserFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	var req dto.ChannelRequest
+	if err := c.Bind(&req); err != nil {
+		middleware.Logger(c).Error("Invalid request body", slog.String("err", err.Error()))
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	response, err := h.controller.ResendVerification(c.Request().Context(), user.UserID, &req)
+	if err != nil {
+		middleware.Logger(c).Error("Failed to resend verification code", slog.String("err", err.Error()))
+		return err
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+func (h *FanHandler) VerifyChannel(c echo.Context) error {
+	user, err := middleware.GetUserFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	var req dto.VerifyChannelRequest
+	if err := c.Bind(&req); err != nil {
+		middleware.Logger(c).Error("Invalid request body", slog.String("err", err.Error()))
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	response, err := h.controller.VerifyChannel(c.Request().Context(), user.UserID, &req)
+	if err != nil {
+		middleware.Logger(c).Error("Failed to verify channel", slog.String("err", err.Error()))
+		return err
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// Shown when the unsubscribe link is opened in a browser. GET must not change anything, link
+// scanners open every URL in an email, so the page posts back to confirm (RFC 8058)
+const unsubscribePage = `<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Football APP</title></head>
+<body style="font-family: sans-serif; text-align: center; padding: 40px;">
+    <h2>⚽ Football APP</h2>
+    <p>Deseja parar de receber estas notificações?</p>
+    <form method="POST">
+        <input type="hidden" name="List-Unsubscribe" value="One-Click">
+        <button type="submit">Cancelar inscrição</button>
+    </form>
+</body>
+</html>`
+
+func (h *FanHandler) UnsubscribePage(c echo.Context) error {
+	if c.QueryParam("token") == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Token required")
+	}
+
+	return c.HTML(http.StatusOK, unsubscribePage)
+}
+
+// OneClickUnsubscribe is the target of List-Unsubscribe-Post and of the confirmation page
+func (h *FanHandler) OneClickUnsubscribe(c echo.Context) error {
+	response, err := h.controller.UnsubscribeByToken(c.Request().Context(), c.QueryParam("token"))
+	if err != nil {
+		middleware.Logger(c).Error("Failed to unsubscribe by token", slog.String("err", err.Error()))
+		return err
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,34 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/tsntt/footballapi/pkg/health"
+)
+
+type HealthHandler struct {
+	checker *health.Checker
+}
+
+func NewHealthHandler(checker *health.Checker) *HealthHandler {
+	return &HealthHandler{checker: checker}
+}
+
+// Livez only tells the process is up and serving, a restart would not fix a down dependency
+func (h *HealthHandler) Livez(c echo.Context) error {
+	c.Response().Header().Set("Cache-Control", "no-store")
+	return c.JSON(http.StatusOK, map[string]string{"status": health.StatusOK})
+}
+
+// Readyz answers 503 while a required component is down or the server is shutting down
+func (h *HealthHandler) Readyz(c echo.Context) error {
+	c.Response().Header().Set("Cache-Control", "no-store")
+
+	report := h.checker.Check(c.Request().Context())
+	if !report.Ready() {
+		return c.JSON(http.StatusServiceUnavailable, report)
+	}
+
+	return c.JSON(http.StatusOK, report)
+}
@@ -4,6 +4,9 @@ import (
 	"github.com/labstack/echo/v4"
 	"github.com/tsntt/footballapi/internal/api/middleware"
 	"github.com/tsntt/footballapi/internal/controller"
+	"github.com/tsntt/footballapi/internal/model"
+	"github.com/tsntt/footballapi/pkg/health"
+	"github.com/tsntt/footballapi/pkg/utils"
 )
 
 type Handlers struct {
@@ -11,6 +14,16 @@ type Handlers struct {
 	Championship *ChampionshipHandler
 	Fan          *FanHandler
 	Admin        *AdminHandler
+	Delivery     *DeliveryHandler
+	Role         *RoleHandler
+	AdminUser    *AdminUserHandler
+	Password     *PasswordResetHandler
+	JWKS         *JWKSHandler
+	OAuth        *OAuthHandler
+	APIKey       *APIKeyHandler
+	TwoFactor    *TwoFactorHandler
+	Health       *HealthHandler
+	OpenAPI      *OpenAPIHandler
 }
 
 func NewHandlers(
@@ -18,41 +31,141 @@ func NewHandlers(
 	championshipController *controller.ChampionshipController,
 	fanController *controller.FanController,
 	adminController *controller.AdminController,
+	deliveryController *controller.DeliveryController,
+	roleController *controller.RoleController,
+	adminUserController *controller.AdminUserController,
+	passwordResetController *controller.PasswordResetController,
+	oauthController *controller.OAuthController,
+	apiKeyController *controller.APIKeyController,
+	twoFactorController *controller.TwoFactorController,
+	jwtService *utils.JWTService,
+	healthChecker *health.Checker,
+	sessionCookies *middleware.SessionCookies,
+	appURL string,
+	allowedOrigins []string,
 ) *Handlers {
 	return &Handlers{
-		User:         NewUserHandler(userController),
+		User:         NewUserHandler(userController, sessionCookies),
 		Championship: NewChampionshipHandler(championshipController),
 		Fan:          NewFanHandler(fanController),
-		Admin:        NewAdminHandler(adminController),
+		Admin:        NewAdminHandler(adminController, allowedOrigins),
+		Delivery:     NewDeliveryHandler(deliveryController),
+		Role:         NewRoleHandler(roleController),
+		AdminUser:    NewAdminUserHandler(adminUserController),
+		Password:     NewPasswordResetHandler(passwordResetController),
+		JWKS:         NewJWKSHandler(jwtService),
+		OAuth:        NewOAuthHandler(oauthController, sessionCookies, appURL),
+		APIKey:       NewAPIKeyHandler(apiKeyController),
+		TwoFactor:    NewTwoFactorHandler(twoFactorController, sessionCookies),
+		Health:       NewHealthHandler(healthChecker),
+		OpenAPI:      NewOpenAPIHandler(),
 	}
 }
 
-func SetupRoutes(e *echo.Echo, handlers *Handlers, authMiddleware *middleware.AuthMiddleware) {
+// SetupRoutes registers the routes, validateRequest checks the bodies once the limits and the
+// credential passed so a flood of invalid bodies is limited too
+func SetupRoutes(e *echo.Echo, handlers *Handlers, authMiddleware *middleware.AuthMiddleware, rateLimiter *middleware.RateLimiter, validateRequest echo.MiddlewareFunc) {
+	// Probes
+	e.GET("/livez", handlers.Health.Livez)
+	e.GET("/readyz", handlers.Health.Readyz)
+	// kept for existing monitors, same as /livez
+	e.GET("/health", handlers.Health.Livez)
+
+	// Public [Token verification keys for other services]
+	e.GET("/.well-known/jwks.json", handlers.JWKS.GetKeys)
+
 	apiV1 := e.Group("/api/v1")
-	// Public
+	// Public [API reference, internal/api/openapi lists every route]
+	apiV1.GET("/openapi.json", handlers.OpenAPI.GetSpec)
+	apiV1.GET("/docs", handlers.OpenAPI.Docs)
+
+	// Public [Limited per IP]
 	auth := apiV1.Group("/auth")
+	auth.Use(rateLimiter.Auth(), validateRequest)
 	auth.POST("/register", handlers.User.Register)
 	auth.POST("/login", handlers.User.Login)
 	auth.POST("/logout", handlers.User.Logout)
+	auth.POST("/password/forgot", handlers.Password.ForgotPassword)
+	auth.POST("/password/reset", handlers.Password.ResetPassword)
+
+	// Public [Second login step, authenticated by the challenge token of the first]
+	auth.POST("/2fa/setup", handlers.TwoFactor.SetupLogin)
+	auth.POST("/2fa/verify", handlers.TwoFactor.VerifyLogin)
+
+	// Public [Login with an external provider, the browser is redirected there and back]
+	auth.GET("/oauth/providers", handlers.OAuth.ListProviders)
+	auth.GET("/oauth/:provider", handlers.OAuth.StartLogin)
+	auth.GET("/oauth/:provider/callback", handlers.OAuth.Callback)
+
+	// Public [Provider webhooks, authenticated by signature]
+	webhooks := apiV1.Group("/webhooks")
+	webhooks.Use(validateRequest)
+	webhooks.POST("/twilio/status", handlers.Delivery.TwilioStatus)
+	webhooks.POST("/mailgun/events", handlers.Delivery.MailgunEvents)
+	webhooks.POST("/twilio/inbound", handlers.Delivery.TwilioInbound)
+
+	// Public [One-click unsubscribe, authenticated by signed token]
+	apiV1.GET("/unsubscribe", handlers.Fan.UnsubscribePage)
+	apiV1.POST("/unsubscribe", handlers.Fan.OneClickUnsubscribe)
 
-	// Protected
+	// Protected [API keys need the scope of the route, limited per IP and per user]
 	protected := apiV1.Group("")
-	protected.Use(authMiddleware.JWTAuth())
+	protected.Use(rateLimiter.IP(), authMiddleware.JWTAuth(), rateLimiter.User(), validateRequest)
+	readScope := authMiddleware.RequireScope(model.ScopeRead)
+	writeScope := authMiddleware.RequireScope(model.ScopeWrite)
+	sessionOnly := authMiddleware.DenyAPIKeys()
+
+	// Account
+	protected.GET("/me", handlers.User.GetProfile, readScope)
+	protected.PUT("/me", handlers.User.UpdateProfile, sessionOnly)
+	protected.PUT("/me/password", handlers.User.ChangePassword, sessionOnly)
+	protected.DELETE("/me", handlers.User.DeleteAccount, sessionOnly)
+
+	// Two factor authentication
+	protected.GET("/me/2fa", handlers.TwoFactor.GetStatus, sessionOnly)
+	protected.POST("/me/2fa/setup", handlers.TwoFactor.Setup, sessionOnly)
+	protected.POST("/me/2fa/enable", handlers.TwoFactor.Enable, sessionOnly)
+	protected.POST("/me/2fa/recovery-codes", handlers.TwoFactor.RegenerateRecoveryCodes, sessionOnly)
+	protected.DELETE("/me/2fa", handlers.TwoFactor.Disable, sessionOnly)
+
+	// API keys
+	protected.GET("/me/api-keys", handlers.APIKey.ListKeys, sessionOnly)
+	protected.POST("/me/api-keys", handlers.APIKey.CreateKey, sessionOnly)
+	protected.DELETE("/me/api-keys/:id", handlers.APIKey.RevokeKey, sessionOnly)
 
 	// Championship
-	protected.GET("/championship", handlers.Championship.GetChampionships)
-	protected.GET("/championship/:id/matches", handlers.Championship.GetMatches)
+	protected.GET("/championship", handlers.Championship.GetChampionships, readScope)
+	protected.GET("/championship/:id/matches", handlers.Championship.GetMatches, readScope)
 
 	// Fan
-	protected.POST("/fans", handlers.Fan.Subscribe)
-	protected.DELETE("/fans", handlers.Fan.Unsubscribe)
-	protected.GET("/fans", handlers.Fan.GetSubscriptions)
+	protected.POST("/fans", handlers.Fan.Subscribe, writeScope)
+	protected.DELETE("/fans", handlers.Fan.Unsubscribe, writeScope)
+	protected.GET("/fans", handlers.Fan.GetSubscriptions, readScope)
+	protected.POST("/fans/channels/verify", handlers.Fan.VerifyChannel, writeScope)
+	protected.POST("/fans/channels/resend", handlers.Fan.ResendVerification, writeScope)
 
-	// Protected [Only Admin]
+	// Protected [By permission, limited per IP and per user]
 	admin := apiV1.Group("/admin")
-	admin.Use(authMiddleware.JWTAuth())
-	admin.Use(authMiddleware.AdminAuth())
-	admin.GET("/", handlers.Admin.GetMatches)
-	apiV1.GET("/ws", handlers.Admin.WsHandler)
-	admin.POST("/broadcast/:match_id", handlers.Admin.BroadcastMatch)
+	admin.Use(rateLimiter.IP(), authMiddleware.JWTAuth(), rateLimiter.User(), validateRequest)
+	admin.GET("/", handlers.Admin.GetMatches, authMiddleware.RequirePermission(model.PermBroadcastRead))
+	// JWTAuth also takes the session cookie, browsers cannot set a header on a WebSocket
+	apiV1.GET("/ws", handlers.Admin.WsHandler, rateLimiter.IP(), authMiddleware.JWTAuth(), authMiddleware.RequirePermission(model.PermBroadcastRead))
+	admin.POST("/broadcast/:match_id", handlers.Admin.BroadcastMatch, authMiddleware.RequirePermission(model.PermBroadcastSend), rateLimiter.Broadcast())
+	admin.GET("/broadcast/:match_id/deliveries", handlers.Delivery.GetMatchDeliveries, authMiddleware.RequirePermission(model.PermBroadcastRead))
+
+	// Roles
+	admin.GET("/roles", handlers.Role.GetRoles, authMiddleware.RequirePermission(model.PermUsersManage))
+	admin.PUT("/users/:id/role", handlers.Role.AssignRole, authMiddleware.RequirePermission(model.PermUsersManage))
+
+	// Users
+	manageUsers := authMiddleware.RequirePermission(model.PermUsersManage)
+	admin.GET("/users", handlers.AdminUser.ListUsers, manageUsers)
+	admin.GET("/users/:id", handlers.AdminUser.GetUser, manageUsers)
+	admin.POST("/users/:id/suspend", handlers.AdminUser.SuspendUser, manageUsers)
+	admin.POST("/users/:id/reactivate", handlers.AdminUser.ReactivateUser, manageUsers)
+	admin.DELETE("/users/:id", handlers.AdminUser.DeleteUser, manageUsers)
+
+	// Login lockouts, kind is "account" (value is the user name) or "ip"
+	admin.GET("/lockouts", handlers.AdminUser.ListLockouts, manageUsers)
+	admin.DELETE("/lockouts/:kind/:value", handlers.AdminUser.ClearLockout, manageUsers)
 }
@@ -0,0 +1,23 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/tsntt/footballapi/pkg/utils"
+)
+
+type JWKSHandler struct {
+	jwtService *utils.JWTService
+}
+
+func NewJWKSHandler(jwtService *utils.JWTService) *JWKSHandler {
+	return &JWKSHandler{jwtService: jwtService}
+}
+
+// GetKeys publishes the public signing keys so other services can verify our tokens
+func (h *JWKSHandler) GetKeys(c echo.Context) error {
+	// Short enough for verifiers to see a new key soon after a rotation
+	c.Response().Header().Set("Cache-Control", "public, max-age=300")
+	return c.JSON(http.StatusOK, h.jwtService.JWKS())
+}
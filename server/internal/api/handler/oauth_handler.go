@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/tsntt/footballapi/internal/api/middleware"
+	"github.com/tsntt/footballapi/internal/controller"
+	"github.com/tsntt/footballapi/internal/dto"
+)
+
+// How long the browser has to finish the login at the provider, matches the stored state
+const oauthStateCookieTTL = 10 * time.Minute
+
+type OAuthHandler struct {
+	controller *controller.OAuthController
+	cookies    *middleware.SessionCookies
+	// Client page the callback redirects to, with the outcome
+	callbackURL string
+}
+
+func NewOAuthHandler(controller *controller.OAuthController, cookies *middleware.SessionCookies, appURL string) *OAuthHandler {
+	return &OAuthHandler{
+		controller:  controller,
+		cookies:     cookies,
+		callbackURL: strings.TrimRight(appURL, "/") + "/auth/callback",
+	}
+}
+
+func (h *OAuthHandler) ListProviders(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string][]string{"providers": h.controller.Providers()})
+}
+
+// StartLogin redirects the browser to the provider, ?mode=cookie ends in a cookie session
+func (h *OAuthHandler) StartLogin(c echo.Context) error {
+	mode := c.QueryParam("mode")
+	if mode != "" && mode != "bearer" && mode != "cookie" {
+		return echo.NewHTTPError(http.StatusBadRequest, "mode must be bearer or cookie")
+	}
+
+	authURL, state, err := h.controller.StartLogin(c.Request().Context(), c.Param("provider"), mode == "cookie")
+	if err != nil {
+		middleware.Logger(c).Error("Failed to start login", slog.String("provider", c.Param("provider")), slog.String("err", err.Error()))
+		return err
+	}
+
+	h.cookies.SetOAuthState(c, state, oauthStateCookieTTL)
+	return c.Redirect(http.StatusFound, authURL)
+}
+
+// Callback is where the provider sends the browser back, it always redirects to the client
+func (h *OAuthHandler) Callback(c echo.Context) error {
+	provider := c.Param("provider")
+	state := c.QueryParam("state")
+
+	// e.g. access_denied when the user cancels at the provider
+	if providerErr := c.QueryParam("error"); providerErr != "" {
+		middleware.Logger(c).Info("Login cancelled at provider", slog.String("provider", provider), slog.String("error", providerErr))
+		return h.redirectError(c, "access_denied")
+	}
+
+	cookie, err := c.Cookie(middleware.OAuthStateCookieName)
+	h.cookies.ClearOAuthState(c)
+	if err != nil || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state)) != 1 {
+		middleware.Logger(c).Error("OAuth state does not match the browser", slog.String("provider", provider))
+		return h.redirectError(c, "invalid_state")
+	}
+
+	client := dto.ClientInfo{IP: c.RealIP(), UserAgent: c.Request().UserAgent()}
+	response, err := h.controller.Callback(c.Request().Context(), provider, state, c.QueryParam("code"), client)
+	if err != nil {
+		middleware.Logger(c).Error("Failed to log in with provider", slog.String("provider", provider), slog.String("err", err.Error()))
+		switch {
+		case errors.Is(err, controller.ErrInvalidState):
+			return h.redirectError(c, "invalid_state")
+		case errors.Is(err, controller.ErrUserSuspended):
+			return h.redirectError(c, "suspended")
+		default:
+			return h.redirectError(c, "login_failed")
+		}
+	}
+
+	// 2FA: the client finishes the login at /auth/2fa/verify
+	if response.ChallengeToken != "" {
+		fragment := url.Values{"challenge_token": {response.ChallengeToken}, "two_factor": {response.TwoFactor}}
+		return c.Redirect(http.StatusFound, h.callbackURL+"#"+fragment.Encode())
+	}
+
+	if response.CSRFToken != "" {
+		h.cookies.Set(c, response.Token, response.CSRFToken)
+		return c.Redirect(http.StatusFound, h.callbackURL)
+	}
+
+	// In the fragment, it is not sent to servers nor written to their logs
+	return c.Redirect(http.StatusFound, h.callbackURL+"#"+url.Values{"token": {response.Token}}.Encode())
+}
+
+func (h *OAuthHandler) redirectError(c echo.Context, code string) error {
+	return c.Redirect(http.StatusFound, h.callbackURL+"?"+url.Values{"error": {code}}.Encode())
+}
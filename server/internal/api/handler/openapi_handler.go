@@ -0,0 +1,43 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/tsntt/footballapi/internal/api/openapi"
+)
+
+type OpenAPIHandler struct {
+	spec []byte
+}
+
+func NewOpenAPIHandler() *OpenAPIHandler {
+	// built from Go values only, it cannot fail to marshal
+	spec, _ := json.Marshal(openapi.Document(openapi.Operations))
+	return &OpenAPIHandler{spec: spec}
+}
+
+// GetSpec serves the OpenAPI document, client generators and the reference page read it
+func (h *OpenAPIHandler) GetSpec(c echo.Context) error {
+	c.Response().Header().Set("Cache-Control", "public, max-age=300")
+	return c.JSONBlob(http.StatusOK, h.spec)
+}
+
+func (h *OpenAPIHandler) Docs(c echo.Context) error {
+	return c.HTML(http.StatusOK, docsPage)
+}
+
+// Redoc renders the document in the browser
+const docsPage = `<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="utf-8">
+    <title>Football API</title>
+    <meta name="viewport" content="width=device-width, initial-scale=1">
+</head>
+<body>
+    <redoc spec-url="` + openapi.SpecPath + `"></redoc>
+    <script src="https://cdn.jsdelivr.net/npm/redoc@2.1.5/bundles/redoc.standalone.js"></script>
+</body>
+</html>`
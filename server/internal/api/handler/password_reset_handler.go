@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/tsntt/footballapi/internal/api/middleware"
+	"github.com/tsntt/footballapi/internal/controller"
+	"github.com/tsntt/footballapi/internal/dto"
+)
+
+type PasswordResetHandler struct {
+	controller *controller.PasswordResetController
+}
+
+func NewPasswordResetHandler(controller *controller.PasswordResetController) *PasswordResetHandler {
+	return &PasswordResetHandler{controller: controller}
+}
+
+func (h *PasswordResetHandler) ForgotPassword(c echo.Context) error {
+	var req dto.ForgotPasswordRequest
+	if err := c.Bind(&req); err != nil {
+		middleware.Logger(c).Error("Invalid request body", slog.String("err", err.Error()))
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	response, err := h.controller.ForgotPassword(c.Request().Context(), &req)
+	if err != nil {
+		middleware.Logger(c).Error("Failed to request password reset", slog.String("err", err.Error()))
+		return err
+	}
+
+	// 202, the email may still be on its way (or never sent, on purpose)
+	return c.JSON(http.StatusAccepted, response)
+}
+
+func (h *PasswordResetHandler) ResetPassword(c echo.Context) error {
+	var req dto.ResetPasswordRequest
+	if err := c.Bind(&req); err != nil {
+		middleware.Logger(c).Error("Invalid request body", slog.String("err", err.Error()))
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	response, err := h.controller.ResetPassword(c.Request().Context(), &req)
+	if err != nil {
+		middleware.Logger(c).Error("Failed to reset password", slog.String("err", err.Error()))
+		return err
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
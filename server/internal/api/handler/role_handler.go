@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/tsntt/footballapi/internal/api/middleware"
+	"github.com/tsntt/footballapi/internal/controller"
+	"github.com/tsntt/footballapi/internal/dto"
+)
+
+type RoleHandler struct {
+	controller *controller.RoleController
+}
+
+func NewRoleHandler(controller *controller.RoleController) *RoleHandler {
+	return &RoleHandler{controller: controller}
+}
+
+func (h *RoleHandler) GetRoles(c echo.Context) error {
+	roles, err := h.controller.GetRoles(c.Request().Context())
+	if err != nil {
+		middleware.Logger(c).Error("Failed to get roles", slog.String("err", err.Error()))
+		return err
+	}
+
+	return c.JSON(http.StatusOK, roles)
+}
+
+func (h *RoleHandler) AssignRole(c echo.Context) error {
+	user, err := middleware.GetUserFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		middleware.Logger(c).Error("Invalid user ID", slog.String("err", err.Error()))
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid user ID")
+	}
+
+	var req dto.AssignRoleRequest
+	if err := c.Bind(&req); err != nil {
+		middleware.Logger(c).Error("Invalid request body", slog.String("err", err.Error()))
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	response, err := h.controller.AssignRole(c.Request().Context(), user.UserID, userID, &req)
+	if err != nil {
+		middleware.Logger(c).Error("Failed to assign role", slog.String("err", err.Error()))
+		return err
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,53 @@
+package handler_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/tsntt/footballapi/internal/api/handler"
+	"github.com/tsntt/footballapi/internal/api/middleware"
+	"github.com/tsntt/footballapi/internal/api/openapi"
+)
+
+// TestRoutesDocumented fails when a route is added without documenting it in the spec, or a
+// documented route is gone
+func TestRoutesDocumented(t *testing.T) {
+	validator, err := openapi.NewValidator(openapi.Operations)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := echo.New()
+	// the routes are only registered, no handler runs
+	handler.SetupRoutes(e, &handler.Handlers{}, middleware.NewAuthMiddleware(nil, nil, nil, nil), middleware.NewRateLimiter(nil, middleware.RateLimits{}), validator.Middleware())
+
+	documented := map[string]bool{}
+	for _, op := range openapi.Operations {
+		key := op.Method + " " + op.Path
+		if documented[key] {
+			t.Errorf("%s is documented twice", key)
+		}
+		documented[key] = true
+	}
+
+	registered := map[string]bool{}
+	for _, route := range e.Routes() {
+		// added by Group.Use to answer unknown paths under the group
+		if route.Method == echo.RouteNotFound || strings.HasSuffix(route.Path, "/*") {
+			continue
+		}
+
+		key := route.Method + " " + route.Path
+		registered[key] = true
+		if !documented[key] {
+			t.Errorf("%s is missing from the OpenAPI spec (internal/api/openapi/spec.go)", key)
+		}
+	}
+
+	for key := range documented {
+		if !registered[key] {
+			t.Errorf("%s is documented but not registered", key)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/tsntt/footballapi/internal/api/middleware"
+	"github.com/tsntt/footballapi/internal/controller"
+	"github.com/tsntt/footballapi/internal/dto"
+)
+
+type TwoFactorHandler struct {
+	controller *controller.TwoFactorController
+	cookies    *middleware.SessionCookies
+}
+
+func NewTwoFactorHandler(controller *controller.TwoFactorController, cookies *middleware.SessionCookies) *TwoFactorHandler {
+	return &TwoFactorHandler{controller: controller, cookies: cookies}
+}
+
+func (h *TwoFactorHandler) SetupLogin(c echo.Context) error {
+	var req dto.TwoFactorChallengeRequest
+	if err := c.Bind(&req); err != nil {
+		middleware.Logger(c).Error("Invalid request body", slog.String("err", err.Error()))
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	response, err := h.controller.SetupLogin(c.Request().Context(), &req)
+	if err != nil {
+		middleware.Logger(c).Error("Failed to set up two factor", slog.String("err", err.Error()))
+		return err
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+func (h *TwoFactorHandler) VerifyLogin(c echo.Context) error {
+	var req dto.TwoFactorLoginRequest
+	if err := c.Bind(&req); err != nil {
+		middleware.Logger(c).Error("Invalid request body", slog.String("err", err.Error()))
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	client := dto.ClientInfo{IP: c.RealIP(), UserAgent: c.Request().UserAgent()}
+
+	response, err := h.controller.VerifyLogin(c.Request().Context(), &req, client)
+	if err != nil {
+		middleware.Logger(c).Error("Failed to verify two factor", slog.String("err", err.Error()), slog.String("ip", client.IP))
+		return err
+	}
+
+	return c.JSON(http.StatusOK, sessionResponse(c, h.cookies, response))
+}
+
+func (h *TwoFactorHandler) GetStatus(c echo.Context) error {
+	user, err := middleware.GetUserFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	response, err := h.controller.Status(c.Request().Context(), user.UserID)
+	if err != nil {
+		middleware.Logger(c).Error("Failed to get two factor status", slog.String("err", err.Error()))
+		return err
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+func (h *TwoFactorHandler) Setup(c echo.Context) error {
+	user, err := middleware.GetUserFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	response, err := h.controller.Setup(c.Request().Context(), user.UserID)
+	if err != nil {
+		middleware.Logger(c).Error("Failed to set up two factor", slog.String("err", err.Error()))
+		return err
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+func (h *TwoFactorHandler) Enable(c echo.Context) error {
+	user, err := middleware.GetUserFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	var req dto.TwoFactorCodeRequest
+	if err := c.Bind(&req); err != nil {
+		middleware.Logger(c).Error("Invalid request body", slog.String("err", err.Error()))
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	response, err := h.controller.Enable(c.Request().Context(), user.UserID, &req)
+	if err != nil {
+		middleware.Logger(c).Error("Failed to enable two factor", slog.String("err", err.Error()))
+		return err
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+func (h *TwoFactorHandler) RegenerateRecoveryCodes(c echo.Context) error {
+	user, err := middleware.GetUserFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	var req dto.TwoFactorCodeRequest
+	if err := c.Bind(&req); err != nil {
+		middleware.Logger(c).Error("Invalid request body", slog.String("err", err.Error()))
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	response, err := h.controller.RegenerateRecoveryCodes(c.Request().Context(), user.UserID, &req)
+	if err != nil {
+		middleware.Logger(c).Error("Failed to regenerate recovery codes", slog.String("err", err.Error()))
+		return err
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+func (h *TwoFactorHandler) Disable(c echo.Context) error {
+	user, err := middleware.GetUserFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	var req dto.DisableTwoFactorRequest
+	if err := c.Bind(&req); err != nil {
+		middleware.Logger(c).Error("Invalid request body", slog.String("err", err.Error()))
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	response, err := h.controller.Disable(c.Request().Context(), user.UserID, &req, user.IssuedAt)
+	if err != nil {
+		middleware.Logger(c).Error("Failed to disable two factor", slog.String("err", err.Error()))
+		return err
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
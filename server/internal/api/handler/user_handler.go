@@ -5,29 +5,31 @@ import (
 	"net/http"
 
 	"github.com/labstack/echo/v4"
+	"github.com/tsntt/footballapi/internal/api/middleware"
 	"github.com/tsntt/footballapi/internal/controller"
 	"github.com/tsntt/footballapi/internal/dto"
 )
 
 type UserHandler struct {
 	controller *controller.UserController
+	cookies    *middleware.SessionCookies
 }
 
-func NewUserHandler(controller *controller.UserController) *UserHandler {
-	return &UserHandler{controller: controller}
+func NewUserHandler(controller *controller.UserController, cookies *middleware.SessionCookies) *UserHandler {
+	return &UserHandler{controller: controller, cookies: cookies}
 }
 
 func (h *UserHandler) Register(c echo.Context) error {
 	var req dto.UserRequest
 	if err := c.Bind(&req); err != nil {
-		slog.Error("Invalid request body", slog.String("err", err.Error()))
+		middleware.Logger(c).Error("Invalid request body", slog.String("err", err.Error()))
 		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
 	}
 
 	response, err := h.controller.Register(c.Request().Context(), &req)
 	if err != nil {
-		slog.Error("Failed to register user", slog.String("err", err.Error()))
-		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		middleware.Logger(c).Error("Failed to register user", slog.String("err", err.Error()))
+		return err
 	}
 
 	return c.JSON(http.StatusOK, response)
@@ -36,27 +38,125 @@ func (h *UserHandler) Register(c echo.Context) error {
 func (h *UserHandler) Login(c echo.Context) error {
 	var req dto.UserRequest
 	if err := c.Bind(&req); err != nil {
-		slog.Error("Invalid request body", slog.String("err", err.Error()))
+		middleware.Logger(c).Error("Invalid request body", slog.String("err", err.Error()))
 		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
 	}
 
-	response, err := h.controller.Login(c.Request().Context(), &req)
+	// ?mode=cookie keeps the token in an HttpOnly cookie instead of the response body
+	mode := c.QueryParam("mode")
+	if mode != "" && mode != "bearer" && mode != "cookie" {
+		return echo.NewHTTPError(http.StatusBadRequest, "mode must be bearer or cookie")
+	}
+
+	client := dto.ClientInfo{IP: c.RealIP(), UserAgent: c.Request().UserAgent(), CookieSession: mode == "cookie"}
+
+	response, err := h.controller.Login(c.Request().Context(), &req, client)
 	if err != nil {
-		slog.Error("Failed to login user", slog.String("err", err.Error()))
-		return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
+		middleware.Logger(c).Error("Failed to login user", slog.String("err", err.Error()), slog.String("ip", client.IP))
+		return err
 	}
 
-	// INFO: would be safer to store token in cookie
-	return c.JSON(http.StatusOK, response)
+	return c.JSON(http.StatusOK, sessionResponse(c, h.cookies, response))
 }
 
 func (h *UserHandler) Logout(c echo.Context) error {
-	// INFO: would be safer to remove token from cookie
+	h.cookies.Clear(c)
+
 	response, err := h.controller.Logout(c.Request().Context())
 	if err != nil {
-		slog.Error("Failed to logout user", slog.String("err", err.Error()))
-		return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
+		middleware.Logger(c).Error("Failed to logout user", slog.String("err", err.Error()))
+		return err
 	}
 
 	return c.JSON(http.StatusOK, response)
 }
+
+func (h *UserHandler) GetProfile(c echo.Context) error {
+	user, err := middleware.GetUserFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	profile, err := h.controller.GetProfile(c.Request().Context(), user.UserID)
+	if err != nil {
+		middleware.Logger(c).Error("Failed to get profile", slog.String("err", err.Error()))
+		return err
+	}
+
+	return c.JSON(http.StatusOK, profile)
+}
+
+func (h *UserHandler) UpdateProfile(c echo.Context) error {
+	user, err := middleware.GetUserFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	var req dto.ProfileRequest
+	if err := c.Bind(&req); err != nil {
+		middleware.Logger(c).Error("Invalid request body", slog.String("err", err.Error()))
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	profile, err := h.controller.UpdateProfile(c.Request().Context(), user.UserID, &req, user.IssuedAt)
+	if err != nil {
+		middleware.Logger(c).Error("Failed to update profile", slog.String("err", err.Error()))
+		return err
+	}
+
+	return c.JSON(http.StatusOK, profile)
+}
+
+func (h *UserHandler) ChangePassword(c echo.Context) error {
+	user, err := middleware.GetUserFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	var req dto.ChangePasswordRequest
+	if err := c.Bind(&req); err != nil {
+		middleware.Logger(c).Error("Invalid request body", slog.String("err", err.Error()))
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	response, err := h.controller.ChangePassword(c.Request().Context(), user.UserID, &req, user.IssuedAt, middleware.FromCookie(c))
+	if err != nil {
+		middleware.Logger(c).Error("Failed to change password", slog.String("err", err.Error()))
+		return err
+	}
+
+	return c.JSON(http.StatusOK, sessionResponse(c, h.cookies, response))
+}
+
+func (h *UserHandler) DeleteAccount(c echo.Context) error {
+	user, err := middleware.GetUserFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	var req dto.DeleteAccountRequest
+	if err := c.Bind(&req); err != nil {
+		middleware.Logger(c).Error("Invalid request body", slog.String("err", err.Error()))
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	response, err := h.controller.DeleteAccount(c.Request().Context(), user.UserID, &req, user.IssuedAt)
+	if err != nil {
+		middleware.Logger(c).Error("Failed to delete account", slog.String("err", err.Error()))
+		return err
+	}
+
+	h.cookies.Clear(c)
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// sessionResponse moves the token of a cookie session from the body to the cookies
+func sessionResponse(c echo.Context, cookies *middleware.SessionCookies, response *dto.LoginResponse) *dto.LoginResponse {
+	if response.CSRFToken == "" {
+		return response
+	}
+
+	cookies.Set(c, response.Token, response.CSRFToken)
+	return &dto.LoginResponse{CSRFToken: response.CSRFToken, RecoveryCodes: response.RecoveryCodes}
+}
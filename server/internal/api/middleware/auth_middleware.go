@@ -1,8 +1,11 @@
 package middleware
 
 import (
+	"context"
+	"crypto/subtle"
 	"log/slog"
 	"net/http"
+	"slices"
 	"strings"
 
 	"github.com/labstack/echo/v4"
@@ -10,60 +13,188 @@ import (
 	"github.com/tsntt/footballapi/pkg/utils"
 )
 
+// Resolves whether a role currently grants a permission
+type IPermissionChecker interface {
+	HasPermission(ctx context.Context, role, permission string) (bool, error)
+}
+
+// Checks that the user behind a valid token may still use it
+type ISessionValidator interface {
+	ValidateSession(ctx context.Context, claims *dto.JWTClaims) error
+}
+
+// Resolves the identity behind an "Authorization: ApiKey <key>" header
+type IAPIKeyAuthenticator interface {
+	AuthenticateAPIKey(ctx context.Context, key, ip string) (*dto.JWTClaims, error)
+}
+
 type AuthMiddleware struct {
-	jwtService *utils.JWTService
+	jwtService  *utils.JWTService
+	sessions    ISessionValidator
+	apiKeys     IAPIKeyAuthenticator
+	permissions IPermissionChecker
 }
 
-func NewAuthMiddleware(jwtService *utils.JWTService) *AuthMiddleware {
+func NewAuthMiddleware(jwtService *utils.JWTService, sessions ISessionValidator, apiKeys IAPIKeyAuthenticator, permissions IPermissionChecker) *AuthMiddleware {
 	return &AuthMiddleware{
-		jwtService: jwtService,
+		jwtService:  jwtService,
+		sessions:    sessions,
+		apiKeys:     apiKeys,
+		permissions: permissions,
 	}
 }
 
-// JWTAuth middleware
+// JWTAuth accepts a bearer token in the Authorization header, an API key or the session cookie.
+// Cookie sessions also need the CSRF token on state-changing requests, the browser sends the
+// cookie on its own.
 func (m *AuthMiddleware) JWTAuth() echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
-			authHeader := c.Request().Header.Get("Authorization")
-			if authHeader == "" {
-				slog.Error("Authorization header required")
-				return echo.NewHTTPError(http.StatusUnauthorized, "Authorization header required")
+			if key, ok := strings.CutPrefix(c.Request().Header.Get("Authorization"), "ApiKey "); ok {
+				claims, err := m.apiKeys.AuthenticateAPIKey(c.Request().Context(), key, c.RealIP())
+				if err != nil {
+					Logger(c).Error("Invalid API key", slog.String("err", err.Error()))
+					return echo.NewHTTPError(http.StatusUnauthorized, "Invalid API key")
+				}
+
+				c.Set("user", claims)
+				c.Set(sessionCookieKey, false)
+				addLogFields(c, slog.Int("user_id", claims.UserID), slog.Int("api_key_id", claims.APIKeyID))
+				return next(c)
 			}
 
-			// Check token format "Bearer <token>"
-			parts := strings.Split(authHeader, " ")
-			if len(parts) != 2 || parts[0] != "Bearer" {
-				slog.Error("Invalid authorization header format")
-				return echo.NewHTTPError(http.StatusUnauthorized, "Invalid authorization header format")
+			tokenString, fromCookie, err := tokenFromRequest(c)
+			if err != nil {
+				Logger(c).Error("Missing token", slog.String("err", err.Error()))
+				return err
 			}
 
-			tokenString := parts[1]
 			claims, err := m.jwtService.ValidateToken(tokenString)
 			if err != nil {
-				slog.Error("Invalid token", slog.String("err", err.Error()))
+				Logger(c).Error("Invalid token", slog.String("err", err.Error()))
 				return echo.NewHTTPError(http.StatusUnauthorized, "Invalid token: "+err.Error())
 			}
 
+			if fromCookie && !isSafeMethod(c.Request().Method) && !validCSRF(c, claims) {
+				Logger(c).Error("Invalid CSRF token", slog.Int("user_id", claims.UserID))
+				return echo.NewHTTPError(http.StatusForbidden, "Invalid CSRF token")
+			}
+
+			if err := m.sessions.ValidateSession(c.Request().Context(), claims); err != nil {
+				Logger(c).Error("Invalid session", slog.String("err", err.Error()))
+				return echo.NewHTTPError(http.StatusUnauthorized, "Invalid token: session revoked")
+			}
+
 			// Set claims into context
 			c.Set("user", claims)
+			c.Set(sessionCookieKey, fromCookie)
+			addLogFields(c, slog.Int("user_id", claims.UserID))
 			return next(c)
 		}
 	}
 }
 
-// Check if is admin
-func (m *AuthMiddleware) AdminAuth() echo.MiddlewareFunc {
+// tokenFromRequest prefers the Authorization header, a client sending it is not relying on the cookie
+func tokenFromRequest(c echo.Context) (string, bool, error) {
+	if authHeader := c.Request().Header.Get("Authorization"); authHeader != "" {
+		// Check token format "Bearer <token>"
+		parts := strings.Split(authHeader, " ")
+		if len(parts) != 2 || parts[0] != "Bearer" {
+			return "", false, echo.NewHTTPError(http.StatusUnauthorized, "Invalid authorization header format")
+		}
+		return parts[1], false, nil
+	}
+
+	if cookie, err := c.Cookie(SessionCookieName); err == nil && cookie.Value != "" {
+		return cookie.Value, true, nil
+	}
+
+	return "", false, echo.NewHTTPError(http.StatusUnauthorized, "Authorization header required")
+}
+
+func isSafeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// validCSRF checks the header against the cookie (double-submit) and against the hash in the token,
+// so a CSRF cookie planted by a sibling subdomain does not match another session
+func validCSRF(c echo.Context, claims *dto.JWTClaims) bool {
+	header := c.Request().Header.Get(CSRFHeaderName)
+	cookie, err := c.Cookie(CSRFCookieName)
+	if header == "" || err != nil || claims.CSRFHash == "" {
+		return false
+	}
+
+	if subtle.ConstantTimeCompare([]byte(header), []byte(cookie.Value)) != 1 {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(utils.HashToken(header)), []byte(claims.CSRFHash)) == 1
+}
+
+// RequirePermission only lets through users whose role grants permission, must run after JWTAuth
+func (m *AuthMiddleware) RequirePermission(permission string) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
 			user, ok := c.Get("user").(*dto.JWTClaims)
 			if !ok {
-				slog.Error("User not found in context")
+				Logger(c).Error("User not found in context")
 				return echo.NewHTTPError(http.StatusUnauthorized, "User not found in context")
 			}
 
-			if user.Role != "admin" {
-				slog.Error("Admin access required")
-				return echo.NewHTTPError(http.StatusForbidden, "Admin access required")
+			allowed, err := m.permissions.HasPermission(c.Request().Context(), user.Role, permission)
+			if err != nil {
+				Logger(c).Error("Failed to check permission", slog.String("err", err.Error()))
+				return echo.NewHTTPError(http.StatusInternalServerError, "Failed to check permission")
+			}
+
+			if !allowed {
+				Logger(c).Error("Permission required", slog.String("permission", permission))
+				return echo.NewHTTPError(http.StatusForbidden, "Permission required: "+permission)
+			}
+
+			if user.APIKeyID != 0 && !slices.Contains(user.Scopes, permission) {
+				Logger(c).Error("API key scope required", slog.Int("api_key_id", user.APIKeyID), slog.String("scope", permission))
+				return echo.NewHTTPError(http.StatusForbidden, "API key scope required: "+permission)
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// RequireScope limits API keys to routes their scopes cover, tokens pass through. Must run after JWTAuth.
+func (m *AuthMiddleware) RequireScope(scope string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			user, err := GetUserFromContext(c)
+			if err != nil {
+				return err
+			}
+
+			if user.APIKeyID != 0 && !slices.Contains(user.Scopes, scope) {
+				Logger(c).Error("API key scope required", slog.Int("api_key_id", user.APIKeyID), slog.String("scope", scope))
+				return echo.NewHTTPError(http.StatusForbidden, "API key scope required: "+scope)
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// DenyAPIKeys keeps account management to real sessions, a leaked key must not be able to take
+// over the account or mint more keys. Must run after JWTAuth.
+func (m *AuthMiddleware) DenyAPIKeys() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			user, err := GetUserFromContext(c)
+			if err != nil {
+				return err
+			}
+
+			if user.APIKeyID != 0 {
+				Logger(c).Error("API key used on a session only route", slog.Int("api_key_id", user.APIKeyID))
+				return echo.NewHTTPError(http.StatusForbidden, "Not allowed with an API key")
 			}
 
 			return next(c)
@@ -0,0 +1,175 @@
+package middleware_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/tsntt/footballapi/internal/api/middleware"
+	"github.com/tsntt/footballapi/internal/dto"
+	"github.com/tsntt/footballapi/internal/model"
+	"github.com/tsntt/footballapi/pkg/utils"
+)
+
+type allowSessions struct{}
+
+func (allowSessions) ValidateSession(ctx context.Context, claims *dto.JWTClaims) error {
+	return nil
+}
+
+func TestAuthMiddleware_JWTAuth(t *testing.T) {
+	jms := utils.NewJWTService("secret", 1)
+	user := &model.User{ID: 1, Name: "testuser", Role: "default"}
+
+	bearerToken, _ := jms.GenerateToken(user)
+	cookieToken, _ := jms.GenerateCookieToken(user, utils.HashToken("csrf-1"))
+	otherCookieToken, _ := jms.GenerateCookieToken(user, utils.HashToken("csrf-2"))
+
+	tests := []struct {
+		name           string
+		method         string
+		bearer         string
+		session        string
+		csrfCookie     string
+		csrfHeader     string
+		wantStatus     int
+		wantFromCookie bool
+	}{
+		{name: "no token", method: http.MethodGet, wantStatus: http.StatusUnauthorized},
+		{name: "bearer", method: http.MethodPost, bearer: bearerToken, wantStatus: http.StatusOK},
+		{name: "cookie read", method: http.MethodGet, session: cookieToken, wantStatus: http.StatusOK, wantFromCookie: true},
+		{name: "cookie write without CSRF", method: http.MethodPost, session: cookieToken, wantStatus: http.StatusForbidden},
+		{
+			name: "cookie write with CSRF", method: http.MethodPost, session: cookieToken,
+			csrfCookie: "csrf-1", csrfHeader: "csrf-1", wantStatus: http.StatusOK, wantFromCookie: true,
+		},
+		{
+			name: "header does not match cookie", method: http.MethodDelete, session: cookieToken,
+			csrfCookie: "csrf-1", csrfHeader: "other", wantStatus: http.StatusForbidden,
+		},
+		{
+			// a CSRF cookie planted for another session does not help
+			name: "CSRF of another session", method: http.MethodPut, session: otherCookieToken,
+			csrfCookie: "csrf-1", csrfHeader: "csrf-1", wantStatus: http.StatusForbidden,
+		},
+		{
+			name: "bearer token in the cookie", method: http.MethodPost, session: bearerToken,
+			csrfCookie: "csrf-1", csrfHeader: "csrf-1", wantStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := echo.New()
+			req := httptest.NewRequest(tt.method, "/api/v1/me", nil)
+			if tt.bearer != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.bearer)
+			}
+			if tt.session != "" {
+				req.AddCookie(&http.Cookie{Name: middleware.SessionCookieName, Value: tt.session})
+			}
+			if tt.csrfCookie != "" {
+				req.AddCookie(&http.Cookie{Name: middleware.CSRFCookieName, Value: tt.csrfCookie})
+			}
+			if tt.csrfHeader != "" {
+				req.Header.Set(middleware.CSRFHeaderName, tt.csrfHeader)
+			}
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			fromCookie := false
+			auth := middleware.NewAuthMiddleware(jms, allowSessions{}, nil, nil)
+			err := auth.JWTAuth()(func(c echo.Context) error {
+				fromCookie = middleware.FromCookie(c)
+				return c.NoContent(http.StatusOK)
+			})(c)
+
+			status := rec.Code
+			if he, ok := err.(*echo.HTTPError); ok {
+				status = he.Code
+			}
+
+			if status != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d (%v)", tt.wantStatus, status, err)
+			}
+
+			if fromCookie != tt.wantFromCookie {
+				t.Errorf("expected FromCookie %v, got %v", tt.wantFromCookie, fromCookie)
+			}
+		})
+	}
+}
+
+type fakeAPIKeys struct{}
+
+// AuthenticateAPIKey knows one key, of an admin, scoped to read and broadcast:read
+func (fakeAPIKeys) AuthenticateAPIKey(ctx context.Context, key, ip string) (*dto.JWTClaims, error) {
+	if key != "fapi_good" {
+		return nil, errors.New("invalid api key")
+	}
+	return &dto.JWTClaims{
+		UserID:      1,
+		Role:        "admin",
+		Permissions: []string{model.PermBroadcastRead},
+		APIKeyID:    7,
+		Scopes:      []string{model.ScopeRead, model.PermBroadcastRead},
+	}, nil
+}
+
+type allowAll struct{}
+
+func (allowAll) HasPermission(ctx context.Context, role, permission string) (bool, error) {
+	return true, nil
+}
+
+func TestAuthMiddleware_APIKey(t *testing.T) {
+	jms := utils.NewJWTService("secret", 1)
+	bearerToken, _ := jms.GenerateToken(&model.User{ID: 1, Name: "admin", Role: "admin"})
+	auth := middleware.NewAuthMiddleware(jms, allowSessions{}, fakeAPIKeys{}, allowAll{})
+
+	tests := []struct {
+		name          string
+		authorization string
+		guard         echo.MiddlewareFunc
+		wantStatus    int
+	}{
+		{name: "unknown key", authorization: "ApiKey fapi_bad", guard: auth.RequireScope(model.ScopeRead), wantStatus: http.StatusUnauthorized},
+		{name: "scope granted", authorization: "ApiKey fapi_good", guard: auth.RequireScope(model.ScopeRead), wantStatus: http.StatusOK},
+		{name: "scope missing", authorization: "ApiKey fapi_good", guard: auth.RequireScope(model.ScopeWrite), wantStatus: http.StatusForbidden},
+		{name: "permission in scope", authorization: "ApiKey fapi_good", guard: auth.RequirePermission(model.PermBroadcastRead), wantStatus: http.StatusOK},
+		{
+			// the role has it, the key was not given it
+			name: "permission out of scope", authorization: "ApiKey fapi_good",
+			guard: auth.RequirePermission(model.PermBroadcastSend), wantStatus: http.StatusForbidden,
+		},
+		{name: "session only route", authorization: "ApiKey fapi_good", guard: auth.DenyAPIKeys(), wantStatus: http.StatusForbidden},
+		{name: "token ignores scopes", authorization: "Bearer " + bearerToken, guard: auth.RequireScope(model.ScopeWrite), wantStatus: http.StatusOK},
+		{name: "token on session only route", authorization: "Bearer " + bearerToken, guard: auth.DenyAPIKeys(), wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/fans", nil)
+			req.Header.Set("Authorization", tt.authorization)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			err := auth.JWTAuth()(tt.guard(func(c echo.Context) error {
+				return c.NoContent(http.StatusOK)
+			}))(c)
+
+			status := rec.Code
+			if he, ok := err.(*echo.HTTPError); ok {
+				status = he.Code
+			}
+
+			if status != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d (%v)", tt.wantStatus, status, err)
+			}
+		})
+	}
+}
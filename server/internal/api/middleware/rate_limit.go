@@ -0,0 +1,121 @@
+package middleware
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/tsntt/footballapi/pkg/ratelimit"
+)
+
+// Headers of draft-ietf-httpapi-ratelimit-headers, sent on every limited route
+const (
+	RateLimitLimitHeader     = "RateLimit-Limit"
+	RateLimitRemainingHeader = "RateLimit-Remaining"
+	RateLimitResetHeader     = "RateLimit-Reset"
+	RateLimitPolicyHeader    = "RateLimit-Policy"
+)
+
+type RateLimits struct {
+	// Per IP on the public auth routes
+	Auth ratelimit.Limit
+	// Per user on the protected routes
+	User ratelimit.Limit
+	// Per IP on the protected routes, before the credential is checked
+	IP ratelimit.Limit
+	// Per user on the broadcast route, on top of User
+	Broadcast ratelimit.Limit
+}
+
+// RateLimiter counts requests per IP or per user in a store shared by the limits, a nil store
+// turns every limit off
+type RateLimiter struct {
+	store  ratelimit.Store
+	limits RateLimits
+}
+
+func NewRateLimiter(store ratelimit.Store, limits RateLimits) *RateLimiter {
+	return &RateLimiter{store: store, limits: limits}
+}
+
+// Auth limits each IP on the routes anyone can call, logins are also throttled per account
+func (l *RateLimiter) Auth() echo.MiddlewareFunc {
+	return l.perIP("auth", l.limits.Auth)
+}
+
+// IP limits each IP on the routes that need a credential, a guessed token or API key is
+// refused after it is counted. Must run before JWTAuth
+func (l *RateLimiter) IP() echo.MiddlewareFunc {
+	return l.perIP("ip", l.limits.IP)
+}
+
+// User limits each user, whatever the credential (token, cookie or API key). Must run after JWTAuth
+func (l *RateLimiter) User() echo.MiddlewareFunc {
+	return l.perUser("user", l.limits.User)
+}
+
+// Broadcast limits each admin, every broadcast notifies the fans of two teams. Must run after JWTAuth
+func (l *RateLimiter) Broadcast() echo.MiddlewareFunc {
+	return l.perUser("broadcast", l.limits.Broadcast)
+}
+
+func (l *RateLimiter) perIP(name string, limit ratelimit.Limit) echo.MiddlewareFunc {
+	return l.limit(limit, func(c echo.Context) string {
+		return name + ":ip:" + c.RealIP()
+	})
+}
+
+func (l *RateLimiter) perUser(name string, limit ratelimit.Limit) echo.MiddlewareFunc {
+	return l.limit(limit, func(c echo.Context) string {
+		user, err := GetUserFromContext(c)
+		if err != nil {
+			return name + ":ip:" + c.RealIP()
+		}
+		return name + ":user:" + strconv.Itoa(user.UserID)
+	})
+}
+
+func (l *RateLimiter) limit(limit ratelimit.Limit, key func(c echo.Context) string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		if l.store == nil {
+			return next
+		}
+
+		return func(c echo.Context) error {
+			result, err := l.store.Take(c.Request().Context(), key(c), limit)
+			if err != nil {
+				// an outage of the store must not take the API down with it
+				Logger(c).Warn("Rate limit not applied", slog.String("err", err.Error()))
+				return next(c)
+			}
+
+			reset := int(math.Ceil(result.Reset.Seconds()))
+			setRateLimitHeaders(c, limit, result, reset)
+
+			if !result.Allowed {
+				Logger(c).Warn("Rate limit exceeded", slog.Int("limit", limit.Requests), slog.Duration("window", limit.Window))
+				c.Response().Header().Set("Retry-After", strconv.Itoa(reset))
+				return echo.NewHTTPError(http.StatusTooManyRequests, fmt.Sprintf("Too many requests, try again in %d seconds", reset))
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// setRateLimitHeaders reports the limit closest to running out when a route has several
+func setRateLimitHeaders(c echo.Context, limit ratelimit.Limit, result ratelimit.Result, reset int) {
+	header := c.Response().Header()
+	if current, err := strconv.Atoi(header.Get(RateLimitRemainingHeader)); err == nil && current < result.Remaining {
+		return
+	}
+
+	header.Set(RateLimitLimitHeader, strconv.Itoa(result.Limit))
+	header.Set(RateLimitRemainingHeader, strconv.Itoa(result.Remaining))
+	header.Set(RateLimitResetHeader, strconv.Itoa(reset))
+	header.Set(RateLimitPolicyHeader, fmt.Sprintf("%d;w=%d", limit.Requests, int(limit.Window/time.Second)))
+}
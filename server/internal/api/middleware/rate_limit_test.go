@@ -0,0 +1,135 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/tsntt/footballapi/internal/api/middleware"
+	"github.com/tsntt/footballapi/internal/dto"
+	"github.com/tsntt/footballapi/pkg/ratelimit"
+)
+
+func newRateLimitedEcho(limiter *middleware.RateLimiter) *echo.Echo {
+	ok := func(c echo.Context) error { return c.NoContent(http.StatusNoContent) }
+	// stands in for JWTAuth, the user comes from a header
+	fakeAuth := func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if id, err := strconv.Atoi(c.Request().Header.Get("X-Test-User")); err == nil {
+				c.Set("user", &dto.JWTClaims{UserID: id})
+			}
+			return next(c)
+		}
+	}
+
+	// stands in for JWTAuth refusing a guessed token
+	refuse := func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error { return echo.ErrUnauthorized }
+	}
+
+	e := echo.New()
+	e.GET("/auth/login", ok, limiter.Auth())
+	e.GET("/private", ok, limiter.IP(), refuse)
+	e.GET("/me", ok, fakeAuth, limiter.User())
+	e.POST("/broadcast", ok, fakeAuth, limiter.User(), limiter.Broadcast())
+	return e
+}
+
+func request(e *echo.Echo, method, path, user string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	if user != "" {
+		req.Header.Set("X-Test-User", user)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRateLimiter(t *testing.T) {
+	limiter := middleware.NewRateLimiter(ratelimit.NewMemoryStore(), middleware.RateLimits{
+		Auth:      ratelimit.Limit{Requests: 2, Window: time.Minute},
+		User:      ratelimit.Limit{Requests: 5, Window: time.Minute},
+		IP:        ratelimit.Limit{Requests: 3, Window: time.Minute},
+		Broadcast: ratelimit.Limit{Requests: 1, Window: time.Hour},
+	})
+	e := newRateLimitedEcho(limiter)
+
+	t.Run("per IP", func(t *testing.T) {
+		rec := request(e, http.MethodGet, "/auth/login", "")
+		if rec.Code != http.StatusNoContent {
+			t.Fatalf("expected the first request to pass, got %d", rec.Code)
+		}
+		if rec.Header().Get(middleware.RateLimitLimitHeader) != "2" || rec.Header().Get(middleware.RateLimitRemainingHeader) != "1" {
+			t.Errorf("expected 1 of 2 requests left, got %v", rec.Header())
+		}
+		if got := rec.Header().Get(middleware.RateLimitPolicyHeader); got != "2;w=60" {
+			t.Errorf("expected the policy 2;w=60, got %q", got)
+		}
+
+		request(e, http.MethodGet, "/auth/login", "")
+		rec = request(e, http.MethodGet, "/auth/login", "")
+		if rec.Code != http.StatusTooManyRequests {
+			t.Fatalf("expected 429 past the limit, got %d", rec.Code)
+		}
+		if rec.Header().Get("Retry-After") == "" || rec.Header().Get(middleware.RateLimitRemainingHeader) != "0" {
+			t.Errorf("expected Retry-After and no request left, got %v", rec.Header())
+		}
+	})
+
+	t.Run("per IP before the credential", func(t *testing.T) {
+		for range 3 {
+			if rec := request(e, http.MethodGet, "/private", ""); rec.Code != http.StatusUnauthorized {
+				t.Fatalf("expected the credential to be refused, got %d", rec.Code)
+			}
+		}
+		if rec := request(e, http.MethodGet, "/private", ""); rec.Code != http.StatusTooManyRequests {
+			t.Errorf("expected failed credentials to be limited, got %d", rec.Code)
+		}
+	})
+
+	t.Run("per user", func(t *testing.T) {
+		for range 5 {
+			request(e, http.MethodGet, "/me", "1")
+		}
+		if rec := request(e, http.MethodGet, "/me", "1"); rec.Code != http.StatusTooManyRequests {
+			t.Errorf("expected user 1 to be limited, got %d", rec.Code)
+		}
+		// same IP, another user
+		if rec := request(e, http.MethodGet, "/me", "2"); rec.Code != http.StatusNoContent {
+			t.Errorf("expected user 2 to be counted apart, got %d", rec.Code)
+		}
+	})
+
+	t.Run("broadcast", func(t *testing.T) {
+		rec := request(e, http.MethodPost, "/broadcast", "2")
+		if rec.Code != http.StatusNoContent {
+			t.Fatalf("expected the first broadcast to pass, got %d", rec.Code)
+		}
+		// the stricter limit is reported
+		if rec.Header().Get(middleware.RateLimitLimitHeader) != "1" || rec.Header().Get(middleware.RateLimitRemainingHeader) != "0" {
+			t.Errorf("expected the broadcast limit in the headers, got %v", rec.Header())
+		}
+
+		if rec := request(e, http.MethodPost, "/broadcast", "2"); rec.Code != http.StatusTooManyRequests {
+			t.Errorf("expected the second broadcast to be limited, got %d", rec.Code)
+		}
+	})
+}
+
+func TestRateLimiterDisabled(t *testing.T) {
+	e := newRateLimitedEcho(middleware.NewRateLimiter(nil, middleware.RateLimits{}))
+
+	for range 3 {
+		rec := request(e, http.MethodGet, "/auth/login", "")
+		if rec.Code != http.StatusNoContent {
+			t.Fatalf("expected no limit without a store, got %d", rec.Code)
+		}
+		if rec.Header().Get(middleware.RateLimitLimitHeader) != "" {
+			t.Errorf("expected no rate limit headers, got %v", rec.Header())
+		}
+	}
+}
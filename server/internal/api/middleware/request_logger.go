@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/tsntt/footballapi/pkg/logging"
+	"github.com/tsntt/footballapi/pkg/utils"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const RequestIDHeader = "X-Request-ID"
+
+// an ID from a proxy is kept when it is safe to log as is
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// RequestLogger gives every request an ID, echoed in X-Request-ID, and a logger carrying it
+// (and the trace ID) that handlers and controllers get through the request context. Once the
+// request is done it writes the access log line, with the user_id the auth middleware added.
+// Errors are handed to the HTTPErrorHandler of echo so the line logs the status sent
+func RequestLogger() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+			req := c.Request()
+
+			requestID := req.Header.Get(RequestIDHeader)
+			if !validRequestID.MatchString(requestID) {
+				requestID, _ = utils.GenerateRandomToken(16)
+			}
+			c.Response().Header().Set(RequestIDHeader, requestID)
+
+			args := []any{slog.String("request_id", requestID)}
+			if span := trace.SpanContextFromContext(req.Context()); span.HasTraceID() {
+				args = append(args, slog.String("trace_id", span.TraceID().String()))
+			}
+			addLogFields(c, args...)
+
+			// answered here, the status of the error is only known once the error handler ran
+			if err := next(c); err != nil {
+				c.Error(err)
+			}
+			status := c.Response().Status
+
+			level := slog.LevelInfo
+			if status >= http.StatusInternalServerError {
+				level = slog.LevelError
+			}
+
+			Logger(c).LogAttrs(req.Context(), level, "Request",
+				slog.String("method", req.Method),
+				slog.String("route", c.Path()),
+				// without the query, it carries secrets (unsubscribe tokens, OAuth codes)
+				slog.String("path", req.URL.Path),
+				slog.Int("status", status),
+				slog.Duration("latency", time.Since(start)),
+				slog.String("ip", c.RealIP()),
+				slog.Int64("bytes_out", c.Response().Size),
+			)
+
+			return nil
+		}
+	}
+}
+
+// Logger returns the logger of the request, see RequestLogger
+func Logger(c echo.Context) *slog.Logger {
+	return logging.FromContext(c.Request().Context())
+}
+
+// addLogFields adds attributes to the logger of the request for everything logged after
+func addLogFields(c echo.Context, args ...any) {
+	c.SetRequest(c.Request().WithContext(logging.With(c.Request().Context(), args...)))
+}
@@ -0,0 +1,69 @@
+package middleware_test
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/tsntt/footballapi/internal/api/middleware"
+	"github.com/tsntt/footballapi/pkg/logging"
+)
+
+func TestRequestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger, _ := logging.New(&buf, logging.FormatJSON, "info")
+
+	previous := slog.Default()
+	slog.SetDefault(logger)
+	defer slog.SetDefault(previous)
+
+	e := echo.New()
+	e.Use(middleware.RequestLogger())
+	e.GET("/fans/:id", func(c echo.Context) error {
+		middleware.Logger(c).Error("Failed to get fan")
+		return echo.NewHTTPError(http.StatusNotFound, "fan not found")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/fans/7", nil)
+	req.Header.Set(middleware.RequestIDHeader, "edge-1234")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	if rec.Header().Get(middleware.RequestIDHeader) != "edge-1234" {
+		t.Errorf("expected the request ID of the proxy to be kept, got %q", rec.Header().Get(middleware.RequestIDHeader))
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected the handler log and the access log, got %v", lines)
+	}
+	for _, line := range lines {
+		if !strings.Contains(line, `"request_id":"edge-1234"`) {
+			t.Errorf("expected the request ID in %s", line)
+		}
+	}
+	if !strings.Contains(lines[1], `"route":"/fans/:id"`) || !strings.Contains(lines[1], `"status":404`) {
+		t.Errorf("expected route and status in the access log, got %s", lines[1])
+	}
+
+	// query strings carry tokens and are left out
+	buf.Reset()
+	e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/fans/7?token=secret", nil))
+	if !strings.Contains(buf.String(), `"path":"/fans/7"`) || strings.Contains(buf.String(), "secret") {
+		t.Errorf("expected the path without the query in the access log, got %s", buf.String())
+	}
+
+	// an ID that is not safe to log is replaced
+	req = httptest.NewRequest(http.MethodGet, "/fans/7", nil)
+	req.Header.Set(middleware.RequestIDHeader, "bad id\n")
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	if id := rec.Header().Get(middleware.RequestIDHeader); id == "" || id == "bad id\n" {
+		t.Errorf("expected a generated request ID, got %q", id)
+	}
+}
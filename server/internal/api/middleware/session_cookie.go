@@ -0,0 +1,96 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// HttpOnly, holds the JWT of a cookie session
+	SessionCookieName = "session"
+	// Readable by the web client, which copies it into CSRFHeaderName (double-submit)
+	CSRFCookieName = "csrf_token"
+	CSRFHeaderName = "X-CSRF-Token"
+
+	// Binds an OAuth login to the browser that started it
+	OAuthStateCookieName = "oauth_state"
+
+	sessionCookieKey = "session_cookie"
+	oauthStatePath   = "/api/v1/auth/oauth"
+)
+
+type SessionCookieConfig struct {
+	Secure   bool
+	SameSite http.SameSite
+	// Empty keeps the cookies on the API host
+	Domain string
+	MaxAge time.Duration
+}
+
+// SessionCookies writes the cookies of the cookie session mode
+type SessionCookies struct {
+	config SessionCookieConfig
+}
+
+func NewSessionCookies(config SessionCookieConfig) *SessionCookies {
+	return &SessionCookies{config: config}
+}
+
+// ParseSameSite reads "strict", "lax" or "none", anything else is lax
+func ParseSameSite(value string) http.SameSite {
+	switch strings.ToLower(value) {
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	default:
+		return http.SameSiteLaxMode
+	}
+}
+
+func (s *SessionCookies) Set(c echo.Context, token, csrfToken string) {
+	c.SetCookie(s.cookie(SessionCookieName, token, true, int(s.config.MaxAge.Seconds())))
+	c.SetCookie(s.cookie(CSRFCookieName, csrfToken, false, int(s.config.MaxAge.Seconds())))
+}
+
+func (s *SessionCookies) Clear(c echo.Context) {
+	c.SetCookie(s.cookie(SessionCookieName, "", true, -1))
+	c.SetCookie(s.cookie(CSRFCookieName, "", false, -1))
+}
+
+// SetOAuthState is Lax whatever the session setting, it has to come back on the redirect from the provider
+func (s *SessionCookies) SetOAuthState(c echo.Context, state string, maxAge time.Duration) {
+	cookie := s.cookie(OAuthStateCookieName, state, true, int(maxAge.Seconds()))
+	cookie.Path = oauthStatePath
+	cookie.SameSite = http.SameSiteLaxMode
+	c.SetCookie(cookie)
+}
+
+func (s *SessionCookies) ClearOAuthState(c echo.Context) {
+	cookie := s.cookie(OAuthStateCookieName, "", true, -1)
+	cookie.Path = oauthStatePath
+	cookie.SameSite = http.SameSiteLaxMode
+	c.SetCookie(cookie)
+}
+
+func (s *SessionCookies) cookie(name, value string, httpOnly bool, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		Domain:   s.config.Domain,
+		MaxAge:   maxAge,
+		Secure:   s.config.Secure,
+		HttpOnly: httpOnly,
+		SameSite: s.config.SameSite,
+	}
+}
+
+// FromCookie tells whether the request was authenticated by the session cookie, must run after JWTAuth
+func FromCookie(c echo.Context) bool {
+	fromCookie, _ := c.Get(sessionCookieKey).(bool)
+	return fromCookie
+}
@@ -0,0 +1,141 @@
+package openapi_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/tsntt/footballapi/internal/api/handler"
+	"github.com/tsntt/footballapi/internal/api/openapi"
+)
+
+func newValidatedEcho(t *testing.T) *echo.Echo {
+	t.Helper()
+
+	validator, err := openapi.NewValidator(openapi.Operations)
+	if err != nil {
+		t.Fatalf("expected the schemas to compile, got %v", err)
+	}
+
+	e := echo.New()
+	e.HTTPErrorHandler = handler.HTTPErrorHandler
+	e.Use(validator.Middleware())
+
+	ok := func(c echo.Context) error {
+		// the body is still there for the handler
+		var req map[string]any
+		if err := c.Bind(&req); err != nil {
+			return err
+		}
+		return c.JSON(http.StatusOK, req)
+	}
+	e.POST("/api/v1/auth/register", ok)
+	e.POST("/api/v1/me/api-keys", ok)
+	e.PUT("/api/v1/admin/users/:id/role", ok)
+
+	return e
+}
+
+func TestValidator(t *testing.T) {
+	e := newValidatedEcho(t)
+
+	tests := []struct {
+		name        string
+		path        string
+		method      string
+		contentType string
+		body        string
+		wantStatus  int
+		wantMessage string
+	}{
+		{"valid", "/api/v1/auth/register", http.MethodPost, echo.MIMEApplicationJSON, `{"name":"fan","password":"secret"}`, http.StatusOK, ""},
+		{"missing field", "/api/v1/auth/register", http.MethodPost, echo.MIMEApplicationJSON, `{"name":"fan"}`, http.StatusBadRequest, `"field":"/password","rule":"required"`},
+		{"too short", "/api/v1/auth/register", http.MethodPost, echo.MIMEApplicationJSON, `{"name":"f","password":"secret"}`, http.StatusBadRequest, "/name"},
+		{"wrong type", "/api/v1/auth/register", http.MethodPost, echo.MIMEApplicationJSON, `{"name":5,"password":"secret"}`, http.StatusBadRequest, "/name"},
+		{"malformed", "/api/v1/auth/register", http.MethodPost, echo.MIMEApplicationJSON, `{"name":`, http.StatusBadRequest, "malformed JSON"},
+		{"empty", "/api/v1/auth/register", http.MethodPost, echo.MIMEApplicationJSON, ``, http.StatusBadRequest, "body required"},
+		{"not json", "/api/v1/auth/register", http.MethodPost, echo.MIMETextPlain, `name=fan`, http.StatusUnsupportedMediaType, ""},
+		{"item rules", "/api/v1/me/api-keys", http.MethodPost, echo.MIMEApplicationJSON, `{"name":"ci","scopes":[""]}`, http.StatusBadRequest, `"field":"/scopes/0","rule":"minLength"`},
+		{"out of range", "/api/v1/me/api-keys", http.MethodPost, echo.MIMEApplicationJSON, `{"name":"ci","scopes":["read"],"expires_in_days":400}`, http.StatusBadRequest, "/expires_in_days"},
+		{"path parameters", "/api/v1/admin/users/3/role", http.MethodPut, echo.MIMEApplicationJSON + "; charset=utf-8", `{"role":"admin"}`, http.StatusOK, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set(echo.HeaderContentType, tt.contentType)
+			rec := httptest.NewRecorder()
+
+			e.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantMessage) {
+				t.Errorf("expected %q in %s", tt.wantMessage, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestDocument(t *testing.T) {
+	content, err := json.Marshal(openapi.Document(openapi.Operations))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var doc struct {
+		OpenAPI    string                               `json:"openapi"`
+		Paths      map[string]map[string]map[string]any `json:"paths"`
+		Components struct {
+			Schemas map[string]map[string]any `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(content, &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	if doc.OpenAPI != "3.1.0" {
+		t.Errorf("expected OpenAPI 3.1, got %s", doc.OpenAPI)
+	}
+
+	if _, ok := doc.Paths["/api/v1/admin/users/{id}/role"]["put"]; !ok {
+		t.Error("expected echo parameters to become templates")
+	}
+
+	// filled in by the server, not by the client
+	fan := doc.Components.Schemas["FanRequest"]["properties"].(map[string]any)
+	if _, ok := fan["user_id"]; ok {
+		t.Error("expected user_id to be left out of FanRequest")
+	}
+
+	user := doc.Components.Schemas["User"]["properties"].(map[string]any)
+	if _, ok := user["password"]; ok {
+		t.Error("expected the password to be left out of User")
+	}
+
+	conflict, _ := doc.Paths["/api/v1/fans"]["post"]["responses"].(map[string]any)["409"].(map[string]any)
+	if content, _ := conflict["content"].(map[string]any); content["application/problem+json"] == nil {
+		t.Errorf("expected errors to be documented as problem+json, got %v", conflict)
+	}
+
+	// limited per IP and per user, the webhooks are not
+	for _, path := range []string{"/api/v1/auth/register", "/api/v1/admin/broadcast/{match_id}"} {
+		limited, _ := doc.Paths[path]["post"]["responses"].(map[string]any)["429"].(map[string]any)
+		if headers, _ := limited["headers"].(map[string]any); headers["RateLimit-Remaining"] == nil {
+			t.Errorf("expected %s to document the 429 and its headers, got %v", path, limited)
+		}
+	}
+	if _, ok := doc.Paths["/api/v1/webhooks/mailgun/events"]["post"]["responses"].(map[string]any)["429"]; ok {
+		t.Error("expected the webhooks not to be rate limited")
+	}
+
+	for _, name := range []string{"Problem", "FieldError", "Violation", "LoginResponse", "Match"} {
+		if _, ok := doc.Components.Schemas[name]; !ok {
+			t.Errorf("expected the %s schema", name)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package openapi
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var timeType = reflect.TypeFor[time.Time]()
+
+// schemas turns Go types into JSON Schema (draft 2020-12, as used by OpenAPI 3.1). Named
+// structs go to components and are referenced, the validate tags the controllers check
+// become constraints so the spec and the validation middleware agree with them.
+//
+// A field tagged `openapi:"-"` is left out, for values the server fills in itself.
+type schemas struct {
+	components map[string]any
+}
+
+func newSchemas() *schemas {
+	return &schemas{components: map[string]any{}}
+}
+
+func (s *schemas) of(t reflect.Type) map[string]any {
+	if t.Kind() == reflect.Pointer {
+		inner := s.of(t.Elem())
+		return map[string]any{"anyOf": []any{inner, map[string]any{"type": "null"}}}
+	}
+
+	if t == timeType {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": s.of(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": s.of(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.object(t)
+		}
+		return s.ref(t)
+	default:
+		// interface{}, anything goes
+		return map[string]any{}
+	}
+}
+
+// ref adds a named struct to the components once
+func (s *schemas) ref(t reflect.Type) map[string]any {
+	name := t.Name()
+	if _, ok := s.components[name]; !ok {
+		// set first, a struct may refer to itself
+		s.components[name] = map[string]any{}
+		s.components[name] = s.object(t)
+	}
+
+	return map[string]any{"$ref": "#/components/schemas/" + name}
+}
+
+func (s *schemas) object(t reflect.Type) map[string]any {
+	properties := map[string]any{}
+	var required []string
+	s.fields(t, properties, &required)
+
+	schema := map[string]any{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+
+	return schema
+}
+
+func (s *schemas) fields(t reflect.Type, properties map[string]any, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() || field.Tag.Get("openapi") == "-" {
+			continue
+		}
+
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+
+		// embedded structs without a name are flattened, as encoding/json does
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			s.fields(field.Type, properties, required)
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		schema := s.of(field.Type)
+		if constrain(schema, field.Type, field.Tag.Get("validate")) {
+			*required = append(*required, name)
+		}
+		properties[name] = schema
+	}
+}
+
+// constrain applies the validate rules JSON Schema can express and reports whether the
+// field is required. Rules after dive apply to the items
+func constrain(schema map[string]any, t reflect.Type, tag string) bool {
+	if tag == "" {
+		return false
+	}
+
+	rules, itemRules, _ := strings.Cut(tag, ",dive")
+	if items, ok := schema["items"].(map[string]any); ok && itemRules != "" {
+		constrain(items, t.Elem(), strings.TrimPrefix(itemRules, ","))
+	}
+
+	var (
+		required  bool
+		omitEmpty bool
+		kind      = t.Kind()
+	)
+
+	for _, rule := range strings.Split(rules, ",") {
+		name, param, _ := strings.Cut(rule, "=")
+		n, _ := strconv.Atoi(param)
+
+		switch name {
+		case "required":
+			required = true
+			if kind == reflect.String {
+				schema["minLength"] = 1
+			}
+		case "omitempty":
+			omitEmpty = true
+		case "oneof":
+			values := []any{}
+			for _, value := range strings.Fields(param) {
+				values = append(values, value)
+			}
+			schema["enum"] = values
+		case "email":
+			if !omitEmpty {
+				schema["format"] = "email"
+			}
+		case "e164":
+			schema["pattern"] = optionalPattern(`^\+[1-9][0-9]{1,14}$`, omitEmpty)
+		case "numeric":
+			schema["pattern"] = optionalPattern(`^[0-9]+$`, omitEmpty)
+		case "len":
+			bound(schema, kind, "min", n, omitEmpty)
+			bound(schema, kind, "max", n, omitEmpty)
+		case "min", "max":
+			bound(schema, kind, name, n, omitEmpty)
+		}
+	}
+
+	return required
+}
+
+// bound sets the length, item count or value limit that fits the kind. An empty value is
+// allowed with omitempty, so the lower bounds are left out
+func bound(schema map[string]any, kind reflect.Kind, which string, n int, omitEmpty bool) {
+	if which == "min" && omitEmpty {
+		return
+	}
+
+	var keyword string
+	switch kind {
+	case reflect.String:
+		keyword = which + "Length"
+	case reflect.Slice, reflect.Array:
+		keyword = which + "Items"
+	case reflect.Map:
+		keyword = which + "Properties"
+	default:
+		keyword = map[string]string{"min": "minimum", "max": "maximum"}[which]
+	}
+
+	schema[keyword] = n
+}
+
+func optionalPattern(pattern string, omitEmpty bool) string {
+	if omitEmpty {
+		return "^$|" + pattern
+	}
+
+	return pattern
+}
@@ -0,0 +1,541 @@
+package openapi
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/tsntt/footballapi/internal/dto"
+	"github.com/tsntt/footballapi/internal/model"
+	"github.com/tsntt/footballapi/pkg/health"
+)
+
+// SpecPath is where the document is served
+const SpecPath = "/api/v1/openapi.json"
+
+const (
+	mimeJSON    = "application/json"
+	mimeForm    = "application/x-www-form-urlencoded"
+	mimeProblem = "application/problem+json"
+)
+
+type Auth int
+
+const (
+	Public Auth = iota
+	// A bearer token or a cookie session, API keys are refused
+	Session
+	// A bearer token, a cookie session or an API key with the scope of the route
+	AnyCredential
+)
+
+// Param is a query parameter, path parameters are taken from the path
+type Param struct {
+	Name        string
+	Description string
+	Required    bool
+	// Go value the schema is built from, a string when nil
+	Type any
+	// validate tag, see constrain
+	Validate string
+}
+
+// Operation documents one route of SetupRoutes
+type Operation struct {
+	Method  string
+	Path    string // as registered with echo, e.g. /api/v1/users/:id
+	Tag     string
+	Summary string
+	Auth    Auth
+	// Permission or API key scope the route requires
+	Permission string
+	Scope      string
+	Query      []Param
+	// Go value the request schema is built from, sent as JSON unless BodyType says otherwise
+	Body     any
+	BodyType string
+	// Go value of the response body, none when nil
+	Response     any
+	Status       int
+	ResponseType string
+	// Error statuses, 400 for bodies and 401/403 for credentials are added
+	Errors []int
+	// Other statuses answered with a body
+	Responses map[int]any
+}
+
+// Operations is every route of the API, TestRoutesDocumented keeps it in line with SetupRoutes
+var Operations = []Operation{
+	// Probes
+	{Method: http.MethodGet, Path: "/livez", Tag: "Health", Summary: "Liveness probe", Response: health.Report{}},
+	{
+		Method: http.MethodGet, Path: "/readyz", Tag: "Health", Summary: "Readiness probe, 503 while a required component is down or the server shuts down",
+		Response: health.Report{}, Responses: map[int]any{http.StatusServiceUnavailable: health.Report{}},
+	},
+	{Method: http.MethodGet, Path: "/health", Tag: "Health", Summary: "Same as /livez, kept for existing monitors", Response: health.Report{}},
+	{Method: http.MethodGet, Path: "/.well-known/jwks.json", Tag: "Health", Summary: "Public keys that verify the tokens", Response: dto.JWKSResponse{}},
+
+	// Documentation
+	{Method: http.MethodGet, Path: SpecPath, Tag: "Documentation", Summary: "This document", Response: map[string]any{}},
+	{Method: http.MethodGet, Path: "/api/v1/docs", Tag: "Documentation", Summary: "Reference pages of this document", ResponseType: "text/html"},
+
+	// Authentication
+	{
+		Method: http.MethodPost, Path: "/api/v1/auth/register", Tag: "Authentication", Summary: "Create an account",
+		Body: dto.UserRequest{}, Response: dto.APIResponse{}, Errors: []int{http.StatusConflict},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/v1/auth/login", Tag: "Authentication",
+		Summary: "Log in, with mode=cookie the token is set in the session cookie and the body carries the CSRF token",
+		Query:   []Param{modeParam}, Body: dto.UserRequest{}, Response: dto.LoginResponse{},
+		Errors: []int{http.StatusUnauthorized, http.StatusTooManyRequests},
+	},
+	{Method: http.MethodPost, Path: "/api/v1/auth/logout", Tag: "Authentication", Summary: "Clear the session cookies", Response: dto.APIResponse{}},
+	{
+		Method: http.MethodPost, Path: "/api/v1/auth/password/forgot", Tag: "Authentication", Summary: "Send a password reset link",
+		Body: dto.ForgotPasswordRequest{}, Response: dto.APIResponse{}, Status: http.StatusAccepted,
+	},
+	{
+		Method: http.MethodPost, Path: "/api/v1/auth/password/reset", Tag: "Authentication", Summary: "Set a new password with the token of the reset link",
+		Body: dto.ResetPasswordRequest{}, Response: dto.APIResponse{},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/v1/auth/2fa/setup", Tag: "Authentication", Summary: "Set up the second factor a login requires",
+		Body: dto.TwoFactorChallengeRequest{}, Response: dto.TwoFactorSetupResponse{}, Errors: []int{http.StatusUnauthorized},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/v1/auth/2fa/verify", Tag: "Authentication", Summary: "Finish a login with a TOTP or recovery code",
+		Body: dto.TwoFactorLoginRequest{}, Response: dto.LoginResponse{}, Errors: []int{http.StatusUnauthorized, http.StatusTooManyRequests},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/v1/auth/oauth/providers", Tag: "Authentication", Summary: "Configured login providers",
+		Response: struct {
+			Providers []string `json:"providers"`
+		}{},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/v1/auth/oauth/:provider", Tag: "Authentication", Summary: "Redirect to the login page of the provider",
+		Query: []Param{modeParam}, Status: http.StatusFound, Errors: []int{http.StatusNotFound},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/v1/auth/oauth/:provider/callback", Tag: "Authentication",
+		Summary: "Return from the provider, redirects to the app with the token or an error",
+		Query: []Param{
+			{Name: "state", Required: true},
+			{Name: "code"},
+			{Name: "error", Description: "Set by the provider when the login was refused"},
+		},
+		Status: http.StatusFound,
+	},
+
+	// Webhooks
+	{
+		Method: http.MethodPost, Path: "/api/v1/webhooks/twilio/status", Tag: "Webhooks", Summary: "Twilio SMS status callback, signed with X-Twilio-Signature",
+		Body: struct {
+			MessageSid    string `json:"MessageSid" validate:"required"`
+			MessageStatus string `json:"MessageStatus" validate:"required"`
+			ErrorCode     string `json:"ErrorCode"`
+		}{},
+		BodyType: mimeForm, Status: http.StatusNoContent, Errors: []int{http.StatusForbidden},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/v1/webhooks/mailgun/events", Tag: "Webhooks", Summary: "Mailgun event webhook, signed in the body",
+		Body: dto.MailgunWebhookRequest{}, Errors: []int{http.StatusNotAcceptable},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/v1/webhooks/twilio/inbound", Tag: "Webhooks", Summary: "Twilio inbound SMS, STOP and the other opt-out keywords unsubscribe the sender",
+		Body: struct {
+			From string `json:"From" validate:"required"`
+			Body string `json:"Body"`
+		}{},
+		BodyType: mimeForm, ResponseType: "application/xml", Errors: []int{http.StatusForbidden},
+	},
+
+	// Unsubscribe
+	{
+		Method: http.MethodGet, Path: "/api/v1/unsubscribe", Tag: "Subscriptions", Summary: "Confirmation page of the unsubscribe link",
+		Query: []Param{tokenParam}, ResponseType: "text/html",
+	},
+	{
+		Method: http.MethodPost, Path: "/api/v1/unsubscribe", Tag: "Subscriptions", Summary: "One-click unsubscribe (RFC 8058)",
+		Query: []Param{tokenParam}, Response: dto.APIResponse{},
+	},
+
+	// Account
+	{Method: http.MethodGet, Path: "/api/v1/me", Tag: "Account", Summary: "Profile of the user", Auth: AnyCredential, Scope: model.ScopeRead, Response: model.User{}, Errors: []int{http.StatusNotFound}},
+	{Method: http.MethodPut, Path: "/api/v1/me", Tag: "Account", Summary: "Replace the profile", Auth: Session, Body: dto.ProfileRequest{}, Response: model.User{}},
+	{
+		Method: http.MethodPut, Path: "/api/v1/me/password", Tag: "Account", Summary: "Change the password, other sessions are signed out",
+		Auth: Session, Body: dto.ChangePasswordRequest{}, Response: dto.LoginResponse{},
+	},
+	{Method: http.MethodDelete, Path: "/api/v1/me", Tag: "Account", Summary: "Delete the account", Auth: Session, Body: dto.DeleteAccountRequest{}, Response: dto.APIResponse{}},
+
+	// Two factor authentication
+	{Method: http.MethodGet, Path: "/api/v1/me/2fa", Tag: "Two factor authentication", Summary: "Whether 2FA is on", Auth: Session, Response: dto.TwoFactorStatusResponse{}},
+	{
+		Method: http.MethodPost, Path: "/api/v1/me/2fa/setup", Tag: "Two factor authentication", Summary: "Start setting up 2FA",
+		Auth: Session, Response: dto.TwoFactorSetupResponse{}, Errors: []int{http.StatusConflict},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/v1/me/2fa/enable", Tag: "Two factor authentication", Summary: "Confirm the setup with a code",
+		Auth: Session, Body: dto.TwoFactorCodeRequest{}, Response: dto.RecoveryCodesResponse{}, Errors: []int{http.StatusConflict},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/v1/me/2fa/recovery-codes", Tag: "Two factor authentication", Summary: "Replace the recovery codes",
+		Auth: Session, Body: dto.TwoFactorCodeRequest{}, Response: dto.RecoveryCodesResponse{},
+	},
+	{
+		Method: http.MethodDelete, Path: "/api/v1/me/2fa", Tag: "Two factor authentication", Summary: "Turn 2FA off",
+		Auth: Session, Body: dto.DisableTwoFactorRequest{}, Response: dto.APIResponse{},
+	},
+
+	// API keys
+	{Method: http.MethodGet, Path: "/api/v1/me/api-keys", Tag: "API keys", Summary: "Keys of the user", Auth: Session, Response: []model.APIKey{}},
+	{
+		Method: http.MethodPost, Path: "/api/v1/me/api-keys", Tag: "API keys", Summary: "Create a key, it is only returned here",
+		Auth: Session, Body: dto.CreateAPIKeyRequest{}, Response: dto.CreateAPIKeyResponse{}, Status: http.StatusCreated, Errors: []int{http.StatusConflict},
+	},
+	{Method: http.MethodDelete, Path: "/api/v1/me/api-keys/:id", Tag: "API keys", Summary: "Revoke a key", Auth: Session, Response: dto.APIResponse{}, Errors: []int{http.StatusNotFound}},
+
+	// Championship
+	{Method: http.MethodGet, Path: "/api/v1/championship", Tag: "Championships", Summary: "Available championships", Auth: AnyCredential, Scope: model.ScopeRead, Response: []model.Championship{},
+		Errors: []int{http.StatusServiceUnavailable},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/v1/championship/:id/matches", Tag: "Championships", Summary: "Matches of a championship",
+		Auth: AnyCredential, Scope: model.ScopeRead,
+		Query:    []Param{{Name: "team", Description: "Team name"}, {Name: "stage", Description: "e.g. GROUP_STAGE"}},
+		Response: []model.Match{}, Errors: []int{http.StatusServiceUnavailable},
+	},
+
+	// Fan
+	{
+		Method: http.MethodPost, Path: "/api/v1/fans", Tag: "Subscriptions", Summary: "Subscribe to a team, the address is verified first",
+		Auth: AnyCredential, Scope: model.ScopeWrite, Body: dto.FanRequest{}, Response: dto.APIResponse{},
+		Errors: []int{http.StatusConflict, http.StatusTooManyRequests, http.StatusServiceUnavailable},
+	},
+	{
+		Method: http.MethodDelete, Path: "/api/v1/fans", Tag: "Subscriptions", Summary: "Unsubscribe from a team",
+		Auth: AnyCredential, Scope: model.ScopeWrite, Body: dto.UnsubscribeRequest{}, Response: dto.APIResponse{}, Errors: []int{http.StatusNotFound},
+	},
+	{Method: http.MethodGet, Path: "/api/v1/fans", Tag: "Subscriptions", Summary: "Subscriptions of the user", Auth: AnyCredential, Scope: model.ScopeRead, Response: []model.Fan{}},
+	{
+		Method: http.MethodPost, Path: "/api/v1/fans/channels/verify", Tag: "Subscriptions", Summary: "Verify an address with the code sent to it",
+		Auth: AnyCredential, Scope: model.ScopeWrite, Body: dto.VerifyChannelRequest{}, Response: dto.APIResponse{}, Errors: []int{http.StatusTooManyRequests},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/v1/fans/channels/resend", Tag: "Subscriptions", Summary: "Send a new verification code",
+		Auth: AnyCredential, Scope: model.ScopeWrite, Body: dto.ChannelRequest{}, Response: dto.APIResponse{},
+		Errors: []int{http.StatusTooManyRequests, http.StatusServiceUnavailable},
+	},
+
+	// Admin
+	{Method: http.MethodGet, Path: "/api/v1/admin/", Tag: "Admin", Summary: "Matches that can be broadcast", Auth: AnyCredential, Permission: model.PermBroadcastRead, Response: []model.Match{},
+		Errors: []int{http.StatusNotFound, http.StatusServiceUnavailable},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/v1/ws", Tag: "Admin", Summary: "WebSocket with the progress of broadcasts",
+		Auth: AnyCredential, Permission: model.PermBroadcastRead, Status: http.StatusSwitchingProtocols,
+	},
+	{
+		Method: http.MethodPost, Path: "/api/v1/admin/broadcast/:match_id", Tag: "Admin", Summary: "Notify the fans of both teams of a match",
+		Auth: AnyCredential, Permission: model.PermBroadcastSend, Response: dto.APIResponse{}, Errors: []int{http.StatusServiceUnavailable},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/v1/admin/broadcast/:match_id/deliveries", Tag: "Admin", Summary: "Delivery report of the last broadcast of a match",
+		Auth: AnyCredential, Permission: model.PermBroadcastRead, Response: dto.DeliveryReportResponse{}, Errors: []int{http.StatusNotFound},
+	},
+	{Method: http.MethodGet, Path: "/api/v1/admin/roles", Tag: "Admin", Summary: "Roles and their permissions", Auth: AnyCredential, Permission: model.PermUsersManage, Response: []model.Role{}},
+	{
+		Method: http.MethodPut, Path: "/api/v1/admin/users/:id/role", Tag: "Admin", Summary: "Change the role of a user",
+		Auth: AnyCredential, Permission: model.PermUsersManage, Body: dto.AssignRoleRequest{}, Response: dto.APIResponse{}, Errors: []int{http.StatusNotFound},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/v1/admin/users", Tag: "Admin", Summary: "Search the users",
+		Auth: AnyCredential, Permission: model.PermUsersManage, Query: queryParams(dto.UserListRequest{}), Response: dto.UserListResponse{},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/v1/admin/users/:id", Tag: "Admin", Summary: "A user with their subscriptions, deliveries and audit trail",
+		Auth: AnyCredential, Permission: model.PermUsersManage, Response: dto.UserDetailResponse{}, Errors: []int{http.StatusNotFound},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/v1/admin/users/:id/suspend", Tag: "Admin", Summary: "Suspend a user and revoke their sessions",
+		Auth: AnyCredential, Permission: model.PermUsersManage, Response: dto.APIResponse{}, Errors: []int{http.StatusNotFound},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/v1/admin/users/:id/reactivate", Tag: "Admin", Summary: "Lift a suspension",
+		Auth: AnyCredential, Permission: model.PermUsersManage, Response: dto.APIResponse{}, Errors: []int{http.StatusNotFound},
+	},
+	{
+		Method: http.MethodDelete, Path: "/api/v1/admin/users/:id", Tag: "Admin", Summary: "Delete a user",
+		Auth: AnyCredential, Permission: model.PermUsersManage, Response: dto.APIResponse{}, Errors: []int{http.StatusNotFound},
+	},
+	{Method: http.MethodGet, Path: "/api/v1/admin/lockouts", Tag: "Admin", Summary: "Accounts and IPs locked out after failed logins", Auth: AnyCredential, Permission: model.PermUsersManage, Response: []model.LoginThrottle{}},
+	{
+		Method: http.MethodDelete, Path: "/api/v1/admin/lockouts/:kind/:value", Tag: "Admin", Summary: "Lift a lockout, kind is account (value is the user name) or ip",
+		Auth: AnyCredential, Permission: model.PermUsersManage, Response: dto.APIResponse{},
+	},
+}
+
+var (
+	modeParam = Param{
+		Name: "mode", Description: "cookie for a session cookie, bearer (default) for a token in the body", Validate: "omitempty,oneof=bearer cookie",
+	}
+	tokenParam = Param{Name: "token", Description: "Signed token of the unsubscribe link", Required: true}
+)
+
+// queryParams reads the query tags of a struct bound by echo
+func queryParams(v any) []Param {
+	t := reflect.TypeOf(v)
+	params := make([]Param, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := field.Tag.Get("query")
+		if name == "" {
+			continue
+		}
+
+		params = append(params, Param{
+			Name:     name,
+			Type:     reflect.Zero(field.Type).Interface(),
+			Validate: field.Tag.Get("validate"),
+		})
+	}
+
+	return params
+}
+
+// Document builds the OpenAPI 3.1 document of the operations
+func Document(operations []Operation) map[string]any {
+	s := newSchemas()
+	s.ref(reflect.TypeFor[dto.Problem]())
+
+	paths := map[string]any{}
+	for _, op := range operations {
+		path, pathParams := openAPIPath(op.Path)
+		item, ok := paths[path].(map[string]any)
+		if !ok {
+			item = map[string]any{}
+			paths[path] = item
+		}
+		item[strings.ToLower(op.Method)] = s.operation(op, pathParams)
+	}
+
+	return map[string]any{
+		"openapi": "3.1.0",
+		"info": map[string]any{
+			"title":       "Football API",
+			"version":     "1.0.0",
+			"description": "Championships, matches and match notifications for fans. Errors are answered with a Problem body (RFC 9457), its code tells them apart.",
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": s.components,
+			"securitySchemes": map[string]any{
+				"bearerAuth": map[string]any{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+				"cookieAuth": map[string]any{
+					"type": "apiKey", "in": "cookie", "name": "session",
+					"description": "Session of a login with mode=cookie",
+				},
+				"csrfToken": map[string]any{
+					"type": "apiKey", "in": "header", "name": "X-CSRF-Token",
+					"description": "CSRF token of the cookie session, required on requests that change state",
+				},
+				"apiKeyAuth": map[string]any{
+					"type": "apiKey", "in": "header", "name": "Authorization",
+					"description": "ApiKey <key>, limited to the scopes of the key",
+				},
+			},
+		},
+	}
+}
+
+// openAPIPath turns echo parameters (:id) into template expressions ({id})
+func openAPIPath(path string) (string, []string) {
+	var params []string
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if name, ok := strings.CutPrefix(segment, ":"); ok {
+			params = append(params, name)
+			segments[i] = "{" + name + "}"
+		}
+	}
+
+	return strings.Join(segments, "/"), params
+}
+
+func (s *schemas) operation(op Operation, pathParams []string) map[string]any {
+	operation := map[string]any{
+		"tags":        []string{op.Tag},
+		"summary":     op.Summary,
+		"operationId": operationID(op),
+	}
+
+	description := ""
+	switch {
+	case op.Permission != "":
+		description = fmt.Sprintf("Requires the %s permission, API keys need it as a scope.", op.Permission)
+	case op.Scope != "":
+		description = fmt.Sprintf("API keys need the %s scope.", op.Scope)
+	case op.Auth == Session:
+		description = "Not allowed with an API key."
+	}
+	if description != "" {
+		operation["description"] = description
+	}
+
+	var parameters []any
+	for _, name := range pathParams {
+		schema := map[string]any{"type": "string"}
+		if name == "id" || strings.HasSuffix(name, "_id") {
+			schema = map[string]any{"type": "integer"}
+		}
+		parameters = append(parameters, map[string]any{"name": name, "in": "path", "required": true, "schema": schema})
+	}
+	for _, param := range op.Query {
+		var t reflect.Type = reflect.TypeFor[string]()
+		if param.Type != nil {
+			t = reflect.TypeOf(param.Type)
+		}
+
+		schema := s.of(t)
+		required := constrain(schema, t, param.Validate) || param.Required
+		parameter := map[string]any{"name": param.Name, "in": "query", "required": required, "schema": schema}
+		if param.Description != "" {
+			parameter["description"] = param.Description
+		}
+		parameters = append(parameters, parameter)
+	}
+	if len(parameters) > 0 {
+		operation["parameters"] = parameters
+	}
+
+	if op.Body != nil {
+		bodyType := op.BodyType
+		if bodyType == "" {
+			bodyType = mimeJSON
+		}
+		operation["requestBody"] = map[string]any{
+			"required": true,
+			"content":  map[string]any{bodyType: map[string]any{"schema": s.of(reflect.TypeOf(op.Body))}},
+		}
+	}
+
+	operation["responses"] = s.responses(op)
+
+	switch op.Auth {
+	case Public:
+		operation["security"] = []any{}
+	case Session:
+		operation["security"] = []any{
+			map[string]any{"bearerAuth": []string{}},
+			map[string]any{"cookieAuth": []string{}, "csrfToken": []string{}},
+		}
+	case AnyCredential:
+		operation["security"] = []any{
+			map[string]any{"bearerAuth": []string{}},
+			map[string]any{"cookieAuth": []string{}, "csrfToken": []string{}},
+			map[string]any{"apiKeyAuth": []string{}},
+		}
+	}
+
+	return operation
+}
+
+func (s *schemas) responses(op Operation) map[string]any {
+	status := op.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	success := map[string]any{"description": http.StatusText(status)}
+	switch {
+	case op.Response != nil:
+		responseType := op.ResponseType
+		if responseType == "" {
+			responseType = mimeJSON
+		}
+		success["content"] = map[string]any{responseType: map[string]any{"schema": s.of(reflect.TypeOf(op.Response))}}
+	case op.ResponseType != "":
+		success["content"] = map[string]any{op.ResponseType: map[string]any{"schema": map[string]any{"type": "string"}}}
+	}
+	if status == http.StatusFound {
+		success["headers"] = map[string]any{"Location": map[string]any{"schema": map[string]any{"type": "string", "format": "uri"}}}
+	}
+
+	responses := map[string]any{
+		fmt.Sprint(status): success,
+		"default":          errorResponse("Unexpected error"),
+	}
+
+	for code, body := range op.Responses {
+		responses[fmt.Sprint(code)] = map[string]any{
+			"description": http.StatusText(code),
+			"content":     map[string]any{mimeJSON: map[string]any{"schema": s.of(reflect.TypeOf(body))}},
+		}
+	}
+
+	errors := op.Errors
+	if op.Body != nil || len(op.Query) > 0 || strings.Contains(op.Path, ":") {
+		errors = append(errors, http.StatusBadRequest)
+	}
+	if op.Body != nil && op.BodyType == "" {
+		errors = append(errors, http.StatusUnsupportedMediaType)
+	}
+	if op.Auth != Public {
+		errors = append(errors, http.StatusUnauthorized, http.StatusForbidden)
+	}
+	for _, code := range errors {
+		responses[fmt.Sprint(code)] = errorResponse(http.StatusText(code))
+	}
+
+	if rateLimited(op) {
+		tooManyRequests := errorResponse(http.StatusText(http.StatusTooManyRequests))
+		tooManyRequests["headers"] = rateLimitHeaders()
+		responses[fmt.Sprint(http.StatusTooManyRequests)] = tooManyRequests
+	}
+
+	return responses
+}
+
+// rateLimited follows SetupRoutes, the auth routes are limited per IP and the protected ones per IP and per user
+func rateLimited(op Operation) bool {
+	return op.Auth != Public || strings.HasPrefix(op.Path, "/api/v1/auth/")
+}
+
+func rateLimitHeaders() map[string]any {
+	header := func(typ, description string) map[string]any {
+		return map[string]any{"description": description, "schema": map[string]any{"type": typ}}
+	}
+	return map[string]any{
+		"Retry-After":         header("integer", "Seconds until the window ends"),
+		"RateLimit-Limit":     header("integer", "Requests allowed in the window"),
+		"RateLimit-Remaining": header("integer", "Requests left in the window"),
+		"RateLimit-Reset":     header("integer", "Seconds until the window ends"),
+		"RateLimit-Policy":    header("string", "Requests and window in seconds, e.g. 120;w=60"),
+	}
+}
+
+func errorResponse(description string) map[string]any {
+	return map[string]any{
+		"description": description,
+		"content":     map[string]any{mimeProblem: map[string]any{"schema": map[string]any{"$ref": "#/components/schemas/Problem"}}},
+	}
+}
+
+// operationID is the method and the path in camel case, e.g. getApiV1AdminUsersById
+func operationID(op Operation) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(op.Method))
+
+	for _, segment := range strings.FieldsFunc(op.Path, func(r rune) bool { return r == '/' || r == '.' || r == '-' || r == '_' }) {
+		if name, ok := strings.CutPrefix(segment, ":"); ok {
+			b.WriteString("By")
+			segment = name
+		}
+		b.WriteString(strings.ToUpper(segment[:1]) + segment[1:])
+	}
+
+	return b.String()
+}
@@ -0,0 +1,177 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"github.com/santhosh-tekuri/jsonschema/v6/kind"
+	"github.com/tsntt/footballapi/internal/dto"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// Bodies larger than this are refused before they are parsed
+const maxBodyBytes = 1 << 20
+
+var printer = message.NewPrinter(language.English)
+
+// Validator checks JSON request bodies against the schemas of the document, so a payload
+// the spec does not allow never reaches a handler
+type Validator struct {
+	// method and echo path, e.g. "POST /api/v1/fans"
+	bodies map[string]*jsonschema.Schema
+}
+
+func NewValidator(operations []Operation) (*Validator, error) {
+	doc, err := json.Marshal(Document(operations))
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal the document: %w", err)
+	}
+
+	// the compiler wants the values encoding/json gives for an interface{}
+	resource, err := jsonschema.UnmarshalJSON(bytes.NewReader(doc))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the document: %w", err)
+	}
+
+	compiler := jsonschema.NewCompiler()
+	compiler.DefaultDraft(jsonschema.Draft2020)
+	compiler.AssertFormat()
+	if err := compiler.AddResource("openapi.json", resource); err != nil {
+		return nil, fmt.Errorf("failed to add the document: %w", err)
+	}
+
+	v := &Validator{bodies: map[string]*jsonschema.Schema{}}
+	for _, op := range operations {
+		if op.Body == nil || op.BodyType != "" {
+			continue
+		}
+
+		path, _ := openAPIPath(op.Path)
+		pointer := "/paths/" + escapePointer(path) + "/" + strings.ToLower(op.Method) + "/requestBody/content/" + escapePointer(mimeJSON) + "/schema"
+		schema, err := compiler.Compile("openapi.json#" + pointer)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile the body of %s %s: %w", op.Method, op.Path, err)
+		}
+
+		v.bodies[op.Method+" "+op.Path] = schema
+	}
+
+	return v, nil
+}
+
+// Middleware runs after routing, c.Path() is the route the request matched
+func (v *Validator) Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			schema, ok := v.bodies[c.Request().Method+" "+c.Path()]
+			if !ok {
+				return next(c)
+			}
+
+			req := c.Request()
+			if mediaType, _, _ := mime.ParseMediaType(req.Header.Get(echo.HeaderContentType)); mediaType != echo.MIMEApplicationJSON {
+				return echo.NewHTTPError(http.StatusUnsupportedMediaType, "Content-Type must be application/json")
+			}
+
+			body, err := io.ReadAll(http.MaxBytesReader(c.Response(), req.Body, maxBodyBytes))
+			if err != nil {
+				return echo.NewHTTPError(http.StatusRequestEntityTooLarge, "Request body too large")
+			}
+			// the handler binds it again
+			req.Body = io.NopCloser(bytes.NewReader(body))
+
+			if len(bytes.TrimSpace(body)) == 0 {
+				return echo.NewHTTPError(http.StatusBadRequest, "Request body required")
+			}
+
+			instance, err := jsonschema.UnmarshalJSON(bytes.NewReader(body))
+			if err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body: malformed JSON")
+			}
+
+			if err := schema.Validate(instance); err != nil {
+				return &ValidationError{Fields: fieldErrors(err)}
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// ValidationError is a body the schema refuses, Fields says where and why
+type ValidationError struct {
+	Fields []dto.FieldError
+}
+
+// Error lists the failed rules with where they failed, e.g. "/name: minLength: got 1, want 2"
+func (e *ValidationError) Error() string {
+	problems := make([]string, 0, len(e.Fields))
+	for _, field := range e.Fields {
+		problems = append(problems, field.Field+": "+field.Message)
+	}
+
+	return "invalid request body: " + strings.Join(problems, "; ")
+}
+
+// fieldErrors flattens the failed keywords, a missing or unknown property is reported at
+// the property rather than at the object holding it
+func fieldErrors(err error) []dto.FieldError {
+	validationErr, ok := err.(*jsonschema.ValidationError)
+	if !ok {
+		return []dto.FieldError{{Field: "", Rule: "schema", Message: err.Error()}}
+	}
+
+	var fields []dto.FieldError
+	var walk func(e *jsonschema.ValidationError)
+	walk = func(e *jsonschema.ValidationError) {
+		if len(e.Causes) > 0 {
+			for _, cause := range e.Causes {
+				walk(cause)
+			}
+			return
+		}
+
+		location := ""
+		for _, token := range e.InstanceLocation {
+			location += "/" + escapePointer(token)
+		}
+		keyword := e.ErrorKind.KeywordPath()
+		rule := "schema"
+		if len(keyword) > 0 {
+			rule = keyword[len(keyword)-1]
+		}
+
+		switch k := e.ErrorKind.(type) {
+		case *kind.Required:
+			for _, property := range k.Missing {
+				fields = append(fields, dto.FieldError{Field: pointerTo(location, property), Rule: rule, Message: "is required"})
+			}
+		case *kind.AdditionalProperties:
+			for _, property := range k.Properties {
+				fields = append(fields, dto.FieldError{Field: pointerTo(location, property), Rule: rule, Message: "is not allowed"})
+			}
+		default:
+			fields = append(fields, dto.FieldError{Field: location, Rule: rule, Message: e.ErrorKind.LocalizedString(printer)})
+		}
+	}
+	walk(validationErr)
+
+	return fields
+}
+
+func pointerTo(location, property string) string {
+	return location + "/" + escapePointer(property)
+}
+
+// escapePointer escapes a JSON pointer token (RFC 6901)
+func escapePointer(token string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(token)
+}
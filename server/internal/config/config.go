@@ -1,17 +1,38 @@
 package config
 
 import (
-	"os"
-	"strconv"
+	"time"
 )
 
 type Config struct {
+	// development or production, production refuses default secrets
+	Env         string
+	Log         LogConfig
 	Database    DatabaseConfig
 	JWT         JWTConfig
 	FootballAPI FootballAPIConfig
 	Server      ServerConfig
 	EmailAPI    EmailAPIConfig
 	SMSAPI      SMSAPIConfig
+	Unsubscribe UnsubscribeConfig
+	Password    PasswordPolicyConfig
+	Session     SessionConfig
+	OAuth       OAuthConfig
+	TwoFactor   TwoFactorConfig
+	Metrics     MetricsConfig
+	Tracing     TracingConfig
+	RateLimit   RateLimitConfig
+	Seed        SeedConfig
+
+	// Problems found by Load that do not stop the server (deprecated or unknown variables)
+	Warnings []string
+}
+
+type LogConfig struct {
+	// json (production) or text (a terminal)
+	Format string
+	// debug, info, warn or error
+	Level string
 }
 
 type DatabaseConfig struct {
@@ -21,11 +42,18 @@ type DatabaseConfig struct {
 	Password string
 	Name     string
 	SSLMode  string
+	// Applies the embedded migrations on boot, `server migrate` does it by hand
+	AutoMigrate bool
 }
 
 type JWTConfig struct {
-	Secret       string
-	ExpiresHours int
+	// HS256 signs with Secret, RS256 and EdDSA with rotated keys stored in the database
+	Algorithm     string
+	Secret        string
+	ExpiresHours  int
+	RotationHours int
+	Issuer        string
+	Audience      string
 }
 
 type FootballAPIConfig struct {
@@ -34,67 +62,113 @@ type FootballAPIConfig struct {
 }
 
 type ServerConfig struct {
-	Host string
-	Port string
+	// Address the API listens on, empty for every interface
+	Host      string
+	Port      string
+	PublicURL string
+	// Base URL of the web client, used in links that open a client page (e.g. password reset)
+	AppURL string
+	// Browser origins allowed to call the API with credentials (cookie sessions)
+	AllowedOrigins []string
+	// How long /readyz reports draining before the listener closes on shutdown
+	DrainDelay time.Duration
 }
 
 type EmailAPIConfig struct {
-	APIKey string
-	From   string
+	// Mailgun sending domain
+	Domain            string
+	APIKey            string
+	From              string
+	WebhookSigningKey string
 }
 
 type SMSAPIConfig struct {
-	AccountSID string
-	APIKey     string
-	From       string
-}
-
-func Load() *Config {
-	return &Config{
-		Database: DatabaseConfig{
-			Host:     getEnv("DB_HOST", "localhost"),
-			Port:     getEnvInt("DB_PORT", 5432),
-			User:     getEnv("DB_USER", "postgres"),
-			Password: getEnv("DB_PASS", "change_this_password"),
-			Name:     getEnv("DB_NAME", "football"),
-			SSLMode:  getEnv("DB_SSLMODE", "disable"),
-		},
-		JWT: JWTConfig{
-			Secret:       getEnv("JWT_SECRET", "default-secret-key"),
-			ExpiresHours: getEnvInt("JWT_EXPIRES_HOURS", 24),
-		},
-		FootballAPI: FootballAPIConfig{
-			Token: getEnv("FOOTBALL_API_TOKEN", ""),
-			URL:   getEnv("FOOTBALL_API_URL", "https://api.football-data.org/v4"),
-		},
-		Server: ServerConfig{
-			Host: getEnv("SERVER_DOMAIN", "127.0.0.1"),
-			Port: getEnv("SERVER_PORT", "4000"),
-		},
-		EmailAPI: EmailAPIConfig{
-			APIKey: getEnv("MAILGUN_API_KEY", ""),
-			From:   getEnv("MAILGUN_FROM", ""),
-		},
-		SMSAPI: SMSAPIConfig{
-			AccountSID: getEnv("TWILIO_ACCOUNT_SID", ""),
-			APIKey:     getEnv("TWILIO_API_KEY", ""),
-			From:       getEnv("TWILIO_FROM", ""),
-		},
-	}
-}
-
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
-	}
-	return defaultValue
-}
-
-func getEnvInt(key string, defaultValue int) int {
-	if value := os.Getenv(key); value != "" {
-		if intVal, err := strconv.Atoi(value); err == nil {
-			return intVal
-		}
-	}
-	return defaultValue
+	AccountSID        string
+	AuthToken         string
+	From              string
+	StatusCallbackURL string
+	InboundURL        string
+}
+
+type PasswordPolicyConfig struct {
+	MinLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	// Directory with the Pwned Passwords range files, empty disables the breached check
+	BreachedDatasetDir string
+}
+
+// Cookies of the cookie session mode, see middleware.SessionCookies
+type SessionConfig struct {
+	CookieSecure bool
+	// strict, lax or none
+	CookieSameSite string
+	CookieDomain   string
+}
+
+// Login providers, each one is enabled by setting its client ID
+type OAuthConfig struct {
+	GoogleClientID     string
+	GoogleClientSecret string
+	GitHubClientID     string
+	GitHubClientSecret string
+	// Any other OpenID Connect provider (Keycloak, Auth0...), discovered from its issuer
+	OIDCName         string
+	OIDCIssuerURL    string
+	OIDCClientID     string
+	OIDCClientSecret string
+}
+
+type TwoFactorConfig struct {
+	// Name authenticator apps show for the account
+	Issuer string
+	// Encrypts the TOTP secrets at rest, changing it disables every enrolment
+	EncryptionKey string
+}
+
+// Prometheus metrics are served on their own listener, away from the public port
+type MetricsConfig struct {
+	// host:port of the listener, empty disables /metrics
+	Addr string
+	// Bearer token the scraper must send, empty leaves the listener open
+	Token string
+}
+
+// OpenTelemetry traces, the OTLP endpoint and headers come from the standard OTEL_EXPORTER_OTLP_* variables
+type TracingConfig struct {
+	// none, otlp or stdout
+	Exporter    string
+	ServiceName string
+}
+
+// Requests allowed per window, by IP on the public auth routes and by IP then by user on the protected ones
+type RateLimitConfig struct {
+	Enabled bool
+	// memory counts per instance, redis shares the counts between replicas
+	Store    string
+	RedisURL string
+
+	AuthRequests int
+	AuthWindow   time.Duration
+	UserRequests int
+	UserWindow   time.Duration
+	// Counted before the credential is checked, so guessing tokens and API keys is limited too
+	IPRequests int
+	IPWindow   time.Duration
+	// Each broadcast notifies every fan of two teams, on top of the user limit
+	BroadcastRequests int
+	BroadcastWindow   time.Duration
+}
+
+// First admin created by `server seed`, nothing is created without a password
+type SeedConfig struct {
+	AdminName     string
+	AdminPassword string
+}
+
+type UnsubscribeConfig struct {
+	Secret       string
+	ExpiresHours int
 }
@@ -0,0 +1,285 @@
+package config_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tsntt/footballapi/internal/config"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestLoad_Defaults(t *testing.T) {
+	cfg, err := config.Load(config.Options{Environ: []string{}})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if cfg.Database.Port != 5432 || cfg.Server.Port != "4000" || !cfg.Database.AutoMigrate {
+		t.Errorf("unexpected defaults: %+v", cfg)
+	}
+
+	// derived from other settings when not set
+	if cfg.JWT.Issuer != cfg.Server.PublicURL || cfg.Unsubscribe.Secret != cfg.JWT.Secret {
+		t.Errorf("expected derived defaults, got issuer %q and unsubscribe secret %q", cfg.JWT.Issuer, cfg.Unsubscribe.Secret)
+	}
+
+	if len(cfg.Server.AllowedOrigins) != 1 || cfg.Server.AllowedOrigins[0] != cfg.Server.AppURL {
+		t.Errorf("expected the app URL as the only origin, got %v", cfg.Server.AllowedOrigins)
+	}
+}
+
+func TestLoad_Layers(t *testing.T) {
+	file := writeFile(t, "config.yaml", `
+database:
+  host: from-file
+  port: 5433
+  name: from-file
+server:
+  allowed_origins: [https://a.example.com, https://b.example.com]
+`)
+
+	cfg, err := config.Load(config.Options{
+		File:      file,
+		Environ:   []string{"DB_PORT=5434", "DB_NAME=from-env"},
+		Overrides: []string{"database.name=from-flag"},
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if cfg.Database.Host != "from-file" {
+		t.Errorf("expected the host of the file, got %q", cfg.Database.Host)
+	}
+	if cfg.Database.Port != 5434 {
+		t.Errorf("expected the environment to override the file, got %d", cfg.Database.Port)
+	}
+	if cfg.Database.Name != "from-flag" {
+		t.Errorf("expected --set to override the environment, got %q", cfg.Database.Name)
+	}
+	if !reflect.DeepEqual(cfg.Server.AllowedOrigins, []string{"https://a.example.com", "https://b.example.com"}) {
+		t.Errorf("unexpected origins %v", cfg.Server.AllowedOrigins)
+	}
+}
+
+func TestLoad_TOML(t *testing.T) {
+	file := writeFile(t, "config.toml", `
+[jwt]
+algorithm = "EdDSA"
+expires_hours = 12
+`)
+
+	cfg, err := config.Load(config.Options{Environ: []string{"CONFIG_FILE=" + file}})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if cfg.JWT.Algorithm != "EdDSA" || cfg.JWT.ExpiresHours != 12 {
+		t.Errorf("expected the values of the file, got %+v", cfg.JWT)
+	}
+}
+
+func TestLoad_SecretFile(t *testing.T) {
+	secret := writeFile(t, "jwt_secret", "from-docker-secret\n")
+
+	cfg, err := config.Load(config.Options{Environ: []string{"JWT_SECRET_FILE=" + secret}})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if cfg.JWT.Secret != "from-docker-secret" {
+		t.Errorf("expected the secret without the newline, got %q", cfg.JWT.Secret)
+	}
+
+	_, err = config.Load(config.Options{Environ: []string{"JWT_SECRET=inline", "JWT_SECRET_FILE=" + secret}})
+	if err == nil {
+		t.Error("expected an error when both JWT_SECRET and JWT_SECRET_FILE are set")
+	}
+}
+
+func TestLoad_InvalidValues(t *testing.T) {
+	file := writeFile(t, "config.yaml", "database:\n  hots: db\n")
+
+	_, err := config.Load(config.Options{
+		File:      file,
+		Environ:   []string{"DB_PORT=five", "SESSION_COOKIE_SECURE=maybe"},
+		Overrides: []string{"jwt.secrte=x"},
+	})
+	if err == nil {
+		t.Fatal("expected an error, got nil")
+	}
+
+	for _, want := range []string{"DB_PORT", "SESSION_COOKIE_SECURE", "did you mean database.host?", "did you mean jwt.secret?"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in %q", want, err.Error())
+		}
+	}
+}
+
+func TestLoad_MismatchedVariables(t *testing.T) {
+	cfg, err := config.Load(config.Options{Environ: []string{
+		"TWILIO_API_KEY=token",
+		"TWILIO_FROM=+15550001111",
+		"MAILGUN_APIKEY=key",
+		"GOOSE_DRIVER=postgres",
+	}})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if cfg.SMSAPI.AuthToken != "token" || cfg.SMSAPI.From != "+15550001111" {
+		t.Errorf("expected the old names to still be read, got %+v", cfg.SMSAPI)
+	}
+
+	warnings := strings.Join(cfg.Warnings, "\n")
+	for _, want := range []string{"TWILIO_API_KEY is deprecated", "TWILIO_FROM is deprecated", "did you mean MAILGUN_API_KEY?"} {
+		if !strings.Contains(warnings, want) {
+			t.Errorf("expected %q in %q", want, warnings)
+		}
+	}
+
+	if strings.Contains(warnings, "GOOSE_DRIVER") {
+		t.Error("expected variables of other tools to be left alone")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cfg, _ := config.Load(config.Options{Environ: []string{}})
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("expected the defaults to be valid in development, got %v", err)
+	}
+
+	cfg, _ = config.Load(config.Options{Environ: []string{"LOG_LEVEL=loud", "SERVER_PORT=http"}})
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected an invalid log level and port to be reported")
+	}
+
+	cfg, _ = config.Load(config.Options{Environ: []string{"RATE_LIMIT_STORE=redis", "RATE_LIMIT_USER_REQUESTS=0"}})
+	err := cfg.Validate()
+	for _, want := range []string{"rate_limit.redis_url", "rate_limit.user_requests"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in %v", want, err)
+		}
+	}
+}
+
+func TestValidate_Production(t *testing.T) {
+	cfg, _ := config.Load(config.Options{Environ: []string{
+		"APP_ENV=production",
+		"JWT_SECRET=your-super-secret-jwt-key-here",
+	}})
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("expected the example secrets to be refused in production")
+	}
+
+	for _, want := range []string{"database.password", "unsubscribe.secret", "two_factor.encryption_key"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in %q", want, err.Error())
+		}
+	}
+
+	cfg, _ = config.Load(config.Options{Environ: []string{
+		"APP_ENV=production",
+		"DB_PASS=a-real-password",
+		"JWT_SECRET=a-real-secret",
+	}})
+	err = cfg.Validate()
+	for _, want := range []string{"unsubscribe.secret: must be set apart", "two_factor.encryption_key: must be set apart"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in %v", want, err)
+		}
+	}
+
+	cfg, _ = config.Load(config.Options{Environ: []string{
+		"APP_ENV=production",
+		"DB_PASS=a-real-password",
+		"JWT_SECRET=a-real-secret",
+		"UNSUBSCRIBE_SECRET=another-real-secret",
+		"TOTP_ENCRYPTION_KEY=a-real-key",
+	}})
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+	if len(cfg.Warnings) != 0 {
+		t.Errorf("expected no warnings, got %v", cfg.Warnings)
+	}
+}
+
+func TestLoad_Warnings(t *testing.T) {
+	cfg, err := config.Load(config.Options{Environ: []string{
+		"JWT_ALGORITHM=HS256",
+		"SERVER_HOST=mg.example.com",
+	}})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	warnings := strings.Join(cfg.Warnings, "\n")
+	for _, want := range []string{
+		"jwt.secret: INSECURE",
+		"database.password: INSECURE",
+		"unsubscribe.secret: not set, it reuses jwt.secret",
+		"two_factor.encryption_key: not set, it reuses jwt.secret",
+		"MAILGUN_DOMAIN",
+	} {
+		if !strings.Contains(warnings, want) {
+			t.Errorf("expected %q in %q", want, warnings)
+		}
+	}
+
+	for _, host := range []string{"0.0.0.0", "::", "localhost", ""} {
+		cfg, _ := config.Load(config.Options{Environ: []string{"SERVER_HOST=" + host}})
+		if warnings := strings.Join(cfg.Warnings, "\n"); strings.Contains(warnings, "server.host") {
+			t.Errorf("expected no warning for %q, got %q", host, warnings)
+		}
+	}
+}
+
+func TestPrint(t *testing.T) {
+	cfg, err := config.Load(config.Options{Environ: []string{
+		"DB_PASS=a-real-password",
+		"CORS_ALLOWED_ORIGINS=https://a.example.com,https://b.example.com",
+		"SERVER_DRAIN_SECONDS=9",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var redacted bytes.Buffer
+	if err := cfg.Print(&redacted, true); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(redacted.String(), "a-real-password") {
+		t.Error("expected the database password to be redacted")
+	}
+
+	// the full output reads back to the same configuration
+	var full bytes.Buffer
+	if err := cfg.Print(&full, false); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := config.Load(config.Options{File: writeFile(t, "printed.yaml", full.String()), Environ: []string{}})
+	if err != nil {
+		t.Fatalf("expected the printed config to load, got %v", err)
+	}
+
+	if !reflect.DeepEqual(cfg, reloaded) {
+		t.Errorf("expected %+v, got %+v", cfg, reloaded)
+	}
+}
@@ -0,0 +1,281 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Options are the layers applied over the defaults, in order: the file, the environment and
+// the overrides
+type Options struct {
+	// YAML (.yaml, .yml) or TOML (.toml), CONFIG_FILE when empty
+	File string
+	// key=value pairs from --set, e.g. database.host=db
+	Overrides []string
+	// KEY=value pairs, os.Environ when nil
+	Environ []string
+}
+
+// Variables whose prefix is ours, a name we do not know is most likely a typo or an old name
+var ownedPrefixes = []string{
+	"APP_", "CORS_", "DB_", "FOOTBALL_API_", "JWT_", "LOG_", "MAILGUN_", "METRICS_", "OAUTH_", "OIDC_",
+	"PASSWORD_", "RATE_LIMIT_", "REDIS_", "SEED_", "SERVER_", "SESSION_", "TOTP_", "TWILIO_", "UNSUBSCRIBE_",
+}
+
+// Load builds the configuration from the defaults, the config file, the environment and the
+// overrides, a later layer wins. Any variable can also be read from a file named by
+// <NAME>_FILE (Docker secrets). Values that do not parse are errors, names that look like
+// a misspelled or renamed variable end up in Warnings.
+func Load(opts Options) (*Config, error) {
+	cfg := defaults()
+	settings := cfg.settings()
+
+	environ := opts.Environ
+	if environ == nil {
+		environ = os.Environ()
+	}
+	env := make(map[string]string, len(environ))
+	for _, kv := range environ {
+		if name, value, ok := strings.Cut(kv, "="); ok {
+			env[name] = value
+		}
+	}
+
+	var errs []error
+
+	file := opts.File
+	if file == "" {
+		file = env["CONFIG_FILE"]
+	}
+	if file != "" {
+		if err := cfg.loadFile(settings, file); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	errs = append(errs, cfg.loadEnv(settings, env)...)
+
+	for _, override := range opts.Overrides {
+		key, val, ok := strings.Cut(override, "=")
+		s := findSetting(settings, key)
+		if !ok || s == nil {
+			errs = append(errs, fmt.Errorf("--set %s: unknown setting%s", override, suggestion(key, settingKeys(settings))))
+			continue
+		}
+		if err := s.value.Set(val); err != nil {
+			errs = append(errs, fmt.Errorf("--set %s: %w", key, err))
+		}
+	}
+
+	cfg.resolveDerived()
+	cfg.Warnings = append(cfg.Warnings, unknownVariables(settings, env)...)
+	cfg.Warnings = append(cfg.Warnings, cfg.warnings()...)
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+func (c *Config) loadFile(settings []setting, path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	raw := map[string]any{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(content, &raw)
+	case ".toml":
+		err = toml.Unmarshal(content, &raw)
+	default:
+		return fmt.Errorf("config file %s: unsupported format, use .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	values := map[string]string{}
+	flatten("", raw, values)
+
+	// sorted so the errors come in the same order on every run
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var errs []error
+	for _, key := range keys {
+		s := findSetting(settings, key)
+		if s == nil {
+			errs = append(errs, fmt.Errorf("config file %s: unknown key %s%s", path, key, suggestion(key, settingKeys(settings))))
+			continue
+		}
+		if err := s.value.Set(values[key]); err != nil {
+			errs = append(errs, fmt.Errorf("config file %s: %s: %w", path, key, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (c *Config) loadEnv(settings []setting, env map[string]string) []error {
+	var errs []error
+	for _, s := range settings {
+		for _, name := range append([]string{s.env}, s.aliases...) {
+			val, found, err := lookupEnv(env, name)
+			if err != nil {
+				errs = append(errs, err)
+				break
+			}
+			if !found {
+				continue
+			}
+
+			if name != s.env {
+				c.Warnings = append(c.Warnings, fmt.Sprintf("%s is deprecated, rename it to %s", name, s.env))
+			}
+			if err := s.value.Set(val); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			}
+			break
+		}
+	}
+
+	return errs
+}
+
+// lookupEnv reads name or the file named by name_FILE, an empty variable counts as unset
+func lookupEnv(env map[string]string, name string) (string, bool, error) {
+	val := env[name]
+	path := env[name+"_FILE"]
+
+	switch {
+	case val != "" && path != "":
+		return "", false, fmt.Errorf("both %s and %s_FILE are set", name, name)
+	case path != "":
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return "", false, fmt.Errorf("%s_FILE: %w", name, err)
+		}
+		// editors and `echo` leave a trailing newline
+		return strings.TrimRight(string(content), "\r\n"), true, nil
+	default:
+		return val, val != "", nil
+	}
+}
+
+func unknownVariables(settings []setting, env map[string]string) []string {
+	known := []string{"CONFIG_FILE"}
+	for _, s := range settings {
+		known = append(known, s.env)
+		known = append(known, s.aliases...)
+	}
+
+	var warnings []string
+	for name := range env {
+		base := strings.TrimSuffix(name, "_FILE")
+		if slices.Contains(known, base) || !slices.ContainsFunc(ownedPrefixes, func(prefix string) bool {
+			return strings.HasPrefix(name, prefix)
+		}) {
+			continue
+		}
+
+		warnings = append(warnings, fmt.Sprintf("%s is not a known setting and is ignored%s", name, suggestion(base, known)))
+	}
+	sort.Strings(warnings)
+
+	return warnings
+}
+
+// flatten turns nested tables into dotted keys, lists become comma separated
+func flatten(prefix string, raw map[string]any, out map[string]string) {
+	for key, val := range raw {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+
+		switch v := val.(type) {
+		case map[string]any:
+			flatten(key, v, out)
+		case []any:
+			items := make([]string, len(v))
+			for i, item := range v {
+				items[i] = fmt.Sprint(item)
+			}
+			out[key] = strings.Join(items, ",")
+		case nil:
+			out[key] = ""
+		default:
+			out[key] = fmt.Sprint(v)
+		}
+	}
+}
+
+func findSetting(settings []setting, key string) *setting {
+	for i := range settings {
+		if settings[i].key == key {
+			return &settings[i]
+		}
+	}
+
+	return nil
+}
+
+func settingKeys(settings []setting) []string {
+	keys := make([]string, len(settings))
+	for i, s := range settings {
+		keys[i] = s.key
+	}
+
+	return keys
+}
+
+// suggestion names the closest candidate when it is only a few edits away
+func suggestion(name string, candidates []string) string {
+	best, bestDistance := "", 4
+	for _, candidate := range candidates {
+		if d := editDistance(name, candidate); d < bestDistance {
+			best, bestDistance = candidate, d
+		}
+	}
+
+	if best == "" {
+		return ""
+	}
+
+	return fmt.Sprintf(", did you mean %s?", best)
+}
+
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(b)]
+}
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const redactedValue = "REDACTED"
+
+// Print writes the effective configuration as YAML, in the format Load reads back. With
+// redacted the secrets that are set are replaced
+func (c *Config) Print(w io.Writer, redacted bool) error {
+	root := &yaml.Node{Kind: yaml.MappingNode}
+
+	for _, s := range c.settings() {
+		parent := root
+		path := strings.Split(s.key, ".")
+		for _, section := range path[:len(path)-1] {
+			parent = child(parent, section)
+		}
+
+		parent.Content = append(parent.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Value: path[len(path)-1]},
+			valueNode(s, redacted),
+		)
+	}
+
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(root); err != nil {
+		return err
+	}
+
+	return encoder.Close()
+}
+
+// child returns the mapping under key, adding it when missing
+func child(parent *yaml.Node, key string) *yaml.Node {
+	for i := 0; i < len(parent.Content); i += 2 {
+		if parent.Content[i].Value == key {
+			return parent.Content[i+1]
+		}
+	}
+
+	node := &yaml.Node{Kind: yaml.MappingNode}
+	parent.Content = append(parent.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, node)
+
+	return node
+}
+
+func valueNode(s setting, redacted bool) *yaml.Node {
+	val := s.value.String()
+	if s.secret && redacted && val != "" {
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: redactedValue}
+	}
+
+	switch v := s.value.(type) {
+	case *listValue:
+		node := &yaml.Node{Kind: yaml.SequenceNode}
+		for _, item := range *v {
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: item})
+		}
+		return node
+	case *intValue, *secondsValue:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: val}
+	case *boolValue:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: val}
+	default:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: val}
+	}
+}
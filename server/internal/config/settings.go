@@ -0,0 +1,280 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	EnvDevelopment = "development"
+	EnvProduction  = "production"
+)
+
+// setting binds a Config field to its key in the config file and --set, and to its
+// environment variable
+type setting struct {
+	// e.g. database.host
+	key string
+	env string
+	// Printed redacted
+	secret bool
+	// Older environment names, still read with a warning
+	aliases []string
+	value   value
+}
+
+type value interface {
+	Set(s string) error
+	String() string
+}
+
+func defaults() *Config {
+	return &Config{
+		Env: EnvDevelopment,
+		Log: LogConfig{
+			Format: "json",
+			Level:  "info",
+		},
+		Database: DatabaseConfig{
+			Host:        "localhost",
+			Port:        5432,
+			User:        "postgres",
+			Password:    "change_this_password",
+			Name:        "football",
+			SSLMode:     "disable",
+			AutoMigrate: true,
+		},
+		JWT: JWTConfig{
+			Algorithm:     "RS256",
+			Secret:        "default-secret-key",
+			ExpiresHours:  24,
+			RotationHours: 24 * 30,
+			Audience:      "footballapi",
+		},
+		FootballAPI: FootballAPIConfig{
+			URL: "https://api.football-data.org/v4",
+		},
+		Server: ServerConfig{
+			Port:       "4000",
+			PublicURL:  "http://localhost:4000",
+			AppURL:     "http://localhost:3000",
+			DrainDelay: 5 * time.Second,
+		},
+		Unsubscribe: UnsubscribeConfig{
+			ExpiresHours: 24 * 30,
+		},
+		Session: SessionConfig{
+			CookieSecure:   true,
+			CookieSameSite: "lax",
+		},
+		OAuth: OAuthConfig{
+			OIDCName: "oidc",
+		},
+		TwoFactor: TwoFactorConfig{
+			Issuer: "Football API",
+		},
+		Metrics: MetricsConfig{
+			Addr: "127.0.0.1:9100",
+		},
+		Tracing: TracingConfig{
+			Exporter:    "none",
+			ServiceName: "footballapi",
+		},
+		RateLimit: RateLimitConfig{
+			Enabled:           true,
+			Store:             "memory",
+			AuthRequests:      20,
+			AuthWindow:        time.Minute,
+			UserRequests:      120,
+			UserWindow:        time.Minute,
+			IPRequests:        600,
+			IPWindow:          time.Minute,
+			BroadcastRequests: 10,
+			BroadcastWindow:   time.Hour,
+		},
+		Seed: SeedConfig{
+			AdminName: "admin",
+		},
+		Password: PasswordPolicyConfig{
+			MinLength: 8,
+		},
+	}
+}
+
+// settings lists every setting in the order `config print` shows them
+func (c *Config) settings() []setting {
+	return []setting{
+		{key: "env", env: "APP_ENV", value: (*stringValue)(&c.Env)},
+
+		{key: "log.format", env: "LOG_FORMAT", value: (*stringValue)(&c.Log.Format)},
+		{key: "log.level", env: "LOG_LEVEL", value: (*stringValue)(&c.Log.Level)},
+
+		{key: "server.host", env: "SERVER_HOST", value: (*stringValue)(&c.Server.Host)},
+		{key: "server.port", env: "SERVER_PORT", value: (*stringValue)(&c.Server.Port)},
+		{key: "server.public_url", env: "SERVER_PUBLIC_URL", value: (*stringValue)(&c.Server.PublicURL)},
+		{key: "server.app_url", env: "APP_URL", value: (*stringValue)(&c.Server.AppURL)},
+		{key: "server.allowed_origins", env: "CORS_ALLOWED_ORIGINS", value: (*listValue)(&c.Server.AllowedOrigins)},
+		{key: "server.drain_seconds", env: "SERVER_DRAIN_SECONDS", value: (*secondsValue)(&c.Server.DrainDelay)},
+
+		{key: "database.host", env: "DB_HOST", value: (*stringValue)(&c.Database.Host)},
+		{key: "database.port", env: "DB_PORT", value: (*intValue)(&c.Database.Port)},
+		{key: "database.user", env: "DB_USER", value: (*stringValue)(&c.Database.User)},
+		{key: "database.password", env: "DB_PASS", secret: true, value: (*stringValue)(&c.Database.Password)},
+		{key: "database.name", env: "DB_NAME", value: (*stringValue)(&c.Database.Name)},
+		{key: "database.sslmode", env: "DB_SSLMODE", value: (*stringValue)(&c.Database.SSLMode)},
+		{key: "database.auto_migrate", env: "DB_AUTO_MIGRATE", value: (*boolValue)(&c.Database.AutoMigrate)},
+
+		{key: "jwt.algorithm", env: "JWT_ALGORITHM", value: (*stringValue)(&c.JWT.Algorithm)},
+		{key: "jwt.secret", env: "JWT_SECRET", secret: true, value: (*stringValue)(&c.JWT.Secret)},
+		{key: "jwt.expires_hours", env: "JWT_EXPIRES_HOURS", value: (*intValue)(&c.JWT.ExpiresHours)},
+		{key: "jwt.rotation_hours", env: "JWT_ROTATION_HOURS", value: (*intValue)(&c.JWT.RotationHours)},
+		{key: "jwt.issuer", env: "JWT_ISSUER", value: (*stringValue)(&c.JWT.Issuer)},
+		{key: "jwt.audience", env: "JWT_AUDIENCE", value: (*stringValue)(&c.JWT.Audience)},
+
+		{key: "session.cookie_secure", env: "SESSION_COOKIE_SECURE", value: (*boolValue)(&c.Session.CookieSecure)},
+		{key: "session.cookie_samesite", env: "SESSION_COOKIE_SAMESITE", value: (*stringValue)(&c.Session.CookieSameSite)},
+		{key: "session.cookie_domain", env: "SESSION_COOKIE_DOMAIN", value: (*stringValue)(&c.Session.CookieDomain)},
+
+		{key: "oauth.google_client_id", env: "OAUTH_GOOGLE_CLIENT_ID", value: (*stringValue)(&c.OAuth.GoogleClientID)},
+		{key: "oauth.google_client_secret", env: "OAUTH_GOOGLE_CLIENT_SECRET", secret: true, value: (*stringValue)(&c.OAuth.GoogleClientSecret)},
+		{key: "oauth.github_client_id", env: "OAUTH_GITHUB_CLIENT_ID", value: (*stringValue)(&c.OAuth.GitHubClientID)},
+		{key: "oauth.github_client_secret", env: "OAUTH_GITHUB_CLIENT_SECRET", secret: true, value: (*stringValue)(&c.OAuth.GitHubClientSecret)},
+		{key: "oauth.oidc_name", env: "OIDC_PROVIDER_NAME", value: (*stringValue)(&c.OAuth.OIDCName)},
+		{key: "oauth.oidc_issuer_url", env: "OIDC_ISSUER_URL", value: (*stringValue)(&c.OAuth.OIDCIssuerURL)},
+		{key: "oauth.oidc_client_id", env: "OIDC_CLIENT_ID", value: (*stringValue)(&c.OAuth.OIDCClientID)},
+		{key: "oauth.oidc_client_secret", env: "OIDC_CLIENT_SECRET", secret: true, value: (*stringValue)(&c.OAuth.OIDCClientSecret)},
+
+		{key: "two_factor.issuer", env: "TOTP_ISSUER", value: (*stringValue)(&c.TwoFactor.Issuer)},
+		{key: "two_factor.encryption_key", env: "TOTP_ENCRYPTION_KEY", secret: true, value: (*stringValue)(&c.TwoFactor.EncryptionKey)},
+
+		{key: "password.min_length", env: "PASSWORD_MIN_LENGTH", value: (*intValue)(&c.Password.MinLength)},
+		{key: "password.require_upper", env: "PASSWORD_REQUIRE_UPPER", value: (*boolValue)(&c.Password.RequireUpper)},
+		{key: "password.require_lower", env: "PASSWORD_REQUIRE_LOWER", value: (*boolValue)(&c.Password.RequireLower)},
+		{key: "password.require_digit", env: "PASSWORD_REQUIRE_DIGIT", value: (*boolValue)(&c.Password.RequireDigit)},
+		{key: "password.require_symbol", env: "PASSWORD_REQUIRE_SYMBOL", value: (*boolValue)(&c.Password.RequireSymbol)},
+		{key: "password.breached_dataset_dir", env: "PASSWORD_BREACHED_DATASET_DIR", value: (*stringValue)(&c.Password.BreachedDatasetDir)},
+
+		{key: "football_api.url", env: "FOOTBALL_API_URL", value: (*stringValue)(&c.FootballAPI.URL)},
+		{key: "football_api.token", env: "FOOTBALL_API_TOKEN", secret: true, value: (*stringValue)(&c.FootballAPI.Token)},
+
+		// SERVER_DOMAIN was read as the Mailgun domain
+		{key: "email.domain", env: "MAILGUN_DOMAIN", aliases: []string{"SERVER_DOMAIN"}, value: (*stringValue)(&c.EmailAPI.Domain)},
+		{key: "email.api_key", env: "MAILGUN_API_KEY", secret: true, value: (*stringValue)(&c.EmailAPI.APIKey)},
+		{key: "email.from", env: "MAILGUN_FROM", value: (*stringValue)(&c.EmailAPI.From)},
+		{key: "email.webhook_signing_key", env: "MAILGUN_WEBHOOK_SIGNING_KEY", secret: true, value: (*stringValue)(&c.EmailAPI.WebhookSigningKey)},
+
+		{key: "sms.account_sid", env: "TWILIO_ACCOUNT_SID", value: (*stringValue)(&c.SMSAPI.AccountSID)},
+		{key: "sms.auth_token", env: "TWILIO_AUTH_TOKEN", aliases: []string{"TWILIO_API_KEY"}, secret: true, value: (*stringValue)(&c.SMSAPI.AuthToken)},
+		{key: "sms.from", env: "TWILIO_FROM_PHONE", aliases: []string{"TWILIO_FROM"}, value: (*stringValue)(&c.SMSAPI.From)},
+		{key: "sms.status_callback_url", env: "TWILIO_STATUS_CALLBACK_URL", value: (*stringValue)(&c.SMSAPI.StatusCallbackURL)},
+		{key: "sms.inbound_url", env: "TWILIO_INBOUND_URL", value: (*stringValue)(&c.SMSAPI.InboundURL)},
+
+		{key: "unsubscribe.secret", env: "UNSUBSCRIBE_SECRET", secret: true, value: (*stringValue)(&c.Unsubscribe.Secret)},
+		{key: "unsubscribe.expires_hours", env: "UNSUBSCRIBE_EXPIRES_HOURS", value: (*intValue)(&c.Unsubscribe.ExpiresHours)},
+
+		{key: "metrics.addr", env: "METRICS_ADDR", value: (*stringValue)(&c.Metrics.Addr)},
+		{key: "metrics.token", env: "METRICS_TOKEN", secret: true, value: (*stringValue)(&c.Metrics.Token)},
+
+		{key: "tracing.exporter", env: "OTEL_TRACES_EXPORTER", value: (*stringValue)(&c.Tracing.Exporter)},
+		{key: "tracing.service_name", env: "OTEL_SERVICE_NAME", value: (*stringValue)(&c.Tracing.ServiceName)},
+
+		{key: "rate_limit.enabled", env: "RATE_LIMIT_ENABLED", value: (*boolValue)(&c.RateLimit.Enabled)},
+		{key: "rate_limit.store", env: "RATE_LIMIT_STORE", value: (*stringValue)(&c.RateLimit.Store)},
+		{key: "rate_limit.redis_url", env: "REDIS_URL", secret: true, value: (*stringValue)(&c.RateLimit.RedisURL)},
+		{key: "rate_limit.auth_requests", env: "RATE_LIMIT_AUTH_REQUESTS", value: (*intValue)(&c.RateLimit.AuthRequests)},
+		{key: "rate_limit.auth_window_seconds", env: "RATE_LIMIT_AUTH_WINDOW_SECONDS", value: (*secondsValue)(&c.RateLimit.AuthWindow)},
+		{key: "rate_limit.user_requests", env: "RATE_LIMIT_USER_REQUESTS", value: (*intValue)(&c.RateLimit.UserRequests)},
+		{key: "rate_limit.user_window_seconds", env: "RATE_LIMIT_USER_WINDOW_SECONDS", value: (*secondsValue)(&c.RateLimit.UserWindow)},
+		{key: "rate_limit.ip_requests", env: "RATE_LIMIT_IP_REQUESTS", value: (*intValue)(&c.RateLimit.IPRequests)},
+		{key: "rate_limit.ip_window_seconds", env: "RATE_LIMIT_IP_WINDOW_SECONDS", value: (*secondsValue)(&c.RateLimit.IPWindow)},
+		{key: "rate_limit.broadcast_requests", env: "RATE_LIMIT_BROADCAST_REQUESTS", value: (*intValue)(&c.RateLimit.BroadcastRequests)},
+		{key: "rate_limit.broadcast_window_seconds", env: "RATE_LIMIT_BROADCAST_WINDOW_SECONDS", value: (*secondsValue)(&c.RateLimit.BroadcastWindow)},
+
+		{key: "seed.admin_name", env: "SEED_ADMIN_NAME", value: (*stringValue)(&c.Seed.AdminName)},
+		{key: "seed.admin_password", env: "SEED_ADMIN_PASSWORD", secret: true, value: (*stringValue)(&c.Seed.AdminPassword)},
+	}
+}
+
+// resolveDerived fills the settings that default to another one
+func (c *Config) resolveDerived() {
+	if c.JWT.Issuer == "" {
+		c.JWT.Issuer = c.Server.PublicURL
+	}
+	if len(c.Server.AllowedOrigins) == 0 {
+		c.Server.AllowedOrigins = []string{c.Server.AppURL}
+	}
+	if c.Unsubscribe.Secret == "" {
+		c.Unsubscribe.Secret = c.JWT.Secret
+	}
+	if c.TwoFactor.EncryptionKey == "" {
+		c.TwoFactor.EncryptionKey = c.JWT.Secret
+	}
+}
+
+type stringValue string
+
+func (v *stringValue) Set(s string) error {
+	*v = stringValue(s)
+	return nil
+}
+
+func (v *stringValue) String() string { return string(*v) }
+
+type intValue int
+
+func (v *intValue) Set(s string) error {
+	n, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil {
+		return fmt.Errorf("%q is not an integer", s)
+	}
+	*v = intValue(n)
+	return nil
+}
+
+func (v *intValue) String() string { return strconv.Itoa(int(*v)) }
+
+type boolValue bool
+
+func (v *boolValue) Set(s string) error {
+	b, err := strconv.ParseBool(strings.TrimSpace(s))
+	if err != nil {
+		return fmt.Errorf("%q is not a boolean", s)
+	}
+	*v = boolValue(b)
+	return nil
+}
+
+func (v *boolValue) String() string { return strconv.FormatBool(bool(*v)) }
+
+// listValue is comma separated
+type listValue []string
+
+func (v *listValue) Set(s string) error {
+	*v = nil
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*v = append(*v, item)
+		}
+	}
+	return nil
+}
+
+func (v *listValue) String() string { return strings.Join(*v, ",") }
+
+// secondsValue is a whole number of seconds
+type secondsValue time.Duration
+
+func (v *secondsValue) Set(s string) error {
+	n, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || n < 0 {
+		return fmt.Errorf("%q is not a number of seconds", s)
+	}
+	*v = secondsValue(time.Duration(n) * time.Second)
+	return nil
+}
+
+func (v *secondsValue) String() string {
+	return strconv.Itoa(int(time.Duration(*v) / time.Second))
+}
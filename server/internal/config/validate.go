@@ -0,0 +1,152 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Values from .env.example and compose.yaml, anyone who read them can sign tokens or log in
+var insecureSecrets = []string{
+	"default-secret-key",
+	"your-super-secret-jwt-key-here",
+	"your-unsubscribe-link-secret",
+	"change_this_password",
+}
+
+// InsecureSecret reports whether val is empty or one of the published example values
+func InsecureSecret(val string) bool {
+	return val == "" || slices.Contains(insecureSecrets, val)
+}
+
+// Validate reports every invalid setting at once. In production it also refuses the default
+// secrets and cookies sent over plain HTTP
+func (c *Config) Validate() error {
+	var errs []error
+	oneOf := func(key, val string, allowed ...string) {
+		if !slices.Contains(allowed, val) {
+			errs = append(errs, fmt.Errorf("%s: %q is not one of %v", key, val, allowed))
+		}
+	}
+	positive := func(key string, val int) {
+		if val <= 0 {
+			errs = append(errs, fmt.Errorf("%s: must be greater than 0", key))
+		}
+	}
+
+	oneOf("env", c.Env, EnvDevelopment, EnvProduction)
+	oneOf("log.format", c.Log.Format, "json", "text")
+	oneOf("log.level", c.Log.Level, "debug", "info", "warn", "error")
+	oneOf("jwt.algorithm", c.JWT.Algorithm, "HS256", "RS256", "EdDSA")
+	oneOf("session.cookie_samesite", c.Session.CookieSameSite, "strict", "lax", "none")
+	oneOf("tracing.exporter", c.Tracing.Exporter, "none", "otlp", "stdout")
+	oneOf("rate_limit.store", c.RateLimit.Store, "memory", "redis")
+
+	if strings.Contains(c.Server.Host, "://") {
+		errs = append(errs, fmt.Errorf("server.host: %q is a URL, set the address to listen on (e.g. 0.0.0.0)", c.Server.Host))
+	}
+	if port, err := strconv.Atoi(c.Server.Port); err != nil || port < 1 || port > 65535 {
+		errs = append(errs, fmt.Errorf("server.port: %q is not a port", c.Server.Port))
+	}
+	if c.Database.Port < 1 || c.Database.Port > 65535 {
+		errs = append(errs, fmt.Errorf("database.port: %d is not a port", c.Database.Port))
+	}
+
+	positive("jwt.expires_hours", c.JWT.ExpiresHours)
+	positive("jwt.rotation_hours", c.JWT.RotationHours)
+	positive("unsubscribe.expires_hours", c.Unsubscribe.ExpiresHours)
+	positive("password.min_length", c.Password.MinLength)
+
+	if c.RateLimit.Enabled {
+		positive("rate_limit.auth_requests", c.RateLimit.AuthRequests)
+		positive("rate_limit.auth_window_seconds", int(c.RateLimit.AuthWindow.Seconds()))
+		positive("rate_limit.user_requests", c.RateLimit.UserRequests)
+		positive("rate_limit.user_window_seconds", int(c.RateLimit.UserWindow.Seconds()))
+		positive("rate_limit.ip_requests", c.RateLimit.IPRequests)
+		positive("rate_limit.ip_window_seconds", int(c.RateLimit.IPWindow.Seconds()))
+		positive("rate_limit.broadcast_requests", c.RateLimit.BroadcastRequests)
+		positive("rate_limit.broadcast_window_seconds", int(c.RateLimit.BroadcastWindow.Seconds()))
+
+		if c.RateLimit.Store == "redis" && c.RateLimit.RedisURL == "" {
+			errs = append(errs, errors.New("rate_limit.redis_url: required with the redis store"))
+		}
+	}
+
+	// browsers drop SameSite=None cookies without Secure
+	if c.Session.CookieSameSite == "none" && !c.Session.CookieSecure {
+		errs = append(errs, errors.New("session.cookie_samesite: none requires session.cookie_secure"))
+	}
+
+	if c.Env == EnvProduction {
+		// rotating the JWT secret would then also break every unsubscribe link and 2FA enrolment
+		for _, derived := range c.derivedFromJWTSecret() {
+			errs = append(errs, fmt.Errorf("%s: must be set apart from jwt.secret in production", derived))
+		}
+
+		for _, secret := range c.secrets() {
+			if InsecureSecret(secret.val) {
+				errs = append(errs, fmt.Errorf("%s: a default or example value is not allowed in production", secret.key))
+			}
+		}
+
+		if !c.Session.CookieSecure {
+			errs = append(errs, errors.New("session.cookie_secure: must be true in production"))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// secrets lists the values anyone who read the examples could use to sign or decrypt
+func (c *Config) secrets() []struct{ key, val string } {
+	secrets := []struct{ key, val string }{
+		{"database.password", c.Database.Password},
+		{"unsubscribe.secret", c.Unsubscribe.Secret},
+		{"two_factor.encryption_key", c.TwoFactor.EncryptionKey},
+	}
+	// the rotated keys are used instead with RS256 and EdDSA
+	if c.JWT.Algorithm == "HS256" {
+		secrets = append(secrets, struct{ key, val string }{"jwt.secret", c.JWT.Secret})
+	}
+	return secrets
+}
+
+func (c *Config) derivedFromJWTSecret() []string {
+	var derived []string
+	if c.Unsubscribe.Secret == c.JWT.Secret {
+		derived = append(derived, "unsubscribe.secret")
+	}
+	if c.TwoFactor.EncryptionKey == c.JWT.Secret {
+		derived = append(derived, "two_factor.encryption_key")
+	}
+	return derived
+}
+
+// warnings are the settings that work but are most likely a mistake, production refuses the
+// insecure ones in Validate instead
+func (c *Config) warnings() []string {
+	var warnings []string
+
+	// the address to listen on, a public name is easily put here instead of SERVER_PUBLIC_URL or MAILGUN_DOMAIN
+	if host := c.Server.Host; host != "" && host != "localhost" && net.ParseIP(host) == nil && !strings.Contains(host, "://") {
+		warnings = append(warnings, fmt.Sprintf("server.host: %q is a hostname, the API listens on the addresses it resolves to. Set an IP (e.g. 0.0.0.0), the public name goes in SERVER_PUBLIC_URL and the mail domain in MAILGUN_DOMAIN", host))
+	}
+
+	if c.Env == EnvProduction {
+		return warnings
+	}
+
+	for _, secret := range c.secrets() {
+		if InsecureSecret(secret.val) {
+			warnings = append(warnings, fmt.Sprintf("%s: INSECURE, a default or example value is in use, anyone who read it can forge or read what it protects. Production refuses to start with it", secret.key))
+		}
+	}
+	for _, derived := range c.derivedFromJWTSecret() {
+		warnings = append(warnings, fmt.Sprintf("%s: not set, it reuses jwt.secret so rotating one breaks the other. Production requires its own value", derived))
+	}
+
+	return warnings
+}
@@ -3,12 +3,15 @@ package controller
 import (
 	"context"
 	"fmt"
+	"log/slog"
+	"sync"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/gorilla/websocket"
 	"github.com/tsntt/footballapi/internal/dto"
 	"github.com/tsntt/footballapi/internal/model"
 	"github.com/tsntt/footballapi/pkg/broadcast"
+	"github.com/tsntt/footballapi/pkg/logging"
 )
 
 type AdminController struct {
@@ -17,6 +20,9 @@ type AdminController struct {
 	broadcastRepo    model.IBroadcastRepository
 	broadcastService *broadcast.BroadcastService
 	validator        *validator.Validate
+
+	// broadcasts still sending after BroadcastMatch returned
+	inFlight sync.WaitGroup
 }
 
 func NewAdminController(
@@ -30,7 +36,7 @@ func NewAdminController(
 		fanRepo:          fanRepo,
 		broadcastRepo:    broadcastRepo,
 		broadcastService: broadcastService,
-		validator:        validator.New(),
+		validator:        newValidator(),
 	}
 }
 
@@ -38,7 +44,7 @@ func (c *AdminController) GetMatches(ctx context.Context) ([]model.Match, error)
 	// INFO: should be cached for production
 	championships, err := c.externalAPI.GetChampionships(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get championships: %w", err)
+		return nil, ErrFootballAPIDown.Wrap(err)
 	}
 
 	var allMatches []model.Match
@@ -67,7 +73,7 @@ func (c *AdminController) GetMatches(ctx context.Context) ([]model.Match, error)
 	}
 
 	if len(fans) == 0 {
-		return nil, fmt.Errorf("no fans found")
+		return nil, ErrNoFans
 	}
 
 	for _, match := range allMatches {
@@ -85,6 +91,8 @@ func (c *AdminController) GetMatches(ctx context.Context) ([]model.Match, error)
 }
 
 func (c *AdminController) BroadcastMatch(ctx context.Context, matchID int) (*dto.APIResponse, error) {
+	ctx = logging.With(ctx, slog.Int("match_id", matchID))
+
 	// Check if broadcast already sent for this match, avoid duplicates
 	existing, err := c.broadcastRepo.GetByMatchID(ctx, matchID)
 	if err == nil && existing != nil {
@@ -95,18 +103,20 @@ func (c *AdminController) BroadcastMatch(ctx context.Context, matchID int) (*dto
 
 	match, err := c.externalAPI.GetMatch(ctx, matchID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get match details: %w", err)
+		return nil, ErrFootballAPIDown.Wrap(err)
 	}
 
 	homeFans, err := c.fanRepo.GetByTeamID(ctx, match.HomeTeam.ID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get home team fans: %w", err)
 	}
+	homeFans = notifiableFans(homeFans)
 
 	awayFans, err := c.fanRepo.GetByTeamID(ctx, match.AwayTeam.ID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get away team fans: %w", err)
 	}
+	awayFans = notifiableFans(awayFans)
 
 	allFans := append(homeFans, awayFans...)
 	if len(allFans) == 0 {
@@ -117,7 +127,7 @@ func (c *AdminController) BroadcastMatch(ctx context.Context, matchID int) (*dto
 
 	for _, fan := range allFans {
 		c.broadcastService.AddSubscription(broadcast.Subscription{
-			UserID:           fan.ID,
+			UserID:           fan.UserID,
 			ChannelID:        fan.TeamID,
 			NotificationType: broadcast.NotificationType(fan.NotificationType),
 			Address:          fan.Address,
@@ -137,18 +147,25 @@ func (c *AdminController) BroadcastMatch(ctx context.Context, matchID int) (*dto
 		Content: message,
 	}
 
-	go c.broadcastService.BroadCastToChannel(context.Background(), 5, match.HomeTeam.ID, msg)
-
-	broadcast := &model.BroadcastMessage{
+	// Save the record first so every delivery can point back to it
+	broadcastMessage := &model.BroadcastMessage{
 		MatchID:            matchID,
 		MessageContentHash: broadcast.GenerateContentHash(msg),
 		Status:             "sent",
 	}
 
-	if err := c.broadcastRepo.Create(ctx, broadcast); err != nil {
+	if err := c.broadcastRepo.Create(ctx, broadcastMessage); err != nil {
 		return nil, fmt.Errorf("failed to save broadcast record: %w", err)
 	}
 
+	msg.BroadcastID = broadcastMessage.ID
+	logging.FromContext(ctx).Info("Broadcast started", slog.Int("broadcast_id", msg.BroadcastID), slog.Int("targets", len(allFans)))
+
+	// the broadcast runs on after the response, under the trace of this request
+	c.inFlight.Go(func() {
+		c.broadcastService.BroadCastToChannel(ctx, 5, match.HomeTeam.ID, msg)
+	})
+
 	return &dto.APIResponse{
 		Message: fmt.Sprintf("Broadcast started! notifying %d %s fans and %d %s fans.", len(homeFans), match.HomeTeam.Name, len(awayFans), match.AwayTeam.Name),
 		Data: map[string]interface{}{
@@ -160,6 +177,24 @@ func (c *AdminController) BroadcastMatch(ctx context.Context, matchID int) (*dto
 	}, nil
 }
 
+// Wait blocks until the broadcasts started by BroadcastMatch are sent, so the process does
+// not exit in the middle of one
+func (c *AdminController) Wait() {
+	c.inFlight.Wait()
+}
+
+// notifiableFans drops channels that were never verified or have been disabled
+func notifiableFans(fans []model.Fan) []model.Fan {
+	notifiable := make([]model.Fan, 0, len(fans))
+	for _, fan := range fans {
+		if fan.Active && fan.Verified {
+			notifiable = append(notifiable, fan)
+		}
+	}
+
+	return notifiable
+}
+
 func (c *AdminController) RegisterWS(conn *websocket.Conn) {
 	c.broadcastService.RegisterAdmConn(conn)
 }
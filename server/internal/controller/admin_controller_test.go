@@ -3,7 +3,9 @@ package controller_test
 import (
 	"context"
 	"errors"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/tsntt/footballapi/internal/controller"
 	"github.com/tsntt/footballapi/internal/dto"
@@ -196,10 +198,14 @@ func TestAdminController_BroadcastMatch(t *testing.T) {
 				fanRepo: &mockFanRepository{
 					getByTeamID: func(ctx context.Context, teamID int) ([]model.Fan, error) {
 						if teamID == 1 {
-							return []model.Fan{{ID: 1, TeamID: 1}}, nil
+							return []model.Fan{{ID: 1, TeamID: 1, Active: true, Verified: true}}, nil
 						}
 						if teamID == 2 {
-							return []model.Fan{{ID: 2, TeamID: 2}, {ID: 3, TeamID: 2}}, nil
+							return []model.Fan{
+								{ID: 2, TeamID: 2, Active: true, Verified: true},
+								{ID: 3, TeamID: 2, Active: true, Verified: true},
+								{ID: 4, TeamID: 2, Active: false, Verified: false},
+							}, nil
 						}
 						return nil, nil
 					},
@@ -270,6 +276,56 @@ func TestAdminController_BroadcastMatch(t *testing.T) {
 	}
 }
 
+// slowNotifier takes a while per message, like a provider API
+type slowNotifier struct {
+	sent atomic.Int32
+}
+
+func (n *slowNotifier) Send(ctx context.Context, subscription broadcast.Subscription, message broadcast.Message) (string, error) {
+	time.Sleep(50 * time.Millisecond)
+	n.sent.Add(1)
+	return "", nil
+}
+
+func TestAdminController_Wait(t *testing.T) {
+	mockAPI := &mockChampionshipAPI{
+		getMatch: func(ctx context.Context, matchID int) (*model.Match, error) {
+			return &model.Match{HomeTeam: model.Team{ID: 1, Name: "Home"}, AwayTeam: model.Team{ID: 2, Name: "Away"}}, nil
+		},
+	}
+	mockFanRepo := &mockFanRepository{
+		getByTeamID: func(ctx context.Context, teamID int) ([]model.Fan, error) {
+			return []model.Fan{
+				{ID: teamID, UserID: teamID, TeamID: teamID, NotificationType: "email", Address: "fan@example.com", Active: true, Verified: true},
+				{ID: teamID + 10, UserID: teamID + 10, TeamID: teamID, NotificationType: "email", Address: "other@example.com", Active: true, Verified: true},
+			}, nil
+		},
+	}
+	mockBroadcastRepo := &mockBroadcastRepository{
+		getByMatchID: func(ctx context.Context, matchID int) (*model.BroadcastMessage, error) {
+			return nil, errors.New("not found")
+		},
+		create: func(ctx context.Context, broadcast *model.BroadcastMessage) error {
+			return nil
+		},
+	}
+
+	notifier := &slowNotifier{}
+	broadcastService := broadcast.NewBroadcastService()
+	broadcastService.RegisterNotifier(broadcast.Email, notifier)
+	adminController := controller.NewAdminController(mockAPI, mockFanRepo, mockBroadcastRepo, broadcastService)
+
+	if _, err := adminController.BroadcastMatch(context.Background(), 123); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	adminController.Wait()
+
+	// the channel of the home team
+	if sent := notifier.sent.Load(); sent != 2 {
+		t.Errorf("expected every send to be done once Wait returns, got %d of 2", sent)
+	}
+}
+
 func TestAdminController_RegisterWS(t *testing.T) {
 	broadcastService := broadcast.NewBroadcastService()
 	adminController := controller.NewAdminController(nil, nil, nil, broadcastService)
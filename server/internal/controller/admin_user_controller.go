@@ -0,0 +1,190 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/tsntt/footballapi/internal/dto"
+	"github.com/tsntt/footballapi/internal/model"
+)
+
+const (
+	defaultUsersPerPage = 20
+	// Deliveries shown in the user detail, the full history can be large
+	userDeliveriesLimit = 50
+)
+
+type AdminUserController struct {
+	userRepo     model.IUserRepository
+	fanRepo      model.IFanRepository
+	deliveryRepo model.IDeliveryRepository
+	auditRepo    model.IAuditRepository
+	attemptRepo  model.ILoginAttemptRepository
+	validator    *validator.Validate
+}
+
+func NewAdminUserController(
+	userRepo model.IUserRepository,
+	fanRepo model.IFanRepository,
+	deliveryRepo model.IDeliveryRepository,
+	auditRepo model.IAuditRepository,
+	attemptRepo model.ILoginAttemptRepository,
+) *AdminUserController {
+	return &AdminUserController{
+		userRepo:     userRepo,
+		fanRepo:      fanRepo,
+		deliveryRepo: deliveryRepo,
+		auditRepo:    auditRepo,
+		attemptRepo:  attemptRepo,
+		validator:    newValidator(),
+	}
+}
+
+func (c *AdminUserController) ListUsers(ctx context.Context, req *dto.UserListRequest) (*dto.UserListResponse, error) {
+	if err := c.validator.Struct(req); err != nil {
+		return nil, invalid(err)
+	}
+
+	if req.Page == 0 {
+		req.Page = 1
+	}
+	if req.PerPage == 0 {
+		req.PerPage = defaultUsersPerPage
+	}
+
+	users, total, err := c.userRepo.List(ctx, model.UserFilter{
+		Search: req.Search,
+		Limit:  req.PerPage,
+		Offset: (req.Page - 1) * req.PerPage,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+
+	return &dto.UserListResponse{
+		Users:   users,
+		Total:   total,
+		Page:    req.Page,
+		PerPage: req.PerPage,
+	}, nil
+}
+
+func (c *AdminUserController) GetUser(ctx context.Context, userID int) (*dto.UserDetailResponse, error) {
+	user, err := c.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, orNotFound(err, ErrUserNotFound, "get user")
+	}
+	user.Password = ""
+
+	subscriptions, err := c.fanRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get subscriptions: %w", err)
+	}
+
+	deliveries, err := c.deliveryRepo.GetByUserID(ctx, userID, userDeliveriesLimit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get deliveries: %w", err)
+	}
+
+	audit, err := c.auditRepo.GetByTarget(ctx, model.AuditTargetUser, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get audit entries: %w", err)
+	}
+
+	return &dto.UserDetailResponse{
+		User:          user,
+		Subscriptions: subscriptions,
+		Deliveries:    deliveries,
+		Audit:         audit,
+	}, nil
+}
+
+// SuspendUser blocks login and revokes every token issued so far
+func (c *AdminUserController) SuspendUser(ctx context.Context, actorID, userID int) (*dto.APIResponse, error) {
+	if actorID == userID {
+		return nil, ErrOwnAccount
+	}
+
+	if err := c.userRepo.Suspend(ctx, userID, time.Now()); err != nil {
+		return nil, orNotFound(err, ErrUserNotFound, "suspend user")
+	}
+
+	recordUserAudit(ctx, c.auditRepo, actorID, userID, model.AuditUserSuspended, "")
+
+	return &dto.APIResponse{
+		Message: "User successfully suspended!",
+	}, nil
+}
+
+// ReactivateUser lifts a suspension, tokens revoked by it stay revoked
+func (c *AdminUserController) ReactivateUser(ctx context.Context, actorID, userID int) (*dto.APIResponse, error) {
+	if err := c.userRepo.Reactivate(ctx, userID); err != nil {
+		return nil, orNotFound(err, ErrUserNotFound, "reactivate user")
+	}
+
+	recordUserAudit(ctx, c.auditRepo, actorID, userID, model.AuditUserReactivated, "")
+
+	return &dto.APIResponse{
+		Message: "User successfully reactivated!",
+	}, nil
+}
+
+// DeleteUser removes the user and their personal data, only the audit trail keeps the ID
+func (c *AdminUserController) DeleteUser(ctx context.Context, actorID, userID int) (*dto.APIResponse, error) {
+	if actorID == userID {
+		return nil, ErrOwnAccount
+	}
+
+	user, err := c.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, orNotFound(err, ErrUserNotFound, "get user")
+	}
+
+	if err := c.userRepo.Delete(ctx, userID); err != nil {
+		return nil, orNotFound(err, ErrUserNotFound, "delete user")
+	}
+
+	recordUserAudit(ctx, c.auditRepo, actorID, userID, model.AuditUserDeleted, fmt.Sprintf("role %s", user.Role))
+
+	return &dto.APIResponse{
+		Message: "User successfully deleted!",
+	}, nil
+}
+
+// ListLockouts returns the accounts and IPs with recent failed logins, locked or not
+func (c *AdminUserController) ListLockouts(ctx context.Context) ([]model.LoginThrottle, error) {
+	var window time.Duration
+	for _, policy := range throttlePolicies {
+		window = max(window, policy.window)
+	}
+
+	throttles, err := c.attemptRepo.ListThrottles(ctx, time.Now().Add(-window))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list lockouts: %w", err)
+	}
+
+	return throttles, nil
+}
+
+// ClearLockout forgets the failed logins of an account (kind "account") or an IP (kind "ip")
+func (c *AdminUserController) ClearLockout(ctx context.Context, actorID int, kind, value string) (*dto.APIResponse, error) {
+	if _, ok := throttlePolicies[kind]; !ok {
+		return nil, ErrUnknownLockout.Withf("%s", kind)
+	}
+
+	if err := c.attemptRepo.DeleteThrottle(ctx, throttleKey(kind, value)); err != nil {
+		return nil, fmt.Errorf("failed to clear lockout: %w", err)
+	}
+
+	if kind == throttleAccount {
+		if user, err := c.userRepo.GetByName(ctx, value); err == nil {
+			recordUserAudit(ctx, c.auditRepo, actorID, user.ID, model.AuditUserLockoutCleared, "")
+		}
+	}
+
+	return &dto.APIResponse{
+		Message: "Lockout successfully cleared!",
+	}, nil
+}
@@ -0,0 +1,156 @@
+package controller_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/tsntt/footballapi/internal/controller"
+	"github.com/tsntt/footballapi/internal/dto"
+	"github.com/tsntt/footballapi/internal/model"
+)
+
+func TestAdminUserController_ListUsers(t *testing.T) {
+	tests := []struct {
+		name       string
+		req        *dto.UserListRequest
+		wantFilter model.UserFilter
+		wantErr    bool
+	}{
+		{"defaults", &dto.UserListRequest{}, model.UserFilter{Limit: 20, Offset: 0}, false},
+		{"search and page", &dto.UserListRequest{Search: "ana", Page: 3, PerPage: 10}, model.UserFilter{Search: "ana", Limit: 10, Offset: 20}, false},
+		{"page size too big", &dto.UserListRequest{PerPage: 500}, model.UserFilter{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var filter model.UserFilter
+			mockUserRepo := &mockUserRepository{
+				list: func(ctx context.Context, f model.UserFilter) ([]model.User, int, error) {
+					filter = f
+					return []model.User{{ID: 1, Name: "ana"}}, 21, nil
+				},
+			}
+
+			adminUserController := controller.NewAdminUserController(mockUserRepo, nil, nil, nil, nil)
+			resp, err := adminUserController.ListUsers(context.Background(), tt.req)
+
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error, got nil")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			if filter != tt.wantFilter {
+				t.Errorf("expected filter %+v, got %+v", tt.wantFilter, filter)
+			}
+
+			if resp.Total != 21 || len(resp.Users) != 1 {
+				t.Errorf("expected 1 user of 21, got %d of %d", len(resp.Users), resp.Total)
+			}
+		})
+	}
+}
+
+func TestAdminUserController_GetUser(t *testing.T) {
+	mockUserRepo := &mockUserRepository{
+		getByID: func(ctx context.Context, id int) (*model.User, error) {
+			return &model.User{ID: id, Name: "ana", Password: "hash", Role: "default"}, nil
+		},
+	}
+	mockFanRepo := &mockFanRepository{
+		getByUserID: func(ctx context.Context, userID int) ([]model.Fan, error) {
+			return []model.Fan{{UserID: userID, TeamID: 1}}, nil
+		},
+	}
+	mockDeliveryRepo := &mockDeliveryRepository{
+		getByUserID: func(ctx context.Context, userID, limit int) ([]model.Delivery, error) {
+			return []model.Delivery{{UserID: userID, Status: model.DeliveryDelivered}}, nil
+		},
+	}
+	auditRepo := &mockAuditRepository{entries: []model.AuditEntry{
+		{ActorID: 1, Action: model.AuditUserSuspended, TargetType: model.AuditTargetUser, TargetID: 2},
+		{ActorID: 1, Action: model.AuditUserSuspended, TargetType: model.AuditTargetUser, TargetID: 3},
+	}}
+
+	adminUserController := controller.NewAdminUserController(mockUserRepo, mockFanRepo, mockDeliveryRepo, auditRepo, nil)
+	resp, err := adminUserController.GetUser(context.Background(), 2)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if resp.User.Password != "" {
+		t.Error("expected password hash to be cleared")
+	}
+
+	if len(resp.Subscriptions) != 1 || len(resp.Deliveries) != 1 || len(resp.Audit) != 1 {
+		t.Errorf("expected 1 subscription, delivery and audit entry, got %d, %d and %d",
+			len(resp.Subscriptions), len(resp.Deliveries), len(resp.Audit))
+	}
+}
+
+func TestAdminUserController_SuspendReactivateDelete(t *testing.T) {
+	var calls []string
+	mockUserRepo := &mockUserRepository{
+		getByID: func(ctx context.Context, id int) (*model.User, error) {
+			return &model.User{ID: id, Role: "default"}, nil
+		},
+		suspend: func(ctx context.Context, id int, suspendedAt time.Time) error {
+			calls = append(calls, "suspend")
+			return nil
+		},
+		reactivate: func(ctx context.Context, id int) error {
+			calls = append(calls, "reactivate")
+			return nil
+		},
+		delete: func(ctx context.Context, id int) error {
+			calls = append(calls, "delete")
+			return nil
+		},
+	}
+	auditRepo := &mockAuditRepository{}
+	adminUserController := controller.NewAdminUserController(mockUserRepo, nil, nil, auditRepo, nil)
+	ctx := context.Background()
+
+	if _, err := adminUserController.SuspendUser(ctx, 1, 2); err != nil {
+		t.Fatalf("suspend: expected no error, got %v", err)
+	}
+	if _, err := adminUserController.ReactivateUser(ctx, 1, 2); err != nil {
+		t.Fatalf("reactivate: expected no error, got %v", err)
+	}
+	if _, err := adminUserController.DeleteUser(ctx, 1, 2); err != nil {
+		t.Fatalf("delete: expected no error, got %v", err)
+	}
+
+	if len(calls) != 3 {
+		t.Fatalf("expected 3 repository calls, got %v", calls)
+	}
+
+	wantActions := []string{model.AuditUserSuspended, model.AuditUserReactivated, model.AuditUserDeleted}
+	if len(auditRepo.entries) != len(wantActions) {
+		t.Fatalf("expected %d audit entries, got %d", len(wantActions), len(auditRepo.entries))
+	}
+	for i, entry := range auditRepo.entries {
+		if entry.Action != wantActions[i] || entry.ActorID != 1 || entry.TargetID != 2 {
+			t.Errorf("unexpected audit entry %+v", entry)
+		}
+	}
+}
+
+func TestAdminUserController_OwnAccount(t *testing.T) {
+	adminUserController := controller.NewAdminUserController(&mockUserRepository{}, nil, nil, &mockAuditRepository{}, nil)
+
+	if _, err := adminUserController.SuspendUser(context.Background(), 1, 1); !errors.Is(err, controller.ErrOwnAccount) {
+		t.Errorf("suspend: expected ErrOwnAccount, got %v", err)
+	}
+
+	if _, err := adminUserController.DeleteUser(context.Background(), 1, 1); !errors.Is(err, controller.ErrOwnAccount) {
+		t.Errorf("delete: expected ErrOwnAccount, got %v", err)
+	}
+}
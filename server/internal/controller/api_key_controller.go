@@ -0,0 +1,190 @@
+package controller
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/tsntt/footballapi/internal/dto"
+	"github.com/tsntt/footballapi/internal/model"
+	"github.com/tsntt/footballapi/pkg/logging"
+	"github.com/tsntt/footballapi/pkg/utils"
+)
+
+const (
+	// Keys look like fapi_<prefix>_<secret>, the marker makes leaked keys easy to scan for
+	apiKeyMarker      = "fapi_"
+	apiKeyPrefixBytes = 6
+	apiKeySecretBytes = 32
+	defaultAPIKeyDays = 90
+	maxAPIKeysPerUser = 20
+)
+
+// APIKeyController manages the personal API keys scripts use instead of a password
+type APIKeyController struct {
+	apiKeyRepo model.IAPIKeyRepository
+	userRepo   model.IUserRepository
+	roleRepo   model.IRoleRepository
+	validator  *validator.Validate
+}
+
+func NewAPIKeyController(apiKeyRepo model.IAPIKeyRepository, userRepo model.IUserRepository, roleRepo model.IRoleRepository) *APIKeyController {
+	return &APIKeyController{
+		apiKeyRepo: apiKeyRepo,
+		userRepo:   userRepo,
+		roleRepo:   roleRepo,
+		validator:  newValidator(),
+	}
+}
+
+func (c *APIKeyController) Create(ctx context.Context, userID int, req *dto.CreateAPIKeyRequest) (*dto.CreateAPIKeyResponse, error) {
+	if err := c.validator.Struct(req); err != nil {
+		return nil, invalid(err)
+	}
+
+	user, err := c.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	permissions, err := c.roleRepo.GetPermissions(ctx, user.Role)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get permissions: %w", err)
+	}
+
+	var scopes []string
+	for _, scope := range req.Scopes {
+		if scope != model.ScopeRead && scope != model.ScopeWrite && !slices.Contains(permissions, scope) {
+			return nil, ErrInvalidScope.Withf("%s", scope)
+		}
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	count, err := c.apiKeyRepo.CountActive(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if count >= maxAPIKeysPerUser {
+		return nil, ErrAPIKeyLimit
+	}
+
+	prefix, err := utils.GenerateRandomToken(apiKeyPrefixBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := utils.GenerateRandomToken(apiKeySecretBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	days := req.ExpiresInDays
+	if days == 0 {
+		days = defaultAPIKeyDays
+	}
+
+	key := apiKeyMarker + prefix + "_" + secret
+	apiKey := &model.APIKey{
+		UserID:    userID,
+		Name:      req.Name,
+		Prefix:    apiKeyMarker + prefix,
+		KeyHash:   utils.HashToken(key),
+		Scopes:    scopes,
+		ExpiresAt: time.Now().AddDate(0, 0, days),
+	}
+
+	if err := c.apiKeyRepo.Create(ctx, apiKey); err != nil {
+		return nil, err
+	}
+
+	logging.FromContext(ctx).Info("API key created", slog.Int("user_id", userID), slog.String("prefix", apiKey.Prefix))
+	return &dto.CreateAPIKeyResponse{Key: key, APIKey: *apiKey}, nil
+}
+
+// List includes revoked and expired keys, so the user can still see when they were last used
+func (c *APIKeyController) List(ctx context.Context, userID int) ([]model.APIKey, error) {
+	return c.apiKeyRepo.ListByUser(ctx, userID)
+}
+
+func (c *APIKeyController) Revoke(ctx context.Context, userID, id int) (*dto.APIResponse, error) {
+	revoked, err := c.apiKeyRepo.Revoke(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if !revoked {
+		return nil, ErrAPIKeyNotFound
+	}
+
+	logging.FromContext(ctx).Info("API key revoked", slog.Int("user_id", userID), slog.Int("api_key_id", id))
+	return &dto.APIResponse{Message: "API key revoked"}, nil
+}
+
+// AuthenticateAPIKey returns the identity of a request made with key. Permissions are the scopes
+// of the key that the role of its owner still grants, read now rather than when the key was made.
+func (c *APIKeyController) AuthenticateAPIKey(ctx context.Context, key, ip string) (*dto.JWTClaims, error) {
+	rest, ok := strings.CutPrefix(key, apiKeyMarker)
+	if !ok {
+		return nil, ErrInvalidAPIKey
+	}
+
+	prefix, _, ok := strings.Cut(rest, "_")
+	if !ok {
+		return nil, ErrInvalidAPIKey
+	}
+
+	apiKey, err := c.apiKeyRepo.GetByPrefix(ctx, apiKeyMarker+prefix)
+	if err != nil {
+		return nil, ErrInvalidAPIKey
+	}
+
+	if subtle.ConstantTimeCompare([]byte(utils.HashToken(key)), []byte(apiKey.KeyHash)) != 1 {
+		return nil, ErrInvalidAPIKey
+	}
+
+	if apiKey.RevokedAt != nil || time.Now().After(apiKey.ExpiresAt) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	user, err := c.userRepo.GetByID(ctx, apiKey.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if user.SuspendedAt != nil {
+		return nil, ErrUserSuspended
+	}
+
+	permissions, err := c.roleRepo.GetPermissions(ctx, user.Role)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get permissions: %w", err)
+	}
+
+	granted := []string{}
+	for _, permission := range permissions {
+		if slices.Contains(apiKey.Scopes, permission) {
+			granted = append(granted, permission)
+		}
+	}
+
+	if err := c.apiKeyRepo.TouchLastUsed(ctx, apiKey.ID, ip); err != nil {
+		logging.FromContext(ctx).Error("Failed to update api key last use", slog.Int("api_key_id", apiKey.ID), slog.String("err", err.Error()))
+	}
+
+	return &dto.JWTClaims{
+		UserID:      user.ID,
+		Name:        user.Name,
+		Role:        user.Role,
+		Permissions: granted,
+		ID:          "apikey-" + strconv.Itoa(apiKey.ID),
+		APIKeyID:    apiKey.ID,
+		Scopes:      apiKey.Scopes,
+	}, nil
+}
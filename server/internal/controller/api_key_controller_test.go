@@ -0,0 +1,203 @@
+package controller_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tsntt/footballapi/internal/controller"
+	"github.com/tsntt/footballapi/internal/dto"
+	"github.com/tsntt/footballapi/internal/model"
+)
+
+func newAPIKeyTest(users ...*model.User) (*controller.APIKeyController, *mockAPIKeyRepository) {
+	byID := map[int]*model.User{}
+	for _, user := range users {
+		byID[user.ID] = user
+	}
+
+	userRepo := &mockUserRepository{
+		getByID: func(ctx context.Context, id int) (*model.User, error) {
+			if user, ok := byID[id]; ok {
+				return user, nil
+			}
+			return nil, errors.New("user not found")
+		},
+	}
+
+	apiKeyRepo := &mockAPIKeyRepository{}
+	return controller.NewAPIKeyController(apiKeyRepo, userRepo, mockRoleRepo()), apiKeyRepo
+}
+
+func TestAPIKeyController_CreateAndAuthenticate(t *testing.T) {
+	admin := &model.User{ID: 1, Name: "admin", Role: "admin"}
+	apiKeyController, apiKeyRepo := newAPIKeyTest(admin)
+	ctx := context.Background()
+
+	created, err := apiKeyController.Create(ctx, admin.ID, &dto.CreateAPIKeyRequest{
+		Name:   "nightly broadcast",
+		Scopes: []string{model.ScopeRead, model.PermBroadcastSend, model.ScopeRead},
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if !strings.HasPrefix(created.Key, created.Prefix+"_") || !strings.HasPrefix(created.Prefix, "fapi_") {
+		t.Errorf("expected the key %q to start with its prefix %q", created.Key, created.Prefix)
+	}
+
+	stored := apiKeyRepo.keys[0]
+	if strings.Contains(stored.KeyHash, created.Key) || stored.KeyHash == "" {
+		t.Error("expected only a hash of the key to be stored")
+	}
+
+	if len(stored.Scopes) != 2 {
+		t.Errorf("expected duplicate scopes to be dropped, got %v", stored.Scopes)
+	}
+
+	if days := time.Until(stored.ExpiresAt).Hours() / 24; days < 89 || days > 90 {
+		t.Errorf("expected the key to expire in 90 days, got %.1f", days)
+	}
+
+	claims, err := apiKeyController.AuthenticateAPIKey(ctx, created.Key, "203.0.113.7")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if claims.UserID != admin.ID || claims.APIKeyID != stored.ID {
+		t.Errorf("expected the identity of user %d through key %d, got %+v", admin.ID, stored.ID, claims)
+	}
+
+	// only the role permissions the key was given
+	if !slices.Equal(claims.Permissions, []string{model.PermBroadcastSend}) {
+		t.Errorf("expected permissions [%s], got %v", model.PermBroadcastSend, claims.Permissions)
+	}
+
+	if stored.LastUsedAt == nil || stored.LastUsedIP == nil || *stored.LastUsedIP != "203.0.113.7" {
+		t.Errorf("expected the last use to be recorded, got %v %v", stored.LastUsedAt, stored.LastUsedIP)
+	}
+}
+
+func TestAPIKeyController_Create_ScopeNotGranted(t *testing.T) {
+	user := &model.User{ID: 2, Name: "ana", Role: "default"}
+	apiKeyController, apiKeyRepo := newAPIKeyTest(user)
+
+	_, err := apiKeyController.Create(context.Background(), user.ID, &dto.CreateAPIKeyRequest{
+		Name:   "bot",
+		Scopes: []string{model.ScopeRead, model.PermUsersManage},
+	})
+	if !errors.Is(err, controller.ErrInvalidScope) {
+		t.Fatalf("expected ErrInvalidScope, got %v", err)
+	}
+
+	if len(apiKeyRepo.keys) != 0 {
+		t.Errorf("expected no key to be created, got %d", len(apiKeyRepo.keys))
+	}
+}
+
+func TestAPIKeyController_Create_Limit(t *testing.T) {
+	user := &model.User{ID: 2, Name: "ana", Role: "default"}
+	apiKeyController, _ := newAPIKeyTest(user)
+	req := &dto.CreateAPIKeyRequest{Name: "bot", Scopes: []string{model.ScopeRead}}
+
+	var err error
+	for i := 0; i < 21 && err == nil; i++ {
+		_, err = apiKeyController.Create(context.Background(), user.ID, req)
+	}
+
+	if !errors.Is(err, controller.ErrAPIKeyLimit) {
+		t.Fatalf("expected ErrAPIKeyLimit, got %v", err)
+	}
+}
+
+func TestAPIKeyController_AuthenticateAPIKey_Rejected(t *testing.T) {
+	user := &model.User{ID: 2, Name: "ana", Role: "default"}
+	ctx := context.Background()
+	req := &dto.CreateAPIKeyRequest{Name: "bot", Scopes: []string{model.ScopeRead}}
+
+	tests := []struct {
+		name    string
+		prepare func(repo *mockAPIKeyRepository, key string) string
+		wantErr error
+	}{
+		{
+			name:    "wrong secret",
+			prepare: func(repo *mockAPIKeyRepository, key string) string { return key[:len(key)-1] + "x" },
+			wantErr: controller.ErrInvalidAPIKey,
+		},
+		{
+			name:    "not a key",
+			prepare: func(repo *mockAPIKeyRepository, key string) string { return "Bearer abc" },
+			wantErr: controller.ErrInvalidAPIKey,
+		},
+		{
+			name: "expired",
+			prepare: func(repo *mockAPIKeyRepository, key string) string {
+				repo.keys[0].ExpiresAt = time.Now().Add(-time.Minute)
+				return key
+			},
+			wantErr: controller.ErrInvalidAPIKey,
+		},
+		{
+			name: "revoked",
+			prepare: func(repo *mockAPIKeyRepository, key string) string {
+				repo.Revoke(ctx, user.ID, 1)
+				return key
+			},
+			wantErr: controller.ErrInvalidAPIKey,
+		},
+		{
+			name: "owner suspended",
+			prepare: func(repo *mockAPIKeyRepository, key string) string {
+				suspendedAt := time.Now()
+				user.SuspendedAt = &suspendedAt
+				return key
+			},
+			wantErr: controller.ErrUserSuspended,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user.SuspendedAt = nil
+			apiKeyController, apiKeyRepo := newAPIKeyTest(user)
+
+			created, err := apiKeyController.Create(ctx, user.ID, req)
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			_, err = apiKeyController.AuthenticateAPIKey(ctx, tt.prepare(apiKeyRepo, created.Key), "203.0.113.7")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestAPIKeyController_Revoke_OtherUser(t *testing.T) {
+	ana := &model.User{ID: 2, Name: "ana", Role: "default"}
+	bia := &model.User{ID: 3, Name: "bia", Role: "default"}
+	apiKeyController, _ := newAPIKeyTest(ana, bia)
+	ctx := context.Background()
+
+	created, err := apiKeyController.Create(ctx, ana.ID, &dto.CreateAPIKeyRequest{Name: "bot", Scopes: []string{model.ScopeRead}})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if _, err := apiKeyController.Revoke(ctx, bia.ID, created.ID); !errors.Is(err, controller.ErrAPIKeyNotFound) {
+		t.Fatalf("expected ErrAPIKeyNotFound, got %v", err)
+	}
+
+	if _, err := apiKeyController.Revoke(ctx, ana.ID, created.ID); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if _, err := apiKeyController.AuthenticateAPIKey(ctx, created.Key, "203.0.113.7"); !errors.Is(err, controller.ErrInvalidAPIKey) {
+		t.Errorf("expected a revoked key to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,30 @@
+package controller
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/tsntt/footballapi/internal/model"
+	"github.com/tsntt/footballapi/pkg/logging"
+)
+
+// recordUserAudit runs after the change is committed, a failure is logged rather than reported
+// to the caller as the change itself succeeded
+func recordUserAudit(ctx context.Context, auditRepo model.IAuditRepository, actorID, userID int, action, details string) {
+	entry := &model.AuditEntry{
+		ActorID:    actorID,
+		Action:     action,
+		TargetType: model.AuditTargetUser,
+		TargetID:   userID,
+		Details:    details,
+	}
+
+	if err := auditRepo.Create(ctx, entry); err != nil {
+		logging.FromContext(ctx).Error("Failed to record audit entry",
+			slog.String("action", action),
+			slog.Int("actor_id", actorID),
+			slog.Int("user_id", userID),
+			slog.String("err", err.Error()),
+		)
+	}
+}
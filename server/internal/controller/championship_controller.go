@@ -2,7 +2,6 @@ package controller
 
 import (
 	"context"
-	"fmt"
 	"strconv"
 
 	"github.com/go-playground/validator/v10"
@@ -17,14 +16,14 @@ type ChampionshipController struct {
 func NewChampionshipController(externalAPI model.IChampionshipAPI) *ChampionshipController {
 	return &ChampionshipController{
 		externalAPI: externalAPI,
-		validator:   validator.New(),
+		validator:   newValidator(),
 	}
 }
 
 func (c *ChampionshipController) GetChampionships(ctx context.Context) ([]model.Championship, error) {
 	championships, err := c.externalAPI.GetChampionships(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get championships: %w", err)
+		return nil, ErrFootballAPIDown.Wrap(err)
 	}
 	return championships, nil
 }
@@ -32,12 +31,12 @@ func (c *ChampionshipController) GetChampionships(ctx context.Context) ([]model.
 func (c *ChampionshipController) GetMatches(ctx context.Context, championshipIDStr, team, stage string) ([]model.Match, error) {
 	championshipID, err := strconv.Atoi(championshipIDStr)
 	if err != nil {
-		return nil, fmt.Errorf("invalid championship ID: %w", err)
+		return nil, ErrInvalidRequest.Withf("invalid championship ID").Wrap(err)
 	}
 
 	matches, err := c.externalAPI.GetMatches(ctx, championshipID, team, stage)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get matches: %w", err)
+		return nil, ErrFootballAPIDown.Wrap(err)
 	}
 	return matches, nil
 }
@@ -0,0 +1,285 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tsntt/footballapi/internal/dto"
+	"github.com/tsntt/footballapi/internal/model"
+	"github.com/tsntt/footballapi/pkg/broadcast"
+	"github.com/tsntt/footballapi/pkg/logging"
+)
+
+// Twilio reports more states than we track, fold them into ours
+var twilioStatuses = map[string]string{
+	"accepted":    model.DeliveryQueued,
+	"scheduled":   model.DeliveryQueued,
+	"queued":      model.DeliveryQueued,
+	"sending":     model.DeliveryQueued,
+	"sent":        model.DeliverySent,
+	"delivered":   model.DeliveryDelivered,
+	"undelivered": model.DeliveryUndelivered,
+	"failed":      model.DeliveryFailed,
+	"canceled":    model.DeliveryFailed,
+}
+
+// Mailgun event names mapped to the events we keep, "failed" is split by severity
+var mailgunEvents = map[string]string{
+	"delivered":  model.EventDelivered,
+	"opened":     model.EventOpened,
+	"clicked":    model.EventClicked,
+	"complained": model.EventComplained,
+}
+
+// Opt-out keywords carriers expect us to honour, plus the Portuguese ones our fans use
+var stopKeywords = map[string]bool{
+	"STOP":        true,
+	"STOPALL":     true,
+	"UNSUBSCRIBE": true,
+	"CANCEL":      true,
+	"END":         true,
+	"QUIT":        true,
+	"PARAR":       true,
+	"SAIR":        true,
+}
+
+// Mailgun signs the timestamp of the request, an older one (or a token already seen) is a replay
+const webhookMaxAge = 5 * time.Minute
+
+type DeliveryController struct {
+	deliveryRepo   model.IDeliveryRepository
+	broadcastRepo  model.IBroadcastRepository
+	fanRepo        model.IFanRepository
+	smsValidator   model.ISMSWebhookValidator
+	emailValidator model.IEmailWebhookValidator
+	webhookTokens  model.IWebhookTokenRepository
+}
+
+func NewDeliveryController(
+	deliveryRepo model.IDeliveryRepository,
+	broadcastRepo model.IBroadcastRepository,
+	fanRepo model.IFanRepository,
+	smsValidator model.ISMSWebhookValidator,
+	emailValidator model.IEmailWebhookValidator,
+	webhookTokens model.IWebhookTokenRepository,
+) *DeliveryController {
+	return &DeliveryController{
+		deliveryRepo:   deliveryRepo,
+		broadcastRepo:  broadcastRepo,
+		fanRepo:        fanRepo,
+		smsValidator:   smsValidator,
+		emailValidator: emailValidator,
+		webhookTokens:  webhookTokens,
+	}
+}
+
+// RecordDelivery implements broadcast.IDeliveryRecorder
+func (c *DeliveryController) RecordDelivery(ctx context.Context, report broadcast.DeliveryReport) error {
+	delivery := &model.Delivery{
+		BroadcastID:       report.BroadcastID,
+		UserID:            report.Subscription.UserID,
+		NotificationType:  string(report.Subscription.NotificationType),
+		Address:           report.Subscription.Address,
+		ProviderMessageID: report.ProviderMessageID,
+		Status:            model.DeliveryQueued,
+	}
+
+	if report.Error != nil {
+		delivery.Status = model.DeliveryFailed
+		delivery.ErrorMessage = report.Error.Error()
+	}
+
+	if err := c.deliveryRepo.Create(ctx, delivery); err != nil {
+		return fmt.Errorf("failed to record delivery: %w", err)
+	}
+
+	return nil
+}
+
+func (c *DeliveryController) HandleSMSStatus(ctx context.Context, params map[string]string, signature string) error {
+	if !c.smsValidator.ValidateStatusCallback(params, signature) {
+		return ErrInvalidSignature
+	}
+
+	sid := params["MessageSid"]
+	if sid == "" {
+		return ErrInvalidRequest.Withf("missing MessageSid")
+	}
+
+	status, ok := twilioStatuses[params["MessageStatus"]]
+	if !ok {
+		return ErrInvalidRequest.Withf("unknown message status %s", params["MessageStatus"])
+	}
+
+	delivery, err := c.deliveryRepo.GetByProviderMessageID(ctx, sid)
+	if err != nil {
+		return orNotFound(err, ErrDeliveryNotFound, "get delivery")
+	}
+
+	// Twilio does not guarantee callback order, a late "sent" must not override "delivered"
+	if !delivery.CanTransitionTo(status) {
+		return nil
+	}
+
+	delivery.Status = status
+	if code := params["ErrorCode"]; code != "" {
+		delivery.ErrorMessage = fmt.Sprintf("twilio error %s", code)
+	}
+
+	if err := c.deliveryRepo.UpdateStatus(ctx, delivery); err != nil {
+		return fmt.Errorf("failed to update delivery: %w", err)
+	}
+
+	return nil
+}
+
+// HandleSMSInbound disables every SMS subscription of the sender when they reply with an opt-out keyword
+func (c *DeliveryController) HandleSMSInbound(ctx context.Context, params map[string]string, signature string) error {
+	if !c.smsValidator.ValidateInbound(params, signature) {
+		return ErrInvalidSignature
+	}
+
+	keyword := strings.ToUpper(strings.TrimSpace(params["Body"]))
+	if !stopKeywords[keyword] {
+		return nil
+	}
+
+	disabled, err := c.fanRepo.DisableByAddress(ctx, "sms", params["From"])
+	if err != nil {
+		return fmt.Errorf("failed to disable sms subscriptions: %w", err)
+	}
+
+	logging.FromContext(ctx).Info("Disabled sms subscriptions", slog.String("keyword", keyword), slog.Int64("count", disabled))
+
+	return nil
+}
+
+func (c *DeliveryController) HandleEmailEvent(ctx context.Context, req *dto.MailgunWebhookRequest) error {
+	sig := req.Signature
+	if !c.emailValidator.VerifyWebhook(sig.Timestamp, sig.Token, sig.Signature) {
+		return ErrInvalidSignature
+	}
+
+	timestamp, err := strconv.ParseInt(sig.Timestamp, 10, 64)
+	if err != nil || time.Since(time.Unix(timestamp, 0)).Abs() > webhookMaxAge {
+		return fmt.Errorf("%w: stale timestamp", ErrInvalidSignature)
+	}
+
+	fresh, err := c.webhookTokens.Claim(ctx, sig.Token, time.Now().Add(-webhookMaxAge))
+	if err != nil {
+		return err
+	}
+	if !fresh {
+		return fmt.Errorf("%w: token already used", ErrInvalidSignature)
+	}
+
+	// Mailgun retries a failed event with the same token, it must not be refused as a replay
+	if err := c.handleEmailEvent(ctx, req.EventData); err != nil {
+		if releaseErr := c.webhookTokens.Release(ctx, sig.Token); releaseErr != nil {
+			logging.FromContext(ctx).Error("Failed to release webhook token", slog.String("err", releaseErr.Error()))
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (c *DeliveryController) handleEmailEvent(ctx context.Context, data dto.MailgunEventData) error {
+	event, ok := mailgunEvents[data.Event]
+	if data.Event == "failed" {
+		// temporary failures are retried by Mailgun, only a permanent one is a bounce
+		event, ok = model.EventBounced, data.Severity == "permanent"
+	}
+
+	if !ok {
+		return nil
+	}
+
+	deliveryEvent := &model.DeliveryEvent{
+		ProviderEventID: data.ID,
+		Event:           event,
+		Recipient:       data.Recipient,
+		Reason:          data.Reason,
+		OccurredAt:      time.Unix(int64(data.Timestamp), 0).UTC(),
+	}
+
+	// Not every email is a broadcast, events for other messages are kept without a delivery
+	delivery, err := c.deliveryRepo.GetByProviderMessageID(ctx, data.Message.Headers.MessageID)
+	if err == nil {
+		deliveryEvent.DeliveryID = &delivery.ID
+
+		if err := c.updateEmailDeliveryStatus(ctx, delivery, event, data); err != nil {
+			return err
+		}
+	}
+
+	if err := c.deliveryRepo.AddEvent(ctx, deliveryEvent); err != nil {
+		return fmt.Errorf("failed to record email event: %w", err)
+	}
+
+	if event == model.EventBounced || event == model.EventComplained {
+		disabled, err := c.fanRepo.DisableByAddress(ctx, "email", data.Recipient)
+		if err != nil {
+			return fmt.Errorf("failed to disable email subscriptions: %w", err)
+		}
+
+		logging.FromContext(ctx).Info("Disabled email subscriptions", slog.String("event", event), slog.Int64("count", disabled))
+	}
+
+	return nil
+}
+
+func (c *DeliveryController) updateEmailDeliveryStatus(ctx context.Context, delivery *model.Delivery, event string, data dto.MailgunEventData) error {
+	var status string
+	switch event {
+	case model.EventDelivered:
+		status = model.DeliveryDelivered
+	case model.EventBounced:
+		status = model.DeliveryFailed
+		delivery.ErrorMessage = data.DeliveryStatus.Description
+		if delivery.ErrorMessage == "" {
+			delivery.ErrorMessage = data.DeliveryStatus.Message
+		}
+	default:
+		return nil
+	}
+
+	if !delivery.CanTransitionTo(status) {
+		return nil
+	}
+
+	delivery.Status = status
+	if err := c.deliveryRepo.UpdateStatus(ctx, delivery); err != nil {
+		return fmt.Errorf("failed to update delivery: %w", err)
+	}
+
+	return nil
+}
+
+func (c *DeliveryController) GetMatchDeliveries(ctx context.Context, matchID int) (*dto.DeliveryReportResponse, error) {
+	broadcastMessage, err := c.broadcastRepo.GetByMatchID(ctx, matchID)
+	if err != nil {
+		return nil, orNotFound(err, ErrBroadcastNotFound, "get broadcast")
+	}
+
+	deliveries, err := c.deliveryRepo.GetByBroadcastID(ctx, broadcastMessage.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get deliveries: %w", err)
+	}
+
+	summary := map[string]int{}
+	for _, delivery := range deliveries {
+		summary[delivery.Status]++
+	}
+
+	return &dto.DeliveryReportResponse{
+		MatchID:     matchID,
+		BroadcastID: broadcastMessage.ID,
+		Summary:     summary,
+		Deliveries:  deliveries,
+	}, nil
+}
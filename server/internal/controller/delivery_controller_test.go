@@ -0,0 +1,396 @@
+package controller_test
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/tsntt/footballapi/internal/controller"
+	"github.com/tsntt/footballapi/internal/dto"
+	"github.com/tsntt/footballapi/internal/model"
+	"github.com/tsntt/footballapi/pkg/broadcast"
+)
+
+func TestDeliveryController_RecordDelivery(t *testing.T) {
+	var saved *model.Delivery
+	mockDeliveryRepo := &mockDeliveryRepository{
+		create: func(ctx context.Context, delivery *model.Delivery) error {
+			saved = delivery
+			return nil
+		},
+	}
+
+	deliveryController := controller.NewDeliveryController(mockDeliveryRepo, nil, nil, nil, nil, nil)
+	err := deliveryController.RecordDelivery(context.Background(), broadcast.DeliveryReport{
+		BroadcastID: 1,
+		Subscription: broadcast.Subscription{
+			UserID:           2,
+			NotificationType: broadcast.SMS,
+			Address:          "+5511999999999",
+		},
+		ProviderMessageID: "SM123",
+	})
+
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if saved.Status != model.DeliveryQueued {
+		t.Errorf("expected status %s, got %s", model.DeliveryQueued, saved.Status)
+	}
+
+	if saved.ProviderMessageID != "SM123" {
+		t.Errorf("expected provider message id SM123, got %s", saved.ProviderMessageID)
+	}
+}
+
+func TestDeliveryController_RecordDelivery_SendError(t *testing.T) {
+	var saved *model.Delivery
+	mockDeliveryRepo := &mockDeliveryRepository{
+		create: func(ctx context.Context, delivery *model.Delivery) error {
+			saved = delivery
+			return nil
+		},
+	}
+
+	deliveryController := controller.NewDeliveryController(mockDeliveryRepo, nil, nil, nil, nil, nil)
+	err := deliveryController.RecordDelivery(context.Background(), broadcast.DeliveryReport{
+		BroadcastID: 1,
+		Error:       errors.New("invalid phone number format"),
+	})
+
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if saved.Status != model.DeliveryFailed {
+		t.Errorf("expected status %s, got %s", model.DeliveryFailed, saved.Status)
+	}
+}
+
+func TestDeliveryController_HandleSMSStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		valid      bool
+		current    string
+		params     map[string]string
+		wantErr    error
+		wantStatus string
+	}{
+		{
+			name:       "sent to delivered",
+			valid:      true,
+			current:    model.DeliverySent,
+			params:     map[string]string{"MessageSid": "SM123", "MessageStatus": "delivered"},
+			wantStatus: model.DeliveryDelivered,
+		},
+		{
+			name:       "late sent does not override delivered",
+			valid:      true,
+			current:    model.DeliveryDelivered,
+			params:     map[string]string{"MessageSid": "SM123", "MessageStatus": "sent"},
+			wantStatus: model.DeliveryDelivered,
+		},
+		{
+			name:       "undelivered keeps error code",
+			valid:      true,
+			current:    model.DeliveryQueued,
+			params:     map[string]string{"MessageSid": "SM123", "MessageStatus": "undelivered", "ErrorCode": "30003"},
+			wantStatus: model.DeliveryUndelivered,
+		},
+		{
+			name:       "invalid signature",
+			valid:      false,
+			current:    model.DeliveryQueued,
+			params:     map[string]string{"MessageSid": "SM123", "MessageStatus": "delivered"},
+			wantErr:    controller.ErrInvalidSignature,
+			wantStatus: model.DeliveryQueued,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delivery := &model.Delivery{ID: 1, ProviderMessageID: "SM123", Status: tt.current}
+			mockDeliveryRepo := &mockDeliveryRepository{
+				getByProviderMessageID: func(ctx context.Context, providerMessageID string) (*model.Delivery, error) {
+					return delivery, nil
+				},
+				updateStatus: func(ctx context.Context, d *model.Delivery) error {
+					return nil
+				},
+			}
+
+			deliveryController := controller.NewDeliveryController(mockDeliveryRepo, nil, nil, &mockSMSWebhookValidator{valid: tt.valid}, nil, nil)
+			err := deliveryController.HandleSMSStatus(context.Background(), tt.params, "signature")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("DeliveryController.HandleSMSStatus() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if delivery.Status != tt.wantStatus {
+				t.Errorf("expected status %s, got %s", tt.wantStatus, delivery.Status)
+			}
+		})
+	}
+}
+
+func TestDeliveryController_GetMatchDeliveries(t *testing.T) {
+	mockBroadcastRepo := &mockBroadcastRepository{
+		getByMatchID: func(ctx context.Context, matchID int) (*model.BroadcastMessage, error) {
+			return &model.BroadcastMessage{ID: 10, MatchID: matchID}, nil
+		},
+	}
+	mockDeliveryRepo := &mockDeliveryRepository{
+		getByBroadcastID: func(ctx context.Context, broadcastID int) ([]model.Delivery, error) {
+			return []model.Delivery{
+				{ID: 1, Status: model.DeliveryDelivered},
+				{ID: 2, Status: model.DeliveryDelivered},
+				{ID: 3, Status: model.DeliveryFailed},
+			}, nil
+		},
+	}
+
+	deliveryController := controller.NewDeliveryController(mockDeliveryRepo, mockBroadcastRepo, nil, nil, nil, nil)
+	report, err := deliveryController.GetMatchDeliveries(context.Background(), 123)
+
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if report.BroadcastID != 10 {
+		t.Errorf("expected broadcast id 10, got %d", report.BroadcastID)
+	}
+
+	if report.Summary[model.DeliveryDelivered] != 2 || report.Summary[model.DeliveryFailed] != 1 {
+		t.Errorf("unexpected summary: %v", report.Summary)
+	}
+}
+
+func TestDeliveryController_HandleEmailEvent(t *testing.T) {
+	tests := []struct {
+		name         string
+		valid        bool
+		event        string
+		severity     string
+		wantErr      error
+		wantStatus   string
+		wantEvent    string
+		wantDisabled bool
+		// how old the signed timestamp is, and whether its token was already used
+		age      time.Duration
+		replayed bool
+	}{
+		{
+			name:       "delivered",
+			valid:      true,
+			event:      "delivered",
+			wantStatus: model.DeliveryDelivered,
+			wantEvent:  model.EventDelivered,
+		},
+		{
+			name:       "opened keeps status",
+			valid:      true,
+			event:      "opened",
+			wantStatus: model.DeliveryQueued,
+			wantEvent:  model.EventOpened,
+		},
+		{
+			name:         "hard bounce disables subscription",
+			valid:        true,
+			event:        "failed",
+			severity:     "permanent",
+			wantStatus:   model.DeliveryFailed,
+			wantEvent:    model.EventBounced,
+			wantDisabled: true,
+		},
+		{
+			name:       "temporary failure is ignored",
+			valid:      true,
+			event:      "failed",
+			severity:   "temporary",
+			wantStatus: model.DeliveryQueued,
+		},
+		{
+			name:         "complaint disables subscription",
+			valid:        true,
+			event:        "complained",
+			wantStatus:   model.DeliveryQueued,
+			wantEvent:    model.EventComplained,
+			wantDisabled: true,
+		},
+		{
+			name:       "invalid signature",
+			valid:      false,
+			event:      "complained",
+			wantErr:    controller.ErrInvalidSignature,
+			wantStatus: model.DeliveryQueued,
+		},
+		{
+			name:       "stale timestamp",
+			valid:      true,
+			event:      "complained",
+			age:        10 * time.Minute,
+			wantErr:    controller.ErrInvalidSignature,
+			wantStatus: model.DeliveryQueued,
+		},
+		{
+			name:       "replayed token",
+			valid:      true,
+			event:      "complained",
+			replayed:   true,
+			wantErr:    controller.ErrInvalidSignature,
+			wantStatus: model.DeliveryQueued,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delivery := &model.Delivery{ID: 1, ProviderMessageID: "abc@mg.example.com", Status: model.DeliveryQueued}
+			webhookTokens := &mockWebhookTokenRepository{seen: map[string]bool{"token-1": tt.replayed}}
+			var recorded *model.DeliveryEvent
+			disabled := false
+
+			mockDeliveryRepo := &mockDeliveryRepository{
+				getByProviderMessageID: func(ctx context.Context, providerMessageID string) (*model.Delivery, error) {
+					return delivery, nil
+				},
+				updateStatus: func(ctx context.Context, d *model.Delivery) error {
+					return nil
+				},
+				addEvent: func(ctx context.Context, event *model.DeliveryEvent) error {
+					recorded = event
+					return nil
+				},
+			}
+			mockFanRepo := &mockFanRepository{
+				disableByAddress: func(ctx context.Context, notificationType, address string) (int64, error) {
+					disabled = notificationType == "email" && address == "fan@example.com"
+					return 1, nil
+				},
+			}
+
+			req := &dto.MailgunWebhookRequest{}
+			req.Signature.Timestamp = strconv.FormatInt(time.Now().Add(-tt.age).Unix(), 10)
+			req.Signature.Token = "token-1"
+			req.EventData.ID = "event-1"
+			req.EventData.Event = tt.event
+			req.EventData.Severity = tt.severity
+			req.EventData.Recipient = "fan@example.com"
+			req.EventData.Message.Headers.MessageID = delivery.ProviderMessageID
+
+			deliveryController := controller.NewDeliveryController(mockDeliveryRepo, nil, mockFanRepo, nil, &mockEmailWebhookValidator{valid: tt.valid}, webhookTokens)
+			err := deliveryController.HandleEmailEvent(context.Background(), req)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("DeliveryController.HandleEmailEvent() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if delivery.Status != tt.wantStatus {
+				t.Errorf("expected status %s, got %s", tt.wantStatus, delivery.Status)
+			}
+
+			if tt.wantEvent == "" && recorded != nil {
+				t.Errorf("expected no event, got %s", recorded.Event)
+			}
+
+			if tt.wantEvent != "" && (recorded == nil || recorded.Event != tt.wantEvent) {
+				t.Errorf("expected event %s, got %v", tt.wantEvent, recorded)
+			}
+
+			if disabled != tt.wantDisabled {
+				t.Errorf("expected disabled = %v, got %v", tt.wantDisabled, disabled)
+			}
+		})
+	}
+}
+
+func TestDeliveryController_HandleEmailEvent_Retry(t *testing.T) {
+	failures := 1
+	var recorded []*model.DeliveryEvent
+	mockDeliveryRepo := &mockDeliveryRepository{
+		getByProviderMessageID: func(ctx context.Context, providerMessageID string) (*model.Delivery, error) {
+			return nil, model.ErrNotFound
+		},
+		addEvent: func(ctx context.Context, event *model.DeliveryEvent) error {
+			if failures > 0 {
+				failures--
+				return errors.New("connection reset")
+			}
+			recorded = append(recorded, event)
+			return nil
+		},
+	}
+	disabled := 0
+	mockFanRepo := &mockFanRepository{
+		disableByAddress: func(ctx context.Context, notificationType, address string) (int64, error) {
+			disabled++
+			return 1, nil
+		},
+	}
+
+	req := &dto.MailgunWebhookRequest{}
+	req.Signature.Timestamp = strconv.FormatInt(time.Now().Unix(), 10)
+	req.Signature.Token = "token-1"
+	req.EventData.ID = "event-1"
+	req.EventData.Event = "complained"
+	req.EventData.Recipient = "fan@example.com"
+
+	deliveryController := controller.NewDeliveryController(mockDeliveryRepo, nil, mockFanRepo, nil, &mockEmailWebhookValidator{valid: true}, &mockWebhookTokenRepository{})
+	if err := deliveryController.HandleEmailEvent(context.Background(), req); err == nil || errors.Is(err, controller.ErrInvalidSignature) {
+		t.Fatalf("expected the failure to be returned, got %v", err)
+	}
+
+	// Mailgun sends the same request again
+	if err := deliveryController.HandleEmailEvent(context.Background(), req); err != nil {
+		t.Fatalf("expected the retry to be handled, got %v", err)
+	}
+	if len(recorded) != 1 || disabled != 1 {
+		t.Errorf("expected the complaint to be handled once, got %d events and %d disables", len(recorded), disabled)
+	}
+
+	// and once it went through it is a replay
+	if err := deliveryController.HandleEmailEvent(context.Background(), req); !errors.Is(err, controller.ErrInvalidSignature) {
+		t.Errorf("expected a replay to be refused, got %v", err)
+	}
+}
+
+func TestDeliveryController_HandleSMSInbound(t *testing.T) {
+	tests := []struct {
+		name         string
+		valid        bool
+		body         string
+		wantErr      error
+		wantDisabled bool
+	}{
+		{name: "stop", valid: true, body: " stop ", wantDisabled: true},
+		{name: "portuguese keyword", valid: true, body: "SAIR", wantDisabled: true},
+		{name: "regular reply", valid: true, body: "Go team!"},
+		{name: "invalid signature", valid: false, body: "STOP", wantErr: controller.ErrInvalidSignature},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			disabled := false
+			mockFanRepo := &mockFanRepository{
+				disableByAddress: func(ctx context.Context, notificationType, address string) (int64, error) {
+					disabled = notificationType == "sms" && address == "+5511999999999"
+					return 1, nil
+				},
+			}
+
+			deliveryController := controller.NewDeliveryController(nil, nil, mockFanRepo, &mockSMSWebhookValidator{valid: tt.valid}, nil, nil)
+			err := deliveryController.HandleSMSInbound(context.Background(), map[string]string{
+				"From": "+5511999999999",
+				"Body": tt.body,
+			}, "signature")
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("DeliveryController.HandleSMSInbound() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if disabled != tt.wantDisabled {
+				t.Errorf("expected disabled = %v, got %v", tt.wantDisabled, disabled)
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/tsntt/footballapi/internal/model"
+)
+
+// Kinds of errors, the API answers each with its own status. An error that wraps none of them
+// is internal and the client only learns that something went wrong
+var (
+	ErrValidation      = errors.New("invalid request")
+	ErrUnauthorized    = errors.New("unauthorized")
+	ErrForbidden       = errors.New("forbidden")
+	ErrNotFound        = model.ErrNotFound
+	ErrConflict        = model.ErrConflict
+	ErrTooManyRequests = errors.New("too many requests, try again later")
+	// A provider we depend on (football data, email, SMS) failed
+	ErrUnavailable = errors.New("service unavailable")
+)
+
+var (
+	ErrInvalidRequest     = newError(ErrValidation, "validation_failed", "validation error")
+	ErrInvalidSignature   = newError(ErrForbidden, "invalid_signature", "invalid webhook signature")
+	ErrInvalidCode        = newError(ErrValidation, "invalid_code", "invalid or expired verification code")
+	ErrInvalidToken       = newError(ErrValidation, "invalid_token", "invalid or expired token")
+	ErrInvalidCredentials = newError(ErrUnauthorized, "invalid_credentials", "invalid credentials")
+	ErrOwnRole            = newError(ErrForbidden, "own_role", "cannot change your own role")
+	ErrOwnAccount         = newError(ErrForbidden, "own_account", "cannot suspend or delete your own account")
+	ErrUserSuspended      = newError(ErrForbidden, "user_suspended", "user is suspended")
+	ErrWrongPassword      = newError(ErrForbidden, "wrong_password", "password is incorrect")
+	ErrLoginTooOld        = newError(ErrForbidden, "recent_login_required", "log in again with your login provider first")
+	ErrUserNotFound       = newError(ErrNotFound, "user_not_found", "user not found")
+	ErrNameTaken          = newError(ErrConflict, "name_taken", "name is already taken")
+	ErrUnknownRole        = newError(ErrValidation, "unknown_role", "unknown role")
+	ErrUnknownLockout     = newError(ErrValidation, "unknown_lockout_kind", "unknown lockout kind")
+	ErrUnknownProvider    = newError(ErrNotFound, "unknown_provider", "unknown login provider")
+	ErrInvalidState       = newError(ErrValidation, "invalid_state", "invalid or expired login state")
+	ErrInvalidAPIKey      = newError(ErrUnauthorized, "invalid_api_key", "invalid, expired or revoked api key")
+	ErrAPIKeyNotFound     = newError(ErrNotFound, "api_key_not_found", "api key not found")
+	ErrInvalidScope       = newError(ErrForbidden, "invalid_scope", "scope not allowed")
+	ErrAPIKeyLimit        = newError(ErrConflict, "api_key_limit", "too many api keys, revoke one first")
+	ErrInvalidChallenge   = newError(ErrUnauthorized, "invalid_challenge", "invalid or expired login challenge")
+	ErrInvalidTwoFactor   = newError(ErrUnauthorized, "invalid_two_factor_code", "invalid two factor code")
+	ErrTwoFactorEnabled   = newError(ErrConflict, "two_factor_enabled", "two factor authentication is already enabled")
+	ErrTwoFactorOff       = newError(ErrConflict, "two_factor_disabled", "two factor authentication is not enabled")
+	ErrTwoFactorNeeded    = newError(ErrForbidden, "two_factor_required", "two factor authentication is required for your role")
+	ErrAlreadySubscribed  = newError(ErrConflict, "already_subscribed", "already subscribed to this team")
+	ErrNotSubscribed      = newError(ErrNotFound, "subscription_not_found", "subscription not found")
+	ErrNoFans             = newError(ErrNotFound, "no_fans", "no fans found")
+	ErrBroadcastNotFound  = newError(ErrNotFound, "broadcast_not_found", "no broadcast for this match")
+	ErrDeliveryNotFound   = newError(ErrNotFound, "delivery_not_found", "delivery not found")
+	ErrFootballAPIDown    = newError(ErrUnavailable, "football_api_unavailable", "football data provider is unavailable, try again later")
+	ErrNotifierDown       = newError(ErrUnavailable, "notifier_unavailable", "could not send the message, try again later")
+)
+
+// Error is an error the client is told about. Code is stable for clients to branch on and
+// Message is safe to show, Cause is only logged
+type Error struct {
+	Kind    error
+	Code    string
+	Message string
+	Cause   error
+}
+
+func newError(kind error, code, message string) *Error {
+	return &Error{Kind: kind, Code: code, Message: message}
+}
+
+func (e *Error) Error() string {
+	if e.Cause != nil {
+		return e.Message + ": " + e.Cause.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() []error {
+	if e.Cause != nil {
+		return []error{e.Kind, e.Cause}
+	}
+	return []error{e.Kind}
+}
+
+// Is matches errors with the same code, a sentinel still matches once it carries a cause
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// Wrap keeps what went wrong for the logs
+func (e *Error) Wrap(cause error) *Error {
+	err := *e
+	err.Cause = cause
+	return &err
+}
+
+// Withf adds a detail the client may see to the message
+func (e *Error) Withf(format string, args ...any) *Error {
+	err := *e
+	err.Message += ": " + fmt.Sprintf(format, args...)
+	return &err
+}
+
+// RetryAfterError is an ErrTooManyRequests that knows when the client may try again
+type RetryAfterError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RetryAfterError) Error() string {
+	return fmt.Sprintf("%s (retry after %d seconds)", ErrTooManyRequests, e.Seconds())
+}
+
+func (e *RetryAfterError) Is(target error) bool {
+	return target == ErrTooManyRequests
+}
+
+// Seconds rounds up, a client retrying on time must not hit the throttle again
+func (e *RetryAfterError) Seconds() int {
+	return int(math.Ceil(e.RetryAfter.Seconds()))
+}
+
+// newValidator names fields as the client sent them, by their JSON or query name
+func newValidator() *validator.Validate {
+	v := validator.New()
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			name = field.Tag.Get("query")
+		}
+		return name
+	})
+
+	return v
+}
+
+// invalid wraps the validator.ValidationErrors of a request, the API lists them per field
+func invalid(err error) error {
+	return ErrInvalidRequest.Wrap(err)
+}
+
+// orNotFound gives the client notFound when a row is missing, any other failure is internal
+func orNotFound(err error, notFound *Error, action string) error {
+	if errors.Is(err, ErrNotFound) {
+		return notFound.Wrap(err)
+	}
+	return fmt.Errorf("failed to %s: %w", action, err)
+}
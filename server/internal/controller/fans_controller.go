@@ -2,52 +2,236 @@ package controller
 
 import (
 	"context"
+	"crypto/subtle"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/tsntt/footballapi/internal/dto"
 	"github.com/tsntt/footballapi/internal/model"
+	"github.com/tsntt/footballapi/pkg/broadcast"
+	"github.com/tsntt/footballapi/pkg/utils"
 )
 
+const (
+	verificationCodeDigits  = 6
+	verificationCodeTTL     = 10 * time.Minute
+	verificationMaxAttempts = 5
+	verificationResendDelay = time.Minute
+	verificationMaxSends    = 5
+	verificationSendWindow  = time.Hour
+)
+
+// Address format for each channel, checked with validator.Var
+var addressRules = map[string]string{
+	"email": "email",
+	"sms":   "e164",
+}
+
 type FanController struct {
-	fanRepo   model.IFanRepository
-	validator *validator.Validate
+	fanRepo           model.IFanRepository
+	verificationRepo  model.IChannelVerificationRepository
+	broadcastService  *broadcast.BroadcastService
+	unsubscribeTokens *utils.UnsubscribeTokenService
+	validator         *validator.Validate
 }
 
-func NewFanController(fanRepo model.IFanRepository) *FanController {
+func NewFanController(
+	fanRepo model.IFanRepository,
+	verificationRepo model.IChannelVerificationRepository,
+	broadcastService *broadcast.BroadcastService,
+	unsubscribeTokens *utils.UnsubscribeTokenService,
+) *FanController {
 	return &FanController{
-		fanRepo:   fanRepo,
-		validator: validator.New(),
+		fanRepo:           fanRepo,
+		verificationRepo:  verificationRepo,
+		broadcastService:  broadcastService,
+		unsubscribeTokens: unsubscribeTokens,
+		validator:         newValidator(),
 	}
 }
 
 func (c *FanController) Subscribe(ctx context.Context, req *dto.FanRequest) (*dto.APIResponse, error) {
 	// Validate data
 	if err := c.validator.Struct(req); err != nil {
-		return nil, fmt.Errorf("validation error: %w", err)
+		return nil, invalid(err)
+	}
+
+	if err := c.validator.Var(req.Address, addressRules[req.NotificationType]); err != nil {
+		return nil, ErrInvalidRequest.Withf("invalid %s address", req.NotificationType)
 	}
 
+	// Channels stay inactive until the address is confirmed
+	verified := c.isVerified(ctx, req.UserID, req.NotificationType, req.Address)
+
 	fan := &model.Fan{
-		UserID: req.UserID,
-		TeamID: req.TeamID,
+		UserID:           req.UserID,
+		TeamID:           req.TeamID,
+		NotificationType: req.NotificationType,
+		Address:          req.Address,
+		Active:           verified,
 	}
 
 	if err := c.fanRepo.Create(ctx, fan); err != nil {
+		if errors.Is(err, ErrConflict) {
+			return nil, ErrAlreadySubscribed.Wrap(err)
+		}
 		return nil, fmt.Errorf("failed to subscribe to team: %w", err)
 	}
 
+	if verified {
+		return &dto.APIResponse{
+			Message: fmt.Sprintf("Subscribed to %s", req.TeamName),
+		}, nil
+	}
+
+	// A code sent moments ago is still valid, so being throttled here is not an error
+	if err := c.sendVerificationCode(ctx, req.UserID, req.NotificationType, req.Address); err != nil && !errors.Is(err, ErrTooManyRequests) {
+		return nil, err
+	}
+
+	return &dto.APIResponse{
+		Message: fmt.Sprintf("Subscribed to %s, confirm the code sent to %s to start receiving notifications", req.TeamName, req.Address),
+		Data: map[string]interface{}{
+			"verification_required": true,
+		},
+	}, nil
+}
+
+func (c *FanController) ResendVerification(ctx context.Context, userID int, req *dto.ChannelRequest) (*dto.APIResponse, error) {
+	if err := c.validator.Struct(req); err != nil {
+		return nil, invalid(err)
+	}
+
+	if c.isVerified(ctx, userID, req.NotificationType, req.Address) {
+		return &dto.APIResponse{Message: "Channel already verified"}, nil
+	}
+
+	if err := c.sendVerificationCode(ctx, userID, req.NotificationType, req.Address); err != nil {
+		return nil, err
+	}
+
 	return &dto.APIResponse{
-		Message: fmt.Sprintf("Subscribed to %s", req.TeamName),
+		Message: fmt.Sprintf("Verification code sent to %s", req.Address),
 	}, nil
 }
 
+func (c *FanController) VerifyChannel(ctx context.Context, userID int, req *dto.VerifyChannelRequest) (*dto.APIResponse, error) {
+	if err := c.validator.Struct(req); err != nil {
+		return nil, invalid(err)
+	}
+
+	verification, err := c.verificationRepo.Get(ctx, userID, req.NotificationType, req.Address)
+	if err != nil {
+		return nil, ErrInvalidCode
+	}
+
+	if verification.VerifiedAt == nil {
+		if time.Now().After(verification.ExpiresAt) {
+			return nil, ErrInvalidCode
+		}
+
+		// counted before the code is checked, parallel requests cannot share more than the attempts of the code
+		counted, err := c.verificationRepo.IncrementAttempts(ctx, verification.ID, verificationMaxAttempts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to verify channel: %w", err)
+		}
+		if !counted {
+			return nil, ErrTooManyRequests
+		}
+
+		if subtle.ConstantTimeCompare([]byte(utils.HashToken(req.Code)), []byte(verification.CodeHash)) != 1 {
+			return nil, ErrInvalidCode
+		}
+
+		if err := c.verificationRepo.MarkVerified(ctx, verification.ID); err != nil {
+			return nil, fmt.Errorf("failed to verify channel: %w", err)
+		}
+	}
+
+	activated, err := c.fanRepo.ActivateByUserAndAddress(ctx, userID, req.NotificationType, req.Address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to activate subscriptions: %w", err)
+	}
+
+	return &dto.APIResponse{
+		Message: "Channel verified!",
+		Data: map[string]interface{}{
+			"activated_subscriptions": activated,
+		},
+	}, nil
+}
+
+func (c *FanController) isVerified(ctx context.Context, userID int, notificationType, address string) bool {
+	verification, err := c.verificationRepo.Get(ctx, userID, notificationType, address)
+	return err == nil && verification.VerifiedAt != nil
+}
+
+// sendVerificationCode issues a new code, limited to one per resend delay and a few per window
+func (c *FanController) sendVerificationCode(ctx context.Context, userID int, notificationType, address string) error {
+	now := time.Now()
+
+	verification, err := c.verificationRepo.Get(ctx, userID, notificationType, address)
+	if err != nil {
+		verification = &model.ChannelVerification{
+			UserID:           userID,
+			NotificationType: notificationType,
+			Address:          address,
+			WindowStartedAt:  now,
+		}
+	}
+
+	if verification.SendCount > 0 && now.Sub(verification.LastSentAt) < verificationResendDelay {
+		return ErrTooManyRequests
+	}
+
+	if now.Sub(verification.WindowStartedAt) > verificationSendWindow {
+		verification.SendCount = 0
+		verification.WindowStartedAt = now
+	}
+
+	if verification.SendCount >= verificationMaxSends {
+		return ErrTooManyRequests
+	}
+
+	code, err := utils.GenerateNumericCode(verificationCodeDigits)
+	if err != nil {
+		return err
+	}
+
+	verification.CodeHash = utils.HashToken(code)
+	verification.ExpiresAt = now.Add(verificationCodeTTL)
+	verification.Attempts = 0
+	verification.SendCount++
+	verification.LastSentAt = now
+
+	if err := c.verificationRepo.Save(ctx, verification); err != nil {
+		return err
+	}
+
+	_, err = c.broadcastService.Notify(ctx, broadcast.Subscription{
+		UserID:           userID,
+		NotificationType: broadcast.NotificationType(notificationType),
+		Address:          address,
+	}, broadcast.Message{
+		Title:   "Verification code",
+		Content: fmt.Sprintf("Your Football APP verification code is %s. It expires in %d minutes.", code, int(verificationCodeTTL.Minutes())),
+	})
+	if err != nil {
+		return ErrNotifierDown.Wrap(err)
+	}
+
+	return nil
+}
+
 func (c *FanController) Unsubscribe(ctx context.Context, userID int, req *dto.UnsubscribeRequest) (*dto.APIResponse, error) {
 	if err := c.validator.Struct(req); err != nil {
-		return nil, fmt.Errorf("validation error: %w", err)
+		return nil, invalid(err)
 	}
 
 	if err := c.fanRepo.DeleteByUserIDAndTeam(ctx, userID, req.TeamID); err != nil {
-		return nil, fmt.Errorf("failed to unsubscribe from team: %w", err)
+		return nil, orNotFound(err, ErrNotSubscribed, "unsubscribe from team")
 	}
 
 	return &dto.APIResponse{
@@ -58,6 +242,26 @@ func (c *FanController) Unsubscribe(ctx context.Context, userID int, req *dto.Un
 	}, nil
 }
 
+// UnsubscribeByToken disables the channel named in a signed unsubscribe link, no login required
+func (c *FanController) UnsubscribeByToken(ctx context.Context, token string) (*dto.APIResponse, error) {
+	claims, err := c.unsubscribeTokens.ValidateToken(token)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	disabled, err := c.fanRepo.DisableByUserAndAddress(ctx, claims.UserID, claims.NotificationType, claims.Address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unsubscribe: %w", err)
+	}
+
+	return &dto.APIResponse{
+		Message: fmt.Sprintf("Unsubscribed %s from %s notifications", claims.Address, claims.NotificationType),
+		Data: map[string]interface{}{
+			"disabled_subscriptions": disabled,
+		},
+	}, nil
+}
+
 func (c *FanController) GetSubscriptions(ctx context.Context, userID int) ([]model.Fan, error) {
 	fans, err := c.fanRepo.GetByUserID(ctx, userID)
 	if err != nil {
@@ -3,11 +3,15 @@ package controller_test
 import (
 	"context"
 	"errors"
+	"regexp"
 	"testing"
+	"time"
 
 	"github.com/tsntt/footballapi/internal/controller"
 	"github.com/tsntt/footballapi/internal/dto"
 	"github.com/tsntt/footballapi/internal/model"
+	"github.com/tsntt/footballapi/pkg/broadcast"
+	"github.com/tsntt/footballapi/pkg/utils"
 )
 
 func BenchmarkFanController_Subscribe(b *testing.B) {
@@ -17,11 +21,13 @@ func BenchmarkFanController_Subscribe(b *testing.B) {
 		},
 	}
 
-	fanController := controller.NewFanController(mockFanRepo)
+	fanController := controller.NewFanController(mockFanRepo, verifiedChannelRepo(), nil, nil)
 	req := &dto.FanRequest{
-		UserID:   1,
-		TeamID:   1,
-		TeamName: "Test Team",
+		UserID:           1,
+		TeamID:           1,
+		TeamName:         "Test Team",
+		NotificationType: "email",
+		Address:          "fan@example.com",
 	}
 
 	for i := 0; i < b.N; i++ {
@@ -36,7 +42,7 @@ func BenchmarkFanController_Unsubscribe(b *testing.B) {
 		},
 	}
 
-	fanController := controller.NewFanController(mockFanRepo)
+	fanController := controller.NewFanController(mockFanRepo, nil, nil, nil)
 	req := &dto.UnsubscribeRequest{
 		TeamID: "1",
 	}
@@ -53,7 +59,7 @@ func BenchmarkFanController_GetSubscriptions(b *testing.B) {
 		},
 	}
 
-	fanController := controller.NewFanController(mockFanRepo)
+	fanController := controller.NewFanController(mockFanRepo, nil, nil, nil)
 
 	for i := 0; i < b.N; i++ {
 		_, _ = fanController.GetSubscriptions(context.Background(), 1)
@@ -62,7 +68,8 @@ func BenchmarkFanController_GetSubscriptions(b *testing.B) {
 
 func TestFanController_Subscribe(t *testing.T) {
 	type fields struct {
-		fanRepo model.IFanRepository
+		fanRepo          model.IFanRepository
+		verificationRepo model.IChannelVerificationRepository
 	}
 	type args struct {
 		req *dto.FanRequest
@@ -82,12 +89,15 @@ func TestFanController_Subscribe(t *testing.T) {
 						return nil
 					},
 				},
+				verificationRepo: verifiedChannelRepo(),
 			},
 			args: args{
 				req: &dto.FanRequest{
-					UserID:   1,
-					TeamID:   1,
-					TeamName: "Test Team",
+					UserID:           1,
+					TeamID:           1,
+					TeamName:         "Test Team",
+					NotificationType: "email",
+					Address:          "fan@example.com",
 				},
 			},
 			want:    &dto.APIResponse{Message: "Subscribed to Test Team"},
@@ -112,12 +122,15 @@ func TestFanController_Subscribe(t *testing.T) {
 						return errors.New("create error")
 					},
 				},
+				verificationRepo: verifiedChannelRepo(),
 			},
 			args: args{
 				req: &dto.FanRequest{
-					UserID:   1,
-					TeamID:   1,
-					TeamName: "Test Team",
+					UserID:           1,
+					TeamID:           1,
+					TeamName:         "Test Team",
+					NotificationType: "email",
+					Address:          "fan@example.com",
 				},
 			},
 			want:    nil,
@@ -126,7 +139,7 @@ func TestFanController_Subscribe(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			f := controller.NewFanController(tt.fields.fanRepo)
+			f := controller.NewFanController(tt.fields.fanRepo, tt.fields.verificationRepo, nil, nil)
 			got, err := f.Subscribe(context.Background(), tt.args.req)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("FanController.Subscribe() error = %v, wantErr %v", err, tt.wantErr)
@@ -138,3 +151,266 @@ func TestFanController_Subscribe(t *testing.T) {
 		})
 	}
 }
+
+func verifiedChannelRepo() *mockChannelVerificationRepository {
+	return &mockChannelVerificationRepository{
+		get: func(ctx context.Context, userID int, notificationType, address string) (*model.ChannelVerification, error) {
+			verifiedAt := time.Now()
+			return &model.ChannelVerification{ID: 1, VerifiedAt: &verifiedAt}, nil
+		},
+	}
+}
+
+func TestFanController_Subscribe_UnverifiedChannel(t *testing.T) {
+	var created *model.Fan
+	var saved *model.ChannelVerification
+	var sentCode string
+
+	mockFanRepo := &mockFanRepository{
+		create: func(ctx context.Context, fan *model.Fan) error {
+			created = fan
+			return nil
+		},
+	}
+	mockVerificationRepo := &mockChannelVerificationRepository{
+		get: func(ctx context.Context, userID int, notificationType, address string) (*model.ChannelVerification, error) {
+			return nil, errors.New("verification not found")
+		},
+		save: func(ctx context.Context, verification *model.ChannelVerification) error {
+			saved = verification
+			return nil
+		},
+	}
+
+	broadcastService := broadcast.NewBroadcastService()
+	broadcastService.RegisterNotifier(broadcast.SMS, &mockBroadcaster{
+		send: func(ctx context.Context, subscription broadcast.Subscription, message broadcast.Message) (string, error) {
+			sentCode = regexp.MustCompile(`\d{6}`).FindString(message.Content)
+			return "SM123", nil
+		},
+	})
+
+	fanController := controller.NewFanController(mockFanRepo, mockVerificationRepo, broadcastService, nil)
+	resp, err := fanController.Subscribe(context.Background(), &dto.FanRequest{
+		UserID:           1,
+		TeamID:           1,
+		TeamName:         "Test Team",
+		NotificationType: "sms",
+		Address:          "+5511999999999",
+	})
+
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if created.Active {
+		t.Error("expected unverified channel to be inactive")
+	}
+
+	if saved.CodeHash != utils.HashToken(sentCode) {
+		t.Error("expected stored hash to match the code sent")
+	}
+
+	if saved.SendCount != 1 {
+		t.Errorf("expected send count 1, got %d", saved.SendCount)
+	}
+
+	if resp.Data == nil {
+		t.Error("expected verification_required in response data")
+	}
+}
+
+func TestFanController_Subscribe_InvalidAddress(t *testing.T) {
+	fanController := controller.NewFanController(&mockFanRepository{}, nil, nil, nil)
+	_, err := fanController.Subscribe(context.Background(), &dto.FanRequest{
+		UserID:           1,
+		TeamID:           1,
+		TeamName:         "Test Team",
+		NotificationType: "sms",
+		Address:          "11999999999",
+	})
+
+	if err == nil {
+		t.Fatal("expected a validation error, got nil")
+	}
+}
+
+func TestFanController_VerifyChannel(t *testing.T) {
+	tests := []struct {
+		name          string
+		verification  model.ChannelVerification
+		code          string
+		wantErr       error
+		wantAttempted bool
+		wantVerified  bool
+	}{
+		{
+			name:          "valid code",
+			verification:  model.ChannelVerification{ID: 1, CodeHash: utils.HashToken("123456"), ExpiresAt: time.Now().Add(time.Minute)},
+			code:          "123456",
+			wantVerified:  true,
+			wantAttempted: true,
+		},
+		{
+			name:          "wrong code",
+			verification:  model.ChannelVerification{ID: 1, CodeHash: utils.HashToken("123456"), ExpiresAt: time.Now().Add(time.Minute)},
+			code:          "654321",
+			wantErr:       controller.ErrInvalidCode,
+			wantAttempted: true,
+		},
+		{
+			name:         "expired code",
+			verification: model.ChannelVerification{ID: 1, CodeHash: utils.HashToken("123456"), ExpiresAt: time.Now().Add(-time.Minute)},
+			code:         "123456",
+			wantErr:      controller.ErrInvalidCode,
+		},
+		{
+			name:         "too many attempts",
+			verification: model.ChannelVerification{ID: 1, CodeHash: utils.HashToken("123456"), ExpiresAt: time.Now().Add(time.Minute), Attempts: 5},
+			code:         "123456",
+			wantErr:      controller.ErrTooManyRequests,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempted, verified := false, false
+			mockVerificationRepo := &mockChannelVerificationRepository{
+				get: func(ctx context.Context, userID int, notificationType, address string) (*model.ChannelVerification, error) {
+					return &tt.verification, nil
+				},
+				incrementAttempts: func(ctx context.Context, id, maxAttempts int) (bool, error) {
+					if tt.verification.Attempts >= maxAttempts {
+						return false, nil
+					}
+					attempted = true
+					return true, nil
+				},
+				markVerified: func(ctx context.Context, id int) error {
+					verified = true
+					return nil
+				},
+			}
+			mockFanRepo := &mockFanRepository{
+				activateByUserAndAddr: func(ctx context.Context, userID int, notificationType, address string) (int64, error) {
+					return 2, nil
+				},
+			}
+
+			fanController := controller.NewFanController(mockFanRepo, mockVerificationRepo, nil, nil)
+			_, err := fanController.VerifyChannel(context.Background(), 1, &dto.VerifyChannelRequest{
+				NotificationType: "email",
+				Address:          "fan@example.com",
+				Code:             tt.code,
+			})
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("FanController.VerifyChannel() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if attempted != tt.wantAttempted {
+				t.Errorf("expected attempted = %v, got %v", tt.wantAttempted, attempted)
+			}
+
+			if verified != tt.wantVerified {
+				t.Errorf("expected verified = %v, got %v", tt.wantVerified, verified)
+			}
+		})
+	}
+}
+
+func TestFanController_VerifyChannel_ParallelGuesses(t *testing.T) {
+	// every request read the code before any guess was counted
+	verification := model.ChannelVerification{ID: 1, CodeHash: utils.HashToken("123456"), ExpiresAt: time.Now().Add(time.Minute)}
+	var attempts int
+	mockVerificationRepo := &mockChannelVerificationRepository{
+		get: func(ctx context.Context, userID int, notificationType, address string) (*model.ChannelVerification, error) {
+			stale := verification
+			return &stale, nil
+		},
+		incrementAttempts: func(ctx context.Context, id, maxAttempts int) (bool, error) {
+			if attempts >= maxAttempts {
+				return false, nil
+			}
+			attempts++
+			return true, nil
+		},
+	}
+
+	fanController := controller.NewFanController(&mockFanRepository{}, mockVerificationRepo, nil, nil)
+
+	var guessed, throttled int
+	for range 10 {
+		_, err := fanController.VerifyChannel(context.Background(), 1, &dto.VerifyChannelRequest{
+			NotificationType: "email",
+			Address:          "fan@example.com",
+			Code:             "654321",
+		})
+
+		switch {
+		case errors.Is(err, controller.ErrInvalidCode):
+			guessed++
+		case errors.Is(err, controller.ErrTooManyRequests):
+			throttled++
+		default:
+			t.Fatalf("expected ErrInvalidCode or ErrTooManyRequests, got %v", err)
+		}
+	}
+
+	if guessed != 5 || throttled != 5 {
+		t.Errorf("expected 5 guesses checked and 5 throttled, got %d and %d", guessed, throttled)
+	}
+}
+
+func TestFanController_ResendVerification_Throttled(t *testing.T) {
+	mockVerificationRepo := &mockChannelVerificationRepository{
+		get: func(ctx context.Context, userID int, notificationType, address string) (*model.ChannelVerification, error) {
+			return &model.ChannelVerification{ID: 1, SendCount: 1, LastSentAt: time.Now(), WindowStartedAt: time.Now()}, nil
+		},
+	}
+
+	fanController := controller.NewFanController(&mockFanRepository{}, mockVerificationRepo, nil, nil)
+	_, err := fanController.ResendVerification(context.Background(), 1, &dto.ChannelRequest{
+		NotificationType: "email",
+		Address:          "fan@example.com",
+	})
+
+	if !errors.Is(err, controller.ErrTooManyRequests) {
+		t.Fatalf("expected ErrTooManyRequests, got %v", err)
+	}
+}
+
+func TestFanController_UnsubscribeByToken(t *testing.T) {
+	uts := utils.NewUnsubscribeTokenService("secret", 1, "https://api.example.com")
+	token, _ := uts.GenerateToken(1, "email", "fan@example.com")
+
+	disabled := false
+	mockFanRepo := &mockFanRepository{
+		disableByUserAndAddr: func(ctx context.Context, userID int, notificationType, address string) (int64, error) {
+			disabled = userID == 1 && notificationType == "email" && address == "fan@example.com"
+			return 1, nil
+		},
+	}
+
+	fanController := controller.NewFanController(mockFanRepo, nil, nil, uts)
+	_, err := fanController.UnsubscribeByToken(context.Background(), token)
+
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if !disabled {
+		t.Error("expected the channel in the token to be disabled")
+	}
+}
+
+func TestFanController_UnsubscribeByToken_InvalidToken(t *testing.T) {
+	uts := utils.NewUnsubscribeTokenService("secret", 1, "https://api.example.com")
+
+	fanController := controller.NewFanController(&mockFanRepository{}, nil, nil, uts)
+	_, err := fanController.UnsubscribeByToken(context.Background(), "invalid-token")
+
+	if !errors.Is(err, controller.ErrInvalidToken) {
+		t.Fatalf("expected ErrInvalidToken, got %v", err)
+	}
+}
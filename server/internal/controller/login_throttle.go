@@ -0,0 +1,133 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/tsntt/footballapi/internal/model"
+	"github.com/tsntt/footballapi/pkg/logging"
+)
+
+const (
+	throttleAccount = "account"
+	throttleIP      = "ip"
+)
+
+// throttlePolicy lets freeFailures through, then makes each try wait twice as long as the previous
+// one (up to maxDelay) and locks the key for lockDuration once lockAfter failures are reached.
+// Failures older than window are forgotten
+type throttlePolicy struct {
+	freeFailures int
+	baseDelay    time.Duration
+	maxDelay     time.Duration
+	lockAfter    int
+	lockDuration time.Duration
+	window       time.Duration
+}
+
+var throttlePolicies = map[string]throttlePolicy{
+	throttleAccount: {
+		freeFailures: 3,
+		baseDelay:    time.Second,
+		maxDelay:     time.Minute,
+		lockAfter:    10,
+		lockDuration: 15 * time.Minute,
+		window:       time.Hour,
+	},
+	// Shared addresses (offices, carrier NAT) get more room before they are slowed down
+	throttleIP: {
+		freeFailures: 20,
+		baseDelay:    time.Second,
+		maxDelay:     time.Minute,
+		lockAfter:    100,
+		lockDuration: time.Hour,
+		window:       time.Hour,
+	},
+}
+
+func throttleKey(kind, value string) string {
+	return kind + ":" + value
+}
+
+// retryAfter is how long the throttle still blocks logins, zero when they are allowed
+func (p throttlePolicy) retryAfter(throttle *model.LoginThrottle, now time.Time) time.Duration {
+	if throttle.LockedUntil != nil && now.Before(*throttle.LockedUntil) {
+		return throttle.LockedUntil.Sub(now)
+	}
+
+	if now.Sub(throttle.LastFailureAt) > p.window || throttle.Failures < p.freeFailures {
+		return 0
+	}
+
+	delay := p.maxDelay
+	if shift := throttle.Failures - p.freeFailures; shift < 16 {
+		delay = min(p.baseDelay<<shift, p.maxDelay)
+	}
+
+	if next := throttle.LastFailureAt.Add(delay); now.Before(next) {
+		return next.Sub(now)
+	}
+
+	return 0
+}
+
+// loginThrottle looks after the account and IP counters of one login
+type loginThrottle struct {
+	repo model.ILoginAttemptRepository
+	keys map[string]string
+}
+
+func newLoginThrottle(repo model.ILoginAttemptRepository, name, ip string) *loginThrottle {
+	return &loginThrottle{
+		repo: repo,
+		keys: map[string]string{
+			throttleAccount: throttleKey(throttleAccount, name),
+			throttleIP:      throttleKey(throttleIP, ip),
+		},
+	}
+}
+
+// retryAfter returns the longest wait among the counters
+func (t *loginThrottle) retryAfter(ctx context.Context, now time.Time) time.Duration {
+	var wait time.Duration
+	for kind, key := range t.keys {
+		throttle, err := t.repo.GetThrottle(ctx, key)
+		if err != nil {
+			if !errors.Is(err, model.ErrNotFound) {
+				logging.FromContext(ctx).Error("Failed to get login throttle", slog.String("key", key), slog.String("err", err.Error()))
+			}
+			continue
+		}
+		wait = max(wait, throttlePolicies[kind].retryAfter(throttle, now))
+	}
+	return wait
+}
+
+// fail counts one more failure, parallel failures each get their own count so the one reaching
+// lockAfter locks the key
+func (t *loginThrottle) fail(ctx context.Context, now time.Time) {
+	for kind, key := range t.keys {
+		policy := throttlePolicies[kind]
+
+		throttle, err := t.repo.AddFailure(ctx, key, now, now.Add(-policy.window))
+		if err != nil {
+			logging.FromContext(ctx).Error("Failed to count login failure", slog.String("key", key), slog.String("err", err.Error()))
+			continue
+		}
+
+		if throttle.Failures >= policy.lockAfter {
+			if err := t.repo.LockThrottle(ctx, key, now.Add(policy.lockDuration)); err != nil {
+				logging.FromContext(ctx).Error("Failed to lock login throttle", slog.String("key", key), slog.String("err", err.Error()))
+			}
+		}
+	}
+}
+
+// succeed clears the account counter, the IP one keeps counting other accounts' failures
+func (t *loginThrottle) succeed(ctx context.Context) {
+	if err := t.repo.DeleteThrottle(ctx, t.keys[throttleAccount]); err != nil {
+		logging.FromContext(ctx).Error("Failed to clear login throttle", slog.String("err", err.Error()))
+	}
+}
@@ -0,0 +1,171 @@
+package controller_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/tsntt/footballapi/internal/controller"
+	"github.com/tsntt/footballapi/internal/dto"
+	"github.com/tsntt/footballapi/internal/model"
+	"github.com/tsntt/footballapi/pkg/utils"
+)
+
+var throttleClient = dto.ClientInfo{IP: "203.0.113.7", UserAgent: "test-agent"}
+
+func newThrottledUserController(t *testing.T) (*controller.UserController, *mockLoginAttemptRepository) {
+	t.Helper()
+
+	hashedPassword, _ := utils.HashPassword("password")
+	mockUserRepo := &mockUserRepository{
+		getByName: func(ctx context.Context, name string) (*model.User, error) {
+			return &model.User{ID: 1, Name: name, Password: hashedPassword, Role: "default"}, nil
+		},
+	}
+
+	attemptRepo := newMockLoginAttemptRepository()
+	userController := controller.NewUserController(mockUserRepo, mockRoleRepo(), attemptRepo, testPasswordPolicy, utils.NewJWTService("secret", 1))
+
+	return userController, attemptRepo
+}
+
+func login(userController *controller.UserController, password string) error {
+	_, err := userController.Login(context.Background(), &dto.UserRequest{Name: "ana", Password: password}, throttleClient)
+	return err
+}
+
+func TestUserController_Login_ProgressiveDelay(t *testing.T) {
+	userController, attemptRepo := newThrottledUserController(t)
+
+	for i := range 3 {
+		if err := login(userController, "wrong-password"); err == nil || errors.Is(err, controller.ErrTooManyRequests) {
+			t.Fatalf("attempt %d: expected invalid credentials, got %v", i+1, err)
+		}
+	}
+
+	// the 4th try comes too soon, even with the right password
+	err := login(userController, "password")
+	var throttled *controller.RetryAfterError
+	if !errors.As(err, &throttled) || !errors.Is(err, controller.ErrTooManyRequests) {
+		t.Fatalf("expected a RetryAfterError, got %v", err)
+	}
+
+	if throttled.Seconds() != 1 {
+		t.Errorf("expected to retry after 1 second, got %d", throttled.Seconds())
+	}
+
+	last := attemptRepo.attempts[len(attemptRepo.attempts)-1]
+	if last.Success || last.FailureReason != model.LoginFailureThrottled || last.IP != throttleClient.IP || last.UserAgent != throttleClient.UserAgent {
+		t.Errorf("expected a throttled attempt to be recorded, got %+v", last)
+	}
+
+	// once the delay passed the right password works and clears the account counter
+	account := attemptRepo.throttles["account:ana"]
+	account.LastFailureAt = account.LastFailureAt.Add(-2 * time.Second)
+	attemptRepo.throttles["ip:203.0.113.7"].LastFailureAt = account.LastFailureAt
+
+	if err := login(userController, "password"); err != nil {
+		t.Fatalf("expected login after the delay, got %v", err)
+	}
+
+	if _, ok := attemptRepo.throttles["account:ana"]; ok {
+		t.Error("expected account throttle to be cleared")
+	}
+
+	if ip := attemptRepo.throttles["ip:203.0.113.7"]; ip == nil || ip.Failures != 3 {
+		t.Errorf("expected IP throttle to keep its 3 failures, got %+v", ip)
+	}
+
+	if last := attemptRepo.attempts[len(attemptRepo.attempts)-1]; !last.Success || last.UserID == nil {
+		t.Errorf("expected a successful attempt to be recorded, got %+v", last)
+	}
+}
+
+func TestUserController_Login_Lockout(t *testing.T) {
+	userController, attemptRepo := newThrottledUserController(t)
+	attemptRepo.throttles["account:ana"] = &model.LoginThrottle{
+		Key:           "account:ana",
+		Failures:      9,
+		LastFailureAt: time.Now().Add(-2 * time.Minute),
+	}
+
+	if err := login(userController, "wrong-password"); errors.Is(err, controller.ErrTooManyRequests) {
+		t.Fatalf("expected invalid credentials, got %v", err)
+	}
+
+	if attemptRepo.throttles["account:ana"].LockedUntil == nil {
+		t.Fatal("expected the account to be locked after 10 failures")
+	}
+
+	var throttled *controller.RetryAfterError
+	if err := login(userController, "password"); !errors.As(err, &throttled) {
+		t.Fatalf("expected a RetryAfterError, got %v", err)
+	}
+
+	if throttled.RetryAfter < 14*time.Minute {
+		t.Errorf("expected a 15 minute lockout, got %s", throttled.RetryAfter)
+	}
+}
+
+func TestUserController_Login_OldFailuresForgotten(t *testing.T) {
+	userController, attemptRepo := newThrottledUserController(t)
+	attemptRepo.throttles["account:ana"] = &model.LoginThrottle{
+		Key:           "account:ana",
+		Failures:      9,
+		LastFailureAt: time.Now().Add(-2 * time.Hour),
+	}
+
+	if err := login(userController, "wrong-password"); errors.Is(err, controller.ErrTooManyRequests) {
+		t.Fatalf("expected invalid credentials, got %v", err)
+	}
+
+	if throttle := attemptRepo.throttles["account:ana"]; throttle.Failures != 1 || throttle.LockedUntil != nil {
+		t.Errorf("expected the counter to restart, got %+v", throttle)
+	}
+}
+
+func TestAdminUserController_Lockouts(t *testing.T) {
+	attemptRepo := newMockLoginAttemptRepository()
+	lockedUntil := time.Now().Add(10 * time.Minute)
+	attemptRepo.throttles["account:ana"] = &model.LoginThrottle{Key: "account:ana", Failures: 10, LastFailureAt: time.Now(), LockedUntil: &lockedUntil}
+	attemptRepo.throttles["ip:203.0.113.7"] = &model.LoginThrottle{Key: "ip:203.0.113.7", Failures: 10, LastFailureAt: time.Now()}
+
+	mockUserRepo := &mockUserRepository{
+		getByName: func(ctx context.Context, name string) (*model.User, error) {
+			return &model.User{ID: 2, Name: name}, nil
+		},
+	}
+	auditRepo := &mockAuditRepository{}
+	adminUserController := controller.NewAdminUserController(mockUserRepo, nil, nil, auditRepo, attemptRepo)
+	ctx := context.Background()
+
+	lockouts, err := adminUserController.ListLockouts(ctx)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if len(lockouts) != 2 {
+		t.Errorf("expected 2 lockouts, got %d", len(lockouts))
+	}
+
+	if _, err := adminUserController.ClearLockout(ctx, 1, "user", "ana"); err == nil {
+		t.Error("expected an error for an unknown kind, got nil")
+	}
+
+	if _, err := adminUserController.ClearLockout(ctx, 1, "account", "ana"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if _, ok := attemptRepo.throttles["account:ana"]; ok {
+		t.Error("expected account lockout to be cleared")
+	}
+
+	if _, ok := attemptRepo.throttles["ip:203.0.113.7"]; !ok {
+		t.Error("expected IP lockout to be kept")
+	}
+
+	if len(auditRepo.entries) != 1 || auditRepo.entries[0].Action != model.AuditUserLockoutCleared || auditRepo.entries[0].TargetID != 2 {
+		t.Errorf("expected a lockout cleared audit entry, got %+v", auditRepo.entries)
+	}
+}
@@ -0,0 +1,226 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tsntt/footballapi/internal/dto"
+	"github.com/tsntt/footballapi/internal/model"
+	"github.com/tsntt/footballapi/pkg/logging"
+	"github.com/tsntt/footballapi/pkg/utils"
+)
+
+const (
+	oauthStateTTL   = 10 * time.Minute
+	oauthStateBytes = 32
+	// hex encoded that is 64 characters, RFC 7636 wants 43 to 128
+	oauthVerifierBytes = 32
+	oauthNonceBytes    = 16
+	// Tries with a random suffix when the provider's user name is taken
+	oauthProvisionAttempts = 5
+)
+
+var unsafeNameChars = regexp.MustCompile(`[^a-z0-9._-]+`)
+
+// OAuthController logs users in with external providers (authorization code + PKCE), creating
+// the account on the first login. Accounts are never linked by email, an address at one
+// provider does not prove ownership of an account here.
+type OAuthController struct {
+	providers    map[string]model.IOAuthProvider
+	stateRepo    model.IOAuthStateRepository
+	identityRepo model.IUserIdentityRepository
+	userRepo     model.IUserRepository
+	users        *UserController
+}
+
+func NewOAuthController(
+	providers []model.IOAuthProvider,
+	stateRepo model.IOAuthStateRepository,
+	identityRepo model.IUserIdentityRepository,
+	userRepo model.IUserRepository,
+	users *UserController,
+) *OAuthController {
+	byName := make(map[string]model.IOAuthProvider, len(providers))
+	for _, provider := range providers {
+		byName[provider.Name()] = provider
+	}
+
+	return &OAuthController{
+		providers:    byName,
+		stateRepo:    stateRepo,
+		identityRepo: identityRepo,
+		userRepo:     userRepo,
+		users:        users,
+	}
+}
+
+// Providers lists the configured providers by name
+func (c *OAuthController) Providers() []string {
+	names := make([]string, 0, len(c.providers))
+	for name := range c.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// StartLogin returns the provider login URL and the state the callback has to come back with
+func (c *OAuthController) StartLogin(ctx context.Context, providerName string, cookieSession bool) (string, string, error) {
+	provider, ok := c.providers[providerName]
+	if !ok {
+		return "", "", ErrUnknownProvider
+	}
+
+	state, err := utils.GenerateRandomToken(oauthStateBytes)
+	if err != nil {
+		return "", "", err
+	}
+
+	verifier, err := utils.GenerateRandomToken(oauthVerifierBytes)
+	if err != nil {
+		return "", "", err
+	}
+
+	nonce, err := utils.GenerateRandomToken(oauthNonceBytes)
+	if err != nil {
+		return "", "", err
+	}
+
+	err = c.stateRepo.Create(ctx, &model.OAuthState{
+		StateHash:     utils.HashToken(state),
+		Provider:      providerName,
+		CodeVerifier:  verifier,
+		Nonce:         nonce,
+		CookieSession: cookieSession,
+		ExpiresAt:     time.Now().Add(oauthStateTTL),
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	return provider.AuthCodeURL(state, nonce, verifier), state, nil
+}
+
+// Callback finishes a login started by StartLogin and issues the same token as a password login
+func (c *OAuthController) Callback(ctx context.Context, providerName, state, code string, client dto.ClientInfo) (*dto.LoginResponse, error) {
+	provider, ok := c.providers[providerName]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+
+	if state == "" || code == "" {
+		return nil, ErrInvalidState
+	}
+
+	pending, err := c.stateRepo.Consume(ctx, utils.HashToken(state))
+	if err != nil || pending.Provider != providerName || time.Now().After(pending.ExpiresAt) {
+		return nil, ErrInvalidState
+	}
+
+	external, err := provider.Exchange(ctx, code, pending.CodeVerifier, pending.Nonce)
+	if err != nil {
+		return nil, fmt.Errorf("failed to log in with %s: %w", providerName, err)
+	}
+
+	if external.Subject == "" {
+		return nil, fmt.Errorf("failed to log in with %s: no subject", providerName)
+	}
+
+	user, err := c.findOrProvision(ctx, providerName, external)
+	if err != nil {
+		return nil, err
+	}
+
+	client.CookieSession = pending.CookieSession
+	return c.users.loginVerified(ctx, user, client)
+}
+
+func (c *OAuthController) findOrProvision(ctx context.Context, providerName string, external *model.ExternalIdentity) (*model.User, error) {
+	identity, err := c.identityRepo.GetByProviderSubject(ctx, providerName, external.Subject)
+	if err == nil {
+		if err := c.identityRepo.TouchLogin(ctx, identity.ID); err != nil {
+			logging.FromContext(ctx).Error("Failed to update identity", slog.Int("identity_id", identity.ID), slog.String("err", err.Error()))
+		}
+
+		user, err := c.userRepo.GetByID(ctx, identity.UserID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get user: %w", err)
+		}
+		return user, nil
+	}
+
+	return c.provision(ctx, providerName, external)
+}
+
+// provision creates a user without a password, one can be set with PUT /me/password right after a
+// provider login (see reauthenticate)
+func (c *OAuthController) provision(ctx context.Context, providerName string, external *model.ExternalIdentity) (*model.User, error) {
+	user := &model.User{
+		Role:        "default",
+		DisplayName: truncate(external.Name, 50),
+	}
+	identity := &model.UserIdentity{Provider: providerName, Subject: external.Subject}
+
+	// An unverified address would let someone receive password resets for a mailbox they do not own
+	if external.EmailVerified {
+		user.Email = external.Email
+		identity.Email = external.Email
+	}
+
+	base := baseUserName(external)
+	var lastErr error
+	for i := 0; i < oauthProvisionAttempts; i++ {
+		user.Name = base
+		if i > 0 {
+			suffix, err := utils.GenerateRandomToken(2)
+			if err != nil {
+				return nil, err
+			}
+			user.Name = base + "-" + suffix
+		}
+
+		if _, err := c.userRepo.GetByName(ctx, user.Name); err == nil {
+			continue
+		}
+
+		// may still lose a race for the name, the next attempt picks another one
+		if lastErr = c.identityRepo.CreateWithUser(ctx, user, identity); lastErr == nil {
+			logging.FromContext(ctx).Info("Provisioned user from login provider", slog.Int("user_id", user.ID), slog.String("provider", providerName))
+			return user, nil
+		}
+	}
+
+	if lastErr == nil {
+		lastErr = fmt.Errorf("no free user name for %q", base)
+	}
+	return nil, fmt.Errorf("failed to provision user: %w", lastErr)
+}
+
+// baseUserName picks a user name from what the provider knows, restricted to safe characters
+func baseUserName(external *model.ExternalIdentity) string {
+	emailLocal, _, _ := strings.Cut(external.Email, "@")
+
+	for _, candidate := range []string{external.Username, emailLocal, external.Name} {
+		name := unsafeNameChars.ReplaceAllString(strings.ToLower(candidate), "-")
+		name = strings.Trim(truncate(name, 40), "-.")
+		if len(name) >= 2 {
+			return name
+		}
+	}
+
+	return "user"
+}
+
+func truncate(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) > max {
+		return string(runes[:max])
+	}
+	return s
+}
@@ -0,0 +1,215 @@
+package controller_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tsntt/footballapi/internal/controller"
+	"github.com/tsntt/footballapi/internal/dto"
+	"github.com/tsntt/footballapi/internal/model"
+	"github.com/tsntt/footballapi/pkg/services/oauth"
+	"github.com/tsntt/footballapi/pkg/services/oauth/oidctest"
+	"github.com/tsntt/footballapi/pkg/utils"
+)
+
+// newOAuthTest logs in against a local OIDC provider named "test"
+func newOAuthTest(t *testing.T) (*controller.OAuthController, *oidctest.Server, *mockIdentityStore, *utils.JWTService) {
+	t.Helper()
+
+	server := oidctest.NewServer("football-api", "client-secret")
+	t.Cleanup(server.Close)
+
+	provider, err := oauth.NewOIDCProvider(context.Background(), "test", server.URL, server.ClientID, server.ClientSecret,
+		"http://api.test/api/v1/auth/oauth/test/callback")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	store := newMockIdentityStore()
+	userRepo := store.userRepo()
+	jms := utils.NewJWTService("secret", 1)
+	users := controller.NewUserController(userRepo, mockRoleRepo(), newMockLoginAttemptRepository(), testPasswordPolicy, jms)
+
+	oauthController := controller.NewOAuthController([]model.IOAuthProvider{provider}, store, store, userRepo, users)
+	return oauthController, server, store, jms
+}
+
+// authorize opens the login URL like a browser and returns the state and code sent back to the callback
+func authorize(t *testing.T, authURL string) (string, string) {
+	t.Helper()
+
+	client := &http.Client{CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("expected a redirect from the provider, got %d", resp.StatusCode)
+	}
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatalf("invalid redirect: %v", err)
+	}
+
+	return location.Query().Get("state"), location.Query().Get("code")
+}
+
+func loginWithProvider(t *testing.T, oauthController *controller.OAuthController, cookieSession bool) (*dto.LoginResponse, error) {
+	t.Helper()
+
+	authURL, state, err := oauthController.StartLogin(context.Background(), "test", cookieSession)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	returnedState, code := authorize(t, authURL)
+	if returnedState != state {
+		t.Fatalf("expected the provider to return state %q, got %q", state, returnedState)
+	}
+
+	return oauthController.Callback(context.Background(), "test", state, code, dto.ClientInfo{IP: "203.0.113.1"})
+}
+
+func TestOAuthController_Login(t *testing.T) {
+	oauthController, _, store, jms := newOAuthTest(t)
+
+	resp, err := loginWithProvider(t, oauthController, false)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	claims, err := jms.ValidateToken(resp.Token)
+	if err != nil {
+		t.Fatalf("expected a valid token, got %v", err)
+	}
+
+	user := store.users[claims.UserID]
+	if user == nil || user.Name != "ana" || user.Email != "ana@example.com" || user.Role != "default" {
+		t.Fatalf("expected user ana to be provisioned, got %+v", user)
+	}
+
+	if user.Password != "" {
+		t.Error("expected a provisioned user to have no password")
+	}
+
+	// the next login finds the same account
+	resp, err = loginWithProvider(t, oauthController, false)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	claims, _ = jms.ValidateToken(resp.Token)
+	if claims.UserID != user.ID || len(store.users) != 1 {
+		t.Errorf("expected to log in as user %d again, got %d with %d users", user.ID, claims.UserID, len(store.users))
+	}
+}
+
+func TestOAuthController_Login_CookieSession(t *testing.T) {
+	oauthController, _, _, _ := newOAuthTest(t)
+
+	resp, err := loginWithProvider(t, oauthController, true)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if resp.CSRFToken == "" {
+		t.Error("expected a CSRF token for a cookie session")
+	}
+}
+
+func TestOAuthController_Login_NameTakenAndUnverifiedEmail(t *testing.T) {
+	oauthController, server, store, _ := newOAuthTest(t)
+	store.users[1] = &model.User{ID: 1, Name: "ana"}
+
+	server.SetIdentity(oidctest.Identity{Subject: "user-2", Email: "ana@other.example", EmailVerified: false, Username: "ana"})
+
+	if _, err := loginWithProvider(t, oauthController, false); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	user := store.users[2]
+	if user == nil || !strings.HasPrefix(user.Name, "ana-") {
+		t.Fatalf("expected a user name with a suffix, got %+v", user)
+	}
+
+	if user.Email != "" {
+		t.Errorf("expected an unverified email to be left out, got %q", user.Email)
+	}
+}
+
+func TestOAuthController_Login_Suspended(t *testing.T) {
+	oauthController, _, store, _ := newOAuthTest(t)
+
+	if _, err := loginWithProvider(t, oauthController, false); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	suspendedAt := time.Now()
+	store.users[1].SuspendedAt = &suspendedAt
+
+	if _, err := loginWithProvider(t, oauthController, false); !errors.Is(err, controller.ErrUserSuspended) {
+		t.Fatalf("expected ErrUserSuspended, got %v", err)
+	}
+}
+
+func TestOAuthController_Callback_InvalidState(t *testing.T) {
+	oauthController, _, _, _ := newOAuthTest(t)
+	ctx := context.Background()
+
+	authURL, state, _ := oauthController.StartLogin(ctx, "test", false)
+	_, code := authorize(t, authURL)
+
+	if _, err := oauthController.Callback(ctx, "test", "made-up-state", code, dto.ClientInfo{}); !errors.Is(err, controller.ErrInvalidState) {
+		t.Errorf("expected ErrInvalidState for an unknown state, got %v", err)
+	}
+
+	if _, err := oauthController.Callback(ctx, "test", state, code, dto.ClientInfo{}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	// a state works once
+	if _, err := oauthController.Callback(ctx, "test", state, code, dto.ClientInfo{}); !errors.Is(err, controller.ErrInvalidState) {
+		t.Errorf("expected ErrInvalidState for a used state, got %v", err)
+	}
+}
+
+func TestOAuthController_Callback_WrongVerifier(t *testing.T) {
+	oauthController, _, store, _ := newOAuthTest(t)
+	ctx := context.Background()
+
+	authURL, state, _ := oauthController.StartLogin(ctx, "test", false)
+	_, code := authorize(t, authURL)
+
+	// a code intercepted on its way back is useless without the verifier
+	for hash, pending := range store.states {
+		pending.CodeVerifier = strings.Repeat("0", 64)
+		store.states[hash] = pending
+	}
+
+	if _, err := oauthController.Callback(ctx, "test", state, code, dto.ClientInfo{}); err == nil {
+		t.Fatal("expected the provider to reject the code, got nil")
+	}
+
+	if len(store.users) != 0 {
+		t.Errorf("expected no user to be provisioned, got %d", len(store.users))
+	}
+}
+
+func TestOAuthController_UnknownProvider(t *testing.T) {
+	oauthController, _, _, _ := newOAuthTest(t)
+
+	if _, _, err := oauthController.StartLogin(context.Background(), "myspace", false); !errors.Is(err, controller.ErrUnknownProvider) {
+		t.Fatalf("expected ErrUnknownProvider, got %v", err)
+	}
+}
@@ -0,0 +1,224 @@
+package controller
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/tsntt/footballapi/internal/dto"
+	"github.com/tsntt/footballapi/internal/model"
+	"github.com/tsntt/footballapi/pkg/broadcast"
+	"github.com/tsntt/footballapi/pkg/logging"
+	"github.com/tsntt/footballapi/pkg/passwordpolicy"
+	"github.com/tsntt/footballapi/pkg/utils"
+)
+
+const (
+	resetSelectorBytes = 8
+	resetVerifierBytes = 32
+	resetTokenTTL      = 30 * time.Minute
+	resetMaxAttempts   = 5
+	resetMaxRequests   = 3
+	resetRequestWindow = time.Hour
+)
+
+// Same answer whether the account exists or not, so the endpoint cannot list accounts
+const forgotPasswordMessage = "If the account exists and has an email address, a reset link was sent to it"
+
+type PasswordResetController struct {
+	userRepo         model.IUserRepository
+	resetRepo        model.IPasswordResetRepository
+	verificationRepo model.IChannelVerificationRepository
+	passwordPolicy   *passwordpolicy.Policy
+	broadcastService *broadcast.BroadcastService
+	appURL           string
+	validator        *validator.Validate
+
+	// reset links still being sent after ForgotPassword returned
+	inFlight sync.WaitGroup
+}
+
+func NewPasswordResetController(
+	userRepo model.IUserRepository,
+	resetRepo model.IPasswordResetRepository,
+	verificationRepo model.IChannelVerificationRepository,
+	passwordPolicy *passwordpolicy.Policy,
+	broadcastService *broadcast.BroadcastService,
+	appURL string,
+) *PasswordResetController {
+	return &PasswordResetController{
+		userRepo:         userRepo,
+		resetRepo:        resetRepo,
+		verificationRepo: verificationRepo,
+		passwordPolicy:   passwordPolicy,
+		broadcastService: broadcastService,
+		appURL:           strings.TrimRight(appURL, "/"),
+		validator:        newValidator(),
+	}
+}
+
+// ForgotPassword emails a reset link to the account. The account is looked up and the link sent
+// after the response, so neither the answer nor the time it takes tells accounts apart. What
+// happened (unknown name, no verified email, suspended, rate limited, send failure) is only logged
+func (c *PasswordResetController) ForgotPassword(ctx context.Context, req *dto.ForgotPasswordRequest) (*dto.APIResponse, error) {
+	if err := c.validator.Struct(req); err != nil {
+		return nil, invalid(err)
+	}
+
+	// keeps the logger and trace of the request, not its cancellation
+	sendCtx := context.WithoutCancel(ctx)
+	name := req.Name
+	c.inFlight.Go(func() {
+		c.forgotPassword(sendCtx, name)
+	})
+
+	return &dto.APIResponse{Message: forgotPasswordMessage}, nil
+}
+
+// Wait blocks until the reset links requested so far are sent
+func (c *PasswordResetController) Wait() {
+	c.inFlight.Wait()
+}
+
+func (c *PasswordResetController) forgotPassword(ctx context.Context, name string) {
+	user, err := c.userRepo.GetByName(ctx, name)
+	if err != nil {
+		logging.FromContext(ctx).Info("Password reset requested for unknown user")
+		return
+	}
+
+	if user.Email == "" || user.SuspendedAt != nil {
+		logging.FromContext(ctx).Info("Password reset not sent", slog.Int("user_id", user.ID))
+		return
+	}
+
+	// the link only goes to an address the user proved is theirs with a code
+	verification, err := c.verificationRepo.Get(ctx, user.ID, "email", user.Email)
+	if err != nil || verification.VerifiedAt == nil {
+		logging.FromContext(ctx).Info("Password reset not sent, email not verified", slog.Int("user_id", user.ID))
+		return
+	}
+
+	if err := c.sendResetLink(ctx, user); err != nil {
+		logging.FromContext(ctx).Error("Failed to send password reset", slog.Int("user_id", user.ID), slog.String("err", err.Error()))
+	}
+}
+
+func (c *PasswordResetController) sendResetLink(ctx context.Context, user *model.User) error {
+	now := time.Now()
+
+	requests, err := c.resetRepo.CountSince(ctx, user.ID, now.Add(-resetRequestWindow))
+	if err != nil {
+		return err
+	}
+
+	if requests >= resetMaxRequests {
+		return ErrTooManyRequests
+	}
+
+	selector, err := utils.GenerateRandomToken(resetSelectorBytes)
+	if err != nil {
+		return err
+	}
+
+	verifier, err := utils.GenerateRandomToken(resetVerifierBytes)
+	if err != nil {
+		return err
+	}
+
+	reset := &model.PasswordReset{
+		UserID:       user.ID,
+		Selector:     selector,
+		VerifierHash: utils.HashToken(verifier),
+		ExpiresAt:    now.Add(resetTokenTTL),
+	}
+
+	if err := c.resetRepo.Create(ctx, reset); err != nil {
+		return err
+	}
+
+	token := selector + "." + verifier
+	link := c.appURL + "/reset-password?token=" + url.QueryEscape(token)
+
+	_, err = c.broadcastService.Notify(ctx, broadcast.Subscription{
+		UserID:           user.ID,
+		NotificationType: broadcast.Email,
+		Address:          user.Email,
+	}, broadcast.Message{
+		Title: "Password reset",
+		Content: fmt.Sprintf("To choose a new Football APP password open %s within %d minutes. "+
+			"If you did not ask for it, ignore this email.", link, int(resetTokenTTL.Minutes())),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to deliver password reset: %w", err)
+	}
+
+	return nil
+}
+
+// ResetPassword consumes the token and sets the new password, signing the user out everywhere
+func (c *PasswordResetController) ResetPassword(ctx context.Context, req *dto.ResetPasswordRequest) (*dto.APIResponse, error) {
+	if err := c.validator.Struct(req); err != nil {
+		return nil, invalid(err)
+	}
+
+	selector, verifier, ok := strings.Cut(req.Token, ".")
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+
+	reset, err := c.resetRepo.GetBySelector(ctx, selector)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	if reset.UsedAt != nil || time.Now().After(reset.ExpiresAt) || reset.Attempts >= resetMaxAttempts {
+		return nil, ErrInvalidToken
+	}
+
+	if subtle.ConstantTimeCompare([]byte(utils.HashToken(verifier)), []byte(reset.VerifierHash)) != 1 {
+		if err := c.resetRepo.IncrementAttempts(ctx, reset.ID); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidToken
+	}
+
+	user, err := c.userRepo.GetByID(ctx, reset.UserID)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	// Checked before the token is used, the user can fix the password with the same link
+	if err := c.passwordPolicy.Validate(req.NewPassword, user.Name); err != nil {
+		return nil, err
+	}
+
+	hashedPassword, err := utils.HashPassword(req.NewPassword)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	// Claim the token before changing anything, two concurrent requests cannot both use it
+	used, err := c.resetRepo.MarkUsed(ctx, reset.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !used {
+		return nil, ErrInvalidToken
+	}
+
+	if err := c.userRepo.UpdatePassword(ctx, reset.UserID, hashedPassword, time.Now()); err != nil {
+		return nil, fmt.Errorf("failed to update password: %w", err)
+	}
+
+	return &dto.APIResponse{
+		Message: "Password successfully reset!",
+	}, nil
+}
@@ -0,0 +1,256 @@
+package controller_test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tsntt/footballapi/internal/controller"
+	"github.com/tsntt/footballapi/internal/dto"
+	"github.com/tsntt/footballapi/internal/model"
+	"github.com/tsntt/footballapi/pkg/broadcast"
+	"github.com/tsntt/footballapi/pkg/passwordpolicy"
+	"github.com/tsntt/footballapi/pkg/utils"
+)
+
+var resetLinkPattern = regexp.MustCompile(`token=(\S+)`)
+
+// newPasswordResetTest returns a controller whose emails are captured in sent,
+// only ana@example.com is verified
+func newPasswordResetTest(user *model.User) (*controller.PasswordResetController, *mockPasswordResetRepository, *[]broadcast.Message, *string) {
+	var sent []broadcast.Message
+	var newHash string
+
+	mockUserRepo := &mockUserRepository{
+		getByName: func(ctx context.Context, name string) (*model.User, error) {
+			if user == nil || name != user.Name {
+				return nil, errors.New("user not found")
+			}
+			return user, nil
+		},
+		getByID: func(ctx context.Context, id int) (*model.User, error) {
+			return user, nil
+		},
+		updatePassword: func(ctx context.Context, id int, password string, revokedAt time.Time) error {
+			newHash = password
+			return nil
+		},
+	}
+
+	broadcastService := broadcast.NewBroadcastService()
+	broadcastService.RegisterNotifier(broadcast.Email, &mockBroadcaster{
+		send: func(ctx context.Context, subscription broadcast.Subscription, message broadcast.Message) (string, error) {
+			sent = append(sent, message)
+			return "msg-1", nil
+		},
+	})
+
+	mockVerificationRepo := &mockChannelVerificationRepository{
+		get: func(ctx context.Context, userID int, notificationType, address string) (*model.ChannelVerification, error) {
+			verification := &model.ChannelVerification{ID: 1, UserID: userID, NotificationType: notificationType, Address: address}
+			if address == "ana@example.com" {
+				verifiedAt := time.Now()
+				verification.VerifiedAt = &verifiedAt
+			}
+			return verification, nil
+		},
+	}
+
+	resetRepo := &mockPasswordResetRepository{}
+	resetController := controller.NewPasswordResetController(mockUserRepo, resetRepo, mockVerificationRepo, testPasswordPolicy, broadcastService, "http://app.test/")
+
+	return resetController, resetRepo, &sent, &newHash
+}
+
+func resetTokenFrom(t *testing.T, message broadcast.Message) string {
+	t.Helper()
+
+	match := resetLinkPattern.FindStringSubmatch(message.Content)
+	if match == nil {
+		t.Fatalf("expected a reset link in %q", message.Content)
+	}
+
+	token, err := url.QueryUnescape(match[1])
+	if err != nil {
+		t.Fatalf("invalid token in link: %v", err)
+	}
+
+	return token
+}
+
+func TestPasswordResetController_ForgotAndReset(t *testing.T) {
+	user := &model.User{ID: 1, Name: "ana", Email: "ana@example.com"}
+	resetController, resetRepo, sent, newHash := newPasswordResetTest(user)
+	ctx := context.Background()
+
+	if _, err := resetController.ForgotPassword(ctx, &dto.ForgotPasswordRequest{Name: "ana"}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	resetController.Wait()
+
+	if len(*sent) != 1 {
+		t.Fatalf("expected 1 email, got %d", len(*sent))
+	}
+
+	if !strings.Contains((*sent)[0].Content, "http://app.test/reset-password?token=") {
+		t.Errorf("expected link to the app, got %q", (*sent)[0].Content)
+	}
+
+	token := resetTokenFrom(t, (*sent)[0])
+	_, verifier, _ := strings.Cut(token, ".")
+	if resetRepo.resets[0].VerifierHash != utils.HashToken(verifier) {
+		t.Error("expected verifier to be stored hashed")
+	}
+
+	if _, err := resetController.ResetPassword(ctx, &dto.ResetPasswordRequest{Token: token, NewPassword: "newpassword"}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if !utils.CheckPasswordHash("newpassword", *newHash) {
+		t.Error("expected the new password to be stored hashed")
+	}
+
+	// single use
+	_, err := resetController.ResetPassword(ctx, &dto.ResetPasswordRequest{Token: token, NewPassword: "another"})
+	if !errors.Is(err, controller.ErrInvalidToken) {
+		t.Errorf("expected ErrInvalidToken on reuse, got %v", err)
+	}
+}
+
+func TestPasswordResetController_ForgotPassword_SameResponse(t *testing.T) {
+	suspendedAt := time.Now()
+
+	tests := []struct {
+		name     string
+		user     *model.User
+		wantSent int
+	}{
+		{"existing user", &model.User{ID: 1, Name: "ana", Email: "ana@example.com"}, 1},
+		{"unknown user", nil, 0},
+		{"user without email", &model.User{ID: 1, Name: "ana"}, 0},
+		{"unverified email", &model.User{ID: 1, Name: "ana", Email: "new@example.com"}, 0},
+		{"suspended user", &model.User{ID: 1, Name: "ana", Email: "ana@example.com", SuspendedAt: &suspendedAt}, 0},
+	}
+
+	var messages []string
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetController, _, sent, _ := newPasswordResetTest(tt.user)
+
+			resp, err := resetController.ForgotPassword(context.Background(), &dto.ForgotPasswordRequest{Name: "ana"})
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			// the email goes out after the response
+			resetController.Wait()
+
+			if len(*sent) != tt.wantSent {
+				t.Errorf("expected %d emails, got %d", tt.wantSent, len(*sent))
+			}
+
+			messages = append(messages, resp.Message)
+		})
+	}
+
+	for _, message := range messages {
+		if message != messages[0] {
+			t.Errorf("expected the same response for every case, got %q and %q", messages[0], message)
+		}
+	}
+}
+
+func TestPasswordResetController_ForgotPassword_RateLimited(t *testing.T) {
+	user := &model.User{ID: 1, Name: "ana", Email: "ana@example.com"}
+	resetController, _, sent, _ := newPasswordResetTest(user)
+
+	for range 5 {
+		if _, err := resetController.ForgotPassword(context.Background(), &dto.ForgotPasswordRequest{Name: "ana"}); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		resetController.Wait()
+	}
+
+	if len(*sent) != 3 {
+		t.Errorf("expected 3 emails in the hour, got %d", len(*sent))
+	}
+
+	// only the latest link works
+	tokens := []string{resetTokenFrom(t, (*sent)[0]), resetTokenFrom(t, (*sent)[2])}
+	if _, err := resetController.ResetPassword(context.Background(), &dto.ResetPasswordRequest{Token: tokens[0], NewPassword: "newpassword"}); !errors.Is(err, controller.ErrInvalidToken) {
+		t.Errorf("expected ErrInvalidToken for a superseded link, got %v", err)
+	}
+	if _, err := resetController.ResetPassword(context.Background(), &dto.ResetPasswordRequest{Token: tokens[1], NewPassword: "newpassword"}); err != nil {
+		t.Errorf("expected latest link to work, got %v", err)
+	}
+}
+
+func TestPasswordResetController_ResetPassword_Invalid(t *testing.T) {
+	user := &model.User{ID: 1, Name: "ana", Email: "ana@example.com"}
+	resetController, resetRepo, sent, _ := newPasswordResetTest(user)
+	ctx := context.Background()
+
+	if _, err := resetController.ForgotPassword(ctx, &dto.ForgotPasswordRequest{Name: "ana"}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	resetController.Wait()
+	token := resetTokenFrom(t, (*sent)[0])
+	selector, _, _ := strings.Cut(token, ".")
+
+	for _, bad := range []string{"no-separator", "unknown.verifier", selector + ".wrong"} {
+		_, err := resetController.ResetPassword(ctx, &dto.ResetPasswordRequest{Token: bad, NewPassword: "newpassword"})
+		if !errors.Is(err, controller.ErrInvalidToken) {
+			t.Errorf("token %q: expected ErrInvalidToken, got %v", bad, err)
+		}
+	}
+
+	if resetRepo.resets[0].Attempts != 1 {
+		t.Errorf("expected 1 failed attempt, got %d", resetRepo.resets[0].Attempts)
+	}
+
+	// too many wrong guesses burn the token
+	resetRepo.resets[0].Attempts = 5
+	if _, err := resetController.ResetPassword(ctx, &dto.ResetPasswordRequest{Token: token, NewPassword: "newpassword"}); !errors.Is(err, controller.ErrInvalidToken) {
+		t.Errorf("expected ErrInvalidToken after too many attempts, got %v", err)
+	}
+
+	// expired
+	resetRepo.resets[0].Attempts = 0
+	resetRepo.resets[0].ExpiresAt = time.Now().Add(-time.Minute)
+	if _, err := resetController.ResetPassword(ctx, &dto.ResetPasswordRequest{Token: token, NewPassword: "newpassword"}); !errors.Is(err, controller.ErrInvalidToken) {
+		t.Errorf("expected ErrInvalidToken for an expired token, got %v", err)
+	}
+}
+
+func TestPasswordResetController_ResetPassword_WeakPassword(t *testing.T) {
+	user := &model.User{ID: 1, Name: "ana", Email: "ana@example.com"}
+	resetController, resetRepo, sent, newHash := newPasswordResetTest(user)
+	ctx := context.Background()
+
+	if _, err := resetController.ForgotPassword(ctx, &dto.ForgotPasswordRequest{Name: "ana"}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	resetController.Wait()
+	token := resetTokenFrom(t, (*sent)[0])
+
+	_, err := resetController.ResetPassword(ctx, &dto.ResetPasswordRequest{Token: token, NewPassword: "12345678"})
+	var policyErr *passwordpolicy.Error
+	if !errors.As(err, &policyErr) {
+		t.Fatalf("expected a policy error, got %v", err)
+	}
+
+	if *newHash != "" {
+		t.Error("expected the password to be left unchanged")
+	}
+
+	// a rejected password does not burn the token
+	if resetRepo.resets[0].UsedAt != nil {
+		t.Error("expected the token to stay usable")
+	}
+	if _, err := resetController.ResetPassword(ctx, &dto.ResetPasswordRequest{Token: token, NewPassword: "newpassword"}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+}
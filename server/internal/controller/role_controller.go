@@ -0,0 +1,114 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/tsntt/footballapi/internal/dto"
+	"github.com/tsntt/footballapi/internal/model"
+)
+
+// Role permissions are read on every protected request, keep them for a short while
+const permissionsCacheTTL = time.Minute
+
+type cachedPermissions struct {
+	permissions []string
+	expiresAt   time.Time
+}
+
+type RoleController struct {
+	roleRepo  model.IRoleRepository
+	userRepo  model.IUserRepository
+	auditRepo model.IAuditRepository
+	validator *validator.Validate
+
+	mu    sync.RWMutex
+	cache map[string]cachedPermissions
+}
+
+func NewRoleController(roleRepo model.IRoleRepository, userRepo model.IUserRepository, auditRepo model.IAuditRepository) *RoleController {
+	return &RoleController{
+		roleRepo:  roleRepo,
+		userRepo:  userRepo,
+		auditRepo: auditRepo,
+		validator: newValidator(),
+		cache:     make(map[string]cachedPermissions),
+	}
+}
+
+func (c *RoleController) GetRoles(ctx context.Context) ([]model.Role, error) {
+	roles, err := c.roleRepo.GetAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get roles: %w", err)
+	}
+
+	return roles, nil
+}
+
+// AssignRole replaces the role of userID, actorID is the admin doing it
+func (c *RoleController) AssignRole(ctx context.Context, actorID, userID int, req *dto.AssignRoleRequest) (*dto.APIResponse, error) {
+	if err := c.validator.Struct(req); err != nil {
+		return nil, invalid(err)
+	}
+
+	// An admin demoting themselves could leave nobody able to manage users
+	if actorID == userID {
+		return nil, ErrOwnRole
+	}
+
+	if _, err := c.roleRepo.GetByName(ctx, req.Role); err != nil {
+		return nil, orNotFound(err, ErrUnknownRole.Withf("%s", req.Role), "get role")
+	}
+
+	user, err := c.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, orNotFound(err, ErrUserNotFound, "get user")
+	}
+
+	if err := c.userRepo.UpdateRole(ctx, userID, req.Role, time.Now()); err != nil {
+		return nil, orNotFound(err, ErrUserNotFound, "assign role")
+	}
+
+	recordUserAudit(ctx, c.auditRepo, actorID, userID, model.AuditUserRoleAssigned, fmt.Sprintf("%s -> %s", user.Role, req.Role))
+
+	return &dto.APIResponse{
+		Message: "Role successfully assigned!",
+	}, nil
+}
+
+// HasPermission checks the current permissions of role, not the ones frozen in the token, so a
+// permission revoked from a role takes effect within permissionsCacheTTL. Assigning another role
+// to a user revokes their tokens instead (see UserRepository.UpdateRole), the role is in the claims
+func (c *RoleController) HasPermission(ctx context.Context, role, permission string) (bool, error) {
+	permissions, err := c.permissions(ctx, role)
+	if err != nil {
+		return false, err
+	}
+
+	return slices.Contains(permissions, permission), nil
+}
+
+func (c *RoleController) permissions(ctx context.Context, role string) ([]string, error) {
+	c.mu.RLock()
+	cached, ok := c.cache[role]
+	c.mu.RUnlock()
+
+	if ok && time.Now().Before(cached.expiresAt) {
+		return cached.permissions, nil
+	}
+
+	permissions, err := c.roleRepo.GetPermissions(ctx, role)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get permissions: %w", err)
+	}
+
+	c.mu.Lock()
+	c.cache[role] = cachedPermissions{permissions: permissions, expiresAt: time.Now().Add(permissionsCacheTTL)}
+	c.mu.Unlock()
+
+	return permissions, nil
+}
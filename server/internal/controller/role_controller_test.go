@@ -0,0 +1,134 @@
+package controller_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/tsntt/footballapi/internal/controller"
+	"github.com/tsntt/footballapi/internal/dto"
+	"github.com/tsntt/footballapi/internal/model"
+)
+
+func TestRoleController_AssignRole(t *testing.T) {
+	var assigned string
+	auditRepo := &mockAuditRepository{}
+	mockUserRepo := &mockUserRepository{
+		getByID: func(ctx context.Context, id int) (*model.User, error) {
+			return &model.User{ID: id, Role: "default"}, nil
+		},
+		updateRole: func(ctx context.Context, id int, role string, revokedAt time.Time) error {
+			assigned = role
+			return nil
+		},
+	}
+
+	roleController := controller.NewRoleController(mockRoleRepo(), mockUserRepo, auditRepo)
+
+	_, err := roleController.AssignRole(context.Background(), 1, 2, &dto.AssignRoleRequest{Role: "admin"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if assigned != "admin" {
+		t.Errorf("expected role admin, got %q", assigned)
+	}
+
+	if len(auditRepo.entries) != 1 || auditRepo.entries[0].Action != model.AuditUserRoleAssigned {
+		t.Fatalf("expected a role assigned audit entry, got %+v", auditRepo.entries)
+	}
+
+	if auditRepo.entries[0].Details != "default -> admin" {
+		t.Errorf("expected details %q, got %q", "default -> admin", auditRepo.entries[0].Details)
+	}
+}
+
+func TestRoleController_AssignRole_Errors(t *testing.T) {
+	auditRepo := &mockAuditRepository{}
+	mockUserRepo := &mockUserRepository{
+		updateRole: func(ctx context.Context, id int, role string, revokedAt time.Time) error {
+			t.Fatal("role should not be updated")
+			return nil
+		},
+	}
+
+	roleController := controller.NewRoleController(mockRoleRepo(), mockUserRepo, auditRepo)
+
+	tests := []struct {
+		name    string
+		actorID int
+		req     *dto.AssignRoleRequest
+		wantErr error
+	}{
+		{"missing role", 1, &dto.AssignRoleRequest{}, nil},
+		{"unknown role", 1, &dto.AssignRoleRequest{Role: "superuser"}, nil},
+		{"own role", 2, &dto.AssignRoleRequest{Role: "default"}, controller.ErrOwnRole},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := roleController.AssignRole(context.Background(), tt.actorID, 2, tt.req)
+			if err == nil {
+				t.Fatal("expected an error, got nil")
+			}
+
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("expected %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestRoleController_HasPermission(t *testing.T) {
+	calls := 0
+	roleRepo := mockRoleRepo()
+	getPermissions := roleRepo.getPermissions
+	roleRepo.getPermissions = func(ctx context.Context, role string) ([]string, error) {
+		calls++
+		return getPermissions(ctx, role)
+	}
+
+	roleController := controller.NewRoleController(roleRepo, nil, nil)
+
+	tests := []struct {
+		role       string
+		permission string
+		want       bool
+	}{
+		{"admin", model.PermBroadcastSend, true},
+		{"admin", model.PermUsersManage, true},
+		{"default", model.PermBroadcastSend, false},
+		{"unknown", model.PermBroadcastRead, false},
+	}
+
+	for _, tt := range tests {
+		got, err := roleController.HasPermission(context.Background(), tt.role, tt.permission)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if got != tt.want {
+			t.Errorf("HasPermission(%s, %s) = %v, want %v", tt.role, tt.permission, got, tt.want)
+		}
+	}
+
+	// admin permissions are served from cache the second time
+	if calls != 3 {
+		t.Errorf("expected 3 repository calls, got %d", calls)
+	}
+}
+
+func TestRoleController_HasPermission_Error(t *testing.T) {
+	roleRepo := &mockRoleRepository{
+		getPermissions: func(ctx context.Context, role string) ([]string, error) {
+			return nil, errors.New("db error")
+		},
+	}
+
+	roleController := controller.NewRoleController(roleRepo, nil, nil)
+
+	if _, err := roleController.HasPermission(context.Background(), "admin", model.PermBroadcastSend); err == nil {
+		t.Fatal("expected an error, got nil")
+	}
+}
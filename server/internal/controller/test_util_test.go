@@ -2,10 +2,17 @@ package controller_test
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"time"
 
 	"github.com/tsntt/footballapi/internal/model"
+	"github.com/tsntt/footballapi/pkg/broadcast"
+	"github.com/tsntt/footballapi/pkg/passwordpolicy"
 )
 
+var testPasswordPolicy = passwordpolicy.New(passwordpolicy.Options{MinLength: 8})
+
 // Mocks
 type mockChampionshipAPI struct {
 	getChampionships func(ctx context.Context) ([]model.Championship, error)
@@ -31,6 +38,9 @@ type mockFanRepository struct {
 	getByTeamID           func(ctx context.Context, teamID int) ([]model.Fan, error)
 	getByUserID           func(ctx context.Context, userID int) ([]model.Fan, error)
 	deleteByUserIDAndTeam func(ctx context.Context, userID int, team string) error
+	disableByAddress      func(ctx context.Context, notificationType, address string) (int64, error)
+	disableByUserAndAddr  func(ctx context.Context, userID int, notificationType, address string) (int64, error)
+	activateByUserAndAddr func(ctx context.Context, userID int, notificationType, address string) (int64, error)
 }
 
 func (m *mockFanRepository) Create(ctx context.Context, fan *model.Fan) error {
@@ -53,6 +63,18 @@ func (m *mockFanRepository) DeleteByUserIDAndTeam(ctx context.Context, userID in
 	return m.deleteByUserIDAndTeam(ctx, userID, team)
 }
 
+func (m *mockFanRepository) DisableByAddress(ctx context.Context, notificationType, address string) (int64, error) {
+	return m.disableByAddress(ctx, notificationType, address)
+}
+
+func (m *mockFanRepository) DisableByUserAndAddress(ctx context.Context, userID int, notificationType, address string) (int64, error) {
+	return m.disableByUserAndAddr(ctx, userID, notificationType, address)
+}
+
+func (m *mockFanRepository) ActivateByUserAndAddress(ctx context.Context, userID int, notificationType, address string) (int64, error) {
+	return m.activateByUserAndAddr(ctx, userID, notificationType, address)
+}
+
 type mockBroadcastRepository struct {
 	create       func(ctx context.Context, broadcast *model.BroadcastMessage) error
 	getByMatchID func(ctx context.Context, matchID int) (*model.BroadcastMessage, error)
@@ -72,9 +94,16 @@ func (m *mockBroadcastRepository) Update(ctx context.Context, broadcast *model.B
 }
 
 type mockUserRepository struct {
-	create    func(ctx context.Context, user *model.User) error
-	getByName func(ctx context.Context, name string) (*model.User, error)
-	getByID   func(ctx context.Context, id int) (*model.User, error)
+	create         func(ctx context.Context, user *model.User) error
+	getByName      func(ctx context.Context, name string) (*model.User, error)
+	getByID        func(ctx context.Context, id int) (*model.User, error)
+	updateRole     func(ctx context.Context, id int, role string, revokedAt time.Time) error
+	updateProfile  func(ctx context.Context, user *model.User) error
+	updatePassword func(ctx context.Context, id int, password string, revokedAt time.Time) error
+	list           func(ctx context.Context, filter model.UserFilter) ([]model.User, int, error)
+	suspend        func(ctx context.Context, id int, suspendedAt time.Time) error
+	reactivate     func(ctx context.Context, id int) error
+	delete         func(ctx context.Context, id int) error
 }
 
 func (m *mockUserRepository) Create(ctx context.Context, user *model.User) error {
@@ -88,3 +117,544 @@ func (m *mockUserRepository) GetByName(ctx context.Context, name string) (*model
 func (m *mockUserRepository) GetByID(ctx context.Context, id int) (*model.User, error) {
 	return m.getByID(ctx, id)
 }
+
+func (m *mockUserRepository) UpdateRole(ctx context.Context, id int, role string, revokedAt time.Time) error {
+	return m.updateRole(ctx, id, role, revokedAt)
+}
+
+func (m *mockUserRepository) UpdateProfile(ctx context.Context, user *model.User) error {
+	return m.updateProfile(ctx, user)
+}
+
+func (m *mockUserRepository) UpdatePassword(ctx context.Context, id int, password string, revokedAt time.Time) error {
+	return m.updatePassword(ctx, id, password, revokedAt)
+}
+
+func (m *mockUserRepository) List(ctx context.Context, filter model.UserFilter) ([]model.User, int, error) {
+	return m.list(ctx, filter)
+}
+
+func (m *mockUserRepository) Suspend(ctx context.Context, id int, suspendedAt time.Time) error {
+	return m.suspend(ctx, id, suspendedAt)
+}
+
+func (m *mockUserRepository) Reactivate(ctx context.Context, id int) error {
+	return m.reactivate(ctx, id)
+}
+
+func (m *mockUserRepository) Delete(ctx context.Context, id int) error {
+	return m.delete(ctx, id)
+}
+
+// mockAuditRepository keeps created entries in memory
+type mockAuditRepository struct {
+	entries []model.AuditEntry
+}
+
+func (m *mockAuditRepository) Create(ctx context.Context, entry *model.AuditEntry) error {
+	m.entries = append(m.entries, *entry)
+	return nil
+}
+
+func (m *mockAuditRepository) GetByTarget(ctx context.Context, targetType string, targetID int) ([]model.AuditEntry, error) {
+	entries := []model.AuditEntry{}
+	for _, entry := range m.entries {
+		if entry.TargetType == targetType && entry.TargetID == targetID {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+type mockRoleRepository struct {
+	getAll         func(ctx context.Context) ([]model.Role, error)
+	getByName      func(ctx context.Context, name string) (*model.Role, error)
+	getPermissions func(ctx context.Context, role string) ([]string, error)
+}
+
+func (m *mockRoleRepository) GetAll(ctx context.Context) ([]model.Role, error) {
+	return m.getAll(ctx)
+}
+
+func (m *mockRoleRepository) GetByName(ctx context.Context, name string) (*model.Role, error) {
+	return m.getByName(ctx, name)
+}
+
+func (m *mockRoleRepository) GetPermissions(ctx context.Context, role string) ([]string, error) {
+	return m.getPermissions(ctx, role)
+}
+
+// mockRoleRepo knows the seeded admin and default roles
+func mockRoleRepo() *mockRoleRepository {
+	permissions := map[string][]string{
+		"admin":   {model.PermBroadcastRead, model.PermBroadcastSend, model.PermTemplatesEdit, model.PermUsersManage},
+		"default": {},
+	}
+
+	return &mockRoleRepository{
+		getByName: func(ctx context.Context, name string) (*model.Role, error) {
+			if _, ok := permissions[name]; !ok {
+				return nil, fmt.Errorf("role %w", model.ErrNotFound)
+			}
+			return &model.Role{Name: name, Permissions: permissions[name]}, nil
+		},
+		getPermissions: func(ctx context.Context, role string) ([]string, error) {
+			return permissions[role], nil
+		},
+	}
+}
+
+type mockDeliveryRepository struct {
+	create                 func(ctx context.Context, delivery *model.Delivery) error
+	getByProviderMessageID func(ctx context.Context, providerMessageID string) (*model.Delivery, error)
+	getByBroadcastID       func(ctx context.Context, broadcastID int) ([]model.Delivery, error)
+	getByUserID            func(ctx context.Context, userID, limit int) ([]model.Delivery, error)
+	updateStatus           func(ctx context.Context, delivery *model.Delivery) error
+	addEvent               func(ctx context.Context, event *model.DeliveryEvent) error
+}
+
+func (m *mockDeliveryRepository) Create(ctx context.Context, delivery *model.Delivery) error {
+	return m.create(ctx, delivery)
+}
+
+func (m *mockDeliveryRepository) GetByProviderMessageID(ctx context.Context, providerMessageID string) (*model.Delivery, error) {
+	return m.getByProviderMessageID(ctx, providerMessageID)
+}
+
+func (m *mockDeliveryRepository) GetByBroadcastID(ctx context.Context, broadcastID int) ([]model.Delivery, error) {
+	return m.getByBroadcastID(ctx, broadcastID)
+}
+
+func (m *mockDeliveryRepository) GetByUserID(ctx context.Context, userID, limit int) ([]model.Delivery, error) {
+	return m.getByUserID(ctx, userID, limit)
+}
+
+func (m *mockDeliveryRepository) UpdateStatus(ctx context.Context, delivery *model.Delivery) error {
+	return m.updateStatus(ctx, delivery)
+}
+
+func (m *mockDeliveryRepository) AddEvent(ctx context.Context, event *model.DeliveryEvent) error {
+	return m.addEvent(ctx, event)
+}
+
+type mockSMSWebhookValidator struct {
+	valid bool
+}
+
+func (m *mockSMSWebhookValidator) ValidateStatusCallback(params map[string]string, signature string) bool {
+	return m.valid
+}
+
+func (m *mockSMSWebhookValidator) ValidateInbound(params map[string]string, signature string) bool {
+	return m.valid
+}
+
+type mockEmailWebhookValidator struct {
+	valid bool
+}
+
+func (m *mockEmailWebhookValidator) VerifyWebhook(timestamp, token, signature string) bool {
+	return m.valid
+}
+
+// mockWebhookTokenRepository remembers every token, none is forgotten
+type mockWebhookTokenRepository struct {
+	seen map[string]bool
+}
+
+func (m *mockWebhookTokenRepository) Claim(ctx context.Context, token string, forgetBefore time.Time) (bool, error) {
+	if m.seen == nil {
+		m.seen = map[string]bool{}
+	}
+	if m.seen[token] {
+		return false, nil
+	}
+	m.seen[token] = true
+	return true, nil
+}
+
+func (m *mockWebhookTokenRepository) Release(ctx context.Context, token string) error {
+	delete(m.seen, token)
+	return nil
+}
+
+type mockChannelVerificationRepository struct {
+	get               func(ctx context.Context, userID int, notificationType, address string) (*model.ChannelVerification, error)
+	save              func(ctx context.Context, verification *model.ChannelVerification) error
+	incrementAttempts func(ctx context.Context, id, maxAttempts int) (bool, error)
+	markVerified      func(ctx context.Context, id int) error
+}
+
+func (m *mockChannelVerificationRepository) Get(ctx context.Context, userID int, notificationType, address string) (*model.ChannelVerification, error) {
+	return m.get(ctx, userID, notificationType, address)
+}
+
+func (m *mockChannelVerificationRepository) Save(ctx context.Context, verification *model.ChannelVerification) error {
+	return m.save(ctx, verification)
+}
+
+func (m *mockChannelVerificationRepository) IncrementAttempts(ctx context.Context, id, maxAttempts int) (bool, error) {
+	return m.incrementAttempts(ctx, id, maxAttempts)
+}
+
+func (m *mockChannelVerificationRepository) MarkVerified(ctx context.Context, id int) error {
+	return m.markVerified(ctx, id)
+}
+
+type mockBroadcaster struct {
+	send func(ctx context.Context, subscription broadcast.Subscription, message broadcast.Message) (string, error)
+}
+
+func (m *mockBroadcaster) Send(ctx context.Context, subscription broadcast.Subscription, message broadcast.Message) (string, error) {
+	return m.send(ctx, subscription, message)
+}
+
+// mockPasswordResetRepository keeps resets in memory
+type mockPasswordResetRepository struct {
+	resets []*model.PasswordReset
+}
+
+func (m *mockPasswordResetRepository) Create(ctx context.Context, reset *model.PasswordReset) error {
+	now := time.Now()
+	for _, r := range m.resets {
+		if r.UserID == reset.UserID && r.UsedAt == nil {
+			r.UsedAt = &now
+		}
+	}
+	reset.ID = len(m.resets) + 1
+	reset.CreatedAt = now
+	m.resets = append(m.resets, reset)
+	return nil
+}
+
+func (m *mockPasswordResetRepository) GetBySelector(ctx context.Context, selector string) (*model.PasswordReset, error) {
+	for _, r := range m.resets {
+		if r.Selector == selector {
+			return r, nil
+		}
+	}
+	return nil, fmt.Errorf("password reset %w", model.ErrNotFound)
+}
+
+func (m *mockPasswordResetRepository) CountSince(ctx context.Context, userID int, since time.Time) (int, error) {
+	count := 0
+	for _, r := range m.resets {
+		if r.UserID == userID && !r.CreatedAt.Before(since) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (m *mockPasswordResetRepository) IncrementAttempts(ctx context.Context, id int) error {
+	m.resets[id-1].Attempts++
+	return nil
+}
+
+func (m *mockPasswordResetRepository) MarkUsed(ctx context.Context, id int) (bool, error) {
+	reset := m.resets[id-1]
+	if reset.UsedAt != nil {
+		return false, nil
+	}
+	now := time.Now()
+	reset.UsedAt = &now
+	return true, nil
+}
+
+// mockLoginAttemptRepository keeps attempts and throttles in memory
+type mockLoginAttemptRepository struct {
+	attempts  []model.LoginAttempt
+	throttles map[string]*model.LoginThrottle
+}
+
+func newMockLoginAttemptRepository() *mockLoginAttemptRepository {
+	return &mockLoginAttemptRepository{throttles: map[string]*model.LoginThrottle{}}
+}
+
+func (m *mockLoginAttemptRepository) Record(ctx context.Context, attempt *model.LoginAttempt) error {
+	m.attempts = append(m.attempts, *attempt)
+	return nil
+}
+
+func (m *mockLoginAttemptRepository) GetThrottle(ctx context.Context, key string) (*model.LoginThrottle, error) {
+	throttle, ok := m.throttles[key]
+	if !ok {
+		return nil, fmt.Errorf("login throttle %w", model.ErrNotFound)
+	}
+	copied := *throttle
+	return &copied, nil
+}
+
+func (m *mockLoginAttemptRepository) AddFailure(ctx context.Context, key string, now, windowStart time.Time) (*model.LoginThrottle, error) {
+	throttle, ok := m.throttles[key]
+	if !ok || throttle.LastFailureAt.Before(windowStart) {
+		throttle = &model.LoginThrottle{Key: key}
+		m.throttles[key] = throttle
+	}
+	throttle.Failures++
+	throttle.LastFailureAt = now
+
+	copied := *throttle
+	return &copied, nil
+}
+
+func (m *mockLoginAttemptRepository) LockThrottle(ctx context.Context, key string, until time.Time) error {
+	if throttle, ok := m.throttles[key]; ok {
+		throttle.LockedUntil = &until
+	}
+	return nil
+}
+
+func (m *mockLoginAttemptRepository) DeleteThrottle(ctx context.Context, key string) error {
+	delete(m.throttles, key)
+	return nil
+}
+
+func (m *mockLoginAttemptRepository) ListThrottles(ctx context.Context, since time.Time) ([]model.LoginThrottle, error) {
+	throttles := []model.LoginThrottle{}
+	for _, throttle := range m.throttles {
+		if !throttle.LastFailureAt.Before(since) {
+			throttles = append(throttles, *throttle)
+		}
+	}
+	return throttles, nil
+}
+
+// mockIdentityStore keeps users, identities and OAuth states in memory, it backs the user,
+// identity and OAuth state repositories of a test
+type mockIdentityStore struct {
+	users      map[int]*model.User
+	identities []model.UserIdentity
+	states     map[string]model.OAuthState
+}
+
+func newMockIdentityStore() *mockIdentityStore {
+	return &mockIdentityStore{users: make(map[int]*model.User), states: make(map[string]model.OAuthState)}
+}
+
+func (m *mockIdentityStore) userRepo() *mockUserRepository {
+	return &mockUserRepository{
+		getByName: func(ctx context.Context, name string) (*model.User, error) {
+			for _, user := range m.users {
+				if user.Name == name {
+					return user, nil
+				}
+			}
+			return nil, fmt.Errorf("user %w", model.ErrNotFound)
+		},
+		getByID: func(ctx context.Context, id int) (*model.User, error) {
+			if user, ok := m.users[id]; ok {
+				return user, nil
+			}
+			return nil, fmt.Errorf("user %w", model.ErrNotFound)
+		},
+	}
+}
+
+func (m *mockIdentityStore) GetByProviderSubject(ctx context.Context, provider, subject string) (*model.UserIdentity, error) {
+	for i := range m.identities {
+		if m.identities[i].Provider == provider && m.identities[i].Subject == subject {
+			return &m.identities[i], nil
+		}
+	}
+	return nil, fmt.Errorf("identity %w", model.ErrNotFound)
+}
+
+func (m *mockIdentityStore) CreateWithUser(ctx context.Context, user *model.User, identity *model.UserIdentity) error {
+	user.ID = len(m.users) + 1
+	m.users[user.ID] = user
+
+	identity.ID = len(m.identities) + 1
+	identity.UserID = user.ID
+	m.identities = append(m.identities, *identity)
+	return nil
+}
+
+func (m *mockIdentityStore) TouchLogin(ctx context.Context, id int) error {
+	return nil
+}
+
+func (m *mockIdentityStore) Create(ctx context.Context, state *model.OAuthState) error {
+	m.states[state.StateHash] = *state
+	return nil
+}
+
+func (m *mockIdentityStore) Consume(ctx context.Context, stateHash string) (*model.OAuthState, error) {
+	state, ok := m.states[stateHash]
+	if !ok {
+		return nil, fmt.Errorf("oauth state %w", model.ErrNotFound)
+	}
+	delete(m.states, stateHash)
+	return &state, nil
+}
+
+// mockAPIKeyRepository keeps keys in memory
+type mockAPIKeyRepository struct {
+	keys []*model.APIKey
+}
+
+func (m *mockAPIKeyRepository) Create(ctx context.Context, key *model.APIKey) error {
+	key.ID = len(m.keys) + 1
+	key.CreatedAt = time.Now()
+	m.keys = append(m.keys, key)
+	return nil
+}
+
+func (m *mockAPIKeyRepository) GetByPrefix(ctx context.Context, prefix string) (*model.APIKey, error) {
+	for _, key := range m.keys {
+		if key.Prefix == prefix {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("api key %w", model.ErrNotFound)
+}
+
+func (m *mockAPIKeyRepository) ListByUser(ctx context.Context, userID int) ([]model.APIKey, error) {
+	var keys []model.APIKey
+	for _, key := range m.keys {
+		if key.UserID == userID {
+			keys = append(keys, *key)
+		}
+	}
+	return keys, nil
+}
+
+func (m *mockAPIKeyRepository) CountActive(ctx context.Context, userID int) (int, error) {
+	count := 0
+	for _, key := range m.keys {
+		if key.UserID == userID && key.RevokedAt == nil && time.Now().Before(key.ExpiresAt) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (m *mockAPIKeyRepository) Revoke(ctx context.Context, userID, id int) (bool, error) {
+	for _, key := range m.keys {
+		if key.ID == id && key.UserID == userID && key.RevokedAt == nil {
+			now := time.Now()
+			key.RevokedAt = &now
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *mockAPIKeyRepository) TouchLastUsed(ctx context.Context, id int, ip string) error {
+	now := time.Now()
+	m.keys[id-1].LastUsedAt = &now
+	m.keys[id-1].LastUsedIP = &ip
+	return nil
+}
+
+// mockTwoFactorStore keeps enrolments, recovery codes and challenges in memory
+type mockTwoFactorStore struct {
+	enrolments    map[int]*model.TwoFactor
+	recoveryCodes map[int]map[string]bool
+}
+
+func newMockTwoFactorStore() *mockTwoFactorStore {
+	return &mockTwoFactorStore{enrolments: map[int]*model.TwoFactor{}, recoveryCodes: map[int]map[string]bool{}}
+}
+
+func (m *mockTwoFactorStore) Get(ctx context.Context, userID int) (*model.TwoFactor, error) {
+	if twoFactor, ok := m.enrolments[userID]; ok {
+		return twoFactor, nil
+	}
+	return nil, fmt.Errorf("two factor %w", model.ErrNotFound)
+}
+
+func (m *mockTwoFactorStore) SavePending(ctx context.Context, userID int, secret string) error {
+	if twoFactor, ok := m.enrolments[userID]; ok && twoFactor.EnabledAt != nil {
+		return errors.New("two factor already enabled")
+	}
+	m.enrolments[userID] = &model.TwoFactor{UserID: userID, Secret: secret, CreatedAt: time.Now()}
+	return nil
+}
+
+func (m *mockTwoFactorStore) Enable(ctx context.Context, userID int, step int64, recoveryCodeHashes []string) error {
+	now := time.Now()
+	m.enrolments[userID].EnabledAt = &now
+	m.enrolments[userID].LastUsedStep = step
+	return m.ReplaceRecoveryCodes(ctx, userID, recoveryCodeHashes)
+}
+
+func (m *mockTwoFactorStore) Delete(ctx context.Context, userID int) error {
+	delete(m.enrolments, userID)
+	delete(m.recoveryCodes, userID)
+	return nil
+}
+
+func (m *mockTwoFactorStore) UseStep(ctx context.Context, userID int, step int64) (bool, error) {
+	twoFactor := m.enrolments[userID]
+	if step <= twoFactor.LastUsedStep {
+		return false, nil
+	}
+	twoFactor.LastUsedStep = step
+	return true, nil
+}
+
+func (m *mockTwoFactorStore) ReplaceRecoveryCodes(ctx context.Context, userID int, codeHashes []string) error {
+	m.recoveryCodes[userID] = map[string]bool{}
+	for _, codeHash := range codeHashes {
+		m.recoveryCodes[userID][codeHash] = false
+	}
+	return nil
+}
+
+func (m *mockTwoFactorStore) UseRecoveryCode(ctx context.Context, userID int, codeHash string) (bool, error) {
+	used, ok := m.recoveryCodes[userID][codeHash]
+	if !ok || used {
+		return false, nil
+	}
+	m.recoveryCodes[userID][codeHash] = true
+	return true, nil
+}
+
+func (m *mockTwoFactorStore) CountRecoveryCodes(ctx context.Context, userID int) (int, error) {
+	count := 0
+	for _, used := range m.recoveryCodes[userID] {
+		if !used {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// mockMFAChallengeRepository keeps challenges in memory, deleted ones are nil
+type mockMFAChallengeRepository struct {
+	challenges []*model.MFAChallenge
+}
+
+func (m *mockMFAChallengeRepository) Create(ctx context.Context, challenge *model.MFAChallenge) error {
+	challenge.ID = len(m.challenges) + 1
+	challenge.CreatedAt = time.Now()
+	m.challenges = append(m.challenges, challenge)
+	return nil
+}
+
+func (m *mockMFAChallengeRepository) GetByTokenHash(ctx context.Context, tokenHash string) (*model.MFAChallenge, error) {
+	for _, challenge := range m.challenges {
+		if challenge != nil && challenge.TokenHash == tokenHash {
+			return challenge, nil
+		}
+	}
+	return nil, fmt.Errorf("mfa challenge %w", model.ErrNotFound)
+}
+
+func (m *mockMFAChallengeRepository) IncrementAttempts(ctx context.Context, id, maxAttempts int) (bool, error) {
+	challenge := m.challenges[id-1]
+	if challenge == nil || challenge.Attempts >= maxAttempts {
+		return false, nil
+	}
+	challenge.Attempts++
+	return true, nil
+}
+
+func (m *mockMFAChallengeRepository) Delete(ctx context.Context, id int) (bool, error) {
+	if m.challenges[id-1] == nil {
+		return false, nil
+	}
+	m.challenges[id-1] = nil
+	return true, nil
+}
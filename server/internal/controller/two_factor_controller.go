@@ -0,0 +1,439 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/tsntt/footballapi/internal/dto"
+	"github.com/tsntt/footballapi/internal/model"
+	"github.com/tsntt/footballapi/pkg/logging"
+	"github.com/tsntt/footballapi/pkg/totp"
+	"github.com/tsntt/footballapi/pkg/utils"
+)
+
+const (
+	mfaChallengeTTL      = 5 * time.Minute
+	mfaChallengeBytes    = 32
+	mfaChallengeAttempts = 5
+	recoveryCodeCount    = 10
+	// hex encoded that is 10 characters, shown as xxxxx-xxxxx
+	recoveryCodeBytes = 5
+)
+
+// TwoFactorController handles TOTP enrolment and the second step of logins. 2FA is mandatory
+// for roles that can send broadcasts, their users set it up during their next login.
+type TwoFactorController struct {
+	twoFactorRepo model.ITwoFactorRepository
+	challengeRepo model.IMFAChallengeRepository
+	userRepo      model.IUserRepository
+	roleRepo      model.IRoleRepository
+	users         *UserController
+	secrets       *utils.SecretBox
+	// Shown by authenticator apps next to the account name
+	issuer    string
+	validator *validator.Validate
+}
+
+func NewTwoFactorController(
+	twoFactorRepo model.ITwoFactorRepository,
+	challengeRepo model.IMFAChallengeRepository,
+	userRepo model.IUserRepository,
+	roleRepo model.IRoleRepository,
+	users *UserController,
+	secrets *utils.SecretBox,
+	issuer string,
+) *TwoFactorController {
+	return &TwoFactorController{
+		twoFactorRepo: twoFactorRepo,
+		challengeRepo: challengeRepo,
+		userRepo:      userRepo,
+		roleRepo:      roleRepo,
+		users:         users,
+		secrets:       secrets,
+		issuer:        issuer,
+		validator:     newValidator(),
+	}
+}
+
+// startChallenge returns the challenge of a user who passed the first factor, nil when the
+// user has no second factor and needs none
+func (c *TwoFactorController) startChallenge(ctx context.Context, user *model.User, cookieSession bool) (*dto.LoginResponse, error) {
+	step := dto.TwoFactorVerify
+	if !c.enabled(ctx, user.ID) {
+		required, err := c.required(ctx, user.Role)
+		if err != nil {
+			return nil, err
+		}
+		if !required {
+			return nil, nil
+		}
+		step = dto.TwoFactorSetup
+	}
+
+	token, err := utils.GenerateRandomToken(mfaChallengeBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.challengeRepo.Create(ctx, &model.MFAChallenge{
+		UserID:        user.ID,
+		TokenHash:     utils.HashToken(token),
+		CookieSession: cookieSession,
+		ExpiresAt:     time.Now().Add(mfaChallengeTTL),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.LoginResponse{ChallengeToken: token, TwoFactor: step}, nil
+}
+
+// SetupLogin starts the enrolment of a user whose role requires 2FA, in the middle of a login
+func (c *TwoFactorController) SetupLogin(ctx context.Context, req *dto.TwoFactorChallengeRequest) (*dto.TwoFactorSetupResponse, error) {
+	if err := c.validator.Struct(req); err != nil {
+		return nil, invalid(err)
+	}
+
+	challenge, err := c.challenge(ctx, req.ChallengeToken)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.Setup(ctx, challenge.UserID)
+}
+
+// VerifyLogin exchanges a challenge and a code for the token of a normal login. When the login
+// is also the enrolment, the first code enables 2FA and the recovery codes come with the token.
+func (c *TwoFactorController) VerifyLogin(ctx context.Context, req *dto.TwoFactorLoginRequest, client dto.ClientInfo) (*dto.LoginResponse, error) {
+	if err := c.validator.Struct(req); err != nil {
+		return nil, invalid(err)
+	}
+
+	challenge, err := c.challenge(ctx, req.ChallengeToken)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := c.userRepo.GetByID(ctx, challenge.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	now := time.Now()
+	attempt := &model.LoginAttempt{UserID: &user.ID, Name: user.Name, IP: client.IP, UserAgent: client.UserAgent}
+	throttle := newLoginThrottle(c.users.attemptRepo, user.Name, client.IP)
+
+	// A stolen password must not give unlimited guesses at the code, failures lock the account like wrong passwords
+	if wait := throttle.retryAfter(ctx, now); wait > 0 {
+		c.users.recordAttempt(ctx, attempt, model.LoginFailureThrottled)
+		return nil, &RetryAfterError{RetryAfter: wait}
+	}
+
+	// counted before the code is checked, parallel requests cannot share more than the attempts of the challenge
+	counted, err := c.challengeRepo.IncrementAttempts(ctx, challenge.ID, mfaChallengeAttempts)
+	if err != nil {
+		return nil, err
+	}
+	if !counted {
+		return nil, ErrInvalidChallenge
+	}
+
+	var recoveryCodes []string
+	if c.enabled(ctx, user.ID) {
+		err = c.checkCode(ctx, user.ID, req.Code)
+	} else {
+		recoveryCodes, err = c.enable(ctx, user.ID, req.Code)
+	}
+
+	if errors.Is(err, ErrInvalidTwoFactor) {
+		throttle.fail(ctx, now)
+		c.users.recordAttempt(ctx, attempt, model.LoginFailureInvalidSecondFactor)
+		return nil, ErrInvalidTwoFactor
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// single use, a concurrent request with the same challenge loses
+	deleted, err := c.challengeRepo.Delete(ctx, challenge.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !deleted {
+		return nil, ErrInvalidChallenge
+	}
+
+	if user.SuspendedAt != nil {
+		c.users.recordAttempt(ctx, attempt, model.LoginFailureSuspended)
+		return nil, ErrUserSuspended
+	}
+
+	throttle.succeed(ctx)
+	c.users.recordAttempt(ctx, attempt, "")
+
+	response, err := c.users.issueToken(ctx, user, challenge.CookieSession)
+	if err != nil {
+		return nil, err
+	}
+	response.RecoveryCodes = recoveryCodes
+
+	return response, nil
+}
+
+func (c *TwoFactorController) Status(ctx context.Context, userID int) (*dto.TwoFactorStatusResponse, error) {
+	user, err := c.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	required, err := c.required(ctx, user.Role)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &dto.TwoFactorStatusResponse{Enabled: c.enabled(ctx, userID), Required: required}
+	if status.Enabled {
+		if status.RecoveryCodesLeft, err = c.twoFactorRepo.CountRecoveryCodes(ctx, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	return status, nil
+}
+
+// Setup creates a new secret, 2FA stays off until Enable checks a first code from it
+func (c *TwoFactorController) Setup(ctx context.Context, userID int) (*dto.TwoFactorSetupResponse, error) {
+	if c.enabled(ctx, userID) {
+		return nil, ErrTwoFactorEnabled
+	}
+
+	user, err := c.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	sealed, err := c.secrets.Seal(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.twoFactorRepo.SavePending(ctx, userID, sealed); err != nil {
+		return nil, err
+	}
+
+	return &dto.TwoFactorSetupResponse{
+		Secret:          secret,
+		ProvisioningURI: totp.ProvisioningURI(c.issuer, user.Name, secret),
+	}, nil
+}
+
+func (c *TwoFactorController) Enable(ctx context.Context, userID int, req *dto.TwoFactorCodeRequest) (*dto.RecoveryCodesResponse, error) {
+	if err := c.validator.Struct(req); err != nil {
+		return nil, invalid(err)
+	}
+
+	if c.enabled(ctx, userID) {
+		return nil, ErrTwoFactorEnabled
+	}
+
+	codes, err := c.enable(ctx, userID, req.Code)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.RecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+// RegenerateRecoveryCodes replaces every recovery code, used or not
+func (c *TwoFactorController) RegenerateRecoveryCodes(ctx context.Context, userID int, req *dto.TwoFactorCodeRequest) (*dto.RecoveryCodesResponse, error) {
+	if err := c.validator.Struct(req); err != nil {
+		return nil, invalid(err)
+	}
+
+	if !c.enabled(ctx, userID) {
+		return nil, ErrTwoFactorOff
+	}
+
+	if err := c.checkCode(ctx, userID, req.Code); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.twoFactorRepo.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		return nil, err
+	}
+
+	return &dto.RecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+// Disable asks for the password (or a recent login, see reauthenticate) and a code, and is refused
+// while the role requires 2FA
+func (c *TwoFactorController) Disable(ctx context.Context, userID int, req *dto.DisableTwoFactorRequest, loggedInAt time.Time) (*dto.APIResponse, error) {
+	if err := c.validator.Struct(req); err != nil {
+		return nil, invalid(err)
+	}
+
+	user, err := c.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if err := reauthenticate(user, req.Password, loggedInAt); err != nil {
+		return nil, err
+	}
+
+	required, err := c.required(ctx, user.Role)
+	if err != nil {
+		return nil, err
+	}
+	if required {
+		return nil, ErrTwoFactorNeeded
+	}
+
+	if !c.enabled(ctx, userID) {
+		return nil, ErrTwoFactorOff
+	}
+
+	if err := c.checkCode(ctx, userID, req.Code); err != nil {
+		return nil, err
+	}
+
+	if err := c.twoFactorRepo.Delete(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	return &dto.APIResponse{Message: "Two factor authentication disabled"}, nil
+}
+
+// challenge returns a challenge that can still be used
+func (c *TwoFactorController) challenge(ctx context.Context, token string) (*model.MFAChallenge, error) {
+	challenge, err := c.challengeRepo.GetByTokenHash(ctx, utils.HashToken(token))
+	if err != nil {
+		return nil, ErrInvalidChallenge
+	}
+
+	if time.Now().After(challenge.ExpiresAt) || challenge.Attempts >= mfaChallengeAttempts {
+		return nil, ErrInvalidChallenge
+	}
+
+	return challenge, nil
+}
+
+func (c *TwoFactorController) enabled(ctx context.Context, userID int) bool {
+	twoFactor, err := c.twoFactorRepo.Get(ctx, userID)
+	return err == nil && twoFactor.EnabledAt != nil
+}
+
+func (c *TwoFactorController) required(ctx context.Context, role string) (bool, error) {
+	permissions, err := c.roleRepo.GetPermissions(ctx, role)
+	if err != nil {
+		return false, fmt.Errorf("failed to get permissions: %w", err)
+	}
+
+	return slices.Contains(permissions, model.PermBroadcastSend), nil
+}
+
+// enable checks a first code from the pending secret and returns the new recovery codes
+func (c *TwoFactorController) enable(ctx context.Context, userID int, code string) ([]string, error) {
+	twoFactor, err := c.twoFactorRepo.Get(ctx, userID)
+	if err != nil {
+		return nil, ErrTwoFactorOff
+	}
+
+	secret, err := c.secrets.Open(twoFactor.Secret)
+	if err != nil {
+		return nil, err
+	}
+
+	step, ok := totp.Validate(secret, strings.TrimSpace(code), time.Now())
+	if !ok {
+		return nil, ErrInvalidTwoFactor
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.twoFactorRepo.Enable(ctx, userID, step, hashes); err != nil {
+		return nil, err
+	}
+
+	logging.FromContext(ctx).Info("Two factor authentication enabled", slog.Int("user_id", userID))
+	return codes, nil
+}
+
+// checkCode accepts a TOTP code not used before or an unused recovery code
+func (c *TwoFactorController) checkCode(ctx context.Context, userID int, code string) error {
+	code = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+
+	if len(code) != totp.Digits {
+		used, err := c.twoFactorRepo.UseRecoveryCode(ctx, userID, utils.HashToken(code))
+		if err != nil {
+			return err
+		}
+		if !used {
+			return ErrInvalidTwoFactor
+		}
+
+		logging.FromContext(ctx).Info("Recovery code used", slog.Int("user_id", userID))
+		return nil
+	}
+
+	twoFactor, err := c.twoFactorRepo.Get(ctx, userID)
+	if err != nil {
+		return ErrTwoFactorOff
+	}
+
+	secret, err := c.secrets.Open(twoFactor.Secret)
+	if err != nil {
+		return err
+	}
+
+	step, ok := totp.Validate(secret, code, time.Now())
+	if !ok {
+		return ErrInvalidTwoFactor
+	}
+
+	fresh, err := c.twoFactorRepo.UseStep(ctx, userID, step)
+	if err != nil {
+		return err
+	}
+	if !fresh {
+		return ErrInvalidTwoFactor
+	}
+
+	return nil
+}
+
+// newRecoveryCodes returns the codes to show once and the hashes to store
+func newRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+
+	for range recoveryCodeCount {
+		code, err := utils.GenerateRandomToken(recoveryCodeBytes)
+		if err != nil {
+			return nil, nil, err
+		}
+		codes = append(codes, code[:5]+"-"+code[5:])
+		hashes = append(hashes, utils.HashToken(code))
+	}
+
+	return codes, hashes, nil
+}
@@ -0,0 +1,266 @@
+package controller_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/tsntt/footballapi/internal/controller"
+	"github.com/tsntt/footballapi/internal/dto"
+	"github.com/tsntt/footballapi/internal/model"
+	"github.com/tsntt/footballapi/pkg/totp"
+	"github.com/tsntt/footballapi/pkg/utils"
+)
+
+const twoFactorTestPassword = "Gol-de-Placa-1970"
+
+type twoFactorTest struct {
+	users      *controller.UserController
+	twoFactor  *controller.TwoFactorController
+	store      *mockTwoFactorStore
+	challenges *mockMFAChallengeRepository
+	attempts   *mockLoginAttemptRepository
+	jms        *utils.JWTService
+}
+
+// newTwoFactorTest knows an admin (2FA required) and a default user
+func newTwoFactorTest(t *testing.T) *twoFactorTest {
+	t.Helper()
+
+	hashedPassword, err := utils.HashPassword(twoFactorTestPassword)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	identities := newMockIdentityStore()
+	identities.users[1] = &model.User{ID: 1, Name: "admin", Role: "admin", Password: hashedPassword}
+	identities.users[2] = &model.User{ID: 2, Name: "ana", Role: "default", Password: hashedPassword}
+	userRepo := identities.userRepo()
+
+	jms := utils.NewJWTService("secret", 1)
+	attempts := newMockLoginAttemptRepository()
+	users := controller.NewUserController(userRepo, mockRoleRepo(), attempts, testPasswordPolicy, jms)
+
+	store := newMockTwoFactorStore()
+	challenges := &mockMFAChallengeRepository{}
+	twoFactor := controller.NewTwoFactorController(store, challenges, userRepo, mockRoleRepo(), users, utils.NewSecretBox("secret"), "Football API")
+	users.SetTwoFactor(twoFactor)
+
+	return &twoFactorTest{users: users, twoFactor: twoFactor, store: store, challenges: challenges, attempts: attempts, jms: jms}
+}
+
+func (tt *twoFactorTest) login(t *testing.T, name string, cookieSession bool) *dto.LoginResponse {
+	t.Helper()
+
+	resp, err := tt.users.Login(context.Background(), &dto.UserRequest{Name: name, Password: twoFactorTestPassword},
+		dto.ClientInfo{IP: "203.0.113.1", CookieSession: cookieSession})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	return resp
+}
+
+func codeAt(t *testing.T, secret string, offset int64) string {
+	t.Helper()
+
+	code, err := totp.Code(secret, totp.Step(time.Now())+offset)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	return code
+}
+
+// enrol enables 2FA for a logged in user and returns the secret and recovery codes
+func (tt *twoFactorTest) enrol(t *testing.T, userID int) (string, []string) {
+	t.Helper()
+	ctx := context.Background()
+
+	setup, err := tt.twoFactor.Setup(ctx, userID)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	enabled, err := tt.twoFactor.Enable(ctx, userID, &dto.TwoFactorCodeRequest{Code: codeAt(t, setup.Secret, -1)})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	return setup.Secret, enabled.RecoveryCodes
+}
+
+func TestTwoFactorController_Login_SetupRequiredForBroadcasters(t *testing.T) {
+	tt := newTwoFactorTest(t)
+	ctx := context.Background()
+
+	challenge := tt.login(t, "admin", false)
+	if challenge.Token != "" || challenge.TwoFactor != dto.TwoFactorSetup || challenge.ChallengeToken == "" {
+		t.Fatalf("expected a setup challenge instead of a token, got %+v", challenge)
+	}
+
+	setup, err := tt.twoFactor.SetupLogin(ctx, &dto.TwoFactorChallengeRequest{ChallengeToken: challenge.ChallengeToken})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if tt.store.enrolments[1].Secret == setup.Secret {
+		t.Error("expected the secret to be stored encrypted")
+	}
+
+	_, err = tt.twoFactor.VerifyLogin(ctx, &dto.TwoFactorLoginRequest{ChallengeToken: challenge.ChallengeToken, Code: "000000"}, dto.ClientInfo{})
+	if !errors.Is(err, controller.ErrInvalidTwoFactor) {
+		t.Fatalf("expected ErrInvalidTwoFactor, got %v", err)
+	}
+
+	resp, err := tt.twoFactor.VerifyLogin(ctx, &dto.TwoFactorLoginRequest{
+		ChallengeToken: challenge.ChallengeToken,
+		Code:           codeAt(t, setup.Secret, 0),
+	}, dto.ClientInfo{})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if claims, err := tt.jms.ValidateToken(resp.Token); err != nil || claims.UserID != 1 {
+		t.Fatalf("expected a token for the admin, got %v", err)
+	}
+
+	if len(resp.RecoveryCodes) != 10 {
+		t.Errorf("expected 10 recovery codes, got %d", len(resp.RecoveryCodes))
+	}
+
+	// the challenge works once
+	_, err = tt.twoFactor.VerifyLogin(ctx, &dto.TwoFactorLoginRequest{ChallengeToken: challenge.ChallengeToken, Code: codeAt(t, setup.Secret, 1)}, dto.ClientInfo{})
+	if !errors.Is(err, controller.ErrInvalidChallenge) {
+		t.Errorf("expected ErrInvalidChallenge, got %v", err)
+	}
+}
+
+func TestTwoFactorController_Login_NotRequired(t *testing.T) {
+	tt := newTwoFactorTest(t)
+
+	if resp := tt.login(t, "ana", false); resp.Token == "" || resp.ChallengeToken != "" {
+		t.Fatalf("expected a token without 2FA, got %+v", resp)
+	}
+}
+
+func TestTwoFactorController_Login_Verify(t *testing.T) {
+	tt := newTwoFactorTest(t)
+	ctx := context.Background()
+	secret, recoveryCodes := tt.enrol(t, 2)
+
+	verify := func(code string) error {
+		challenge := tt.login(t, "ana", false)
+		if challenge.TwoFactor != dto.TwoFactorVerify {
+			t.Fatalf("expected a verify challenge, got %+v", challenge)
+		}
+
+		_, err := tt.twoFactor.VerifyLogin(ctx, &dto.TwoFactorLoginRequest{ChallengeToken: challenge.ChallengeToken, Code: code}, dto.ClientInfo{})
+		return err
+	}
+
+	code := codeAt(t, secret, 0)
+	if err := verify(code); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if err := verify(code); !errors.Is(err, controller.ErrInvalidTwoFactor) {
+		t.Errorf("expected a used code to be rejected, got %v", err)
+	}
+
+	if err := verify(codeAt(t, secret, 1)); err != nil {
+		t.Errorf("expected the next code to work, got %v", err)
+	}
+
+	if err := verify(recoveryCodes[0]); err != nil {
+		t.Errorf("expected a recovery code to work, got %v", err)
+	}
+
+	if err := verify(recoveryCodes[0]); !errors.Is(err, controller.ErrInvalidTwoFactor) {
+		t.Errorf("expected a used recovery code to be rejected, got %v", err)
+	}
+
+	status, _ := tt.twoFactor.Status(ctx, 2)
+	if !status.Enabled || status.Required || status.RecoveryCodesLeft != 9 {
+		t.Errorf("expected 2FA on with 9 recovery codes left, got %+v", status)
+	}
+}
+
+func TestTwoFactorController_Login_CookieSession(t *testing.T) {
+	tt := newTwoFactorTest(t)
+	secret, _ := tt.enrol(t, 2)
+
+	challenge := tt.login(t, "ana", true)
+	resp, err := tt.twoFactor.VerifyLogin(context.Background(), &dto.TwoFactorLoginRequest{
+		ChallengeToken: challenge.ChallengeToken,
+		Code:           codeAt(t, secret, 0),
+	}, dto.ClientInfo{})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if resp.CSRFToken == "" {
+		t.Error("expected the cookie session chosen at the first step")
+	}
+}
+
+func TestTwoFactorController_VerifyLogin_WrongCodes(t *testing.T) {
+	tt := newTwoFactorTest(t)
+	ctx := context.Background()
+	secret, _ := tt.enrol(t, 2)
+
+	challenge := tt.login(t, "ana", false)
+	for i := 0; i < 5; i++ {
+		tt.twoFactor.VerifyLogin(ctx, &dto.TwoFactorLoginRequest{ChallengeToken: challenge.ChallengeToken, Code: "000000"}, dto.ClientInfo{})
+	}
+
+	// the account throttle or the attempts of the challenge, whichever gives out first
+	_, err := tt.twoFactor.VerifyLogin(ctx, &dto.TwoFactorLoginRequest{ChallengeToken: challenge.ChallengeToken, Code: codeAt(t, secret, 0)}, dto.ClientInfo{})
+	if !errors.Is(err, controller.ErrTooManyRequests) && !errors.Is(err, controller.ErrInvalidChallenge) {
+		t.Fatalf("expected the right code to be refused after 5 wrong ones, got %v", err)
+	}
+}
+
+func TestTwoFactorController_VerifyLogin_PasswordKeepsFailedCodes(t *testing.T) {
+	tt := newTwoFactorTest(t)
+	ctx := context.Background()
+	tt.enrol(t, 2)
+
+	challenge := tt.login(t, "ana", false)
+	for i := 0; i < 3; i++ {
+		tt.twoFactor.VerifyLogin(ctx, &dto.TwoFactorLoginRequest{ChallengeToken: challenge.ChallengeToken, Code: "000000"}, dto.ClientInfo{})
+	}
+
+	// a new challenge after the delay must not give the guesses back
+	account := tt.attempts.throttles["account:ana"]
+	account.LastFailureAt = account.LastFailureAt.Add(-2 * time.Second)
+	tt.login(t, "ana", false)
+
+	if account := tt.attempts.throttles["account:ana"]; account == nil || account.Failures != 3 {
+		t.Fatalf("expected the 3 wrong codes to stay counted, got %+v", account)
+	}
+}
+
+func TestTwoFactorController_Disable(t *testing.T) {
+	tt := newTwoFactorTest(t)
+	ctx := context.Background()
+
+	adminSecret, _ := tt.enrol(t, 1)
+	_, err := tt.twoFactor.Disable(ctx, 1, &dto.DisableTwoFactorRequest{Password: twoFactorTestPassword, Code: codeAt(t, adminSecret, 0)}, time.Now())
+	if !errors.Is(err, controller.ErrTwoFactorNeeded) {
+		t.Fatalf("expected ErrTwoFactorNeeded for a broadcaster, got %v", err)
+	}
+
+	secret, _ := tt.enrol(t, 2)
+	_, err = tt.twoFactor.Disable(ctx, 2, &dto.DisableTwoFactorRequest{Password: "wrong", Code: codeAt(t, secret, 0)}, time.Now())
+	if !errors.Is(err, controller.ErrWrongPassword) {
+		t.Fatalf("expected ErrWrongPassword, got %v", err)
+	}
+
+	if _, err := tt.twoFactor.Disable(ctx, 2, &dto.DisableTwoFactorRequest{Password: twoFactorTestPassword, Code: codeAt(t, secret, 0)}, time.Now()); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if resp := tt.login(t, "ana", false); resp.Token == "" {
+		t.Errorf("expected a token once 2FA is off, got %+v", resp)
+	}
+}
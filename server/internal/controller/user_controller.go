@@ -2,32 +2,82 @@ package controller
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"time"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/tsntt/footballapi/internal/dto"
 	"github.com/tsntt/footballapi/internal/model"
+	"github.com/tsntt/footballapi/pkg/logging"
+	"github.com/tsntt/footballapi/pkg/passwordpolicy"
 	"github.com/tsntt/footballapi/pkg/utils"
 )
 
+// Users created by a login provider have no password, they prove who they are by a login this recent
+const recentLoginWindow = 10 * time.Minute
+
 type UserController struct {
-	userRepo   model.IUserRepository
-	jwtService *utils.JWTService
-	validator  *validator.Validate
+	userRepo       model.IUserRepository
+	roleRepo       model.IRoleRepository
+	attemptRepo    model.ILoginAttemptRepository
+	passwordPolicy *passwordpolicy.Policy
+	jwtService     *utils.JWTService
+	// Optional, asks for a second factor before the token is issued
+	twoFactor *TwoFactorController
+	validator *validator.Validate
 }
 
-func NewUserController(userRepo model.IUserRepository, jwtService *utils.JWTService) *UserController {
+func NewUserController(
+	userRepo model.IUserRepository,
+	roleRepo model.IRoleRepository,
+	attemptRepo model.ILoginAttemptRepository,
+	passwordPolicy *passwordpolicy.Policy,
+	jwtService *utils.JWTService,
+) *UserController {
 	return &UserController{
-		userRepo:   userRepo,
-		jwtService: jwtService,
-		validator:  validator.New(),
+		userRepo:       userRepo,
+		roleRepo:       roleRepo,
+		attemptRepo:    attemptRepo,
+		passwordPolicy: passwordPolicy,
+		jwtService:     jwtService,
+		validator:      newValidator(),
 	}
 }
 
+// SetTwoFactor turns on the second login step for users with 2FA or whose role requires it
+func (c *UserController) SetTwoFactor(twoFactor *TwoFactorController) {
+	c.twoFactor = twoFactor
+}
+
 func (c *UserController) Register(ctx context.Context, req *dto.UserRequest) (*dto.APIResponse, error) {
+	if _, err := c.create(ctx, req, "default"); err != nil {
+		return nil, err
+	}
+
+	return &dto.APIResponse{
+		Message: "User successfully created!",
+	}, nil
+}
+
+// CreateUser creates a user with the given role, used by the command line to bootstrap admins
+func (c *UserController) CreateUser(ctx context.Context, req *dto.UserRequest, role string) (*model.User, error) {
+	if _, err := c.roleRepo.GetByName(ctx, role); err != nil {
+		return nil, orNotFound(err, ErrUnknownRole.Withf("%s", role), "get role")
+	}
+
+	return c.create(ctx, req, role)
+}
+
+func (c *UserController) create(ctx context.Context, req *dto.UserRequest, role string) (*model.User, error) {
 	// Validate data
 	if err := c.validator.Struct(req); err != nil {
-		return nil, fmt.Errorf("validation error: %w", err)
+		return nil, invalid(err)
+	}
+
+	if err := c.passwordPolicy.Validate(req.Password, req.Name); err != nil {
+		return nil, err
 	}
 
 	// Hash password
@@ -40,39 +90,253 @@ func (c *UserController) Register(ctx context.Context, req *dto.UserRequest) (*d
 	user := &model.User{
 		Name:     req.Name,
 		Password: hashedPassword,
-		Role:     "default",
+		Role:     role,
 	}
 
 	if err := c.userRepo.Create(ctx, user); err != nil {
+		if errors.Is(err, ErrConflict) {
+			return nil, ErrNameTaken.Wrap(err)
+		}
 		return nil, fmt.Errorf("failed to create user: %w", err)
 	}
 
-	return &dto.APIResponse{
-		Message: "User successfully created!",
-	}, nil
+	return user, nil
 }
 
-func (c *UserController) Login(ctx context.Context, req *dto.UserRequest) (*dto.LoginResponse, error) {
+func (c *UserController) Login(ctx context.Context, req *dto.UserRequest, client dto.ClientInfo) (*dto.LoginResponse, error) {
 	if err := c.validator.Struct(req); err != nil {
-		return nil, fmt.Errorf("validation error: %w", err)
+		return nil, invalid(err)
+	}
+
+	now := time.Now()
+	attempt := &model.LoginAttempt{Name: req.Name, IP: client.IP, UserAgent: client.UserAgent}
+	throttle := newLoginThrottle(c.attemptRepo, req.Name, client.IP)
+
+	// Checked before the password, a throttled client learns nothing about it
+	if wait := throttle.retryAfter(ctx, now); wait > 0 {
+		c.recordAttempt(ctx, attempt, model.LoginFailureThrottled)
+		return nil, &RetryAfterError{RetryAfter: wait}
 	}
 
 	user, err := c.userRepo.GetByName(ctx, req.Name)
+	if err == nil {
+		attempt.UserID = &user.ID
+	}
+
+	if err != nil || !utils.CheckPasswordHash(req.Password, user.Password) {
+		throttle.fail(ctx, now)
+		c.recordAttempt(ctx, attempt, model.LoginFailureInvalidCredentials)
+		return nil, ErrInvalidCredentials
+	}
+
+	if user.SuspendedAt != nil {
+		c.recordAttempt(ctx, attempt, model.LoginFailureSuspended)
+		return nil, ErrUserSuspended
+	}
+
+	c.recordAttempt(ctx, attempt, "")
+
+	response, err := c.finishLogin(ctx, user, client.CookieSession)
+	if err != nil {
+		return nil, err
+	}
+
+	// With a second factor the account counter is cleared once the code is right (see VerifyLogin),
+	// the password alone must not wipe out the failed codes
+	if response.ChallengeToken == "" {
+		throttle.succeed(ctx)
+	}
+
+	return response, nil
+}
+
+// loginVerified logs in a user already authenticated elsewhere (e.g. by a login provider)
+func (c *UserController) loginVerified(ctx context.Context, user *model.User, client dto.ClientInfo) (*dto.LoginResponse, error) {
+	attempt := &model.LoginAttempt{UserID: &user.ID, Name: user.Name, IP: client.IP, UserAgent: client.UserAgent}
+
+	if user.SuspendedAt != nil {
+		c.recordAttempt(ctx, attempt, model.LoginFailureSuspended)
+		return nil, ErrUserSuspended
+	}
+
+	c.recordAttempt(ctx, attempt, "")
+
+	return c.finishLogin(ctx, user, client.CookieSession)
+}
+
+// finishLogin issues the token of a user who passed the first factor, or the challenge of the second
+func (c *UserController) finishLogin(ctx context.Context, user *model.User, cookieSession bool) (*dto.LoginResponse, error) {
+	if c.twoFactor != nil {
+		challenge, err := c.twoFactor.startChallenge(ctx, user, cookieSession)
+		if err != nil {
+			return nil, err
+		}
+		if challenge != nil {
+			return challenge, nil
+		}
+	}
+
+	return c.issueToken(ctx, user, cookieSession)
+}
+
+// recordAttempt stores the attempt, a successful one when failureReason is empty
+func (c *UserController) recordAttempt(ctx context.Context, attempt *model.LoginAttempt, failureReason string) {
+	attempt.Success = failureReason == ""
+	attempt.FailureReason = failureReason
+
+	if err := c.attemptRepo.Record(ctx, attempt); err != nil {
+		logging.FromContext(ctx).Error("Failed to record login attempt", slog.String("err", err.Error()))
+	}
+}
+
+func (c *UserController) GetProfile(ctx context.Context, userID int) (*model.User, error) {
+	user, err := c.userRepo.GetByID(ctx, userID)
 	if err != nil {
-		return nil, fmt.Errorf("invalid credentials")
+		return nil, orNotFound(err, ErrUserNotFound, "get user")
 	}
+	user.Password = ""
+
+	return user, nil
+}
 
-	if !utils.CheckPasswordHash(req.Password, user.Password) {
-		return nil, fmt.Errorf("invalid credentials")
+// UpdateProfile asks for the password (or a recent provider login) when the email changes, a
+// stolen session must not be able to redirect the password reset links
+func (c *UserController) UpdateProfile(ctx context.Context, userID int, req *dto.ProfileRequest, loggedInAt time.Time) (*model.User, error) {
+	if err := c.validator.Struct(req); err != nil {
+		return nil, invalid(err)
 	}
 
-	// INFO: in production is recomended to use token and refresh token
-	token, err := c.jwtService.GenerateToken(user)
+	user, err := c.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if req.Email != user.Email {
+		if err := reauthenticate(user, req.CurrentPassword, loggedInAt); err != nil {
+			return nil, err
+		}
+	}
+
+	user.DisplayName = req.DisplayName
+	user.Email = req.Email
+	user.Phone = req.Phone
+	user.Language = req.Language
+	user.Timezone = req.Timezone
+
+	if err := c.userRepo.UpdateProfile(ctx, user); err != nil {
+		return nil, fmt.Errorf("failed to update profile: %w", err)
+	}
+	user.Password = ""
+
+	return user, nil
+}
+
+// ChangePassword revokes every other session, the caller keeps working with the returned token
+// ChangePassword also sets the first password of a user created by a login provider
+func (c *UserController) ChangePassword(ctx context.Context, userID int, req *dto.ChangePasswordRequest, loggedInAt time.Time, cookieSession bool) (*dto.LoginResponse, error) {
+	if err := c.validator.Struct(req); err != nil {
+		return nil, invalid(err)
+	}
+
+	user, err := c.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if err := reauthenticate(user, req.CurrentPassword, loggedInAt); err != nil {
+		return nil, err
+	}
+
+	if err := c.passwordPolicy.Validate(req.NewPassword, user.Name); err != nil {
+		return nil, err
+	}
+
+	hashedPassword, err := utils.HashPassword(req.NewPassword)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	if err := c.userRepo.UpdatePassword(ctx, userID, hashedPassword, time.Now()); err != nil {
+		return nil, fmt.Errorf("failed to update password: %w", err)
+	}
+
+	return c.issueToken(ctx, user, cookieSession)
+}
+
+// DeleteAccount removes the user and their personal data, the password is asked again
+// so a stolen token is not enough
+func (c *UserController) DeleteAccount(ctx context.Context, userID int, req *dto.DeleteAccountRequest, loggedInAt time.Time) (*dto.APIResponse, error) {
+	if err := c.validator.Struct(req); err != nil {
+		return nil, invalid(err)
+	}
+
+	user, err := c.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if err := reauthenticate(user, req.Password, loggedInAt); err != nil {
+		return nil, err
+	}
+
+	if err := c.userRepo.Delete(ctx, userID); err != nil {
+		return nil, fmt.Errorf("failed to delete account: %w", err)
+	}
+
+	return &dto.APIResponse{
+		Message: "Account successfully deleted!",
+	}, nil
+}
+
+// issueToken returns a bearer token, or for a cookie session a token bound to a new CSRF token
+func (c *UserController) issueToken(ctx context.Context, user *model.User, cookieSession bool) (*dto.LoginResponse, error) {
+	permissions, err := c.roleRepo.GetPermissions(ctx, user.Role)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get permissions: %w", err)
+	}
+	user.Permissions = permissions
+
+	if !cookieSession {
+		// INFO: in production is recomended to use token and refresh token
+		token, err := c.jwtService.GenerateToken(user)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate token: %w", err)
+		}
+
+		return &dto.LoginResponse{Token: token}, nil
+	}
+
+	csrfToken, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := c.jwtService.GenerateCookieToken(user, utils.HashToken(csrfToken))
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate token: %w", err)
 	}
 
-	return &dto.LoginResponse{Token: token}, nil
+	return &dto.LoginResponse{Token: token, CSRFToken: csrfToken}, nil
+}
+
+// ValidateSession rejects tokens of users that were deleted or suspended after the token was issued
+func (c *UserController) ValidateSession(ctx context.Context, claims *dto.JWTClaims) error {
+	user, err := c.userRepo.GetByID(ctx, claims.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if user.SuspendedAt != nil {
+		return ErrUserSuspended
+	}
+
+	// iat has whole seconds, a token issued in the second of the revocation is kept so the one
+	// ChangePassword returns works. Both instants come from our clock, not the database's
+	if user.TokensValidAfter != nil && claims.IssuedAt.Before(user.TokensValidAfter.Truncate(time.Second)) {
+		return ErrInvalidToken
+	}
+
+	return nil
 }
 
 func (c *UserController) Logout(ctx context.Context) (*dto.APIResponse, error) {
@@ -80,3 +344,20 @@ func (c *UserController) Logout(ctx context.Context) (*dto.APIResponse, error) {
 		Message: "Logged out successfully",
 	}, nil
 }
+
+// reauthenticate asks for the password before a sensitive change. A user without one logged in
+// through a provider, the session (loggedInAt) must then be recent
+func reauthenticate(user *model.User, password string, loggedInAt time.Time) error {
+	if user.Password == "" {
+		if time.Since(loggedInAt) > recentLoginWindow {
+			return ErrLoginTooOld
+		}
+		return nil
+	}
+
+	if !utils.CheckPasswordHash(password, user.Password) {
+		return ErrWrongPassword
+	}
+
+	return nil
+}
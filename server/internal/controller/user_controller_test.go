@@ -4,10 +4,12 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/tsntt/footballapi/internal/controller"
 	"github.com/tsntt/footballapi/internal/dto"
 	"github.com/tsntt/footballapi/internal/model"
+	"github.com/tsntt/footballapi/pkg/passwordpolicy"
 	"github.com/tsntt/footballapi/pkg/utils"
 )
 
@@ -18,10 +20,10 @@ func BenchmarkUserController_Register(b *testing.B) {
 		},
 	}
 
-	userController := controller.NewUserController(mockUserRepo, nil)
+	userController := controller.NewUserController(mockUserRepo, nil, nil, testPasswordPolicy, nil)
 	req := &dto.UserRequest{
 		Name:     "testuser",
-		Password: "password",
+		Password: "Gol-de-Placa-1970",
 	}
 
 	for i := 0; i < b.N; i++ {
@@ -38,14 +40,14 @@ func BenchmarkUserController_Login(b *testing.B) {
 	}
 
 	jms := utils.NewJWTService("secret", 24)
-	userController := controller.NewUserController(mockUserRepo, jms)
+	userController := controller.NewUserController(mockUserRepo, mockRoleRepo(), newMockLoginAttemptRepository(), testPasswordPolicy, jms)
 	req := &dto.UserRequest{
 		Name:     "testuser",
 		Password: "password",
 	}
 
 	for i := 0; i < b.N; i++ {
-		_, _ = userController.Login(context.Background(), req)
+		_, _ = userController.Login(context.Background(), req, dto.ClientInfo{IP: "203.0.113.1"})
 	}
 }
 
@@ -56,10 +58,10 @@ func TestUserController_Register(t *testing.T) {
 		},
 	}
 
-	userController := controller.NewUserController(mockUserRepo, nil)
+	userController := controller.NewUserController(mockUserRepo, nil, nil, testPasswordPolicy, nil)
 	req := &dto.UserRequest{
 		Name:     "testuser",
-		Password: "password",
+		Password: "Gol-de-Placa-1970",
 	}
 
 	resp, err := userController.Register(context.Background(), req)
@@ -74,7 +76,7 @@ func TestUserController_Register(t *testing.T) {
 }
 
 func TestUserController_Register_ValidationError(t *testing.T) {
-	userController := controller.NewUserController(nil, nil)
+	userController := controller.NewUserController(nil, nil, nil, testPasswordPolicy, nil)
 	req := &dto.UserRequest{}
 
 	_, err := userController.Register(context.Background(), req)
@@ -84,6 +86,31 @@ func TestUserController_Register_ValidationError(t *testing.T) {
 	}
 }
 
+func TestUserController_Register_WeakPassword(t *testing.T) {
+	created := false
+	mockUserRepo := &mockUserRepository{
+		create: func(ctx context.Context, user *model.User) error {
+			created = true
+			return nil
+		},
+	}
+
+	userController := controller.NewUserController(mockUserRepo, nil, nil, testPasswordPolicy, nil)
+
+	for _, password := range []string{"short", "password", "testuser99"} {
+		_, err := userController.Register(context.Background(), &dto.UserRequest{Name: "testuser", Password: password})
+
+		var policyErr *passwordpolicy.Error
+		if !errors.As(err, &policyErr) {
+			t.Errorf("password %q: expected a policy error, got %v", password, err)
+		}
+	}
+
+	if created {
+		t.Error("expected no user to be created")
+	}
+}
+
 func TestUserController_Register_CreateError(t *testing.T) {
 	mockUserRepo := &mockUserRepository{
 		create: func(ctx context.Context, user *model.User) error {
@@ -91,10 +118,10 @@ func TestUserController_Register_CreateError(t *testing.T) {
 		},
 	}
 
-	userController := controller.NewUserController(mockUserRepo, nil)
+	userController := controller.NewUserController(mockUserRepo, nil, nil, testPasswordPolicy, nil)
 	req := &dto.UserRequest{
 		Name:     "testuser",
-		Password: "password",
+		Password: "Gol-de-Placa-1970",
 	}
 
 	_, err := userController.Register(context.Background(), req)
@@ -104,6 +131,54 @@ func TestUserController_Register_CreateError(t *testing.T) {
 	}
 }
 
+func TestUserController_CreateUser(t *testing.T) {
+	var created *model.User
+	mockUserRepo := &mockUserRepository{
+		create: func(ctx context.Context, user *model.User) error {
+			user.ID = 7
+			created = user
+			return nil
+		},
+	}
+
+	userController := controller.NewUserController(mockUserRepo, mockRoleRepo(), nil, testPasswordPolicy, nil)
+	req := &dto.UserRequest{
+		Name:     "operator",
+		Password: "Gol-de-Placa-1970",
+	}
+
+	user, err := userController.CreateUser(context.Background(), req, "admin")
+
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if user.ID != 7 || created.Role != "admin" {
+		t.Errorf("expected user 7 with the admin role, got %d with %q", user.ID, created.Role)
+	}
+
+	if !utils.CheckPasswordHash(req.Password, created.Password) {
+		t.Error("expected the password to be stored hashed")
+	}
+}
+
+func TestUserController_CreateUser_UnknownRole(t *testing.T) {
+	mockUserRepo := &mockUserRepository{
+		create: func(ctx context.Context, user *model.User) error {
+			t.Fatal("expected no user to be created")
+			return nil
+		},
+	}
+
+	userController := controller.NewUserController(mockUserRepo, mockRoleRepo(), nil, testPasswordPolicy, nil)
+
+	_, err := userController.CreateUser(context.Background(), &dto.UserRequest{Name: "operator", Password: "Gol-de-Placa-1970"}, "superuser")
+
+	if err == nil {
+		t.Fatal("expected an unknown role error, got nil")
+	}
+}
+
 func TestUserController_Login(t *testing.T) {
 	hashedPassword, _ := utils.HashPassword("password")
 	mockUserRepo := &mockUserRepository{
@@ -113,13 +188,13 @@ func TestUserController_Login(t *testing.T) {
 	}
 
 	jms := utils.NewJWTService("secret", 24)
-	userController := controller.NewUserController(mockUserRepo, jms)
+	userController := controller.NewUserController(mockUserRepo, mockRoleRepo(), newMockLoginAttemptRepository(), testPasswordPolicy, jms)
 	req := &dto.UserRequest{
 		Name:     "testuser",
 		Password: "password",
 	}
 
-	resp, err := userController.Login(context.Background(), req)
+	resp, err := userController.Login(context.Background(), req, dto.ClientInfo{IP: "203.0.113.1"})
 
 	if err != nil {
 		t.Fatalf("expected no error, got %v", err)
@@ -130,6 +205,43 @@ func TestUserController_Login(t *testing.T) {
 	}
 }
 
+func TestUserController_Login_CookieSession(t *testing.T) {
+	hashedPassword, _ := utils.HashPassword("password")
+	mockUserRepo := &mockUserRepository{
+		getByName: func(ctx context.Context, name string) (*model.User, error) {
+			return &model.User{ID: 1, Name: "testuser", Password: hashedPassword, Role: "default"}, nil
+		},
+	}
+
+	jms := utils.NewJWTService("secret", 24)
+	userController := controller.NewUserController(mockUserRepo, mockRoleRepo(), newMockLoginAttemptRepository(), testPasswordPolicy, jms)
+	req := &dto.UserRequest{Name: "testuser", Password: "password"}
+
+	resp, err := userController.Login(context.Background(), req, dto.ClientInfo{IP: "203.0.113.1", CookieSession: true})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if resp.CSRFToken == "" {
+		t.Fatal("expected a CSRF token, got an empty string")
+	}
+
+	claims, err := jms.ValidateToken(resp.Token)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if claims.CSRFHash != utils.HashToken(resp.CSRFToken) {
+		t.Error("expected the token to be bound to the CSRF token")
+	}
+
+	// bearer tokens are not bound to any CSRF token
+	resp, _ = userController.Login(context.Background(), req, dto.ClientInfo{IP: "203.0.113.1"})
+	if resp.CSRFToken != "" {
+		t.Errorf("expected no CSRF token for a bearer login, got %q", resp.CSRFToken)
+	}
+}
+
 func TestUserController_Login_InvalidCredentials(t *testing.T) {
 	mockUserRepo := &mockUserRepository{
 		getByName: func(ctx context.Context, name string) (*model.User, error) {
@@ -137,13 +249,13 @@ func TestUserController_Login_InvalidCredentials(t *testing.T) {
 		},
 	}
 
-	userController := controller.NewUserController(mockUserRepo, nil)
+	userController := controller.NewUserController(mockUserRepo, nil, newMockLoginAttemptRepository(), testPasswordPolicy, nil)
 	req := &dto.UserRequest{
 		Name:     "testuser",
 		Password: "password",
 	}
 
-	_, err := userController.Login(context.Background(), req)
+	_, err := userController.Login(context.Background(), req, dto.ClientInfo{IP: "203.0.113.1"})
 
 	if err == nil {
 		t.Fatal("expected an error, got nil")
@@ -151,7 +263,7 @@ func TestUserController_Login_InvalidCredentials(t *testing.T) {
 }
 
 func TestUserController_Logout(t *testing.T) {
-	userController := controller.NewUserController(nil, nil)
+	userController := controller.NewUserController(nil, nil, nil, testPasswordPolicy, nil)
 	resp, err := userController.Logout(context.Background())
 
 	if err != nil {
@@ -161,4 +273,284 @@ func TestUserController_Logout(t *testing.T) {
 	if resp.Message != "Logged out successfully" {
 		t.Errorf("unexpected response message: %s", resp.Message)
 	}
-}
\ No newline at end of file
+}
+
+func TestUserController_ValidateSession(t *testing.T) {
+	suspendedAt := time.Now().Add(-time.Hour)
+
+	tests := []struct {
+		name    string
+		user    *model.User
+		claims  *dto.JWTClaims
+		wantErr error
+	}{
+		{"active", &model.User{ID: 1}, &dto.JWTClaims{UserID: 1, IssuedAt: time.Now()}, nil},
+		{"suspended", &model.User{ID: 1, SuspendedAt: &suspendedAt, TokensValidAfter: &suspendedAt}, &dto.JWTClaims{UserID: 1, IssuedAt: time.Now()}, controller.ErrUserSuspended},
+		{"token issued before revocation", &model.User{ID: 1, TokensValidAfter: &suspendedAt}, &dto.JWTClaims{UserID: 1, IssuedAt: suspendedAt.Add(-time.Minute)}, controller.ErrInvalidToken},
+		{"token issued after revocation", &model.User{ID: 1, TokensValidAfter: &suspendedAt}, &dto.JWTClaims{UserID: 1, IssuedAt: time.Now()}, nil},
+		// iat has whole seconds
+		{"token issued the second before revocation", &model.User{ID: 1, TokensValidAfter: &suspendedAt}, &dto.JWTClaims{UserID: 1, IssuedAt: suspendedAt.Truncate(time.Second).Add(-time.Second)}, controller.ErrInvalidToken},
+		{"token reissued in the revocation second", &model.User{ID: 1, TokensValidAfter: &suspendedAt}, &dto.JWTClaims{UserID: 1, IssuedAt: suspendedAt.Truncate(time.Second)}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockUserRepo := &mockUserRepository{
+				getByID: func(ctx context.Context, id int) (*model.User, error) {
+					return tt.user, nil
+				},
+			}
+
+			userController := controller.NewUserController(mockUserRepo, nil, nil, testPasswordPolicy, nil)
+			err := userController.ValidateSession(context.Background(), tt.claims)
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("expected %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestUserController_ValidateSession_DeletedUser(t *testing.T) {
+	mockUserRepo := &mockUserRepository{
+		getByID: func(ctx context.Context, id int) (*model.User, error) {
+			return nil, errors.New("user not found")
+		},
+	}
+
+	userController := controller.NewUserController(mockUserRepo, nil, nil, testPasswordPolicy, nil)
+	if err := userController.ValidateSession(context.Background(), &dto.JWTClaims{UserID: 1}); err == nil {
+		t.Fatal("expected an error, got nil")
+	}
+}
+
+func TestUserController_Login_Suspended(t *testing.T) {
+	hashedPassword, _ := utils.HashPassword("password")
+	suspendedAt := time.Now()
+	mockUserRepo := &mockUserRepository{
+		getByName: func(ctx context.Context, name string) (*model.User, error) {
+			return &model.User{ID: 1, Name: "testuser", Password: hashedPassword, Role: "default", SuspendedAt: &suspendedAt}, nil
+		},
+	}
+
+	userController := controller.NewUserController(mockUserRepo, mockRoleRepo(), newMockLoginAttemptRepository(), testPasswordPolicy, utils.NewJWTService("secret", 24))
+	_, err := userController.Login(context.Background(), &dto.UserRequest{Name: "testuser", Password: "password"}, dto.ClientInfo{IP: "203.0.113.1"})
+
+	if !errors.Is(err, controller.ErrUserSuspended) {
+		t.Fatalf("expected ErrUserSuspended, got %v", err)
+	}
+}
+
+func TestUserController_GetProfile(t *testing.T) {
+	mockUserRepo := &mockUserRepository{
+		getByID: func(ctx context.Context, id int) (*model.User, error) {
+			return &model.User{ID: id, Name: "testuser", Password: "hash", Language: "pt-BR"}, nil
+		},
+	}
+
+	userController := controller.NewUserController(mockUserRepo, nil, nil, testPasswordPolicy, nil)
+	profile, err := userController.GetProfile(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if profile.Password != "" {
+		t.Error("expected password hash to be cleared")
+	}
+}
+
+func TestUserController_UpdateProfile(t *testing.T) {
+	hashedPassword, _ := utils.HashPassword("password")
+
+	tests := []struct {
+		name    string
+		req     *dto.ProfileRequest
+		wantErr bool
+	}{
+		{"valid", &dto.ProfileRequest{DisplayName: "Ana", Email: "ana@example.com", Phone: "+5511999999999", Language: "pt-BR", Timezone: "America/Sao_Paulo"}, false},
+		{"optional fields empty", &dto.ProfileRequest{CurrentPassword: "password", Language: "en", Timezone: "UTC"}, false},
+		{"email changed", &dto.ProfileRequest{Email: "new@example.com", CurrentPassword: "password", Language: "en", Timezone: "UTC"}, false},
+		{"invalid email", &dto.ProfileRequest{Email: "ana", Language: "pt-BR", Timezone: "UTC"}, true},
+		{"invalid phone", &dto.ProfileRequest{Phone: "11999999999", Language: "pt-BR", Timezone: "UTC"}, true},
+		{"invalid language", &dto.ProfileRequest{Language: "not a language", Timezone: "UTC"}, true},
+		{"invalid timezone", &dto.ProfileRequest{Language: "pt-BR", Timezone: "Mars/Olympus"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var updated *model.User
+			mockUserRepo := &mockUserRepository{
+				getByID: func(ctx context.Context, id int) (*model.User, error) {
+					return &model.User{ID: id, Name: "testuser", Password: hashedPassword, Email: "ana@example.com"}, nil
+				},
+				updateProfile: func(ctx context.Context, user *model.User) error {
+					updated = user
+					return nil
+				},
+			}
+
+			userController := controller.NewUserController(mockUserRepo, nil, nil, testPasswordPolicy, nil)
+			profile, err := userController.UpdateProfile(context.Background(), 1, tt.req, time.Now().Add(-time.Hour))
+
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error, got nil")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			if updated.Email != tt.req.Email || updated.Timezone != tt.req.Timezone {
+				t.Errorf("expected profile to be saved, got %+v", updated)
+			}
+
+			if profile.Password != "" {
+				t.Error("expected password hash to be cleared")
+			}
+		})
+	}
+}
+
+func TestUserController_UpdateProfile_EmailChangeNeedsPassword(t *testing.T) {
+	hashedPassword, _ := utils.HashPassword("password")
+	mockUserRepo := &mockUserRepository{
+		getByID: func(ctx context.Context, id int) (*model.User, error) {
+			return &model.User{ID: id, Name: "testuser", Password: hashedPassword, Email: "ana@example.com"}, nil
+		},
+		updateProfile: func(ctx context.Context, user *model.User) error {
+			t.Error("expected the profile not to be saved")
+			return nil
+		},
+	}
+
+	userController := controller.NewUserController(mockUserRepo, nil, nil, testPasswordPolicy, nil)
+
+	req := &dto.ProfileRequest{Email: "attacker@example.com", CurrentPassword: "wrong", Language: "en", Timezone: "UTC"}
+	_, err := userController.UpdateProfile(context.Background(), 1, req, time.Now().Add(-time.Hour))
+	if !errors.Is(err, controller.ErrWrongPassword) {
+		t.Fatalf("expected ErrWrongPassword, got %v", err)
+	}
+}
+
+func TestUserController_ChangePassword(t *testing.T) {
+	hashedPassword, _ := utils.HashPassword("password")
+	var newHash string
+	var tokensValidAfter *time.Time
+	mockUserRepo := &mockUserRepository{
+		getByID: func(ctx context.Context, id int) (*model.User, error) {
+			return &model.User{ID: id, Name: "testuser", Password: hashedPassword, Role: "default", TokensValidAfter: tokensValidAfter}, nil
+		},
+		updatePassword: func(ctx context.Context, id int, password string, revokedAt time.Time) error {
+			newHash = password
+			tokensValidAfter = &revokedAt
+			return nil
+		},
+	}
+
+	jwtService := utils.NewJWTService("secret", 24)
+	userController := controller.NewUserController(mockUserRepo, mockRoleRepo(), newMockLoginAttemptRepository(), testPasswordPolicy, jwtService)
+
+	_, err := userController.ChangePassword(context.Background(), 1, &dto.ChangePasswordRequest{CurrentPassword: "wrong", NewPassword: "newpassword"}, time.Now(), false)
+	if !errors.Is(err, controller.ErrWrongPassword) {
+		t.Fatalf("expected ErrWrongPassword, got %v", err)
+	}
+
+	_, err = userController.ChangePassword(context.Background(), 1, &dto.ChangePasswordRequest{CurrentPassword: "password", NewPassword: "password"}, time.Now(), false)
+	if err == nil {
+		t.Fatal("expected an error for unchanged password, got nil")
+	}
+
+	_, err = userController.ChangePassword(context.Background(), 1, &dto.ChangePasswordRequest{CurrentPassword: "password", NewPassword: "qwerty123"}, time.Now(), false)
+	var policyErr *passwordpolicy.Error
+	if !errors.As(err, &policyErr) {
+		t.Fatalf("expected a policy error for a common password, got %v", err)
+	}
+
+	resp, err := userController.ChangePassword(context.Background(), 1, &dto.ChangePasswordRequest{CurrentPassword: "password", NewPassword: "newpassword"}, time.Now(), false)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if resp.Token == "" {
+		t.Error("expected a new token, got an empty string")
+	}
+
+	if !utils.CheckPasswordHash("newpassword", newHash) {
+		t.Error("expected the new password to be stored hashed")
+	}
+
+	// the returned token outlives the revocation it comes with
+	claims, err := jwtService.ValidateToken(resp.Token)
+	if err != nil {
+		t.Fatalf("expected a valid token, got %v", err)
+	}
+
+	if err := userController.ValidateSession(context.Background(), claims); err != nil {
+		t.Errorf("expected the new token to be accepted, got %v", err)
+	}
+}
+
+func TestUserController_DeleteAccount(t *testing.T) {
+	hashedPassword, _ := utils.HashPassword("password")
+	deleted := false
+	mockUserRepo := &mockUserRepository{
+		getByID: func(ctx context.Context, id int) (*model.User, error) {
+			return &model.User{ID: id, Password: hashedPassword}, nil
+		},
+		delete: func(ctx context.Context, id int) error {
+			deleted = true
+			return nil
+		},
+	}
+
+	userController := controller.NewUserController(mockUserRepo, nil, nil, testPasswordPolicy, nil)
+
+	_, err := userController.DeleteAccount(context.Background(), 1, &dto.DeleteAccountRequest{Password: "wrong"}, time.Now())
+	if !errors.Is(err, controller.ErrWrongPassword) {
+		t.Fatalf("expected ErrWrongPassword, got %v", err)
+	}
+
+	if deleted {
+		t.Fatal("account should not be deleted with a wrong password")
+	}
+
+	if _, err := userController.DeleteAccount(context.Background(), 1, &dto.DeleteAccountRequest{Password: "password"}, time.Now()); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if !deleted {
+		t.Error("expected account to be deleted")
+	}
+}
+
+func TestUserController_DeleteAccount_WithoutPassword(t *testing.T) {
+	deleted := false
+	mockUserRepo := &mockUserRepository{
+		getByID: func(ctx context.Context, id int) (*model.User, error) {
+			// created by a login provider
+			return &model.User{ID: id}, nil
+		},
+		delete: func(ctx context.Context, id int) error {
+			deleted = true
+			return nil
+		},
+	}
+
+	userController := controller.NewUserController(mockUserRepo, nil, nil, testPasswordPolicy, nil)
+
+	_, err := userController.DeleteAccount(context.Background(), 1, &dto.DeleteAccountRequest{}, time.Now().Add(-time.Hour))
+	if !errors.Is(err, controller.ErrLoginTooOld) || deleted {
+		t.Fatalf("expected ErrLoginTooOld for an old session, got %v", err)
+	}
+
+	if _, err := userController.DeleteAccount(context.Background(), 1, &dto.DeleteAccountRequest{}, time.Now().Add(-time.Minute)); err != nil {
+		t.Fatalf("expected a recent login to be enough, got %v", err)
+	}
+
+	if !deleted {
+		t.Error("expected account to be deleted")
+	}
+}
@@ -1,21 +1,184 @@
 package dto
 
-import "github.com/tsntt/footballapi/internal/model"
+import (
+	"time"
 
+	"github.com/tsntt/footballapi/internal/model"
+	"github.com/tsntt/footballapi/pkg/passwordpolicy"
+)
+
+// UserRequest is used to register and to log in, new passwords are checked by the password policy
 type UserRequest struct {
 	Name     string `json:"name" validate:"required,min=2,max=50"`
-	Password string `json:"password" validate:"required,min=6"`
+	Password string `json:"password" validate:"required"`
+}
+
+// ClientInfo identifies where a request came from
+type ClientInfo struct {
+	IP        string
+	UserAgent string
+	// The token goes in an HttpOnly cookie and is bound to a CSRF token
+	CookieSession bool
+}
+
+// ProfileRequest replaces the whole profile, empty optional fields are cleared
+type ProfileRequest struct {
+	DisplayName string `json:"display_name" validate:"max=50"`
+	Email       string `json:"email" validate:"omitempty,email,max=254"`
+	Phone       string `json:"phone" validate:"omitempty,e164"`
+	Language    string `json:"language" validate:"required,bcp47_language_tag,max=35"`
+	Timezone    string `json:"timezone" validate:"required,timezone,max=64"`
+	// Required to change the email, password reset links go there
+	CurrentPassword string `json:"current_password"`
+}
+
+type ForgotPasswordRequest struct {
+	Name string `json:"name" validate:"required,max=50"`
+}
+
+type ResetPasswordRequest struct {
+	Token       string `json:"token" validate:"required,max=128"`
+	NewPassword string `json:"new_password" validate:"required"`
+}
+
+// ChangePasswordRequest CurrentPassword is left out by users created by a login provider, they
+// have none and must have logged in recently instead
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password" validate:"required,nefield=CurrentPassword"`
+}
+
+type DeleteAccountRequest struct {
+	// Left out by users without a password, see ChangePasswordRequest
+	Password string `json:"password"`
+}
+
+// CreateAPIKeyRequest scopes are model.ScopeRead, model.ScopeWrite or permissions of the user's role
+type CreateAPIKeyRequest struct {
+	Name          string   `json:"name" validate:"required,max=50"`
+	Scopes        []string `json:"scopes" validate:"required,min=1,dive,required,max=30"`
+	ExpiresInDays int      `json:"expires_in_days" validate:"omitempty,min=1,max=365"`
+}
+
+// CreateAPIKeyResponse is the only time the key is returned
+type CreateAPIKeyResponse struct {
+	Key string `json:"key"`
+	model.APIKey
 }
 
 type LoginResponse struct {
-	Token string `json:"token"`
+	Token string `json:"token,omitempty"`
+	// Cookie sessions only, sent back in the X-CSRF-Token header of state-changing requests
+	CSRFToken string `json:"csrf_token,omitempty"`
+	// Set instead of a token when a second factor is needed, exchanged at /auth/2fa/verify
+	ChallengeToken string `json:"challenge_token,omitempty"`
+	// TwoFactorVerify or TwoFactorSetup, with ChallengeToken
+	TwoFactor string `json:"two_factor,omitempty"`
+	// Returned once, when 2FA was set up during the login
+	RecoveryCodes []string `json:"recovery_codes,omitempty"`
+}
+
+const (
+	// The user has 2FA on and sends a code
+	TwoFactorVerify = "verify"
+	// 2FA is mandatory for the role of the user, it has to be set up before the login goes on
+	TwoFactorSetup = "setup"
+)
+
+type TwoFactorChallengeRequest struct {
+	ChallengeToken string `json:"challenge_token" validate:"required,max=128"`
+}
+
+// TwoFactorLoginRequest finishes a login, code is a TOTP code or a recovery code
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challenge_token" validate:"required,max=128"`
+	Code           string `json:"code" validate:"required,max=32"`
+}
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" validate:"required,max=32"`
+}
+
+type DisableTwoFactorRequest struct {
+	// Left out by users without a password, see ChangePasswordRequest
+	Password string `json:"password"`
+	Code     string `json:"code" validate:"required,max=32"`
+}
+
+// TwoFactorSetupResponse is shown as a QR code (ProvisioningURI) or typed in (Secret)
+type TwoFactorSetupResponse struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+type TwoFactorStatusResponse struct {
+	Enabled           bool `json:"enabled"`
+	Required          bool `json:"required"`
+	RecoveryCodesLeft int  `json:"recovery_codes_left"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
 }
 
 type JWTClaims struct {
 	UserID int    `json:"user_id"`
 	Name   string `json:"name"`
 	Role   string `json:"role"`
-	Exp    int64  `json:"exp"`
+	// Permissions granted to Role when the token was issued, meant for the client UI
+	Permissions []string `json:"permissions"`
+	// Token ID, unique per token
+	ID string `json:"jti"`
+	// Hash of the CSRF token a cookie session is bound to, empty for bearer tokens
+	CSRFHash string `json:"csrf,omitempty"`
+	// Set for requests authenticated by an API key, never read from a token
+	APIKeyID int       `json:"-"`
+	Scopes   []string  `json:"-"`
+	IssuedAt time.Time `json:"-"`
+	Exp      int64     `json:"exp"`
+}
+
+// JWK is the public half of a signing key (RFC 7517), RSA keys set N and E, Ed25519 keys Crv and X
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKSResponse struct {
+	Keys []JWK `json:"keys"`
+}
+
+// Problem is the body of every error response, served as application/problem+json (RFC 9457)
+type Problem struct {
+	// about:blank, Code tells the problems apart
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	// Safe to show, never the internal error
+	Detail string `json:"detail,omitempty"`
+	// Path of the request
+	Instance string `json:"instance,omitempty"`
+	// Stable, for clients to branch on, e.g. validation_failed or user_not_found
+	Code      string `json:"code"`
+	RequestID string `json:"request_id,omitempty"`
+	// The broken rules of a validation_failed request
+	Errors []FieldError `json:"errors,omitempty"`
+	// The rules a new password breaks, with password_policy
+	Violations []passwordpolicy.Violation `json:"violations,omitempty"`
+}
+
+type FieldError struct {
+	// JSON pointer to the value, e.g. /scopes/0, empty for the whole body
+	Field string `json:"field"`
+	// The rule it breaks, e.g. required or max
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
 }
 
 type APIResponse struct {
@@ -31,12 +194,93 @@ type MatchesResponse struct {
 	Matches []model.Match `json:"matches"`
 }
 
+type AssignRoleRequest struct {
+	Role string `json:"role" validate:"required,max=20"`
+}
+
 type FanRequest struct {
-	UserID   int    `json:"user_id" validate:"required"`
-	TeamID   int    `json:"team_id" validate:"required"`
-	TeamName string `json:"team_name" validate:"required"`
+	UserID           int    `json:"user_id" validate:"required" openapi:"-"`
+	TeamID           int    `json:"team_id" validate:"required"`
+	TeamName         string `json:"team_name" validate:"required"`
+	NotificationType string `json:"notification_type" validate:"required,oneof=email sms"`
+	Address          string `json:"address" validate:"required"`
+}
+
+type ChannelRequest struct {
+	NotificationType string `json:"notification_type" validate:"required,oneof=email sms"`
+	Address          string `json:"address" validate:"required"`
+}
+
+type VerifyChannelRequest struct {
+	NotificationType string `json:"notification_type" validate:"required,oneof=email sms"`
+	Address          string `json:"address" validate:"required"`
+	Code             string `json:"code" validate:"required,numeric,len=6"`
 }
 
 type UnsubscribeRequest struct {
 	TeamID string `json:"team_id" validate:"required"`
 }
+
+type DeliveryReportResponse struct {
+	MatchID     int              `json:"match_id"`
+	BroadcastID int              `json:"broadcast_id"`
+	Summary     map[string]int   `json:"summary"`
+	Deliveries  []model.Delivery `json:"deliveries"`
+}
+
+type UserListRequest struct {
+	Search  string `query:"q" validate:"max=50"`
+	Page    int    `query:"page" validate:"omitempty,min=1"`
+	PerPage int    `query:"per_page" validate:"omitempty,min=1,max=100"`
+}
+
+type UserListResponse struct {
+	Users   []model.User `json:"users"`
+	Total   int          `json:"total"`
+	Page    int          `json:"page"`
+	PerPage int          `json:"per_page"`
+}
+
+type UserDetailResponse struct {
+	User          *model.User        `json:"user"`
+	Subscriptions []model.Fan        `json:"subscriptions"`
+	Deliveries    []model.Delivery   `json:"deliveries"`
+	Audit         []model.AuditEntry `json:"audit"`
+}
+
+// Mailgun webhook body, only the fields we use
+type MailgunWebhookRequest struct {
+	Signature MailgunSignature `json:"signature"`
+	EventData MailgunEventData `json:"event-data"`
+}
+
+type MailgunSignature struct {
+	Timestamp string `json:"timestamp"`
+	Token     string `json:"token"`
+	Signature string `json:"signature"`
+}
+
+type MailgunEventData struct {
+	ID        string  `json:"id"`
+	Event     string  `json:"event"`
+	Timestamp float64 `json:"timestamp"`
+	Severity  string  `json:"severity"`
+	Reason    string  `json:"reason"`
+	Recipient string  `json:"recipient"`
+	Message   struct {
+		Headers struct {
+			MessageID string `json:"message-id"`
+		} `json:"headers"`
+	} `json:"message"`
+	DeliveryStatus struct {
+		Description string `json:"description"`
+		Message     string `json:"message"`
+	} `json:"delivery-status"`
+}
+
+type UnsubscribeClaims struct {
+	UserID           int    `json:"user_id"`
+	NotificationType string `json:"notification_type"`
+	Address          string `json:"address"`
+	Exp              int64  `json:"exp"`
+}
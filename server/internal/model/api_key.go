@@ -0,0 +1,40 @@
+package model
+
+import (
+	"context"
+	"time"
+)
+
+// Scopes of API keys besides the role permissions (PermBroadcastSend, ...), which a key can only
+// hold while the role of its owner grants them
+const (
+	ScopeRead  = "read"
+	ScopeWrite = "write"
+)
+
+// APIKey is a long-lived credential for scripts, the key itself is shown once and stored hashed
+type APIKey struct {
+	ID     int    `json:"id" db:"id"`
+	UserID int    `json:"-" db:"user_id"`
+	Name   string `json:"name" db:"name"`
+	// Public part of the key, shown in listings to tell keys apart
+	Prefix     string     `json:"prefix" db:"prefix"`
+	KeyHash    string     `json:"-" db:"key_hash"`
+	Scopes     []string   `json:"scopes" db:"-"`
+	ExpiresAt  time.Time  `json:"expires_at" db:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty" db:"last_used_at"`
+	LastUsedIP *string    `json:"last_used_ip,omitempty" db:"last_used_ip"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+}
+
+type IAPIKeyRepository interface {
+	Create(ctx context.Context, key *APIKey) error
+	GetByPrefix(ctx context.Context, prefix string) (*APIKey, error)
+	ListByUser(ctx context.Context, userID int) ([]APIKey, error)
+	CountActive(ctx context.Context, userID int) (int, error)
+	// Revoke returns false when the user has no such active key
+	Revoke(ctx context.Context, userID, id int) (bool, error)
+	// TouchLastUsed skips the write when the key was used from ip in the last minute
+	TouchLastUsed(ctx context.Context, id int, ip string) error
+}
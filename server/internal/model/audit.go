@@ -0,0 +1,34 @@
+package model
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	AuditUserRoleAssigned   = "user.role_assigned"
+	AuditUserSuspended      = "user.suspended"
+	AuditUserReactivated    = "user.reactivated"
+	AuditUserDeleted        = "user.deleted"
+	AuditUserLockoutCleared = "user.lockout_cleared"
+)
+
+const AuditTargetUser = "user"
+
+// AuditActorCLI is the actor of changes made with the server command line, there is no signed in user
+const AuditActorCLI = 0
+
+type AuditEntry struct {
+	ID         int       `json:"id" db:"id"`
+	ActorID    int       `json:"actor_id" db:"actor_id"`
+	Action     string    `json:"action" db:"action"`
+	TargetType string    `json:"target_type" db:"target_type"`
+	TargetID   int       `json:"target_id" db:"target_id"`
+	Details    string    `json:"details,omitempty" db:"details"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+}
+
+type IAuditRepository interface {
+	Create(ctx context.Context, entry *AuditEntry) error
+	GetByTarget(ctx context.Context, targetType string, targetID int) ([]AuditEntry, error)
+}
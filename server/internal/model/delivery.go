@@ -0,0 +1,94 @@
+package model
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	DeliveryQueued      = "queued"
+	DeliverySent        = "sent"
+	DeliveryDelivered   = "delivered"
+	DeliveryUndelivered = "undelivered"
+	DeliveryFailed      = "failed"
+)
+
+// deliveryStatusRank orders statuses so late or out of order callbacks never move a delivery backwards
+var deliveryStatusRank = map[string]int{
+	DeliveryQueued:      1,
+	DeliverySent:        2,
+	DeliveryDelivered:   3,
+	DeliveryUndelivered: 3,
+	DeliveryFailed:      3,
+}
+
+type Delivery struct {
+	ID                int       `json:"id" db:"id"`
+	BroadcastID       int       `json:"broadcast_id" db:"broadcast_id"`
+	UserID            int       `json:"user_id" db:"user_id"`
+	NotificationType  string    `json:"notification_type" db:"notification_type"`
+	Address           string    `json:"address" db:"address"`
+	ProviderMessageID string    `json:"provider_message_id" db:"provider_message_id"`
+	Status            string    `json:"status" db:"status"`
+	ErrorMessage      string    `json:"error_message,omitempty" db:"error_message"`
+	CreatedAt         time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// CanTransitionTo reports whether moving from the current status to next is a step forward
+func (d *Delivery) CanTransitionTo(next string) bool {
+	nextRank, ok := deliveryStatusRank[next]
+	if !ok {
+		return false
+	}
+
+	return nextRank > deliveryStatusRank[d.Status]
+}
+
+const (
+	EventDelivered  = "delivered"
+	EventOpened     = "opened"
+	EventClicked    = "clicked"
+	EventBounced    = "bounced"
+	EventComplained = "complained"
+)
+
+type DeliveryEvent struct {
+	ID              int       `json:"id" db:"id"`
+	DeliveryID      *int      `json:"delivery_id,omitempty" db:"delivery_id"`
+	ProviderEventID string    `json:"provider_event_id" db:"provider_event_id"`
+	Event           string    `json:"event" db:"event"`
+	Recipient       string    `json:"recipient" db:"recipient"`
+	Reason          string    `json:"reason,omitempty" db:"reason"`
+	OccurredAt      time.Time `json:"occurred_at" db:"occurred_at"`
+	CreatedAt       time.Time `json:"created_at" db:"created_at"`
+}
+
+type IDeliveryRepository interface {
+	Create(ctx context.Context, delivery *Delivery) error
+	GetByProviderMessageID(ctx context.Context, providerMessageID string) (*Delivery, error)
+	GetByBroadcastID(ctx context.Context, broadcastID int) ([]Delivery, error)
+	// GetByUserID returns the latest limit deliveries of a user, newest first
+	GetByUserID(ctx context.Context, userID, limit int) ([]Delivery, error)
+	UpdateStatus(ctx context.Context, delivery *Delivery) error
+	AddEvent(ctx context.Context, event *DeliveryEvent) error
+}
+
+// Validates the X-Twilio-Signature of status callbacks and inbound messages
+type ISMSWebhookValidator interface {
+	ValidateStatusCallback(params map[string]string, signature string) bool
+	ValidateInbound(params map[string]string, signature string) bool
+}
+
+// Validates the HMAC Mailgun attaches to every webhook
+type IEmailWebhookValidator interface {
+	VerifyWebhook(timestamp, token, signature string) bool
+}
+
+// Remembers the tokens of the webhooks already handled, a signed request could otherwise be replayed
+type IWebhookTokenRepository interface {
+	// Claim records token, false when it was already seen. Tokens seen before forgetBefore are dropped
+	Claim(ctx context.Context, token string, forgetBefore time.Time) (bool, error)
+	// Release forgets a claimed token whose request could not be handled, so it can be retried
+	Release(ctx context.Context, token string) error
+}
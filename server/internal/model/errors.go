@@ -0,0 +1,10 @@
+package model
+
+import "errors"
+
+// Wrapped by the repositories when a row does not exist or would break a unique constraint,
+// match them with errors.Is
+var (
+	ErrNotFound = errors.New("not found")
+	ErrConflict = errors.New("already exists")
+)
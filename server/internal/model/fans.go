@@ -8,6 +8,8 @@ type Fan struct {
 	TeamID           int    `json:"team_id" db:"team_id" validate:"required"`
 	NotificationType string `json:"notification_type" db:"notification_type"`
 	Address          string `json:"address" db:"address"`
+	Active           bool   `json:"active" db:"active"`
+	Verified         bool   `json:"verified" db:"verified"`
 }
 
 type IFanRepository interface {
@@ -16,4 +18,9 @@ type IFanRepository interface {
 	GetByTeamID(ctx context.Context, teamID int) ([]Fan, error)
 	GetByUserID(ctx context.Context, userID int) ([]Fan, error)
 	DeleteByUserIDAndTeam(ctx context.Context, userID int, team string) error
+	// DisableByAddress follows an opt-out or a bounce, the verification of the address is revoked
+	// too so the owner has to confirm a new code before it is notified again
+	DisableByAddress(ctx context.Context, notificationType, address string) (int64, error)
+	DisableByUserAndAddress(ctx context.Context, userID int, notificationType, address string) (int64, error)
+	ActivateByUserAndAddress(ctx context.Context, userID int, notificationType, address string) (int64, error)
 }
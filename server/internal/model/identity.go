@@ -0,0 +1,62 @@
+package model
+
+import (
+	"context"
+	"time"
+)
+
+// UserIdentity links a user to their account at an external login provider
+type UserIdentity struct {
+	ID       int    `json:"id" db:"id"`
+	UserID   int    `json:"user_id" db:"user_id"`
+	Provider string `json:"provider" db:"provider"`
+	// Stable id of the account at the provider (OIDC sub)
+	Subject     string     `json:"-" db:"subject"`
+	Email       string     `json:"email" db:"email"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	LastLoginAt *time.Time `json:"last_login_at,omitempty" db:"last_login_at"`
+}
+
+// ExternalIdentity is what a provider tells about the user after a successful login
+type ExternalIdentity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	// Preferred user name at the provider, may be empty
+	Username string
+}
+
+// OAuthState is a login started at a provider, waiting for the callback
+type OAuthState struct {
+	ID            int       `db:"id"`
+	StateHash     string    `db:"state_hash"`
+	Provider      string    `db:"provider"`
+	CodeVerifier  string    `db:"code_verifier"`
+	Nonce         string    `db:"nonce"`
+	CookieSession bool      `db:"cookie_session"`
+	ExpiresAt     time.Time `db:"expires_at"`
+	CreatedAt     time.Time `db:"created_at"`
+}
+
+type IOAuthProvider interface {
+	Name() string
+	// AuthCodeURL is the provider login page, verifier is sent as a PKCE S256 challenge
+	AuthCodeURL(state, nonce, verifier string) string
+	// Exchange redeems the code from the callback and returns the verified identity
+	Exchange(ctx context.Context, code, verifier, nonce string) (*ExternalIdentity, error)
+}
+
+type IUserIdentityRepository interface {
+	GetByProviderSubject(ctx context.Context, provider, subject string) (*UserIdentity, error)
+	// CreateWithUser provisions a user and its first identity in one transaction
+	CreateWithUser(ctx context.Context, user *User, identity *UserIdentity) error
+	TouchLogin(ctx context.Context, id int) error
+}
+
+type IOAuthStateRepository interface {
+	// Create also deletes the expired states
+	Create(ctx context.Context, state *OAuthState) error
+	// Consume deletes and returns the state, a state works once
+	Consume(ctx context.Context, stateHash string) (*OAuthState, error)
+}
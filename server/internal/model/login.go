@@ -0,0 +1,43 @@
+package model
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	LoginFailureInvalidCredentials = "invalid_credentials"
+	LoginFailureSuspended          = "suspended"
+	LoginFailureThrottled          = "throttled"
+)
+
+type LoginAttempt struct {
+	ID            int       `json:"id" db:"id"`
+	UserID        *int      `json:"user_id,omitempty" db:"user_id"`
+	Name          string    `json:"name" db:"name"`
+	IP            string    `json:"ip" db:"ip"`
+	UserAgent     string    `json:"user_agent" db:"user_agent"`
+	Success       bool      `json:"success" db:"success"`
+	FailureReason string    `json:"failure_reason,omitempty" db:"failure_reason"`
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+}
+
+// LoginThrottle counts recent failed logins of an account or an IP
+type LoginThrottle struct {
+	Key           string     `json:"key" db:"key"`
+	Failures      int        `json:"failures" db:"failures"`
+	LastFailureAt time.Time  `json:"last_failure_at" db:"last_failure_at"`
+	LockedUntil   *time.Time `json:"locked_until,omitempty" db:"locked_until"`
+}
+
+type ILoginAttemptRepository interface {
+	Record(ctx context.Context, attempt *LoginAttempt) error
+	GetThrottle(ctx context.Context, key string) (*LoginThrottle, error)
+	// AddFailure counts one failure at now in a single statement, failures before windowStart are
+	// forgotten first. It returns the counter with this failure
+	AddFailure(ctx context.Context, key string, now, windowStart time.Time) (*LoginThrottle, error)
+	LockThrottle(ctx context.Context, key string, until time.Time) error
+	DeleteThrottle(ctx context.Context, key string) error
+	// ListThrottles returns the throttles with a failure since the given time, latest first
+	ListThrottles(ctx context.Context, since time.Time) ([]LoginThrottle, error)
+}
@@ -0,0 +1,28 @@
+package model
+
+import (
+	"context"
+	"time"
+)
+
+// PasswordReset backs a "selector.verifier" token sent by email
+type PasswordReset struct {
+	ID           int        `json:"id" db:"id"`
+	UserID       int        `json:"user_id" db:"user_id"`
+	Selector     string     `json:"-" db:"selector"`
+	VerifierHash string     `json:"-" db:"verifier_hash"`
+	ExpiresAt    time.Time  `json:"expires_at" db:"expires_at"`
+	Attempts     int        `json:"attempts" db:"attempts"`
+	UsedAt       *time.Time `json:"used_at,omitempty" db:"used_at"`
+	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
+}
+
+type IPasswordResetRepository interface {
+	// Create also invalidates the unused resets of the user, only the latest link works
+	Create(ctx context.Context, reset *PasswordReset) error
+	GetBySelector(ctx context.Context, selector string) (*PasswordReset, error)
+	CountSince(ctx context.Context, userID int, since time.Time) (int, error)
+	IncrementAttempts(ctx context.Context, id int) error
+	// MarkUsed returns false when the reset was already used
+	MarkUsed(ctx context.Context, id int) (bool, error)
+}
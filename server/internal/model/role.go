@@ -0,0 +1,22 @@
+package model
+
+import "context"
+
+const (
+	PermBroadcastSend = "broadcast:send"
+	PermBroadcastRead = "broadcast:read"
+	PermUsersManage   = "users:manage"
+	PermTemplatesEdit = "templates:edit"
+)
+
+type Role struct {
+	Name        string   `json:"name" db:"name"`
+	Description string   `json:"description" db:"description"`
+	Permissions []string `json:"permissions" db:"-"`
+}
+
+type IRoleRepository interface {
+	GetAll(ctx context.Context) ([]Role, error)
+	GetByName(ctx context.Context, name string) (*Role, error)
+	GetPermissions(ctx context.Context, role string) ([]string, error)
+}
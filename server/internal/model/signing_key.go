@@ -0,0 +1,24 @@
+package model
+
+import (
+	"context"
+	"time"
+)
+
+// SigningKey signs access tokens with an asymmetric algorithm, verifiers get its public half from the JWKS endpoint
+type SigningKey struct {
+	ID         int        `db:"id"`
+	KID        string     `db:"kid"`
+	Algorithm  string     `db:"algorithm"`
+	PrivateKey string     `db:"private_key"`
+	CreatedAt  time.Time  `db:"created_at"`
+	RetiredAt  *time.Time `db:"retired_at"`
+}
+
+type ISigningKeyRepository interface {
+	// Rotate stores key and retires every other active key
+	Rotate(ctx context.Context, key *SigningKey) error
+	// ListValid returns the active keys and the ones retired after since, newest first
+	ListValid(ctx context.Context, since time.Time) ([]SigningKey, error)
+	DeleteRetiredBefore(ctx context.Context, before time.Time) (int64, error)
+}
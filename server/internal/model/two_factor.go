@@ -0,0 +1,56 @@
+package model
+
+import (
+	"context"
+	"time"
+)
+
+const LoginFailureInvalidSecondFactor = "invalid_second_factor"
+
+// TwoFactor is the TOTP enrolment of a user, pending until a first code is verified
+type TwoFactor struct {
+	UserID int `json:"-" db:"user_id"`
+	// Encrypted with utils.SecretBox
+	Secret    string     `json:"-" db:"secret"`
+	EnabledAt *time.Time `json:"enabled_at,omitempty" db:"enabled_at"`
+	// Time step of the last accepted code, a code is only accepted once
+	LastUsedStep int64     `json:"-" db:"last_used_step"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+}
+
+// MFAChallenge is the state between the password step of a login and the second factor
+type MFAChallenge struct {
+	ID            int       `db:"id"`
+	UserID        int       `db:"user_id"`
+	TokenHash     string    `db:"token_hash"`
+	CookieSession bool      `db:"cookie_session"`
+	Attempts      int       `db:"attempts"`
+	ExpiresAt     time.Time `db:"expires_at"`
+	CreatedAt     time.Time `db:"created_at"`
+}
+
+type ITwoFactorRepository interface {
+	Get(ctx context.Context, userID int) (*TwoFactor, error)
+	// SavePending starts over an enrolment that was not enabled yet
+	SavePending(ctx context.Context, userID int, secret string) error
+	// Enable turns on a pending enrolment and replaces the recovery codes
+	Enable(ctx context.Context, userID int, step int64, recoveryCodeHashes []string) error
+	// Delete removes the enrolment and the recovery codes
+	Delete(ctx context.Context, userID int) error
+	// UseStep returns false when step is not newer than the last accepted one
+	UseStep(ctx context.Context, userID int, step int64) (bool, error)
+	ReplaceRecoveryCodes(ctx context.Context, userID int, codeHashes []string) error
+	// UseRecoveryCode returns false when the user has no unused code with that hash
+	UseRecoveryCode(ctx context.Context, userID int, codeHash string) (bool, error)
+	CountRecoveryCodes(ctx context.Context, userID int) (int, error)
+}
+
+type IMFAChallengeRepository interface {
+	// Create also deletes expired challenges
+	Create(ctx context.Context, challenge *MFAChallenge) error
+	GetByTokenHash(ctx context.Context, tokenHash string) (*MFAChallenge, error)
+	// IncrementAttempts counts one attempt, it returns false and counts nothing once maxAttempts were made
+	IncrementAttempts(ctx context.Context, id, maxAttempts int) (bool, error)
+	// Delete returns false when the challenge was already used
+	Delete(ctx context.Context, id int) (bool, error)
+}
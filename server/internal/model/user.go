@@ -7,16 +7,47 @@ import (
 
 // maybe change password omitempty to -
 type User struct {
-	ID        int       `json:"id" db:"id"`
-	Name      string    `json:"name" db:"name" validate:"required,min=2,max=50"`
-	Password  string    `json:"password,omitempty" db:"password" validate:"required,min=6"`
-	Role      string    `json:"role" db:"role"`
-	CreatedAt time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	ID       int    `json:"id" db:"id"`
+	Name     string `json:"name" db:"name" validate:"required,min=2,max=50"`
+	Password string `json:"password,omitempty" db:"password" validate:"required,min=6" openapi:"-"`
+	Role     string `json:"role" db:"role"`
+	// Profile
+	DisplayName string `json:"display_name" db:"display_name"`
+	Email       string `json:"email" db:"email"`
+	Phone       string `json:"phone" db:"phone"`
+	Language    string `json:"language" db:"language"`
+	Timezone    string `json:"timezone" db:"timezone"`
+	// Permissions of Role, loaded at login to be carried in the token
+	Permissions []string   `json:"permissions,omitempty" db:"-"`
+	SuspendedAt *time.Time `json:"suspended_at,omitempty" db:"suspended_at"`
+	// Tokens issued before the second of this instant are no longer accepted
+	TokensValidAfter *time.Time `json:"-" db:"tokens_valid_after"`
+	CreatedAt        time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at" db:"updated_at"`
 }
 
 type IUserRepository interface {
 	Create(ctx context.Context, user *User) error
 	GetByName(ctx context.Context, name string) (*User, error)
 	GetByID(ctx context.Context, id int) (*User, error)
+	// UpdateRole also revokes every token issued before revokedAt
+	UpdateRole(ctx context.Context, id int, role string, revokedAt time.Time) error
+	UpdateProfile(ctx context.Context, user *User) error
+	// UpdatePassword also revokes every token issued before revokedAt
+	UpdatePassword(ctx context.Context, id int, password string, revokedAt time.Time) error
+	List(ctx context.Context, filter UserFilter) ([]User, int, error)
+	// Suspend also revokes every token issued before suspendedAt
+	Suspend(ctx context.Context, id int, suspendedAt time.Time) error
+	Reactivate(ctx context.Context, id int) error
+	// Delete removes the user with their subscriptions, verifications, deliveries and email events
+	Delete(ctx context.Context, id int) error
+}
+
+type UserFilter struct {
+	// Search matches any part of the name, case insensitive
+	Search string
+	// Role only keeps users with this role, empty for any
+	Role   string
+	Limit  int
+	Offset int
 }
@@ -0,0 +1,30 @@
+package model
+
+import (
+	"context"
+	"time"
+)
+
+// ChannelVerification proves a user owns an email address or phone number before we notify it
+type ChannelVerification struct {
+	ID               int        `json:"id" db:"id"`
+	UserID           int        `json:"user_id" db:"user_id"`
+	NotificationType string     `json:"notification_type" db:"notification_type"`
+	Address          string     `json:"address" db:"address"`
+	CodeHash         string     `json:"-" db:"code_hash"`
+	ExpiresAt        time.Time  `json:"expires_at" db:"expires_at"`
+	Attempts         int        `json:"attempts" db:"attempts"`
+	SendCount        int        `json:"send_count" db:"send_count"`
+	WindowStartedAt  time.Time  `json:"window_started_at" db:"window_started_at"`
+	LastSentAt       time.Time  `json:"last_sent_at" db:"last_sent_at"`
+	VerifiedAt       *time.Time `json:"verified_at,omitempty" db:"verified_at"`
+	CreatedAt        time.Time  `json:"created_at" db:"created_at"`
+}
+
+type IChannelVerificationRepository interface {
+	Get(ctx context.Context, userID int, notificationType, address string) (*ChannelVerification, error)
+	Save(ctx context.Context, verification *ChannelVerification) error
+	// IncrementAttempts counts one attempt, it returns false and counts nothing once maxAttempts were made
+	IncrementAttempts(ctx context.Context, id, maxAttempts int) (bool, error)
+	MarkVerified(ctx context.Context, id int) error
+}
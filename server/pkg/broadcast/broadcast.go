@@ -7,8 +7,15 @@ import (
 	"sync"
 
 	"github.com/gorilla/websocket"
+	"github.com/tsntt/footballapi/pkg/logging"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = otel.Tracer("github.com/tsntt/footballapi/pkg/broadcast")
+
 type BroadcastJob struct {
 	Subscription Subscription
 	Message      Message
@@ -24,6 +31,8 @@ type BroadcastService struct {
 	notifiers     map[NotificationType]IBroadcaster
 	subscriptions map[int][]Subscription
 	admConn       map[*websocket.Conn]bool
+	recorder      IDeliveryRecorder
+	metrics       IBroadcastMetrics
 	mu            sync.RWMutex
 }
 
@@ -40,15 +49,61 @@ func (s *BroadcastService) RegisterNotifier(nt NotificationType, notifier IBroad
 	s.notifiers[nt] = notifier
 }
 
+// SetDeliveryRecorder stores every send attempt so provider callbacks can update it later
+func (s *BroadcastService) SetDeliveryRecorder(recorder IDeliveryRecorder) {
+	s.recorder = recorder
+}
+
+// Notify sends a single message right away through the registered notifier, used for
+// transactional messages such as verification codes that must not wait for a broadcast
+func (s *BroadcastService) Notify(ctx context.Context, subscription Subscription, msg Message) (string, error) {
+	notifier, ok := s.notifiers[subscription.NotificationType]
+	if !ok {
+		return "", fmt.Errorf("no notifier found for %s", subscription.NotificationType)
+	}
+
+	return send(ctx, notifier, subscription, msg)
+}
+
+// send wraps a notifier call in a span, the provider is usually where a slow broadcast spends its time
+func send(ctx context.Context, notifier IBroadcaster, subscription Subscription, msg Message) (string, error) {
+	ctx, span := tracer.Start(ctx, "Send "+string(subscription.NotificationType),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("notification.type", string(subscription.NotificationType)),
+			attribute.Int("broadcast.id", msg.BroadcastID),
+		),
+	)
+	defer span.End()
+
+	providerMessageID, err := notifier.Send(ctx, subscription, msg)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return "", err
+	}
+
+	span.SetAttributes(attribute.String("notification.provider_message_id", providerMessageID))
+	return providerMessageID, nil
+}
+
 func (s *BroadcastService) AddSubscription(subscription Subscription) {
 	s.subscriptions[subscription.ChannelID] = append(s.subscriptions[subscription.ChannelID], subscription)
 }
 
+// SetMetrics reports sends, queued jobs and admin connections, nothing is reported without it
+func (s *BroadcastService) SetMetrics(metrics IBroadcastMetrics) {
+	s.metrics = metrics
+}
+
 func (s *BroadcastService) RegisterAdmConn(conn *websocket.Conn) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	s.admConn[conn] = true
+	if s.metrics != nil {
+		s.metrics.AdminConnections(len(s.admConn))
+	}
 }
 
 func (s *BroadcastService) UnregisterAdmConn(conn *websocket.Conn) {
@@ -56,12 +111,17 @@ func (s *BroadcastService) UnregisterAdmConn(conn *websocket.Conn) {
 	defer s.mu.Unlock()
 
 	delete(s.admConn, conn)
+	if s.metrics != nil {
+		s.metrics.AdminConnections(len(s.admConn))
+	}
 }
 
+// BroadCastToChannel sends msg to every subscription of the channel with nWorkers workers and
+// returns once every send is done and reported
 func (s *BroadcastService) BroadCastToChannel(ctx context.Context, nWorkers, channelID int, msg Message) {
 	subs, ok := s.subscriptions[channelID]
 	if !ok || len(subs) == 0 {
-		slog.Info("No subscriptions found for channel", slog.Int("channel_id", channelID))
+		logging.FromContext(ctx).Info("No subscriptions found for channel", slog.Int("channel_id", channelID))
 		return
 	}
 
@@ -69,8 +129,20 @@ func (s *BroadcastService) BroadCastToChannel(ctx context.Context, nWorkers, cha
 	jobs := make(chan BroadcastJob, totalJobs)
 	results := make(chan BroadcastResult, totalJobs)
 
+	if s.metrics != nil {
+		s.metrics.BroadcastQueued(totalJobs)
+	}
+
+	// the workers outlive the request that started the broadcast, they keep its trace but not
+	// its cancellation. The span ends once every result is in
+	ctx, span := tracer.Start(context.WithoutCancel(ctx), "BroadcastToChannel", trace.WithAttributes(
+		attribute.Int("broadcast.channel_id", channelID),
+		attribute.Int("broadcast.jobs", totalJobs),
+	))
+	ctx = logging.With(ctx, slog.Int("channel_id", channelID), slog.Int("broadcast_id", msg.BroadcastID))
+
 	for w := 1; w <= nWorkers; w++ {
-		go s.worker(w, jobs, results)
+		go s.worker(ctx, w, jobs, results)
 	}
 
 	for _, sub := range subs {
@@ -78,24 +150,38 @@ func (s *BroadcastService) BroadCastToChannel(ctx context.Context, nWorkers, cha
 	}
 	close(jobs)
 
-	go s.aggregateResults(channelID, totalJobs, results)
+	s.aggregateResults(ctx, span, channelID, totalJobs, results)
 }
 
-func (s *BroadcastService) worker(id int, jobs <-chan BroadcastJob, results chan<- BroadcastResult) {
+func (s *BroadcastService) worker(ctx context.Context, id int, jobs <-chan BroadcastJob, results chan<- BroadcastResult) {
 	for job := range jobs {
-		slog.Info("Worker", slog.Int("id", id), "processing job", slog.Int("channel_id", job.Subscription.ChannelID))
+		// the fan, the user_id of the logger is whoever started the broadcast
+		logger := logging.FromContext(ctx).With(slog.Group("recipient",
+			slog.Int("user_id", job.Subscription.UserID),
+			slog.String("type", string(job.Subscription.NotificationType)),
+		))
+		logger.Debug("Processing broadcast job", slog.Int("worker", id))
+		if s.metrics != nil {
+			s.metrics.BroadcastQueued(-1)
+		}
 
 		broadcaster, ok := s.notifiers[job.Subscription.NotificationType]
 		if !ok {
+			err := fmt.Errorf("no notifier found for %s", job.Subscription.NotificationType)
+			logger.Warn("Failed to send notification", slog.String("err", err.Error()))
+			s.reportSend(job, err)
 			results <- BroadcastResult{
 				Success: false,
-				Error:   fmt.Errorf("no notifier found for %s", job.Subscription.NotificationType),
+				Error:   err,
 			}
 			continue
 		}
 
-		err := broadcaster.Send(context.Background(), job.Subscription, job.Message)
+		providerMessageID, err := send(ctx, broadcaster, job.Subscription, job.Message)
+		s.reportSend(job, err)
+		s.recordDelivery(ctx, job, providerMessageID, err)
 		if err != nil {
+			logger.Warn("Failed to send notification", slog.String("err", err.Error()))
 			results <- BroadcastResult{
 				Success: false,
 				Error:   err,
@@ -109,7 +195,30 @@ func (s *BroadcastService) worker(id int, jobs <-chan BroadcastJob, results chan
 	}
 }
 
-func (s *BroadcastService) aggregateResults(channelID int, totalJobs int, results <-chan BroadcastResult) {
+func (s *BroadcastService) reportSend(job BroadcastJob, err error) {
+	if s.metrics != nil {
+		s.metrics.BroadcastSent(string(job.Subscription.NotificationType), err)
+	}
+}
+
+func (s *BroadcastService) recordDelivery(ctx context.Context, job BroadcastJob, providerMessageID string, sendErr error) {
+	if s.recorder == nil {
+		return
+	}
+
+	report := DeliveryReport{
+		BroadcastID:       job.Message.BroadcastID,
+		Subscription:      job.Subscription,
+		ProviderMessageID: providerMessageID,
+		Error:             sendErr,
+	}
+
+	if err := s.recorder.RecordDelivery(ctx, report); err != nil {
+		logging.FromContext(ctx).Warn("Failed to record delivery", slog.Int("user_id", job.Subscription.UserID), slog.String("err", err.Error()))
+	}
+}
+
+func (s *BroadcastService) aggregateResults(ctx context.Context, span trace.Span, channelID int, totalJobs int, results <-chan BroadcastResult) {
 	status := BroadcastStatus{
 		ChannelID:    channelID,
 		TotalToSend:  totalJobs,
@@ -132,7 +241,10 @@ func (s *BroadcastService) aggregateResults(channelID int, totalJobs int, result
 
 	status.IsCompleted = true
 	s.broadcastStatusToAdmins(status)
-	slog.Info(fmt.Sprintf("Broadcast completed for channel %d\n with %d sent, %d failed\n", channelID, status.SentCount, status.FailedCount))
+
+	span.SetAttributes(attribute.Int("broadcast.sent", status.SentCount), attribute.Int("broadcast.failed", status.FailedCount))
+	span.End()
+	logging.FromContext(ctx).Info("Broadcast completed", slog.Int("sent", status.SentCount), slog.Int("failed", status.FailedCount))
 }
 
 func (s *BroadcastService) broadcastStatusToAdmins(status BroadcastStatus) {
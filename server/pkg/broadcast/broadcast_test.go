@@ -2,22 +2,28 @@ package broadcast_test
 
 import (
 	"context"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/tsntt/footballapi/pkg/broadcast"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // MockBroadcaster is a mock implementation of the IBroadcaster interface for testing purposes.
 type MockBroadcaster struct {
-	send func(ctx context.Context, subscription broadcast.Subscription, message broadcast.Message) error
+	send func(ctx context.Context, subscription broadcast.Subscription, message broadcast.Message) (string, error)
 }
 
 // Send calls the underlying SendFunc.
-func (m *MockBroadcaster) Send(ctx context.Context, subscription broadcast.Subscription, message broadcast.Message) error {
+func (m *MockBroadcaster) Send(ctx context.Context, subscription broadcast.Subscription, message broadcast.Message) (string, error) {
 	if m.send != nil {
 		return m.send(ctx, subscription, message)
 	}
-	return nil
+	return "", nil
 }
 
 func TestBroadcastService_RegisterNotifier(t *testing.T) {
@@ -54,12 +60,12 @@ func TestBroadcastService_BroadCastToChannel_NoSubscriptions(t *testing.T) {
 func TestBroadcastService_BroadCastToChannel_SuccessfulBroadcast(t *testing.T) {
 	service := broadcast.NewBroadcastService()
 	mockNotifier := &MockBroadcaster{
-		send: func(ctx context.Context, sub broadcast.Subscription, msg broadcast.Message) error {
+		send: func(ctx context.Context, sub broadcast.Subscription, msg broadcast.Message) (string, error) {
 			// Assert that the correct message is being sent to the correct subscriber.
 			if msg.Content != "Test message" {
 				t.Errorf("Expected message body 'Test message', got '%s'", msg.Content)
 			}
-			return nil
+			return "SM123", nil
 		},
 	}
 
@@ -77,3 +83,132 @@ func TestBroadcastService_BroadCastToChannel_SuccessfulBroadcast(t *testing.T) {
 	// In a real-world scenario, you might need to wait for the broadcast to complete.
 	// For this example, we assume synchronous or fast execution.
 }
+
+// MockRecorder forwards every delivery report to a channel so tests can wait for workers.
+type MockRecorder struct {
+	reports chan broadcast.DeliveryReport
+}
+
+func (m *MockRecorder) RecordDelivery(ctx context.Context, report broadcast.DeliveryReport) error {
+	m.reports <- report
+	return nil
+}
+
+func TestBroadcastService_BroadCastToChannel_RecordsDelivery(t *testing.T) {
+	service := broadcast.NewBroadcastService()
+	service.RegisterNotifier(broadcast.SMS, &MockBroadcaster{
+		send: func(ctx context.Context, sub broadcast.Subscription, msg broadcast.Message) (string, error) {
+			return "SM123", nil
+		},
+	})
+
+	recorder := &MockRecorder{reports: make(chan broadcast.DeliveryReport, 1)}
+	service.SetDeliveryRecorder(recorder)
+
+	service.AddSubscription(broadcast.Subscription{
+		UserID:           7,
+		ChannelID:        1,
+		NotificationType: broadcast.SMS,
+		Address:          "+5511999999999",
+	})
+
+	service.BroadCastToChannel(context.Background(), 1, 1, broadcast.Message{BroadcastID: 42, Content: "Test message"})
+
+	select {
+	case report := <-recorder.reports:
+		if report.BroadcastID != 42 {
+			t.Errorf("expected broadcast id 42, got %d", report.BroadcastID)
+		}
+		if report.ProviderMessageID != "SM123" {
+			t.Errorf("expected provider message id SM123, got %s", report.ProviderMessageID)
+		}
+		if report.Subscription.UserID != 7 {
+			t.Errorf("expected user id 7, got %d", report.Subscription.UserID)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected a delivery report, got none")
+	}
+}
+
+// MockMetrics forwards every send to a channel and keeps the queue depth
+type MockMetrics struct {
+	sent  chan error
+	queue atomic.Int64
+}
+
+func (m *MockMetrics) BroadcastSent(notificationType string, err error) {
+	m.sent <- err
+}
+
+func (m *MockMetrics) BroadcastQueued(delta int) {
+	m.queue.Add(int64(delta))
+}
+
+func (m *MockMetrics) AdminConnections(count int) {}
+
+func TestBroadcastService_BroadCastToChannel_ReportsMetrics(t *testing.T) {
+	service := broadcast.NewBroadcastService()
+	service.RegisterNotifier(broadcast.SMS, &MockBroadcaster{})
+
+	metrics := &MockMetrics{sent: make(chan error, 2)}
+	service.SetMetrics(metrics)
+
+	service.AddSubscription(broadcast.Subscription{ChannelID: 1, NotificationType: broadcast.SMS})
+	service.AddSubscription(broadcast.Subscription{ChannelID: 1, NotificationType: broadcast.Push})
+
+	service.BroadCastToChannel(context.Background(), 1, 1, broadcast.Message{Content: "Test message"})
+
+	failed := 0
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-metrics.sent:
+			if err != nil {
+				failed++
+			}
+		case <-time.After(time.Second):
+			t.Fatal("expected a send to be reported, got none")
+		}
+	}
+
+	if failed != 1 {
+		t.Errorf("expected the push without a notifier to fail, got %d failures", failed)
+	}
+
+	if depth := metrics.queue.Load(); depth != 0 {
+		t.Errorf("expected an empty queue, got %d", depth)
+	}
+}
+
+func TestBroadcastService_BroadCastToChannel_KeepsTrace(t *testing.T) {
+	spans := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)))
+
+	sent := make(chan context.Context, 1)
+	service := broadcast.NewBroadcastService()
+	service.RegisterNotifier(broadcast.SMS, &MockBroadcaster{
+		send: func(ctx context.Context, sub broadcast.Subscription, msg broadcast.Message) (string, error) {
+			sent <- ctx
+			return "SM123", nil
+		},
+	})
+	service.AddSubscription(broadcast.Subscription{ChannelID: 1, NotificationType: broadcast.SMS})
+
+	// the request that starts a broadcast is over before the workers run
+	ctx, cancel := context.WithCancel(context.Background())
+	ctx, request := otel.Tracer("test").Start(ctx, "request")
+	service.BroadCastToChannel(ctx, 1, 1, broadcast.Message{Content: "Test message"})
+	request.End()
+	cancel()
+
+	select {
+	case sendCtx := <-sent:
+		if sendCtx.Err() != nil {
+			t.Errorf("expected the send not to be cancelled with the request, got %v", sendCtx.Err())
+		}
+		if trace.SpanContextFromContext(sendCtx).TraceID() != request.SpanContext().TraceID() {
+			t.Error("expected the send to be part of the request trace")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected a send, got none")
+	}
+}
@@ -26,8 +26,9 @@ type Subscription struct {
 }
 
 type Message struct {
-	Title   string `json:"title"`
-	Content string `json:"content"`
+	BroadcastID int    `json:"broadcast_id"`
+	Title       string `json:"title"`
+	Content     string `json:"content"`
 }
 
 type BroadcastStatus struct {
@@ -39,6 +40,27 @@ type BroadcastStatus struct {
 	ErrorDetails []string `json:"error_details"`
 }
 
+// DeliveryReport is what a worker knows about a single send once the notifier returns
+type DeliveryReport struct {
+	BroadcastID       int
+	Subscription      Subscription
+	ProviderMessageID string
+	Error             error
+}
+
+// Send returns the provider message id so later status callbacks can be matched to the delivery
 type IBroadcaster interface {
-	Send(ctx context.Context, subscription Subscription, message Message) error
+	Send(ctx context.Context, subscription Subscription, message Message) (string, error)
+}
+
+type IDeliveryRecorder interface {
+	RecordDelivery(ctx context.Context, report DeliveryReport) error
+}
+
+// IBroadcastMetrics is told about every send, the jobs waiting for a worker and the admins
+// following broadcasts live
+type IBroadcastMetrics interface {
+	BroadcastSent(notificationType string, err error)
+	BroadcastQueued(delta int)
+	AdminConnections(count int)
 }
@@ -7,16 +7,34 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"strconv"
 	"time"
 
 	"github.com/tsntt/footballapi/internal/dto"
 	"github.com/tsntt/footballapi/internal/model"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = otel.Tracer("github.com/tsntt/footballapi/pkg/external_api_consumer")
+
+// football-data.org reports the requests left in the current minute on every response
+const quotaHeader = "X-Requests-Available-Minute"
+
+// IRequestObserver is told about every request to the API, status is 0 when no response came back
+type IRequestObserver interface {
+	UpstreamRequest(endpoint string, status int, duration time.Duration)
+	UpstreamQuota(remaining int)
+}
+
 type FootballAPIClient struct {
-	baseURL string
-	token   string
-	client  *http.Client
+	baseURL  string
+	token    string
+	client   *http.Client
+	observer IRequestObserver
 }
 
 func NewFootballAPIClient(baseURL, token string) *FootballAPIClient {
@@ -24,15 +42,21 @@ func NewFootballAPIClient(baseURL, token string) *FootballAPIClient {
 		baseURL: baseURL,
 		token:   token,
 		client: &http.Client{
-			Timeout: 30 * time.Second,
+			Timeout:   30 * time.Second,
+			Transport: otelhttp.NewTransport(http.DefaultTransport),
 		},
 	}
 }
 
+// SetMetrics reports request counts, latencies and the remaining quota
+func (c *FootballAPIClient) SetMetrics(observer IRequestObserver) {
+	c.observer = observer
+}
+
 func (c *FootballAPIClient) GetChampionships(ctx context.Context) ([]model.Championship, error) {
 	url := fmt.Sprintf("%s/competitions", c.baseURL)
 
-	resp, err := c.makeRequest(ctx, "GET", url, nil)
+	resp, err := c.makeRequest(ctx, "competitions", "GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -65,7 +89,7 @@ func (c *FootballAPIClient) GetMatches(ctx context.Context, championshipID int,
 		fullURL += "?" + params.Encode()
 	}
 
-	resp, err := c.makeRequest(ctx, "GET", fullURL, nil)
+	resp, err := c.makeRequest(ctx, "matches", "GET", fullURL, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -98,7 +122,7 @@ func (c *FootballAPIClient) GetMatches(ctx context.Context, championshipID int,
 func (c *FootballAPIClient) GetMatch(ctx context.Context, matchID int) (*model.Match, error) {
 	url := fmt.Sprintf("%s/matches/%d", c.baseURL, matchID)
 
-	resp, err := c.makeRequest(ctx, "GET", url, nil)
+	resp, err := c.makeRequest(ctx, "match", "GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -117,7 +141,28 @@ func (c *FootballAPIClient) GetMatch(ctx context.Context, matchID int) (*model.M
 	return &match, nil
 }
 
-func (c *FootballAPIClient) makeRequest(ctx context.Context, method, url string, body io.Reader) (*http.Response, error) {
+// Ping checks that the API answers and accepts the token, it costs one request of the quota
+func (c *FootballAPIClient) Ping(ctx context.Context) error {
+	resp, err := c.makeRequest(ctx, "ping", "GET", c.baseURL+"/competitions", nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	return nil
+}
+
+// endpoint names the request in metrics, the URL carries ids
+func (c *FootballAPIClient) makeRequest(ctx context.Context, endpoint, method, url string, body io.Reader) (_ *http.Response, err error) {
+	ctx, span := tracer.Start(ctx, "FootballAPI "+endpoint, trace.WithAttributes(attribute.String("football_api.endpoint", endpoint)))
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
 	req, err := http.NewRequestWithContext(ctx, method, url, body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
@@ -126,7 +171,9 @@ func (c *FootballAPIClient) makeRequest(ctx context.Context, method, url string,
 	req.Header.Set("X-Auth-Token", c.token)
 	req.Header.Set("Content-Type", "application/json")
 
+	start := time.Now()
 	resp, err := c.client.Do(req)
+	c.observe(endpoint, resp, time.Since(start))
 	if err != nil {
 		return nil, fmt.Errorf("failed to make request: %w", err)
 	}
@@ -139,3 +186,19 @@ func (c *FootballAPIClient) makeRequest(ctx context.Context, method, url string,
 
 	return resp, nil
 }
+
+func (c *FootballAPIClient) observe(endpoint string, resp *http.Response, duration time.Duration) {
+	if c.observer == nil {
+		return
+	}
+
+	status := 0
+	if resp != nil {
+		status = resp.StatusCode
+		if remaining, err := strconv.Atoi(resp.Header.Get(quotaHeader)); err == nil {
+			c.observer.UpstreamQuota(remaining)
+		}
+	}
+
+	c.observer.UpstreamRequest(endpoint, status, duration)
+}
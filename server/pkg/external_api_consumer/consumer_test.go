@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	consumer "github.com/tsntt/footballapi/pkg/external_api_consumer"
 	"github.com/tsntt/footballapi/internal/dto"
@@ -101,3 +102,39 @@ func TestFootballAPIClient_APIError(t *testing.T) {
 		t.Fatal("expected an error, got nil")
 	}
 }
+
+type mockObserver struct {
+	endpoint  string
+	status    int
+	remaining int
+}
+
+func (m *mockObserver) UpstreamRequest(endpoint string, status int, duration time.Duration) {
+	m.endpoint, m.status = endpoint, status
+}
+
+func (m *mockObserver) UpstreamQuota(remaining int) {
+	m.remaining = remaining
+}
+
+func TestFootballAPIClient_Metrics(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Requests-Available-Minute", "9")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	observer := &mockObserver{}
+	client := consumer.NewFootballAPIClient(server.URL, "test-token")
+	client.SetMetrics(observer)
+
+	client.GetMatch(context.Background(), 42)
+
+	if observer.endpoint != "match" || observer.status != http.StatusTooManyRequests {
+		t.Errorf("expected a 429 from match to be observed, got %s %d", observer.endpoint, observer.status)
+	}
+
+	if observer.remaining != 9 {
+		t.Errorf("expected 9 requests left, got %d", observer.remaining)
+	}
+}
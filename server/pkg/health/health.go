@@ -0,0 +1,141 @@
+package health
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tsntt/footballapi/pkg/logging"
+)
+
+const (
+	StatusOK          = "ok"
+	StatusDegraded    = "degraded"
+	StatusUnavailable = "unavailable"
+	StatusDraining    = "draining"
+)
+
+// Check returns nil when the component can serve requests
+type Check func(ctx context.Context) error
+
+type ComponentStatus struct {
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	// Only logged, /readyz is public and errors tell addresses, versions and upstream answers
+	Error string `json:"-"`
+	// the result was reused, the check is expensive or rate limited
+	Cached bool `json:"cached,omitempty"`
+}
+
+type Report struct {
+	Status     string                     `json:"status"`
+	Components map[string]ComponentStatus `json:"components,omitempty"`
+}
+
+// Ready is false when the instance should be taken out of the load balancer
+func (r Report) Ready() bool {
+	return r.Status == StatusOK || r.Status == StatusDegraded
+}
+
+type Component struct {
+	Name  string
+	Check Check
+	// A failing optional component degrades the report but the instance stays ready
+	Optional bool
+	// Reuses the last result, for checks that cost a quota
+	CacheFor time.Duration
+}
+
+type component struct {
+	Component
+
+	mu        sync.Mutex
+	last      ComponentStatus
+	checkedAt time.Time
+}
+
+// Checker runs the readiness checks, a failing required component makes the instance not
+// ready, a failing optional one only degrades it
+type Checker struct {
+	components []*component
+	timeout    time.Duration
+	draining   atomic.Bool
+}
+
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{timeout: timeout}
+}
+
+func (h *Checker) Register(c Component) {
+	h.components = append(h.components, &component{Component: c})
+}
+
+// SetDraining makes every following report not ready, called when the shutdown starts so
+// the load balancer stops sending traffic before the listener closes
+func (h *Checker) SetDraining() {
+	h.draining.Store(true)
+}
+
+// Check runs every component in parallel
+func (h *Checker) Check(ctx context.Context) Report {
+	if h.draining.Load() {
+		return Report{Status: StatusDraining}
+	}
+
+	results := make([]ComponentStatus, len(h.components))
+
+	var wg sync.WaitGroup
+	for i, c := range h.components {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = h.run(ctx, c)
+		}()
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusOK, Components: make(map[string]ComponentStatus, len(h.components))}
+	for i, c := range h.components {
+		report.Components[c.Name] = results[i]
+		if results[i].Status == StatusOK {
+			continue
+		}
+
+		if !c.Optional {
+			report.Status = StatusUnavailable
+		} else if report.Status == StatusOK {
+			report.Status = StatusDegraded
+		}
+	}
+
+	return report
+}
+
+func (h *Checker) run(ctx context.Context, c *component) ComponentStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.CacheFor > 0 && !c.checkedAt.IsZero() && time.Since(c.checkedAt) < c.CacheFor {
+		cached := c.last
+		cached.Cached = true
+		return cached
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := c.Check(ctx)
+
+	status := ComponentStatus{Status: StatusOK, LatencyMS: float64(time.Since(start).Microseconds()) / 1000}
+	if err != nil {
+		status.Status = StatusUnavailable
+		status.Error = err.Error()
+		logging.FromContext(ctx).Warn("Health check failed", slog.String("component", c.Name), slog.String("err", status.Error))
+	}
+
+	c.last, c.checkedAt = status, time.Now()
+	return status
+}
@@ -0,0 +1,95 @@
+package health_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tsntt/footballapi/pkg/health"
+)
+
+func ok(ctx context.Context) error { return nil }
+
+func down(ctx context.Context) error { return errors.New("connection refused") }
+
+func TestChecker_Check(t *testing.T) {
+	tests := []struct {
+		name       string
+		components []health.Component
+		status     string
+		ready      bool
+	}{
+		{"all ok", []health.Component{{Name: "database", Check: ok}, {Name: "sms", Check: ok, Optional: true}}, health.StatusOK, true},
+		{"optional down", []health.Component{{Name: "database", Check: ok}, {Name: "sms", Check: down, Optional: true}}, health.StatusDegraded, true},
+		{"required down", []health.Component{{Name: "database", Check: down}, {Name: "sms", Check: ok, Optional: true}}, health.StatusUnavailable, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := health.NewChecker(time.Second)
+			for _, c := range tt.components {
+				checker.Register(c)
+			}
+
+			report := checker.Check(context.Background())
+			if report.Status != tt.status || report.Ready() != tt.ready {
+				t.Errorf("expected %s (ready %v), got %s", tt.status, tt.ready, report.Status)
+			}
+
+			if len(report.Components) != len(tt.components) {
+				t.Errorf("expected %d components, got %d", len(tt.components), len(report.Components))
+			}
+		})
+	}
+}
+
+func TestChecker_Check_Timeout(t *testing.T) {
+	checker := health.NewChecker(10 * time.Millisecond)
+	checker.Register(health.Component{Name: "database", Check: func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}})
+
+	report := checker.Check(context.Background())
+	if report.Components["database"].Error == "" || report.Ready() {
+		t.Errorf("expected a hanging check to fail, got %+v", report)
+	}
+
+	// the report is public, the error is only logged
+	body, _ := json.Marshal(report)
+	if strings.Contains(string(body), "deadline") {
+		t.Errorf("expected the error to be left out of the report, got %s", body)
+	}
+}
+
+func TestChecker_Check_Cached(t *testing.T) {
+	calls := 0
+	checker := health.NewChecker(time.Second)
+	checker.Register(health.Component{Name: "football_api", Check: func(ctx context.Context) error {
+		calls++
+		return nil
+	}, CacheFor: time.Minute})
+
+	checker.Check(context.Background())
+	report := checker.Check(context.Background())
+
+	if calls != 1 {
+		t.Errorf("expected one call within the cache time, got %d", calls)
+	}
+	if !report.Components["football_api"].Cached {
+		t.Error("expected the second result to be marked as cached")
+	}
+}
+
+func TestChecker_SetDraining(t *testing.T) {
+	checker := health.NewChecker(time.Second)
+	checker.Register(health.Component{Name: "database", Check: ok})
+	checker.SetDraining()
+
+	if report := checker.Check(context.Background()); report.Ready() || report.Status != health.StatusDraining {
+		t.Errorf("expected not ready while draining, got %+v", report)
+	}
+}
@@ -0,0 +1,76 @@
+package logging_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/tsntt/footballapi/pkg/logging"
+)
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"send to joao.silva@example.com failed", "send to j***@example.com failed"},
+		{"failed to send to +5511999998877: invalid", "failed to send to +***77: invalid"},
+		{"match 12345678 at 2025-10-01", "match 12345678 at 2025-10-01"},
+	}
+
+	for _, tt := range tests {
+		if got := logging.Redact(tt.in); got != tt.want {
+			t.Errorf("Redact(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := logging.New(&buf, logging.FormatJSON, "warn")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	logger.Info("hidden")
+	logger.Warn("Failed to notify ana@example.com",
+		slog.String("to", "+5511999998877"),
+		slog.Any("err", errors.New("bounced: ana@example.com")),
+	)
+
+	out := buf.String()
+	if strings.Contains(out, "hidden") {
+		t.Error("expected info to be filtered at warn level")
+	}
+	if strings.Contains(out, "ana@example.com") || strings.Contains(out, "5511999998877") {
+		t.Errorf("expected addresses to be redacted, got %s", out)
+	}
+	if !strings.Contains(out, `"msg":"Failed to notify a***@example.com"`) {
+		t.Errorf("expected a JSON line, got %s", out)
+	}
+
+	if _, err := logging.New(&buf, "xml", "info"); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+	if _, err := logging.New(&buf, logging.FormatText, "loud"); err == nil {
+		t.Error("expected an error for an unknown level")
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	if logging.FromContext(context.Background()) != slog.Default() {
+		t.Error("expected the default logger outside of a request")
+	}
+
+	var buf bytes.Buffer
+	logger, _ := logging.New(&buf, logging.FormatText, "info")
+
+	ctx := logging.With(logging.WithLogger(context.Background(), logger), slog.Int("match_id", 42))
+	logging.FromContext(ctx).Info("Broadcast started")
+
+	if !strings.Contains(buf.String(), "match_id=42") {
+		t.Errorf("expected the fields added to the context, got %s", buf.String())
+	}
+}
@@ -0,0 +1,171 @@
+package metrics
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "footballapi"
+
+// Metrics owns its registry so tests can create as many as they need
+type Metrics struct {
+	registry *prometheus.Registry
+
+	httpDuration *prometheus.HistogramVec
+
+	broadcastJobs    *prometheus.CounterVec
+	broadcastQueue   prometheus.Gauge
+	adminConnections prometheus.Gauge
+
+	upstreamRequests *prometheus.CounterVec
+	upstreamDuration *prometheus.HistogramVec
+	upstreamQuota    prometheus.Gauge
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Duration of HTTP requests by route, method and status.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method", "status"}),
+		broadcastJobs: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "broadcast_jobs_total",
+			Help:      "Broadcast sends by notification type and outcome.",
+		}, []string{"type", "outcome"}),
+		broadcastQueue: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "broadcast_queue_depth",
+			Help:      "Broadcast jobs waiting for a worker.",
+		}),
+		adminConnections: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "admin_websocket_connections",
+			Help:      "Admin WebSocket connections following broadcasts.",
+		}),
+		upstreamRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "upstream_requests_total",
+			Help:      "Requests to the football data API by endpoint and status, 0 when no response came back.",
+		}, []string{"endpoint", "status"}),
+		upstreamDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "upstream_request_duration_seconds",
+			Help:      "Duration of requests to the football data API by endpoint.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"endpoint"}),
+		upstreamQuota: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "upstream_quota_remaining",
+			Help:      "Requests left in the current minute of the football data API, as last reported by it.",
+		}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpDuration,
+		m.broadcastJobs,
+		m.broadcastQueue,
+		m.adminConnections,
+		m.upstreamRequests,
+		m.upstreamDuration,
+		m.upstreamQuota,
+	)
+
+	return m
+}
+
+// RegisterDB exports the pool stats of db (open, in use, idle, waits...)
+func (m *Metrics) RegisterDB(db *sql.DB, name string) {
+	m.registry.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
+
+// Middleware observes every request under its route pattern (/fans/:id, not /fans/42) so
+// the number of series stays bounded, requests that match no route share one label
+func (m *Metrics) Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+			err := next(c)
+
+			status := c.Response().Status
+			if err != nil {
+				var httpErr *echo.HTTPError
+				if errors.As(err, &httpErr) {
+					status = httpErr.Code
+				} else {
+					status = http.StatusInternalServerError
+				}
+			}
+
+			route := c.Path()
+			if route == "" || (status == http.StatusNotFound && strings.HasSuffix(route, "*")) {
+				route = "unmatched"
+			}
+
+			m.httpDuration.WithLabelValues(route, c.Request().Method, strconv.Itoa(status)).
+				Observe(time.Since(start).Seconds())
+
+			return err
+		}
+	}
+}
+
+// Handler serves the registry, when token is set the scraper must send it as a bearer token
+func (m *Metrics) Handler(token string) http.Handler {
+	handler := promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+	if token == "" {
+		return handler
+	}
+
+	expected := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// BroadcastSent implements broadcast.IBroadcastMetrics
+func (m *Metrics) BroadcastSent(notificationType string, err error) {
+	outcome := "sent"
+	if err != nil {
+		outcome = "failed"
+	}
+	m.broadcastJobs.WithLabelValues(notificationType, outcome).Inc()
+}
+
+func (m *Metrics) BroadcastQueued(delta int) {
+	m.broadcastQueue.Add(float64(delta))
+}
+
+func (m *Metrics) AdminConnections(count int) {
+	m.adminConnections.Set(float64(count))
+}
+
+// UpstreamRequest implements consumer.IRequestObserver
+func (m *Metrics) UpstreamRequest(endpoint string, status int, duration time.Duration) {
+	m.upstreamRequests.WithLabelValues(endpoint, strconv.Itoa(status)).Inc()
+	m.upstreamDuration.WithLabelValues(endpoint).Observe(duration.Seconds())
+}
+
+func (m *Metrics) UpstreamQuota(remaining int) {
+	m.upstreamQuota.Set(float64(remaining))
+}
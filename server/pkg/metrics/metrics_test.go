@@ -0,0 +1,108 @@
+package metrics_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/tsntt/footballapi/pkg/metrics"
+)
+
+func scrape(t *testing.T, handler http.Handler, authorization string) (int, string) {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	body, _ := io.ReadAll(rec.Body)
+	return rec.Code, string(body)
+}
+
+func TestMetrics_Middleware(t *testing.T) {
+	m := metrics.New()
+
+	e := echo.New()
+	e.Use(m.Middleware())
+	e.GET("/fans/:id", func(c echo.Context) error {
+		if c.Param("id") == "0" {
+			return echo.NewHTTPError(http.StatusNotFound, "fan not found")
+		}
+		return c.NoContent(http.StatusOK)
+	})
+
+	for _, path := range []string{"/fans/1", "/fans/2", "/fans/0", "/nowhere"} {
+		e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	_, body := scrape(t, m.Handler(""), "")
+
+	expected := []string{
+		`footballapi_http_request_duration_seconds_count{method="GET",route="/fans/:id",status="200"} 2`,
+		`footballapi_http_request_duration_seconds_count{method="GET",route="/fans/:id",status="404"} 1`,
+	}
+	for _, line := range expected {
+		if !strings.Contains(body, line) {
+			t.Errorf("expected %q in the metrics", line)
+		}
+	}
+
+	if strings.Contains(body, "/nowhere") {
+		t.Error("expected unmatched paths not to become a label")
+	}
+}
+
+func TestMetrics_Handler_Token(t *testing.T) {
+	handler := metrics.New().Handler("scrape-token")
+
+	if code, _ := scrape(t, handler, ""); code != http.StatusUnauthorized {
+		t.Errorf("expected 401 without the token, got %d", code)
+	}
+
+	if code, _ := scrape(t, handler, "Bearer wrong"); code != http.StatusUnauthorized {
+		t.Errorf("expected 401 with a wrong token, got %d", code)
+	}
+
+	if code, _ := scrape(t, handler, "Bearer scrape-token"); code != http.StatusOK {
+		t.Errorf("expected 200 with the token, got %d", code)
+	}
+}
+
+func TestMetrics_BroadcastAndUpstream(t *testing.T) {
+	m := metrics.New()
+
+	m.BroadcastQueued(3)
+	m.BroadcastQueued(-1)
+	m.BroadcastSent("sms", nil)
+	m.BroadcastSent("email", errors.New("bounced"))
+	m.AdminConnections(2)
+	m.UpstreamRequest("matches", http.StatusOK, 120*time.Millisecond)
+	m.UpstreamRequest("matches", 0, time.Second)
+	m.UpstreamQuota(7)
+
+	_, body := scrape(t, m.Handler(""), "")
+
+	expected := []string{
+		`footballapi_broadcast_queue_depth 2`,
+		`footballapi_broadcast_jobs_total{outcome="sent",type="sms"} 1`,
+		`footballapi_broadcast_jobs_total{outcome="failed",type="email"} 1`,
+		`footballapi_admin_websocket_connections 2`,
+		`footballapi_upstream_requests_total{endpoint="matches",status="200"} 1`,
+		`footballapi_upstream_requests_total{endpoint="matches",status="0"} 1`,
+		`footballapi_upstream_request_duration_seconds_count{endpoint="matches"} 2`,
+		`footballapi_upstream_quota_remaining 7`,
+	}
+	for _, line := range expected {
+		if !strings.Contains(body, line) {
+			t.Errorf("expected %q in the metrics", line)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package passwordpolicy
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const hashPrefixLength = 5
+
+// HashPrefixDataset looks passwords up in a local copy of the Pwned Passwords range files:
+// one file per 5 character SHA-1 prefix (e.g. "21BD1.txt") with "SUFFIX:COUNT" lines.
+// Only the file of the prefix is read, the dataset never sees the full hash of the password
+type HashPrefixDataset struct {
+	dir string
+}
+
+func NewHashPrefixDataset(dir string) *HashPrefixDataset {
+	return &HashPrefixDataset{dir: dir}
+}
+
+func (d *HashPrefixDataset) IsBreached(password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:hashPrefixLength], hash[hashPrefixLength:]
+
+	file, err := os.Open(filepath.Join(d.dir, prefix+".txt"))
+	if err != nil {
+		// the dataset only has files for prefixes with breached passwords
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to open hash prefix file: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lineSuffix, _, _ := strings.Cut(scanner.Text(), ":")
+		if strings.EqualFold(strings.TrimSpace(lineSuffix), suffix) {
+			return true, nil
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return false, fmt.Errorf("failed to read hash prefix file: %w", err)
+	}
+
+	return false, nil
+}
@@ -0,0 +1,161 @@
+// Package passwordpolicy checks new passwords against the configured rules, a list of common
+// passwords and, optionally, a local dataset of breached password hashes
+package passwordpolicy
+
+import (
+	"bufio"
+	"bytes"
+	_ "embed"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// bcrypt ignores everything after 72 bytes
+const maxLengthBytes = 72
+
+// Rules reported in Violation.Rule
+const (
+	RuleMinLength = "min_length"
+	RuleMaxLength = "max_length"
+	RuleUpper     = "uppercase"
+	RuleLower     = "lowercase"
+	RuleDigit     = "digit"
+	RuleSymbol    = "symbol"
+	RuleCommon    = "common"
+	RuleUsername  = "username"
+	RuleBreached  = "breached"
+)
+
+//go:embed common_passwords.txt
+var commonPasswordsFile []byte
+
+type Options struct {
+	MinLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	// Breached is optional, nil skips the check
+	Breached IBreachedChecker
+}
+
+// Reports whether a password appears in a breach
+type IBreachedChecker interface {
+	IsBreached(password string) (bool, error)
+}
+
+type Violation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Error lists every rule the password failed
+type Error struct {
+	Violations []Violation
+}
+
+func (e *Error) Error() string {
+	messages := make([]string, len(e.Violations))
+	for i, violation := range e.Violations {
+		messages[i] = violation.Message
+	}
+	return "password does not meet the policy: " + strings.Join(messages, "; ")
+}
+
+type Policy struct {
+	options Options
+	common  map[string]struct{}
+}
+
+func New(options Options) *Policy {
+	return &Policy{
+		options: options,
+		common:  loadCommonPasswords(commonPasswordsFile),
+	}
+}
+
+// Validate returns an *Error naming the failed rules, or a plain error when the breached
+// dataset could not be read
+func (p *Policy) Validate(password, username string) error {
+	var violations []Violation
+	fail := func(rule, message string) {
+		violations = append(violations, Violation{Rule: rule, Message: message})
+	}
+
+	if utf8.RuneCountInString(password) < p.options.MinLength {
+		fail(RuleMinLength, fmt.Sprintf("must have at least %d characters", p.options.MinLength))
+	}
+
+	if len(password) > maxLengthBytes {
+		fail(RuleMaxLength, fmt.Sprintf("must have at most %d bytes", maxLengthBytes))
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			hasSymbol = true
+		}
+	}
+
+	if p.options.RequireUpper && !hasUpper {
+		fail(RuleUpper, "must contain an uppercase letter")
+	}
+	if p.options.RequireLower && !hasLower {
+		fail(RuleLower, "must contain a lowercase letter")
+	}
+	if p.options.RequireDigit && !hasDigit {
+		fail(RuleDigit, "must contain a digit")
+	}
+	if p.options.RequireSymbol && !hasSymbol {
+		fail(RuleSymbol, "must contain a symbol")
+	}
+
+	normalized := strings.ToLower(password)
+	if _, ok := p.common[normalized]; ok {
+		fail(RuleCommon, "is too common")
+	}
+
+	if username != "" && strings.Contains(normalized, strings.ToLower(username)) {
+		fail(RuleUsername, "must not contain the user name")
+	}
+
+	if p.options.Breached != nil {
+		breached, err := p.options.Breached.IsBreached(password)
+		if err != nil {
+			return fmt.Errorf("failed to check breached passwords: %w", err)
+		}
+		if breached {
+			fail(RuleBreached, "appeared in a data breach")
+		}
+	}
+
+	if len(violations) > 0 {
+		return &Error{Violations: violations}
+	}
+
+	return nil
+}
+
+func loadCommonPasswords(file []byte) map[string]struct{} {
+	common := make(map[string]struct{})
+
+	scanner := bufio.NewScanner(bytes.NewReader(file))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		common[strings.ToLower(line)] = struct{}{}
+	}
+
+	return common
+}
@@ -0,0 +1,114 @@
+package passwordpolicy_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/tsntt/footballapi/pkg/passwordpolicy"
+)
+
+func violatedRules(t *testing.T, err error) []string {
+	t.Helper()
+
+	if err == nil {
+		return nil
+	}
+
+	var policyErr *passwordpolicy.Error
+	if !errors.As(err, &policyErr) {
+		t.Fatalf("expected a *passwordpolicy.Error, got %v", err)
+	}
+
+	rules := make([]string, len(policyErr.Violations))
+	for i, violation := range policyErr.Violations {
+		rules[i] = violation.Rule
+	}
+	return rules
+}
+
+func TestPolicy_Validate(t *testing.T) {
+	policy := passwordpolicy.New(passwordpolicy.Options{
+		MinLength:     10,
+		RequireUpper:  true,
+		RequireLower:  true,
+		RequireDigit:  true,
+		RequireSymbol: true,
+	})
+
+	tests := []struct {
+		name     string
+		password string
+		username string
+		want     []string
+	}{
+		{"valid", "Gol-de-Placa-1970", "ana", nil},
+		{"too short", "Ab1!", "ana", []string{passwordpolicy.RuleMinLength}},
+		{"too long for bcrypt", "Aa1!" + strings.Repeat("gol", 30), "ana", []string{passwordpolicy.RuleMaxLength}},
+		{"missing classes", "abcdefghijkl", "ana", []string{passwordpolicy.RuleUpper, passwordpolicy.RuleDigit, passwordpolicy.RuleSymbol}},
+		{"contains user name", "Ana-Gol-1970!", "ana", []string{passwordpolicy.RuleUsername}},
+		{"unicode letters count once", "Çãéíõ-Ü-1a", "bob", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := violatedRules(t, policy.Validate(tt.password, tt.username))
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("expected rules %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestPolicy_Validate_Common(t *testing.T) {
+	policy := passwordpolicy.New(passwordpolicy.Options{MinLength: 6})
+
+	for _, password := range []string{"admin123", "Flamengo", "PASSWORD123"} {
+		got := violatedRules(t, policy.Validate(password, ""))
+		if !slices.Contains(got, passwordpolicy.RuleCommon) {
+			t.Errorf("expected %q to be rejected as common, got %v", password, got)
+		}
+	}
+}
+
+func TestHashPrefixDataset_IsBreached(t *testing.T) {
+	dir := t.TempDir()
+	// SHA-1("password") = 5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8
+	writePrefixFile(t, dir, "5BAA6", "003D68EB55068C33ACE09247EE4C639306B:3\n1E4C9B93F3F0682250B6CF8331B7EE68FD8:9545824\n")
+
+	dataset := passwordpolicy.NewHashPrefixDataset(dir)
+
+	breached, err := dataset.IsBreached("password")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !breached {
+		t.Error("expected \"password\" to be breached")
+	}
+
+	// same prefix file, different suffix
+	breached, err = dataset.IsBreached("Gol-de-Placa-1970")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if breached {
+		t.Error("expected a password missing from the dataset not to be breached")
+	}
+
+	policy := passwordpolicy.New(passwordpolicy.Options{MinLength: 6, Breached: dataset})
+	got := violatedRules(t, policy.Validate("password", ""))
+	if !slices.Contains(got, passwordpolicy.RuleBreached) {
+		t.Errorf("expected the breached rule to fail, got %v", got)
+	}
+}
+
+func writePrefixFile(t *testing.T, dir, prefix, content string) {
+	t.Helper()
+
+	if err := os.WriteFile(filepath.Join(dir, prefix+".txt"), []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write prefix file: %v", err)
+	}
+}
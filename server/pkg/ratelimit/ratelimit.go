@@ -0,0 +1,80 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Expired windows of the memory store are dropped at most this often
+const sweepInterval = time.Minute
+
+// Limit is the number of requests allowed in each window
+type Limit struct {
+	Requests int
+	Window   time.Duration
+}
+
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Until the window ends and the count starts over
+	Reset time.Duration
+}
+
+// Store counts requests in fixed windows, a window starts with the first request of a key
+type Store interface {
+	// Take counts one request against key
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+func result(count int, limit Limit, reset time.Duration) Result {
+	return Result{
+		Allowed:   count <= limit.Requests,
+		Limit:     limit.Requests,
+		Remaining: max(limit.Requests-count, 0),
+		Reset:     reset,
+	}
+}
+
+type window struct {
+	count int
+	ends  time.Time
+}
+
+// MemoryStore keeps the counts in the process, every replica limits on its own
+type MemoryStore struct {
+	mu        sync.Mutex
+	windows   map[string]*window
+	lastSweep time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{windows: make(map[string]*window), lastSweep: time.Now()}
+}
+
+func (s *MemoryStore) Take(_ context.Context, key string, limit Limit) (Result, error) {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.lastSweep) > sweepInterval {
+		for k, w := range s.windows {
+			if !now.Before(w.ends) {
+				delete(s.windows, k)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	w, ok := s.windows[key]
+	if !ok || !now.Before(w.ends) {
+		w = &window{ends: now.Add(limit.Window)}
+		s.windows[key] = w
+	}
+	w.count++
+
+	return result(w.count, limit, w.ends.Sub(now)), nil
+}
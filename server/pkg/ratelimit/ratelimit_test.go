@@ -0,0 +1,75 @@
+package ratelimit_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/tsntt/footballapi/pkg/ratelimit"
+)
+
+// testStore runs the same requests against every store, expire ends the current windows
+func testStore(t *testing.T, store ratelimit.Store, window time.Duration, expire func()) {
+	t.Helper()
+
+	ctx := context.Background()
+	limit := ratelimit.Limit{Requests: 2, Window: window}
+
+	for i, wantRemaining := range []int{1, 0} {
+		result, err := store.Take(ctx, "ip:10.0.0.1", limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !result.Allowed || result.Remaining != wantRemaining || result.Limit != 2 {
+			t.Errorf("request %d: expected allowed with %d remaining, got %+v", i+1, wantRemaining, result)
+		}
+		if result.Reset <= 0 || result.Reset > window {
+			t.Errorf("request %d: expected the window to end within %v, got %v", i+1, window, result.Reset)
+		}
+	}
+
+	result, err := store.Take(ctx, "ip:10.0.0.1", limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Allowed || result.Remaining != 0 {
+		t.Errorf("expected the third request to be refused, got %+v", result)
+	}
+
+	// keys are counted apart
+	if result, _ := store.Take(ctx, "ip:10.0.0.2", limit); !result.Allowed {
+		t.Errorf("expected another key to be allowed, got %+v", result)
+	}
+
+	expire()
+
+	if result, _ := store.Take(ctx, "ip:10.0.0.1", limit); !result.Allowed || result.Remaining != 1 {
+		t.Errorf("expected a new window, got %+v", result)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	window := 50 * time.Millisecond
+	testStore(t, ratelimit.NewMemoryStore(), window, func() { time.Sleep(window) })
+}
+
+func TestRedisStore(t *testing.T) {
+	server := miniredis.RunT(t)
+
+	store, err := ratelimit.NewRedisStore("redis://" + server.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	if err := store.Ping(context.Background()); err != nil {
+		t.Fatalf("expected the server to answer, got %v", err)
+	}
+
+	testStore(t, store, time.Minute, func() { server.FastForward(time.Minute) })
+
+	if ttl := server.TTL("ratelimit:ip:10.0.0.1"); ttl <= 0 || ttl > time.Minute {
+		t.Errorf("expected the key to expire with the window, got %v", ttl)
+	}
+}
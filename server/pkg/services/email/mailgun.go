@@ -3,22 +3,39 @@ package email
 import (
 	"bytes"
 	"context"
+	_ "embed"
 	"fmt"
 	"html/template"
+	"net"
+	"net/mail"
+	"strings"
 	"time"
 
 	"github.com/mailgun/mailgun-go/v5"
+	"github.com/mailgun/mailgun-go/v5/mtypes"
 	"github.com/tsntt/footballapi/pkg/broadcast"
 )
 
+//go:embed templates/email/notification.html
+var notificationTemplate string
+
+var notificationTmpl = template.Must(template.New("notification").Parse(notificationTemplate))
+
+// Builds the one-click unsubscribe URL for a recipient
+type IUnsubscribeLinker interface {
+	UnsubscribeURL(userID int, notificationType, address string) (string, error)
+}
+
 type MailgunService struct {
-	mg     mailgun.Mailgun
-	from   string
-	domain string
+	mg           mailgun.Mailgun
+	from         string
+	domain       string
+	unsubscriber IUnsubscribeLinker // optional
 }
 
-func NewMailgunService(domain, apiKey, from string) *MailgunService {
+func NewMailgunService(domain, apiKey, from, webhookSigningKey string) *MailgunService {
 	mg := mailgun.NewMailgun(apiKey)
+	mg.SetWebhookSigningKey(webhookSigningKey)
 
 	return &MailgunService{
 		mg:     mg,
@@ -27,31 +44,87 @@ func NewMailgunService(domain, apiKey, from string) *MailgunService {
 	}
 }
 
-func (m *MailgunService) Send(ctx context.Context, subscription broadcast.Subscription, message broadcast.Message) error {
-	return m.sendEmail(subscription.Address, message.Title, message.Content, map[string]string{})
+// CheckConfig reports a missing or malformed setting, it does not call Mailgun
+func (m *MailgunService) CheckConfig(ctx context.Context) error {
+	if m.mg.APIKey() == "" {
+		return fmt.Errorf("missing mailgun api key")
+	}
+
+	// the sending domain, SERVER_DOMAIN defaults to an address
+	if !strings.Contains(m.domain, ".") || net.ParseIP(m.domain) != nil {
+		return fmt.Errorf("invalid mailgun domain %q", m.domain)
+	}
+
+	if _, err := mail.ParseAddress(m.from); err != nil {
+		return fmt.Errorf("invalid mailgun from address")
+	}
+
+	return nil
+}
+
+// SetUnsubscribeLinker enables unsubscribe links and List-Unsubscribe headers on broadcast emails
+func (m *MailgunService) SetUnsubscribeLinker(unsubscriber IUnsubscribeLinker) {
+	m.unsubscriber = unsubscriber
+}
+
+func (m *MailgunService) Send(ctx context.Context, subscription broadcast.Subscription, message broadcast.Message) (string, error) {
+	metadata := map[string]string{
+		"track_opens":  "true",
+		"track_clicks": "true",
+	}
+
+	// Links of transactional emails carry secrets (reset tokens), they must not go through the click
+	// redirector. Turned off explicitly so click tracking enabled on the domain does not apply
+	if message.BroadcastID == 0 {
+		metadata["track_clicks"] = "false"
+	}
+
+	// Only broadcasts can be opted out of, transactional emails such as verification codes can't
+	if m.unsubscriber != nil && message.BroadcastID != 0 {
+		unsubscribeURL, err := m.unsubscriber.UnsubscribeURL(subscription.UserID, string(subscription.NotificationType), subscription.Address)
+		if err != nil {
+			return "", fmt.Errorf("failed to build unsubscribe url: %w", err)
+		}
+		metadata["unsubscribe_url"] = unsubscribeURL
+	}
+
+	return m.sendEmail(ctx, subscription.Address, message.Title, message.Content, metadata)
 }
 
-func (m *MailgunService) sendEmail(to, subject, message string, metadata map[string]string) error {
-	t, err := template.ParseFiles("internals/libs/email/templates/code.html")
+// VerifyWebhook checks the HMAC-SHA256 of timestamp+token against the webhook signing key
+func (m *MailgunService) VerifyWebhook(timestamp, token, signature string) bool {
+	verified, err := m.mg.VerifyWebhookSignature(mtypes.Signature{
+		TimeStamp: timestamp,
+		Token:     token,
+		Signature: signature,
+	})
 	if err != nil {
-		return fmt.Errorf("failed to parse email template: %w", err)
+		return false
 	}
 
+	return verified
+}
+
+func (m *MailgunService) sendEmail(ctx context.Context, to, subject, message string, metadata map[string]string) (string, error) {
+	unsubscribeURL := metadata["unsubscribe_url"]
+
 	data := struct {
-		Subject string
-		Message string
-		AppURL  string
-		Year    int
+		Subject        string
+		Message        string
+		AppURL         string
+		UnsubscribeURL string
+		Year           int
 	}{
-		Subject: subject,
-		Message: message,
-		AppURL:  m.domain,
-		Year:    time.Now().Year(),
+		Subject:        subject,
+		Message:        message,
+		AppURL:         m.domain,
+		UnsubscribeURL: unsubscribeURL,
+		Year:           time.Now().Year(),
 	}
 
 	buf := new(bytes.Buffer)
-	if err := t.Execute(buf, data); err != nil {
-		return fmt.Errorf("failed to execute email template: %w", err)
+	if err := notificationTmpl.Execute(buf, data); err != nil {
+		return "", fmt.Errorf("failed to execute email template: %w", err)
 	}
 
 	mg := m.mg
@@ -59,6 +132,12 @@ func (m *MailgunService) sendEmail(to, subject, message string, metadata map[str
 	msg := mailgun.NewMessage(m.domain, m.from, subject, message, to)
 	msg.SetHTML(buf.String())
 
+	// RFC 8058 one-click unsubscribe, mail clients POST "List-Unsubscribe=One-Click" to the url
+	if unsubscribeURL != "" {
+		msg.AddHeader("List-Unsubscribe", "<"+unsubscribeURL+">")
+		msg.AddHeader("List-Unsubscribe-Post", "List-Unsubscribe=One-Click")
+	}
+
 	if tag, ok := metadata["tag"]; ok {
 		msg.AddTag(tag)
 	} else {
@@ -74,24 +153,25 @@ func (m *MailgunService) sendEmail(to, subject, message string, metadata map[str
 		msg.AddVariable("team", team)
 	}
 
-	if trackClicks, ok := metadata["track_clicks"]; ok && trackClicks == "true" {
+	switch metadata["track_clicks"] {
+	case "true":
 		msg.SetTracking(true)
+	case "false":
+		msg.SetTrackingClicks(false)
 	}
 
 	if trackOpens, ok := metadata["track_opens"]; ok && trackOpens == "true" {
 		msg.SetTrackingOpens(true)
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	ctx, cancel := context.WithTimeout(ctx, time.Second*30)
 	defer cancel()
 
 	resp, err := mg.Send(ctx, msg)
 	if err != nil {
-		return fmt.Errorf("failed to send email via Mailgun: %w", err)
+		return "", fmt.Errorf("failed to send email via Mailgun: %w", err)
 	}
 
-	// Log da resposta (opcional)
-	_ = resp // Response message from Mailgun
-
-	return nil
+	// Mailgun answers with "<id@domain>" but events reference the id without brackets
+	return strings.Trim(resp.ID, "<>"), nil
 }
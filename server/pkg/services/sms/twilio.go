@@ -3,29 +3,40 @@ package sms
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"strings"
 
 	"github.com/tsntt/footballapi/pkg/broadcast"
+	"github.com/tsntt/footballapi/pkg/logging"
 	"github.com/twilio/twilio-go"
+	twclient "github.com/twilio/twilio-go/client"
 	api "github.com/twilio/twilio-go/rest/api/v2010"
 )
 
 type TwilioService struct {
-	client    *twilio.RestClient
-	fromPhone string
-	webhook   string // optional
+	accountSID     string
+	authToken      string
+	client         *twilio.RestClient
+	validator      twclient.RequestValidator
+	fromPhone      string
+	webhook        string // optional
+	inboundWebhook string // optional
 }
 
-func NewTwilioService(accountSID, authToken, fromPhone, webhook string) *TwilioService {
+func NewTwilioService(accountSID, authToken, fromPhone, webhook, inboundWebhook string) *TwilioService {
 	client := twilio.NewRestClientWithParams(twilio.ClientParams{
 		Username: accountSID,
 		Password: authToken,
 	})
 
 	ts := &TwilioService{
-		client:    client,
-		fromPhone: fromPhone,
-		webhook:   "",
+		accountSID:     accountSID,
+		authToken:      authToken,
+		client:         client,
+		validator:      twclient.NewRequestValidator(authToken),
+		fromPhone:      fromPhone,
+		webhook:        "",
+		inboundWebhook: inboundWebhook,
 	}
 
 	if webhook != "" {
@@ -35,15 +46,52 @@ func NewTwilioService(accountSID, authToken, fromPhone, webhook string) *TwilioS
 	return ts
 }
 
-func (t *TwilioService) Send(ctx context.Context, subscription broadcast.Subscription, message broadcast.Message) error {
+func (t *TwilioService) Send(ctx context.Context, subscription broadcast.Subscription, message broadcast.Message) (string, error) {
 	msg := t.formatMessage(fmt.Sprintf("%s: %s", message.Title, message.Content))
 
-	return t.sendSMS(subscription.Address, msg)
+	return t.sendSMS(ctx, subscription.Address, msg)
 }
 
-func (t *TwilioService) sendSMS(to, message string) error {
+// CheckConfig reports a missing or malformed setting, it does not call Twilio
+func (t *TwilioService) CheckConfig(ctx context.Context) error {
+	if !strings.HasPrefix(t.accountSID, "AC") || len(t.accountSID) != 34 {
+		return fmt.Errorf("invalid twilio account sid")
+	}
+
+	if t.authToken == "" {
+		return fmt.Errorf("missing twilio auth token")
+	}
+
+	if !t.isValidPhoneNumber(t.fromPhone) {
+		return fmt.Errorf("invalid twilio from phone")
+	}
+
+	return nil
+}
+
+// ValidateStatusCallback checks the X-Twilio-Signature of a request Twilio made to our status callback URL.
+// Twilio signs the exact URL it was given, so we validate against the configured one instead of
+// rebuilding it from the incoming request, which may have gone through a proxy.
+func (t *TwilioService) ValidateStatusCallback(params map[string]string, signature string) bool {
+	if t.webhook == "" || signature == "" {
+		return false
+	}
+
+	return t.validator.Validate(t.webhook, params, signature)
+}
+
+// ValidateInbound checks the signature of incoming messages, posted to the number's messaging webhook
+func (t *TwilioService) ValidateInbound(params map[string]string, signature string) bool {
+	if t.inboundWebhook == "" || signature == "" {
+		return false
+	}
+
+	return t.validator.Validate(t.inboundWebhook, params, signature)
+}
+
+func (t *TwilioService) sendSMS(ctx context.Context, to, message string) (string, error) {
 	if !t.isValidPhoneNumber(to) {
-		return fmt.Errorf("invalid phone number format: %s", to)
+		return "", fmt.Errorf("invalid phone number format: %s", to)
 	}
 
 	formattedMessage := t.formatMessage(message)
@@ -60,11 +108,11 @@ func (t *TwilioService) sendSMS(to, message string) error {
 
 	resp, err := t.client.Api.CreateMessage(params)
 	if err != nil {
-		return fmt.Errorf("failed to send SMS via Twilio: %w", err)
+		return "", fmt.Errorf("failed to send SMS via Twilio: %w", err)
 	}
 
 	if resp.Status == nil {
-		return fmt.Errorf("twilio returned empty status")
+		return "", fmt.Errorf("twilio returned empty status")
 	}
 
 	status := *resp.Status
@@ -73,18 +121,19 @@ func (t *TwilioService) sendSMS(to, message string) error {
 		if resp.ErrorMessage != nil {
 			errorMessage = *resp.ErrorMessage
 		}
-		return fmt.Errorf("twilio SMS failed with status '%s': %s", status, errorMessage)
+		return "", fmt.Errorf("twilio SMS failed with status '%s': %s", status, errorMessage)
 	}
 
-	// TODO: add slog
+	sid := ""
 	if resp.Sid != nil {
-		fmt.Printf("SMS sent successfully. SID: %s, Status: %s\n", *resp.Sid, status)
+		sid = *resp.Sid
 	}
+	logging.FromContext(ctx).Info("SMS sent", slog.String("sid", sid), slog.String("status", status))
 
-	return nil
+	return sid, nil
 }
 
-func (t *TwilioService) sendBulkSMS(recipients []string, message string) error {
+func (t *TwilioService) sendBulkSMS(ctx context.Context, recipients []string, message string) error {
 	if len(recipients) == 0 {
 		return fmt.Errorf("no recipients provided")
 	}
@@ -93,7 +142,7 @@ func (t *TwilioService) sendBulkSMS(recipients []string, message string) error {
 	successCount := 0
 
 	for _, recipient := range recipients {
-		if err := t.sendSMS(recipient, message); err != nil {
+		if _, err := t.sendSMS(ctx, recipient, message); err != nil {
 			errors = append(errors, fmt.Errorf("failed to send to %s: %w", recipient, err))
 		} else {
 			successCount++
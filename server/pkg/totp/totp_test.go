@@ -0,0 +1,81 @@
+package totp_test
+
+import (
+	"encoding/base32"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tsntt/footballapi/pkg/totp"
+)
+
+// The SHA1 secret of the RFC 6238 test vectors, base32 encoded
+var rfcSecret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestCode_RFC6238(t *testing.T) {
+	// last 6 digits of the 8 digit codes in RFC 6238 appendix B
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{unix: 59, want: "287082"},
+		{unix: 1111111109, want: "081804"},
+		{unix: 1111111111, want: "050471"},
+		{unix: 1234567890, want: "005924"},
+		{unix: 2000000000, want: "279037"},
+	}
+
+	for _, tt := range tests {
+		code, err := totp.Code(rfcSecret, totp.Step(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if code != tt.want {
+			t.Errorf("at %d expected %s, got %s", tt.unix, tt.want, code)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	now := time.Unix(1_760_000_000, 0)
+	code, _ := totp.Code(secret, totp.Step(now))
+
+	step, ok := totp.Validate(secret, code, now)
+	if !ok || step != totp.Step(now) {
+		t.Fatalf("expected the current code to match step %d, got %d %v", totp.Step(now), step, ok)
+	}
+
+	// typed just before the step changed
+	if _, ok := totp.Validate(secret, code, now.Add(totp.Period)); !ok {
+		t.Error("expected the previous step to be accepted")
+	}
+
+	if _, ok := totp.Validate(secret, code, now.Add(3*totp.Period)); ok {
+		t.Error("expected an old code to be rejected")
+	}
+
+	if _, ok := totp.Validate(secret, "12345", now); ok {
+		t.Error("expected a short code to be rejected")
+	}
+}
+
+func TestProvisioningURI(t *testing.T) {
+	uri, err := url.Parse(totp.ProvisioningURI("Football API", "ana", "JBSWY3DPEHPK3PXP"))
+	if err != nil {
+		t.Fatalf("expected a valid URI, got %v", err)
+	}
+
+	if uri.Scheme != "otpauth" || uri.Host != "totp" || !strings.HasSuffix(uri.Path, "Football API:ana") {
+		t.Errorf("unexpected URI %s", uri)
+	}
+
+	if uri.Query().Get("secret") != "JBSWY3DPEHPK3PXP" || uri.Query().Get("issuer") != "Football API" {
+		t.Errorf("unexpected parameters %s", uri.RawQuery)
+	}
+}
@@ -0,0 +1,27 @@
+package tracing_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tsntt/footballapi/pkg/tracing"
+)
+
+func TestSetup(t *testing.T) {
+	for _, exporter := range []string{tracing.ExporterNone, "", tracing.ExporterStdout} {
+		shutdown, err := tracing.Setup(context.Background(), exporter, "footballapi")
+		if err != nil {
+			t.Fatalf("expected no error for %q, got %v", exporter, err)
+		}
+
+		if err := shutdown(context.Background()); err != nil {
+			t.Errorf("expected no error on shutdown, got %v", err)
+		}
+	}
+}
+
+func TestSetup_UnknownExporter(t *testing.T) {
+	if _, err := tracing.Setup(context.Background(), "zipkin", "footballapi"); err == nil {
+		t.Fatal("expected an error, got nil")
+	}
+}
@@ -2,6 +2,7 @@ package utils
 
 import (
 	"errors"
+	"strconv"
 	"time"
 
 	"github.com/tsntt/footballapi/internal/dto"
@@ -10,9 +11,16 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// JWTService signs with a shared HS256 secret, or with the asymmetric keys of a JWTKeyRing
 type JWTService struct {
 	secret     []byte
+	keyRing    *JWTKeyRing
+	issuer     string
+	audience   string
 	expireTime time.Duration
+	// tokens from before iss, aud and key rotation, see AcceptLegacyTokens
+	legacySecret []byte
+	legacyUntil  time.Time
 }
 
 func NewJWTService(secret string, expireHours int) *JWTService {
@@ -22,25 +30,103 @@ func NewJWTService(secret string, expireHours int) *JWTService {
 	}
 }
 
+// NewKeyRingJWTService signs with the current key of keyRing, other services can verify with the JWKS
+func NewKeyRingJWTService(keyRing *JWTKeyRing, expireHours int) *JWTService {
+	return &JWTService{
+		keyRing:    keyRing,
+		expireTime: time.Duration(expireHours) * time.Hour,
+	}
+}
+
+// SetIssuer sets the iss and aud claims, tokens for another issuer or audience are rejected. Empty values are not checked
+func (j *JWTService) SetIssuer(issuer, audience string) {
+	j.issuer = issuer
+	j.audience = audience
+}
+
+// AcceptLegacyTokens keeps the HS256 tokens signed with secret before iss, aud and key rotation
+// were added valid until they expire, so upgrading does not log everybody out. They carry
+// neither iss nor kid and must expire within one token lifetime from now
+func (j *JWTService) AcceptLegacyTokens(secret string) {
+	j.legacySecret = []byte(secret)
+	j.legacyUntil = time.Now().Add(j.expireTime)
+}
+
+// JWKS returns the public keys that verify our tokens, empty when signing with a shared secret
+func (j *JWTService) JWKS() dto.JWKSResponse {
+	if j.keyRing == nil {
+		return dto.JWKSResponse{Keys: []dto.JWK{}}
+	}
+	return j.keyRing.JWKS()
+}
+
 func (j *JWTService) GenerateToken(user *model.User) (string, error) {
+	return j.generateToken(user, "")
+}
+
+// GenerateCookieToken is for tokens kept in a cookie, the token only authorizes state-changing
+// requests that also send the CSRF token whose hash is csrfHash
+func (j *JWTService) GenerateCookieToken(user *model.User, csrfHash string) (string, error) {
+	return j.generateToken(user, csrfHash)
+}
+
+func (j *JWTService) generateToken(user *model.User, csrfHash string) (string, error) {
+	jti, err := GenerateRandomToken(16)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
 	claims := jwt.MapClaims{
-		"user_id": user.ID,
-		"name":    user.Name,
-		"role":    user.Role,
-		"exp":     time.Now().Add(j.expireTime).Unix(),
+		"sub":         strconv.Itoa(user.ID),
+		"jti":         jti,
+		"user_id":     user.ID,
+		"name":        user.Name,
+		"role":        user.Role,
+		"permissions": user.Permissions,
+		"iat":         now.Unix(),
+		"exp":         now.Add(j.expireTime).Unix(),
+	}
+	if j.issuer != "" {
+		claims["iss"] = j.issuer
+	}
+	if j.audience != "" {
+		claims["aud"] = j.audience
+	}
+	if csrfHash != "" {
+		claims["csrf"] = csrfHash
+	}
+
+	if j.keyRing == nil {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		return token.SignedString(j.secret)
+	}
+
+	key, err := j.keyRing.signingKey()
+	if err != nil {
+		return "", err
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(j.secret)
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = key.kid
+	return token.SignedString(key.private)
 }
 
 func (j *JWTService) ValidateToken(tokenString string) (*dto.JWTClaims, error) {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("invalid signing method")
+	options := []jwt.ParserOption{jwt.WithExpirationRequired()}
+	if j.issuer != "" {
+		options = append(options, jwt.WithIssuer(j.issuer))
+	}
+	if j.audience != "" {
+		options = append(options, jwt.WithAudience(j.audience))
+	}
+
+	token, err := jwt.Parse(tokenString, j.verificationKey, options...)
+	if err != nil && j.legacySecret != nil {
+		if legacy, legacyErr := j.parseLegacyToken(tokenString); legacyErr == nil {
+			token, err = legacy, nil
 		}
-		return j.secret, nil
-	})
+	}
 
 	if err != nil {
 		return nil, err
@@ -70,15 +156,88 @@ func (j *JWTService) ValidateToken(tokenString string) (*dto.JWTClaims, error) {
 		return nil, errors.New("invalid role in token")
 	}
 
+	// Tokens issued before permissions were added do not carry them
+	var permissions []string
+	if raw, ok := claims["permissions"].([]interface{}); ok {
+		for _, p := range raw {
+			permission, ok := p.(string)
+			if !ok {
+				return nil, errors.New("invalid permissions in token")
+			}
+			permissions = append(permissions, permission)
+		}
+	}
+
 	exp, ok := claims["exp"].(float64)
 	if !ok {
 		return nil, errors.New("invalid exp in token")
 	}
 
+	// Missing in tokens issued before iat was added, they read as issued at the epoch
+	issuedAt, _ := claims["iat"].(float64)
+	jti, _ := claims["jti"].(string)
+	csrfHash, _ := claims["csrf"].(string)
+
 	return &dto.JWTClaims{
-		UserID: int(userID),
-		Name:   name,
-		Role:   role,
-		Exp:    int64(exp),
+		UserID:      int(userID),
+		Name:        name,
+		Role:        role,
+		Permissions: permissions,
+		ID:          jti,
+		CSRFHash:    csrfHash,
+		IssuedAt:    time.Unix(int64(issuedAt), 0),
+		Exp:         int64(exp),
 	}, nil
 }
+
+func (j *JWTService) parseLegacyToken(tokenString string) (*jwt.Token, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		return j.legacySecret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := token.Header["kid"]; ok {
+		return nil, errors.New("not a legacy token")
+	}
+	if _, ok := token.Claims.(jwt.MapClaims)["iss"]; ok {
+		return nil, errors.New("not a legacy token")
+	}
+
+	exp, err := token.Claims.GetExpirationTime()
+	if err != nil {
+		return nil, err
+	}
+	if exp.After(j.legacyUntil) {
+		return nil, errors.New("legacy token expires too late")
+	}
+
+	return token, nil
+}
+
+// verificationKey only accepts the algorithm we sign with, a token can not pick how it is checked
+func (j *JWTService) verificationKey(token *jwt.Token) (interface{}, error) {
+	if j.keyRing == nil {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("invalid signing method")
+		}
+		return j.secret, nil
+	}
+
+	kid, ok := token.Header["kid"].(string)
+	if !ok {
+		return nil, errors.New("missing kid in token")
+	}
+
+	key, ok := j.keyRing.verificationKey(kid)
+	if !ok {
+		return nil, errors.New("unknown signing key")
+	}
+
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, errors.New("invalid signing method")
+	}
+
+	return key.public, nil
+}
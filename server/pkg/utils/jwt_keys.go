@@ -0,0 +1,305 @@
+package utils
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/tsntt/footballapi/internal/dto"
+	"github.com/tsntt/footballapi/internal/model"
+)
+
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+)
+
+const (
+	rsaKeyBits = 2048
+	// Covers clock drift between instances and the database
+	keyRetentionLeeway = 5 * time.Minute
+	// An unknown kid reloads the keys at most this often, it may come from a key just rotated by another instance
+	unknownKidReloadInterval = 10 * time.Second
+)
+
+type jwtKey struct {
+	kid     string
+	method  jwt.SigningMethod
+	private crypto.Signer
+	public  crypto.PublicKey
+}
+
+// JWTKeyRing keeps the asymmetric signing keys in the database, so every instance signs with the same key
+// and verifies the tokens signed by the others. The newest key signs, retired keys keep verifying until
+// the tokens they signed expire.
+type JWTKeyRing struct {
+	repo        model.ISigningKeyRepository
+	algorithm   string
+	rotateEvery time.Duration
+	retention   time.Duration
+
+	mu               sync.RWMutex
+	current          *jwtKey
+	currentCreatedAt time.Time
+	keys             map[string]*jwtKey
+	lastReload       time.Time
+}
+
+// NewJWTKeyRing signs with algorithm (RS256 or EdDSA), a new key is created every rotateEvery and the old
+// one verifies for tokenTTL after it is retired
+func NewJWTKeyRing(repo model.ISigningKeyRepository, algorithm string, rotateEvery, tokenTTL time.Duration) (*JWTKeyRing, error) {
+	if algorithm != AlgRS256 && algorithm != AlgEdDSA {
+		return nil, fmt.Errorf("unsupported signing algorithm %q", algorithm)
+	}
+
+	return &JWTKeyRing{
+		repo:        repo,
+		algorithm:   algorithm,
+		rotateEvery: rotateEvery,
+		retention:   tokenTTL + keyRetentionLeeway,
+		keys:        make(map[string]*jwtKey),
+	}, nil
+}
+
+// Load reads the valid keys and rotates when there is no active key for the algorithm or it is too old
+func (k *JWTKeyRing) Load(ctx context.Context) error {
+	if err := k.reload(ctx); err != nil {
+		return err
+	}
+
+	k.mu.RLock()
+	current := k.current
+	k.mu.RUnlock()
+
+	if current == nil {
+		return k.Rotate(ctx)
+	}
+
+	return nil
+}
+
+// Rotate creates a new signing key and retires the current one
+func (k *JWTKeyRing) Rotate(ctx context.Context) error {
+	key, err := generateSigningKey(k.algorithm)
+	if err != nil {
+		return err
+	}
+
+	if err := k.repo.Rotate(ctx, key); err != nil {
+		return err
+	}
+
+	slog.Info("Rotated JWT signing key", slog.String("kid", key.KID), slog.String("alg", key.Algorithm))
+
+	return k.reload(ctx)
+}
+
+// Run reloads the keys every interval, rotating and deleting the expired ones when due, until ctx is done
+func (k *JWTKeyRing) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := k.Load(ctx); err != nil {
+				slog.Error("Failed to load JWT signing keys", slog.String("err", err.Error()))
+				continue
+			}
+
+			k.mu.RLock()
+			due := k.current != nil && time.Since(k.currentCreatedAt) >= k.rotateEvery
+			k.mu.RUnlock()
+
+			if due {
+				if err := k.Rotate(ctx); err != nil {
+					slog.Error("Failed to rotate JWT signing key", slog.String("err", err.Error()))
+				}
+			}
+
+			if _, err := k.repo.DeleteRetiredBefore(ctx, time.Now().Add(-k.retention)); err != nil {
+				slog.Error("Failed to delete expired JWT signing keys", slog.String("err", err.Error()))
+			}
+		}
+	}
+}
+
+// JWKS returns the public keys that verify tokens, the current one first
+func (k *JWTKeyRing) JWKS() dto.JWKSResponse {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	jwks := dto.JWKSResponse{Keys: []dto.JWK{}}
+	if k.current != nil {
+		jwks.Keys = append(jwks.Keys, toJWK(k.current))
+	}
+	for _, key := range k.keys {
+		if key != k.current {
+			jwks.Keys = append(jwks.Keys, toJWK(key))
+		}
+	}
+
+	return jwks
+}
+
+func (k *JWTKeyRing) signingKey() (*jwtKey, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	if k.current == nil {
+		return nil, errors.New("no JWT signing key loaded")
+	}
+
+	return k.current, nil
+}
+
+func (k *JWTKeyRing) verificationKey(kid string) (*jwtKey, bool) {
+	k.mu.RLock()
+	key, ok := k.keys[kid]
+	stale := time.Since(k.lastReload) >= unknownKidReloadInterval
+	k.mu.RUnlock()
+
+	if ok || !stale {
+		return key, ok
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := k.reload(ctx); err != nil {
+		slog.Error("Failed to reload JWT signing keys", slog.String("err", err.Error()))
+		return nil, false
+	}
+
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	key, ok = k.keys[kid]
+	return key, ok
+}
+
+func (k *JWTKeyRing) reload(ctx context.Context) error {
+	stored, err := k.repo.ListValid(ctx, time.Now().Add(-k.retention))
+	if err != nil {
+		return err
+	}
+
+	keys := make(map[string]*jwtKey, len(stored))
+	var current *jwtKey
+	var currentCreatedAt time.Time
+	for _, s := range stored {
+		key, err := parseSigningKey(s)
+		if err != nil {
+			return err
+		}
+		keys[key.kid] = key
+
+		// stored newest first
+		if current == nil && s.RetiredAt == nil && s.Algorithm == k.algorithm {
+			current = key
+			currentCreatedAt = s.CreatedAt
+		}
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.keys = keys
+	k.current = current
+	k.currentCreatedAt = currentCreatedAt
+	k.lastReload = time.Now()
+
+	return nil
+}
+
+func generateSigningKey(algorithm string) (*model.SigningKey, error) {
+	var private crypto.Signer
+	var err error
+	switch algorithm {
+	case AlgRS256:
+		private, err = rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	case AlgEdDSA:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm %q", algorithm)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate signing key: %w", err)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode signing key: %w", err)
+	}
+
+	kid, err := GenerateRandomToken(16)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.SigningKey{
+		KID:        kid,
+		Algorithm:  algorithm,
+		PrivateKey: string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+	}, nil
+}
+
+func parseSigningKey(stored model.SigningKey) (*jwtKey, error) {
+	block, _ := pem.Decode([]byte(stored.PrivateKey))
+	if block == nil {
+		return nil, fmt.Errorf("invalid PEM in signing key %s", stored.KID)
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse signing key %s: %w", stored.KID, err)
+	}
+
+	key := &jwtKey{kid: stored.KID}
+	switch private := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.method, key.private, key.public = jwt.SigningMethodRS256, private, &private.PublicKey
+	case ed25519.PrivateKey:
+		key.method, key.private, key.public = jwt.SigningMethodEdDSA, private, private.Public()
+	default:
+		return nil, fmt.Errorf("unsupported key type in signing key %s", stored.KID)
+	}
+
+	if key.method.Alg() != stored.Algorithm {
+		return nil, fmt.Errorf("signing key %s does not match its algorithm %s", stored.KID, stored.Algorithm)
+	}
+
+	return key, nil
+}
+
+func toJWK(key *jwtKey) dto.JWK {
+	jwk := dto.JWK{Kid: key.kid, Use: "sig", Alg: key.method.Alg()}
+
+	switch public := key.public.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(public)
+	}
+
+	return jwk
+}
@@ -0,0 +1,191 @@
+package utils_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/tsntt/footballapi/internal/model"
+	"github.com/tsntt/footballapi/pkg/utils"
+)
+
+// mockSigningKeyRepository keeps the keys in memory, oldest first
+type mockSigningKeyRepository struct {
+	keys []model.SigningKey
+}
+
+func (m *mockSigningKeyRepository) Rotate(ctx context.Context, key *model.SigningKey) error {
+	now := time.Now()
+	for i := range m.keys {
+		if m.keys[i].RetiredAt == nil {
+			m.keys[i].RetiredAt = &now
+		}
+	}
+
+	key.ID = len(m.keys) + 1
+	key.CreatedAt = now
+	m.keys = append(m.keys, *key)
+	return nil
+}
+
+func (m *mockSigningKeyRepository) ListValid(ctx context.Context, since time.Time) ([]model.SigningKey, error) {
+	var keys []model.SigningKey
+	for i := len(m.keys) - 1; i >= 0; i-- {
+		if m.keys[i].RetiredAt == nil || m.keys[i].RetiredAt.After(since) {
+			keys = append(keys, m.keys[i])
+		}
+	}
+	return keys, nil
+}
+
+func (m *mockSigningKeyRepository) DeleteRetiredBefore(ctx context.Context, before time.Time) (int64, error) {
+	var kept []model.SigningKey
+	for _, key := range m.keys {
+		if key.RetiredAt == nil || !key.RetiredAt.Before(before) {
+			kept = append(kept, key)
+		}
+	}
+	deleted := int64(len(m.keys) - len(kept))
+	m.keys = kept
+	return deleted, nil
+}
+
+func newKeyRingJWTService(t *testing.T, repo model.ISigningKeyRepository, algorithm string) (*utils.JWTService, *utils.JWTKeyRing) {
+	t.Helper()
+
+	keyRing, err := utils.NewJWTKeyRing(repo, algorithm, 24*time.Hour, time.Hour)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if err := keyRing.Load(context.Background()); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	return utils.NewKeyRingJWTService(keyRing, 1), keyRing
+}
+
+func TestJWTKeyRing_SignAndVerify(t *testing.T) {
+	for _, algorithm := range []string{utils.AlgRS256, utils.AlgEdDSA} {
+		t.Run(algorithm, func(t *testing.T) {
+			jms, _ := newKeyRingJWTService(t, &mockSigningKeyRepository{}, algorithm)
+
+			token, err := jms.GenerateToken(&model.User{ID: 7, Name: "ana", Role: "default"})
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			parsed, _, err := jwt.NewParser().ParseUnverified(token, jwt.MapClaims{})
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if parsed.Method.Alg() != algorithm {
+				t.Errorf("expected alg %s, got %s", algorithm, parsed.Method.Alg())
+			}
+
+			jwks := jms.JWKS()
+			if len(jwks.Keys) != 1 || jwks.Keys[0].Kid != parsed.Header["kid"] {
+				t.Fatalf("expected the signing key in the JWKS, got %+v", jwks.Keys)
+			}
+
+			claims, err := jms.ValidateToken(token)
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if claims.UserID != 7 {
+				t.Errorf("expected user ID 7, got %d", claims.UserID)
+			}
+		})
+	}
+}
+
+func TestJWTKeyRing_Rotate(t *testing.T) {
+	repo := &mockSigningKeyRepository{}
+	jms, keyRing := newKeyRingJWTService(t, repo, utils.AlgEdDSA)
+	user := &model.User{ID: 1, Name: "ana", Role: "default"}
+
+	oldToken, _ := jms.GenerateToken(user)
+
+	if err := keyRing.Rotate(context.Background()); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	newToken, _ := jms.GenerateToken(user)
+	if kid(t, oldToken) == kid(t, newToken) {
+		t.Fatal("expected a new key to sign after the rotation")
+	}
+
+	// the retired key verifies until its tokens expire
+	if _, err := jms.ValidateToken(oldToken); err != nil {
+		t.Errorf("expected the old token to verify, got %v", err)
+	}
+	if len(jms.JWKS().Keys) != 2 {
+		t.Errorf("expected 2 keys in the JWKS, got %d", len(jms.JWKS().Keys))
+	}
+
+	// past the token lifetime the retired key is gone
+	expired := time.Now().Add(-2 * time.Hour)
+	repo.keys[0].RetiredAt = &expired
+	if err := keyRing.Load(context.Background()); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if _, err := jms.ValidateToken(oldToken); err == nil {
+		t.Error("expected the old token to be rejected once its key expired")
+	}
+	if _, err := jms.ValidateToken(newToken); err != nil {
+		t.Errorf("expected the new token to verify, got %v", err)
+	}
+}
+
+func TestJWTKeyRing_KeysSharedBetweenInstances(t *testing.T) {
+	repo := &mockSigningKeyRepository{}
+	first, _ := newKeyRingJWTService(t, repo, utils.AlgRS256)
+	second, _ := newKeyRingJWTService(t, repo, utils.AlgRS256)
+
+	if len(repo.keys) != 1 {
+		t.Fatalf("expected the second instance to reuse the stored key, got %d keys", len(repo.keys))
+	}
+
+	token, _ := first.GenerateToken(&model.User{ID: 1, Name: "ana", Role: "default"})
+	if _, err := second.ValidateToken(token); err != nil {
+		t.Errorf("expected the other instance to verify the token, got %v", err)
+	}
+}
+
+func TestJWTKeyRing_RejectsOtherAlgorithms(t *testing.T) {
+	jms, _ := newKeyRingJWTService(t, &mockSigningKeyRepository{}, utils.AlgRS256)
+	user := &model.User{ID: 1, Name: "ana", Role: "default"}
+
+	// an HS256 token signed with a made up secret must not verify
+	hmacToken, _ := utils.NewJWTService("secret", 1).GenerateToken(user)
+	if _, err := jms.ValidateToken(hmacToken); err == nil {
+		t.Error("expected an HS256 token to be rejected")
+	}
+
+	unsigned, _ := jwt.NewWithClaims(jwt.SigningMethodNone, jwt.MapClaims{"user_id": 1}).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if _, err := jms.ValidateToken(unsigned); err == nil {
+		t.Error("expected an unsigned token to be rejected")
+	}
+}
+
+func TestNewJWTKeyRing_UnsupportedAlgorithm(t *testing.T) {
+	_, err := utils.NewJWTKeyRing(&mockSigningKeyRepository{}, utils.AlgHS256, time.Hour, time.Hour)
+	if err == nil || !strings.Contains(err.Error(), "unsupported") {
+		t.Fatalf("expected an unsupported algorithm error, got %v", err)
+	}
+}
+
+func kid(t *testing.T, token string) string {
+	t.Helper()
+
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, jwt.MapClaims{})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	kid, _ := parsed.Header["kid"].(string)
+	return kid
+}
@@ -1,9 +1,11 @@
 package utils_test
 
 import (
+	"slices"
 	"testing"
 	"time"
 
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/tsntt/footballapi/internal/model"
 	"github.com/tsntt/footballapi/pkg/utils"
 )
@@ -16,6 +18,7 @@ func TestJWTService_GenerateAndValidateToken(t *testing.T) {
 		Role: "default",
 	}
 
+	before := time.Now().Truncate(time.Microsecond)
 	token, err := jms.GenerateToken(user)
 	if err != nil {
 		t.Fatalf("expected no error, got %v", err)
@@ -37,6 +40,35 @@ func TestJWTService_GenerateAndValidateToken(t *testing.T) {
 	if claims.Role != user.Role {
 		t.Errorf("expected user role %s, got %s", user.Role, claims.Role)
 	}
+
+	// whole seconds, like every other NumericDate
+	if claims.IssuedAt.Before(before.Truncate(time.Second)) || time.Since(claims.IssuedAt) > time.Second || claims.IssuedAt.Nanosecond() != 0 {
+		t.Errorf("expected the issue time of the token, got %v (generated after %v)", claims.IssuedAt, before)
+	}
+}
+
+func TestJWTService_GenerateAndValidateToken_Permissions(t *testing.T) {
+	jms := utils.NewJWTService("secret", 1)
+	user := &model.User{
+		ID:          1,
+		Name:        "admin",
+		Role:        "admin",
+		Permissions: []string{"broadcast:read", "broadcast:send"},
+	}
+
+	token, err := jms.GenerateToken(user)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	claims, err := jms.ValidateToken(token)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if !slices.Equal(claims.Permissions, user.Permissions) {
+		t.Errorf("expected permissions %v, got %v", user.Permissions, claims.Permissions)
+	}
 }
 
 func TestJWTService_ValidateToken_InvalidToken(t *testing.T) {
@@ -69,3 +101,101 @@ func TestJWTService_ValidateToken_ExpiredToken(t *testing.T) {
 		t.Fatal("expected an error for expired token, got nil")
 	}
 }
+
+func TestJWTService_GenerateToken_StandardClaims(t *testing.T) {
+	jms := utils.NewJWTService("secret", 1)
+	jms.SetIssuer("https://api.example.com", "footballapi")
+
+	first, _ := jms.GenerateToken(&model.User{ID: 42, Name: "testuser", Role: "default"})
+	second, _ := jms.GenerateToken(&model.User{ID: 42, Name: "testuser", Role: "default"})
+
+	claims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(first, claims); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if claims["sub"] != "42" {
+		t.Errorf("expected sub 42, got %v", claims["sub"])
+	}
+	if claims["iss"] != "https://api.example.com" {
+		t.Errorf("expected iss https://api.example.com, got %v", claims["iss"])
+	}
+	if claims["aud"] != "footballapi" {
+		t.Errorf("expected aud footballapi, got %v", claims["aud"])
+	}
+
+	firstClaims, err := jms.ValidateToken(first)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	secondClaims, _ := jms.ValidateToken(second)
+	if firstClaims.ID == "" || firstClaims.ID == secondClaims.ID {
+		t.Errorf("expected a unique jti per token, got %q and %q", firstClaims.ID, secondClaims.ID)
+	}
+}
+
+func TestJWTService_ValidateToken_OtherIssuerOrAudience(t *testing.T) {
+	user := &model.User{ID: 1, Name: "testuser", Role: "default"}
+
+	jms := utils.NewJWTService("secret", 1)
+	jms.SetIssuer("https://api.example.com", "footballapi")
+
+	otherIssuer := utils.NewJWTService("secret", 1)
+	otherIssuer.SetIssuer("https://other.example.com", "footballapi")
+	token, _ := otherIssuer.GenerateToken(user)
+	if _, err := jms.ValidateToken(token); err == nil {
+		t.Error("expected a token from another issuer to be rejected")
+	}
+
+	otherAudience := utils.NewJWTService("secret", 1)
+	otherAudience.SetIssuer("https://api.example.com", "billing")
+	token, _ = otherAudience.GenerateToken(user)
+	if _, err := jms.ValidateToken(token); err == nil {
+		t.Error("expected a token for another audience to be rejected")
+	}
+}
+
+func TestJWTService_ValidateToken_LegacyTokens(t *testing.T) {
+	// shaped like the tokens issued before iss, aud and key rotation
+	sign := func(secret string, exp time.Time) string {
+		token, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"user_id": 1,
+			"name":    "testuser",
+			"role":    "default",
+			"exp":     exp.Unix(),
+		}).SignedString([]byte(secret))
+		return token
+	}
+	legacy := sign("secret", time.Now().Add(time.Hour))
+
+	repo := &mockSigningKeyRepository{}
+	jms, _ := newKeyRingJWTService(t, repo, utils.AlgRS256)
+	jms.SetIssuer("https://api.example.com", "footballapi")
+	if _, err := jms.ValidateToken(legacy); err == nil {
+		t.Fatal("expected a legacy token to be rejected without the secret")
+	}
+
+	jms.AcceptLegacyTokens("secret")
+	claims, err := jms.ValidateToken(legacy)
+	if err != nil {
+		t.Fatalf("expected a legacy token to be accepted until it expires, got %v", err)
+	}
+	if claims.UserID != 1 {
+		t.Errorf("expected user ID 1, got %d", claims.UserID)
+	}
+
+	if _, err := jms.ValidateToken(sign("other", time.Now().Add(time.Hour))); err == nil {
+		t.Error("expected a token signed with another secret to be rejected")
+	}
+	if _, err := jms.ValidateToken(sign("secret", time.Now().Add(48*time.Hour))); err == nil {
+		t.Error("expected a token outliving the legacy window to be rejected")
+	}
+
+	// a current HS256 token for another issuer is not a legacy one
+	other := utils.NewJWTService("secret", 1)
+	other.SetIssuer("https://other.example.com", "footballapi")
+	token, _ := other.GenerateToken(&model.User{ID: 1, Name: "testuser", Role: "default"})
+	if _, err := jms.ValidateToken(token); err == nil {
+		t.Error("expected a token from another issuer to be rejected")
+	}
+}
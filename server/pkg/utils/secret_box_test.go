@@ -0,0 +1,33 @@
+package utils_test
+
+import (
+	"testing"
+
+	"github.com/tsntt/footballapi/pkg/utils"
+)
+
+func TestSecretBox(t *testing.T) {
+	box := utils.NewSecretBox("secret")
+
+	sealed, err := box.Seal("JBSWY3DPEHPK3PXP")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	opened, err := box.Open(sealed)
+	if err != nil || opened != "JBSWY3DPEHPK3PXP" {
+		t.Fatalf("expected the secret back, got %q %v", opened, err)
+	}
+
+	if again, _ := box.Seal("JBSWY3DPEHPK3PXP"); again == sealed {
+		t.Error("expected a new nonce on every seal")
+	}
+
+	if _, err := utils.NewSecretBox("other secret").Open(sealed); err == nil {
+		t.Error("expected another key to fail")
+	}
+
+	if _, err := box.Open(sealed[:len(sealed)-2] + "AA"); err == nil {
+		t.Error("expected a tampered secret to fail")
+	}
+}
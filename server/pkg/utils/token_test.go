@@ -0,0 +1,51 @@
+package utils_test
+
+import (
+	"testing"
+
+	"github.com/tsntt/footballapi/pkg/utils"
+)
+
+func TestGenerateNumericCode(t *testing.T) {
+	code, err := utils.GenerateNumericCode(6)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if len(code) != 6 {
+		t.Errorf("expected 6 digits, got %q", code)
+	}
+
+	for _, char := range code {
+		if char < '0' || char > '9' {
+			t.Errorf("expected only digits, got %q", code)
+		}
+	}
+}
+
+func TestGenerateRandomToken(t *testing.T) {
+	token1, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	token2, _ := utils.GenerateRandomToken(32)
+
+	if len(token1) != 64 {
+		t.Errorf("expected 64 hex chars, got %d", len(token1))
+	}
+
+	if token1 == token2 {
+		t.Error("expected different tokens")
+	}
+}
+
+func TestHashToken(t *testing.T) {
+	if utils.HashToken("123456") != utils.HashToken("123456") {
+		t.Error("hashes for identical tokens should be the same")
+	}
+
+	if utils.HashToken("123456") == utils.HashToken("654321") {
+		t.Error("hashes for different tokens should be different")
+	}
+}
@@ -0,0 +1,88 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/tsntt/footballapi/internal/dto"
+)
+
+// UnsubscribeTokenService signs the links we put in emails so a fan can opt out without logging in
+type UnsubscribeTokenService struct {
+	secret     []byte
+	expireTime time.Duration
+	baseURL    string
+}
+
+func NewUnsubscribeTokenService(secret string, expireHours int, baseURL string) *UnsubscribeTokenService {
+	return &UnsubscribeTokenService{
+		secret:     []byte(secret),
+		expireTime: time.Duration(expireHours) * time.Hour,
+		baseURL:    strings.TrimRight(baseURL, "/"),
+	}
+}
+
+func (s *UnsubscribeTokenService) GenerateToken(userID int, notificationType, address string) (string, error) {
+	payload, err := json.Marshal(dto.UnsubscribeClaims{
+		UserID:           userID,
+		NotificationType: notificationType,
+		Address:          address,
+		Exp:              time.Now().Add(s.expireTime).Unix(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode unsubscribe token: %w", err)
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + s.sign(encoded), nil
+}
+
+func (s *UnsubscribeTokenService) ValidateToken(token string) (*dto.UnsubscribeClaims, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, errors.New("invalid token format")
+	}
+
+	if !hmac.Equal([]byte(signature), []byte(s.sign(encoded))) {
+		return nil, errors.New("invalid token signature")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, errors.New("invalid token payload")
+	}
+
+	var claims dto.UnsubscribeClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, errors.New("invalid token payload")
+	}
+
+	if time.Now().Unix() > claims.Exp {
+		return nil, errors.New("token expired")
+	}
+
+	return &claims, nil
+}
+
+// UnsubscribeURL is the public one-click endpoint with a fresh token for the recipient
+func (s *UnsubscribeTokenService) UnsubscribeURL(userID int, notificationType, address string) (string, error) {
+	token, err := s.GenerateToken(userID, notificationType, address)
+	if err != nil {
+		return "", err
+	}
+
+	return s.baseURL + "/api/v1/unsubscribe?token=" + url.QueryEscape(token), nil
+}
+
+func (s *UnsubscribeTokenService) sign(encoded string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(encoded))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
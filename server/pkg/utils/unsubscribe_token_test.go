@@ -0,0 +1,60 @@
+package utils_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tsntt/footballapi/pkg/utils"
+)
+
+func TestUnsubscribeTokenService_GenerateAndValidateToken(t *testing.T) {
+	uts := utils.NewUnsubscribeTokenService("secret", 1, "https://api.example.com/")
+
+	token, err := uts.GenerateToken(1, "email", "fan@example.com")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	claims, err := uts.ValidateToken(token)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if claims.UserID != 1 || claims.NotificationType != "email" || claims.Address != "fan@example.com" {
+		t.Errorf("unexpected claims: %+v", claims)
+	}
+}
+
+func TestUnsubscribeTokenService_ValidateToken_Tampered(t *testing.T) {
+	uts := utils.NewUnsubscribeTokenService("secret", 1, "https://api.example.com")
+	other := utils.NewUnsubscribeTokenService("other-secret", 1, "https://api.example.com")
+
+	token, _ := other.GenerateToken(1, "email", "fan@example.com")
+
+	if _, err := uts.ValidateToken(token); err == nil {
+		t.Fatal("expected an error for a token signed with another secret, got nil")
+	}
+}
+
+func TestUnsubscribeTokenService_ValidateToken_Expired(t *testing.T) {
+	uts := utils.NewUnsubscribeTokenService("secret", -1, "https://api.example.com")
+
+	token, _ := uts.GenerateToken(1, "email", "fan@example.com")
+
+	if _, err := uts.ValidateToken(token); err == nil {
+		t.Fatal("expected an error for expired token, got nil")
+	}
+}
+
+func TestUnsubscribeTokenService_UnsubscribeURL(t *testing.T) {
+	uts := utils.NewUnsubscribeTokenService("secret", 1, "https://api.example.com/")
+
+	link, err := uts.UnsubscribeURL(1, "email", "fan@example.com")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if !strings.HasPrefix(link, "https://api.example.com/api/v1/unsubscribe?token=") {
+		t.Errorf("unexpected unsubscribe url: %s", link)
+	}
+}